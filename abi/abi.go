@@ -42,7 +42,9 @@ func RegisterDefinitions(definitions map[TLBType]TLBFieldsDesc, depth ...int) er
 			tlb.RegisterWithName(string(dn), reflect.New(dt).Elem().Interface())
 		}
 
+		registeredDefinitionsMx.Lock()
 		registeredDefinitions[dn] = d
+		registeredDefinitionsMx.Unlock()
 	}
 
 	if len(noDef) == 0 {
@@ -67,3 +69,151 @@ func RegisterDefinitions(definitions map[TLBType]TLBFieldsDesc, depth ...int) er
 
 	return RegisterDefinitions(noDef, currentDepth+1, maxDepth)
 }
+
+// inliner replaces references to the given definitions with their fields
+type inliner struct {
+	definitions map[TLBType]TLBFieldsDesc
+}
+
+func (in *inliner) checkUnion(tag string) error {
+	tag = strings.TrimSpace(tag)
+	if !strings.HasPrefix(tag, "[") || !strings.HasSuffix(tag, "]") {
+		return nil
+	}
+	for _, dn := range strings.Split(tag[1:len(tag)-1], ",") {
+		if _, ok := in.definitions[TLBType(dn)]; ok {
+			return fmt.Errorf("'%s' definition is used in union and cannot be inlined", dn)
+		}
+	}
+	return nil
+}
+
+func (in *inliner) definition(format TLBType, tag string, depth int) (TLBFieldsDesc, bool, error) {
+	d, ok := in.definitions[format]
+	if !ok {
+		return nil, false, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(tag), "dict") {
+		return nil, false, fmt.Errorf("'%s' definition is used in dictionary and cannot be inlined", format)
+	}
+	fields, err := in.fields(d, depth+1)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "'%s' definition", format)
+	}
+	return fields, true, nil
+}
+
+func (in *inliner) fields(desc TLBFieldsDesc, depth int) (TLBFieldsDesc, error) {
+	if depth > 16 {
+		return nil, fmt.Errorf("definitions are nested too deep")
+	}
+	if desc == nil {
+		return nil, nil
+	}
+
+	ret := make(TLBFieldsDesc, 0, len(desc))
+	for _, f := range desc {
+		if err := in.checkUnion(f.Type); err != nil {
+			return nil, errors.Wrapf(err, "%s field", f.Name)
+		}
+
+		fields, ok, err := in.definition(f.Format, f.Type, depth)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s field", f.Name)
+		}
+		if ok {
+			f.Format, f.Fields = TLBStructCell, fields
+		} else if f.Fields, err = in.fields(f.Fields, depth); err != nil {
+			return nil, errors.Wrapf(err, "%s field", f.Name)
+		}
+
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+func (in *inliner) values(desc []VmValueDesc) ([]VmValueDesc, error) {
+	if desc == nil {
+		return nil, nil
+	}
+
+	ret := make([]VmValueDesc, 0, len(desc))
+	for _, v := range desc {
+		fields, ok, err := in.definition(v.Format, "", 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s value", v.Name)
+		}
+		if ok {
+			v.Format, v.Fields = TLBStructCell, fields
+		} else if v.Fields, err = in.fields(v.Fields, 0); err != nil {
+			return nil, errors.Wrapf(err, "%s value", v.Name)
+		}
+
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+func (in *inliner) operations(desc []OperationDesc) ([]OperationDesc, error) {
+	if desc == nil {
+		return nil, nil
+	}
+
+	ret := make([]OperationDesc, 0, len(desc))
+	for _, op := range desc {
+		body, err := in.fields(op.Body, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s operation", op.Name)
+		}
+		op.Body = body
+		ret = append(ret, op)
+	}
+	return ret, nil
+}
+
+// InlineDefinitions returns a copy of the interface description, where references
+// to its own definitions are replaced with struct fields. The copy can be parsed
+// without registering the definitions, so it is used to check a description
+// before it is saved. Definitions used in dictionaries or unions cannot be inlined.
+func (d *InterfaceDesc) InlineDefinitions() (*InterfaceDesc, error) {
+	var (
+		in  = inliner{definitions: d.Definitions}
+		ret = *d
+		err error
+	)
+
+	ret.Definitions = nil
+	for dn, def := range d.Definitions {
+		fields, err := in.fields(def, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "'%s' definition", dn)
+		}
+		if _, err := tlbParseDesc(nil, fields); err != nil {
+			return nil, errors.Wrapf(err, "parse '%s' definition", dn)
+		}
+	}
+
+	if ret.InMessages, err = in.operations(d.InMessages); err != nil {
+		return nil, err
+	}
+	if ret.OutMessages, err = in.operations(d.OutMessages); err != nil {
+		return nil, err
+	}
+	if ret.ContractData, err = in.fields(d.ContractData, 0); err != nil {
+		return nil, errors.Wrap(err, "contract data")
+	}
+
+	if d.GetMethods == nil {
+		return &ret, nil
+	}
+	ret.GetMethods = make([]GetMethodDesc, 0, len(d.GetMethods))
+	for _, m := range d.GetMethods {
+		// arguments are not parsed with definitions
+		if m.ReturnValues, err = in.values(m.ReturnValues); err != nil {
+			return nil, errors.Wrapf(err, "%s get-method", m.Name)
+		}
+		ret.GetMethods = append(ret.GetMethods, m)
+	}
+
+	return &ret, nil
+}
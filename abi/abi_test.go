@@ -3,9 +3,13 @@ package abi_test
 import (
 	"encoding/base64"
 	"encoding/json"
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
 
 	"github.com/tonindexer/anton/abi"
 )
@@ -31,3 +35,92 @@ func TestInterfaceDesc_UnmarshalJSON(t *testing.T) {
 	require.Equal(t, 1, len(d.Addresses))
 	require.Equal(t, "EQAOQdwdw8kGftJCSFgOErM1mBjYPe4DBPq8-AhF6vr9si5N", d.Addresses[0].Base64())
 }
+
+func TestInterfaceDesc_InlineDefinitions(t *testing.T) {
+	var d abi.InterfaceDesc
+
+	j := []byte(`
+{
+    "interface_name": "inline_test",
+    "definitions": {
+        "inline_ref": [{"name":"addr","tlb_type":"addr","format":"addr"}],
+        "inline_payload": [
+            {"name":"small_int","tlb_type":"## 32","format":"uint32"},
+            {"name":"big_int","tlb_type":"## 128","format":"bigInt"},
+            {"name":"ref_struct","tlb_type":"^","format":"inline_ref"},
+            {"name":"embed_struct","tlb_type":"^","format":"struct","struct_fields":[{"name":"bits","tlb_type":"bits 32","format":"bytes"}]},
+            {"name":"maybe_cell","tlb_type":"maybe ^","format":"cell"},
+            {"name":"either_cell","tlb_type":"either ^ .","format":"cell"}
+        ]
+    },
+    "in_messages": [
+        {"op_name":"inline_op","op_code":"0x00000001","body":[{"name":"payload","tlb_type":"^","format":"inline_payload"}]}
+    ],
+    "get_methods": [
+        {"name":"get_payload","return_values":[{"name":"payload","stack_type":"cell","format":"inline_payload"}]}
+    ]
+}`)
+	require.Nil(t, json.Unmarshal(j, &d))
+
+	inlined, err := d.InlineDefinitions()
+	require.Nil(t, err)
+	require.Nil(t, inlined.Definitions)
+	require.NotNil(t, d.Definitions)
+	require.Equal(t, abi.TLBType("inline_payload"), d.InMessages[0].Body[0].Format)
+	require.Equal(t, abi.TLBStructCell, inlined.GetMethods[0].ReturnValues[0].Format)
+
+	var op Operation
+	op.Payload.SmallInt = 42
+	op.Payload.BigInt, _ = new(big.Int).SetString("8000000000000000000000000", 10)
+	op.Payload.RefStruct.Addr = address.MustParseAddr("EQDj5AA8mQvM5wJEQsFFFof79y3ZsuX6wowktWQFhz_Anton")
+	op.Payload.EmbedStruct.Bits = []byte("asdf")
+	op.Payload.EitherCell = cell.BeginCell().MustStoreStringSnake("either").EndCell()
+
+	c, err := tlb.ToCell(&op)
+	require.Nil(t, err)
+
+	parsed, err := inlined.InMessages[0].FromCell(c)
+	require.Nil(t, err)
+
+	got, err := json.Marshal(parsed)
+	require.Nil(t, err)
+
+	exp := `{"payload":{"small_int":42,"big_int":8000000000000000000000000,"ref_struct":{"addr":"EQDj5AA8mQvM5wJEQsFFFof79y3ZsuX6wowktWQFhz_Anton"},"embed_struct":{"bits":"YXNkZg=="},"maybe_cell":null,"either_cell":"te6cckEBAQEACAAADGVpdGhlcskJ1lc="}}`
+	require.Equal(t, exp, string(got))
+
+	// definitions are not registered
+	_, err = d.InMessages[0].New()
+	require.NotNil(t, err)
+
+	t.Run("dictionary", func(t *testing.T) {
+		desc := abi.InterfaceDesc{
+			Definitions: map[abi.TLBType]abi.TLBFieldsDesc{"inline_ref": d.Definitions["inline_ref"]},
+			ContractData: abi.TLBFieldsDesc{
+				{Name: "refs", Type: "dict 256 -> ^", Format: "inline_ref"},
+			},
+		}
+		_, err := desc.InlineDefinitions()
+		require.NotNil(t, err)
+	})
+
+	t.Run("union", func(t *testing.T) {
+		desc := abi.InterfaceDesc{
+			Definitions: map[abi.TLBType]abi.TLBFieldsDesc{"inline_ref": d.Definitions["inline_ref"]},
+			ContractData: abi.TLBFieldsDesc{
+				{Name: "asset", Type: "[inline_ref]"},
+			},
+		}
+		_, err := desc.InlineDefinitions()
+		require.NotNil(t, err)
+	})
+
+	t.Run("invalid definition", func(t *testing.T) {
+		desc := abi.InterfaceDesc{
+			Definitions: map[abi.TLBType]abi.TLBFieldsDesc{
+				"inline_invalid": {{Name: "x", Type: "## 32", Format: "unknown_format"}},
+			},
+		}
+		_, err := desc.InlineDefinitions()
+		require.NotNil(t, err)
+	})
+}
@@ -0,0 +1,562 @@
+// Package codegen generates Go types for data parsed by contract interface descriptions:
+// message payloads of operations, values returned by get-methods and definitions.
+// The generated code decodes JSON produced by anton and depends only on tonutils-go.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+
+	"github.com/tonindexer/anton/abi"
+)
+
+const (
+	importJSON    = "encoding/json"
+	importFmt     = "fmt"
+	importBig     = "math/big"
+	importAddress = "github.com/xssnick/tonutils-go/address"
+	importTLB     = "github.com/xssnick/tonutils-go/tlb"
+	importCell    = "github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// formatTypes maps formats of known types to go types and imports
+var formatTypes = map[abi.TLBType][2]string{
+	abi.TLBBool:    {"bool"},
+	"int8":         {"int8"},
+	"int16":        {"int16"},
+	"int32":        {"int32"},
+	"int64":        {"int64"},
+	"uint8":        {"uint8"},
+	"uint16":       {"uint16"},
+	"uint32":       {"uint32"},
+	"uint64":       {"uint64"},
+	abi.TLBBytes:   {"[]byte"},
+	abi.TLBBigInt:  {"*big.Int", importBig},
+	abi.TLBCell:    {"*cell.Cell", importCell},
+	"dict":         {"json.RawMessage", importJSON}, // dictionary is not marshaled by anton
+	abi.TLBTag:     {"struct{}"},
+	"coins":        {"tlb.Coins", importTLB},
+	abi.TLBAddr:    {"*address.Address", importAddress},
+	abi.TLBString:  {"string"},
+	"telemintText": {"*TelemintText"},
+	"dedustAsset":  {"*DedustAsset"},
+}
+
+// helperTypes are declared if the corresponding format is used
+var helperTypes = map[string]string{
+	"*TelemintText": `// TelemintText is a string prefixed with its length.
+type TelemintText struct {
+	Len  uint8
+	Text string
+}
+`,
+	"*DedustAsset": `// DedustAsset is a native coin, a jetton or an extra currency.
+type DedustAsset struct {
+	Type       string ` + "`json:\"type\"`" + `
+	Workchain  *int8  ` + "`json:\"workchain,omitempty\"`" + `
+	Address    []byte ` + "`json:\"address,omitempty\"`" + `
+	CurrencyID int32  ` + "`json:\"currency_id,omitempty\"`" + `
+}
+`,
+}
+
+const decodeStrictFunc = `func decodeStrict(data []byte, x any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(x)
+}
+`
+
+type generator struct {
+	definitions map[abi.TLBType]abi.TLBFieldsDesc
+
+	imports  map[string]struct{}
+	declared map[string]string // type name -> declaration
+	order    []string
+
+	operations map[[2]string]string // contract name and operation name -> type name
+	getMethods map[[2]string]string // contract name and get-method name -> type name
+
+	operationBodies map[string]abi.TLBFieldsDesc
+	strictDecoding  bool // unions are decoded without unknown fields
+}
+
+func (g *generator) use(imports ...string) {
+	for _, i := range imports {
+		if i != "" {
+			g.imports[i] = struct{}{}
+		}
+	}
+}
+
+func (g *generator) declare(name, decl string) {
+	if _, ok := g.declared[name]; ok {
+		return
+	}
+	g.declared[name] = decl
+	g.order = append(g.order, name)
+}
+
+func goName(name string) string {
+	return strcase.ToCamel(name)
+}
+
+func (g *generator) formatType(format abi.TLBType, tag string) (string, error) {
+	if t, ok := formatTypes[format]; ok {
+		g.use(t[1])
+		if decl, ok := helperTypes[t[0]]; ok {
+			g.declare(strings.TrimPrefix(t[0], "*"), decl)
+		}
+		return t[0], nil
+	}
+
+	if _, err := g.definitionType(format); err != nil {
+		return "", err
+	}
+	t := "*" + goName(string(format))
+	if strings.HasPrefix(tag, "dict") {
+		return "map[string]" + t, nil
+	}
+	return t, nil
+}
+
+// tagType determines go type by tlb tag in the same way as abi does it
+func (g *generator) tagType(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+
+	if strings.HasPrefix(tag, "[") && strings.HasSuffix(tag, "]") {
+		return g.unionType(strings.Split(tag[1:len(tag)-1], ","))
+	}
+
+	settings := strings.Split(tag, " ")
+
+	switch settings[0] {
+	case "maybe", "either":
+		g.use(importCell)
+		return "*cell.Cell", nil
+
+	case "##":
+		if len(settings) < 2 {
+			return "", fmt.Errorf("wrong int settings: %v", settings)
+		}
+		num, err := strconv.ParseUint(settings[1], 10, 64)
+		if err != nil {
+			return "", errors.New("corrupted num bits in ## tag")
+		}
+		switch {
+		case num <= 8:
+			return "uint8", nil
+		case num <= 16:
+			return "uint16", nil
+		case num <= 32:
+			return "uint32", nil
+		case num <= 64:
+			return "uint64", nil
+		case num <= 256:
+			g.use(importBig)
+			return "*big.Int", nil
+		default:
+			return "", fmt.Errorf("too much bits for ## tag: %d", num)
+		}
+
+	case "addr":
+		g.use(importAddress)
+		return "*address.Address", nil
+
+	case "bool":
+		return "bool", nil
+
+	case "bits":
+		return "[]byte", nil
+
+	case "^", ".":
+		if len(settings) == 1 {
+			g.use(importCell)
+			return "*cell.Cell", nil
+		}
+		return g.tagType(strings.Join(settings[1:], " "))
+
+	case "dict":
+		if len(settings) > 1 && settings[1] == "inline" {
+			settings = settings[1:]
+		}
+		if len(settings) < 4 {
+			g.use(importJSON)
+			return "json.RawMessage", nil
+		}
+		t, err := g.tagType(strings.Join(settings[3:], " "))
+		if err != nil {
+			return "", err
+		}
+		return "map[string]" + t, nil
+
+	default:
+		return "", fmt.Errorf("cannot deserialize field as tag '%s'", tag)
+	}
+}
+
+func (g *generator) fieldType(parent string, f *abi.TLBFieldDesc) (string, error) {
+	switch {
+	case f.Format == abi.TLBStructCell || (f.Format == "" && len(f.Fields) > 0):
+		name := parent + goName(f.Name)
+		if err := g.structType(name, fmt.Sprintf("// %s is %s field of %s.\n", name, f.Name, parent), f.Fields); err != nil {
+			return "", err
+		}
+		return "*" + name, nil
+
+	case f.Format == "":
+		return g.tagType(f.Type)
+
+	default:
+		return g.formatType(f.Format, f.Type)
+	}
+}
+
+func (g *generator) writeFields(w *bytes.Buffer, parent string, fields abi.TLBFieldsDesc) error {
+	for it := range fields {
+		f := &fields[it]
+
+		t, err := g.fieldType(parent, f)
+		if err != nil {
+			return errors.Wrapf(err, "%s field", f.Name)
+		}
+		_, _ = fmt.Fprintf(w, "\t%s %s `json:%q`\n", goName(f.Name), t, strcase.ToSnake(f.Name))
+	}
+	return nil
+}
+
+func (g *generator) structType(name, comment string, fields abi.TLBFieldsDesc) error {
+	if _, ok := g.declared[name]; ok {
+		return nil
+	}
+	g.declare(name, "") // reserve the name for recursive definitions
+
+	var w bytes.Buffer
+	w.WriteString(comment)
+	_, _ = fmt.Fprintf(&w, "type %s struct {\n", name)
+	if err := g.writeFields(&w, name, fields); err != nil {
+		return errors.Wrap(err, name)
+	}
+	w.WriteString("}\n")
+
+	g.declared[name] = w.String()
+	return nil
+}
+
+func (g *generator) definitionType(dn abi.TLBType) (string, error) {
+	d, ok := g.definitions[dn]
+	if !ok {
+		return "", fmt.Errorf("cannot find definition for '%s' format", dn)
+	}
+	name := goName(string(dn))
+	return name, g.structType(name, fmt.Sprintf("// %s is %s definition.\n", name, dn), d)
+}
+
+func (g *generator) unionType(variants []string) (string, error) {
+	var names []string
+	for _, dn := range variants {
+		n, err := g.definitionType(abi.TLBType(dn))
+		if err != nil {
+			return "", errors.Wrap(err, "union")
+		}
+		names = append(names, n)
+	}
+	name := strings.Join(names, "Or")
+	if _, ok := g.declared[name]; ok {
+		return name, nil
+	}
+
+	g.use(importJSON, importFmt, "bytes")
+	g.strictDecoding = true
+
+	var w bytes.Buffer
+	_, _ = fmt.Fprintf(&w, "// %s is one of [%s] definitions.\n", name, strings.Join(variants, ", "))
+	_, _ = fmt.Fprintf(&w, "type %s struct {\n", name)
+	for _, n := range names {
+		_, _ = fmt.Fprintf(&w, "\t%s *%s\n", n, n)
+	}
+	w.WriteString("}\n\n")
+
+	w.WriteString("// UnmarshalJSON takes the first variant without unknown fields.\n")
+	_, _ = fmt.Fprintf(&w, "func (x *%s) UnmarshalJSON(data []byte) error {\n", name)
+	w.WriteString("\tif string(data) == \"null\" {\n\t\treturn nil\n\t}\n")
+	for _, n := range names {
+		_, _ = fmt.Fprintf(&w, "\tif v := new(%s); decodeStrict(data, v) == nil {\n\t\tx.%s = v\n\t\treturn nil\n\t}\n", n, n)
+	}
+	_, _ = fmt.Fprintf(&w, "\treturn fmt.Errorf(\"no %s variant matches %%s\", data)\n}\n\n", name)
+
+	_, _ = fmt.Fprintf(&w, "func (x %s) MarshalJSON() ([]byte, error) {\n", name)
+	w.WriteString("\tswitch {\n")
+	for _, n := range names {
+		_, _ = fmt.Fprintf(&w, "\tcase x.%s != nil:\n\t\treturn json.Marshal(x.%s)\n", n, n)
+	}
+	w.WriteString("\t}\n\treturn []byte(\"null\"), nil\n}\n")
+
+	g.declare(name, w.String())
+	return name, nil
+}
+
+func (g *generator) operationType(i *abi.InterfaceDesc, op *abi.OperationDesc, outgoing bool) error {
+	key := [2]string{string(i.Name), op.Name}
+	if _, ok := g.operations[key]; ok {
+		return nil
+	}
+
+	dir := "incoming"
+	if outgoing {
+		dir = "outgoing"
+	}
+
+	name := goName(op.Name)
+	if body, ok := g.operationBodies[name]; ok && reflect.DeepEqual(body, op.Body) {
+		g.operations[key] = name
+		return nil
+	}
+	if _, ok := g.declared[name]; ok {
+		// another type with the same name is already declared
+		name = goName(string(i.Name)) + name
+	}
+	g.operationBodies[name] = op.Body
+
+	comment := fmt.Sprintf("// %s is the payload of %s %s message (%s) of %s.\n", name, op.Name, dir, op.Code, i.Name)
+	if err := g.structType(name, comment, op.Body); err != nil {
+		return errors.Wrapf(err, "%s operation", op.Name)
+	}
+
+	g.operations[key] = name
+	return nil
+}
+
+func (g *generator) valueType(parent string, v *abi.VmValueDesc) (string, error) {
+	switch v.StackType {
+	case abi.VmInt:
+		if v.Format == "" {
+			g.use(importBig)
+			return "*big.Int", nil
+		}
+		return g.formatType(v.Format, "")
+
+	case abi.VmCell, abi.VmSlice:
+		switch v.Format {
+		case "":
+			if v.StackType == abi.VmCell {
+				g.use(importCell)
+				return "*cell.Cell", nil
+			}
+			g.use(importJSON)
+			return "json.RawMessage", nil // slice is not marshaled
+		case abi.TLBContentCell:
+			g.use(importJSON)
+			return "json.RawMessage", nil // any of nft content types
+		case abi.TLBStructCell:
+			return g.fieldType(parent, &abi.TLBFieldDesc{Name: v.Name, Format: v.Format, Fields: v.Fields})
+		default:
+			return g.formatType(v.Format, "")
+		}
+
+	default:
+		return "", fmt.Errorf("unsupported '%s' stack type", v.StackType)
+	}
+}
+
+func (g *generator) getMethodType(i *abi.InterfaceDesc, m *abi.GetMethodDesc) error {
+	if len(m.ReturnValues) == 0 {
+		return nil
+	}
+
+	name := goName(string(i.Name)) + goName(m.Name)
+	if _, ok := g.declared[name]; ok {
+		// operation with the same name is already declared
+		name += "Result"
+	}
+
+	var w bytes.Buffer
+	_, _ = fmt.Fprintf(&w, "// %s are values returned by %s get-method of %s.\n", name, m.Name, i.Name)
+	_, _ = fmt.Fprintf(&w, "type %s struct {\n", name)
+	for it := range m.ReturnValues {
+		v := &m.ReturnValues[it]
+		t, err := g.valueType(name, v)
+		if err != nil {
+			return errors.Wrapf(err, "%s get-method %s value", m.Name, v.Name)
+		}
+		_, _ = fmt.Fprintf(&w, "\t%s %s `json:%q`\n", goName(v.Name), t, strcase.ToSnake(v.Name))
+	}
+	w.WriteString("}\n\n")
+
+	g.use(importJSON, importFmt)
+	w.WriteString("// UnmarshalJSON decodes returned values in the order of get-method description.\n")
+	_, _ = fmt.Fprintf(&w, "func (x *%s) UnmarshalJSON(data []byte) error {\n", name)
+	w.WriteString("\tvar returns []json.RawMessage\n")
+	w.WriteString("\tif err := json.Unmarshal(data, &returns); err != nil {\n\t\treturn err\n\t}\n")
+	_, _ = fmt.Fprintf(&w, "\tif len(returns) != %d {\n", len(m.ReturnValues))
+	_, _ = fmt.Fprintf(&w, "\t\treturn fmt.Errorf(\"expected %d values, got %%d\", len(returns))\n\t}\n", len(m.ReturnValues))
+	for it := range m.ReturnValues {
+		v := &m.ReturnValues[it]
+		_, _ = fmt.Fprintf(&w, "\tif err := json.Unmarshal(returns[%d], &x.%s); err != nil {\n", it, goName(v.Name))
+		_, _ = fmt.Fprintf(&w, "\t\treturn fmt.Errorf(\"%s: %%w\", err)\n\t}\n", v.Name)
+	}
+	w.WriteString("\treturn nil\n}\n")
+
+	g.declare(name, w.String())
+	g.getMethods[[2]string{string(i.Name), m.Name}] = name
+	return nil
+}
+
+func writeRegistry(w *bytes.Buffer, varName string, types map[[2]string]string) {
+	var keys [][2]string
+	for k := range types {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	_, _ = fmt.Fprintf(w, "var %s = map[[2]string]func() any{\n", varName)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "\t{%q, %q}: func() any { return new(%s) },\n", k[0], k[1], types[k])
+	}
+	w.WriteString("}\n\n")
+}
+
+const decodeFuncs = `// DecodeOperation decodes data of the parsed message with the given contract and operation names.
+func DecodeOperation(contract, operation string, data []byte) (any, error) {
+	newOp, ok := operations[[2]string{contract, operation}]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s operation of %s", operation, contract)
+	}
+	x := newOp()
+	if err := json.Unmarshal(data, x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// DecodeGetMethod decodes values returned by the executed get-method of the given contract.
+func DecodeGetMethod(contract, getMethod string, returns []byte) (any, error) {
+	newRet, ok := getMethods[[2]string{contract, getMethod}]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s get-method of %s", getMethod, contract)
+	}
+	x := newRet()
+	if err := json.Unmarshal(returns, x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+`
+
+func (g *generator) source(pkg string, interfaces []*abi.InterfaceDesc) []byte {
+	var w bytes.Buffer
+
+	w.WriteString("// Code generated by anton contract codegen. DO NOT EDIT.\n\n")
+	_, _ = fmt.Fprintf(&w, "package %s\n\n", pkg)
+
+	var imports []string
+	for i := range g.imports {
+		imports = append(imports, i)
+	}
+	sort.Strings(imports)
+	w.WriteString("import (\n")
+	for _, i := range imports {
+		if strings.Contains(i, ".") {
+			continue
+		}
+		_, _ = fmt.Fprintf(&w, "\t%q\n", i)
+	}
+	w.WriteString("\n")
+	for _, i := range imports {
+		if strings.Contains(i, ".") {
+			_, _ = fmt.Fprintf(&w, "\t%q\n", i)
+		}
+	}
+	w.WriteString(")\n\n")
+
+	w.WriteString("// Contract interface names.\nconst (\n")
+	for _, i := range interfaces {
+		_, _ = fmt.Fprintf(&w, "\tContract%s = %q\n", goName(string(i.Name)), i.Name)
+	}
+	w.WriteString(")\n\n")
+
+	writeRegistry(&w, "operations", g.operations)
+	writeRegistry(&w, "getMethods", g.getMethods)
+	w.WriteString(decodeFuncs)
+	if g.strictDecoding {
+		w.WriteString("\n")
+		w.WriteString(decodeStrictFunc)
+	}
+
+	for _, name := range g.order {
+		w.WriteString("\n")
+		w.WriteString(g.declared[name])
+	}
+
+	return w.Bytes()
+}
+
+// Generate returns formatted source code of the package with types
+// for operations, get-methods and definitions of the given contract interfaces.
+func Generate(pkg string, interfaces []*abi.InterfaceDesc) ([]byte, error) {
+	g := &generator{
+		definitions: map[abi.TLBType]abi.TLBFieldsDesc{},
+		imports:     map[string]struct{}{importJSON: {}, importFmt: {}},
+		declared:    map[string]string{},
+		operations:  map[[2]string]string{},
+		getMethods:  map[[2]string]string{},
+
+		operationBodies: map[string]abi.TLBFieldsDesc{},
+	}
+
+	for _, i := range interfaces {
+		for dn, d := range i.Definitions {
+			g.definitions[dn] = d
+		}
+	}
+
+	for _, i := range interfaces {
+		for it := range i.InMessages {
+			if err := g.operationType(i, &i.InMessages[it], false); err != nil {
+				return nil, errors.Wrapf(err, "%s interface", i.Name)
+			}
+		}
+		for it := range i.OutMessages {
+			if err := g.operationType(i, &i.OutMessages[it], true); err != nil {
+				return nil, errors.Wrapf(err, "%s interface", i.Name)
+			}
+		}
+		for it := range i.GetMethods {
+			if err := g.getMethodType(i, &i.GetMethods[it]); err != nil {
+				return nil, errors.Wrapf(err, "%s interface", i.Name)
+			}
+		}
+	}
+
+	var definitions []string
+	for dn := range g.definitions {
+		definitions = append(definitions, string(dn))
+	}
+	sort.Strings(definitions)
+	for _, dn := range definitions {
+		if _, err := g.definitionType(abi.TLBType(dn)); err != nil {
+			return nil, errors.Wrapf(err, "%s definition", dn)
+		}
+	}
+
+	src := g.source(pkg, interfaces)
+
+	ret, err := format.Source(src)
+	if err != nil {
+		return nil, errors.Wrap(err, "format generated source")
+	}
+	return ret, nil
+}
@@ -0,0 +1,59 @@
+package codegen_test
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/abi/codegen"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate_Known(t *testing.T) {
+	files, err := filepath.Glob("../known/*.json")
+	require.Nil(t, err)
+	require.NotEmpty(t, files)
+
+	for _, fn := range files {
+		name := strings.TrimSuffix(filepath.Base(fn), ".json")
+
+		t.Run(name, func(t *testing.T) {
+			var interfaces []*abi.InterfaceDesc
+
+			raw, err := os.ReadFile(fn)
+			require.Nil(t, err)
+			require.Nil(t, json.Unmarshal(raw, &interfaces))
+
+			src, err := codegen.Generate(name, interfaces)
+			require.Nil(t, err)
+
+			golden := filepath.Join("testdata", name+".go.golden")
+			if *update {
+				require.Nil(t, os.WriteFile(golden, src, 0o600))
+			}
+
+			expected, err := os.ReadFile(golden)
+			require.Nil(t, err)
+			require.Equal(t, string(expected), string(src))
+		})
+	}
+}
+
+func TestGenerate_UnknownDefinition(t *testing.T) {
+	_, err := codegen.Generate("test", []*abi.InterfaceDesc{{
+		Name: "test",
+		InMessages: []abi.OperationDesc{{
+			Name: "transfer",
+			Code: "0x1",
+			Body: abi.TLBFieldsDesc{{Name: "payload", Type: ".", Format: "unknown_payload"}},
+		}},
+	}})
+	require.ErrorContains(t, err, "cannot find definition for 'unknown_payload' format")
+}
@@ -0,0 +1,157 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+func unmarshalBytesJSON(raw json.RawMessage) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, errors.Wrap(err, "unmarshal string")
+	}
+	s = strings.TrimPrefix(s, "0x")
+	if b, err := hex.DecodeString(s); err == nil {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	if b, err := base64.URLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return nil, fmt.Errorf("cannot decode '%s' as hex or base64", s)
+}
+
+func unmarshalCellJSON(raw json.RawMessage) (*cell.Cell, error) {
+	b, err := unmarshalBytesJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cell.FromBOC(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "cell from boc")
+	}
+	return c, nil
+}
+
+func unmarshalBigIntJSON(raw json.RawMessage) (*big.Int, error) {
+	s := string(bytes.Trim(bytes.TrimSpace(raw), `"`))
+
+	bi, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return nil, fmt.Errorf("cannot parse '%s' integer", s)
+	}
+	return bi, nil
+}
+
+func (d *VmValueDesc) intFromJSON(raw json.RawMessage) (any, error) {
+	switch d.Format {
+	case "", TLBBigInt:
+		return unmarshalBigIntJSON(raw)
+
+	case TLBBytes:
+		return unmarshalBytesJSON(raw)
+
+	case TLBBool, "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		v := reflect.New(typeNameMap[d.Format])
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal %s", d.Format)
+		}
+		return v.Elem().Interface(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported '%s' format for '%s' type", d.Format, d.StackType)
+	}
+}
+
+func (d *VmValueDesc) cellFromJSON(raw json.RawMessage) (any, error) {
+	switch d.Format {
+	case TLBAddr:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, errors.Wrap(err, "unmarshal address string")
+		}
+		a, err := address.ParseAddr(s)
+		if err != nil {
+			a, err = address.ParseRawAddr(s)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse '%s' address", s)
+		}
+		return a, nil
+
+	case TLBString:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, errors.Wrap(err, "unmarshal string")
+		}
+		return s, nil
+
+	case TLBStructCell:
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+			v, err := d.Fields.New()
+			if err != nil {
+				return nil, errors.Wrap(err, "creating struct")
+			}
+			if err := json.Unmarshal(raw, v); err != nil {
+				return nil, errors.Wrap(err, "unmarshal struct")
+			}
+			return v, nil
+		}
+	}
+
+	// other formats are passed as a bag of cells
+	c, err := unmarshalCellJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	switch d.Format {
+	case "", TLBCell:
+		if d.StackType == VmSlice {
+			return c.BeginParse(), nil
+		}
+		return c, nil
+	case TLBType(VmSlice):
+		return c.BeginParse(), nil
+	default:
+		return vmParseCell(c, d)
+	}
+}
+
+// FromJSON converts json representation of a get-method argument
+// into the payload accepted by the emulator.
+// Integers are passed as numbers or strings (decimal or 0x-prefixed hex),
+// bytes and cells are passed as hex or base64 strings,
+// addresses in a user-friendly or raw form.
+func (d *VmValueDesc) FromJSON(raw json.RawMessage) (any, error) {
+	var (
+		ret any
+		err error
+	)
+
+	switch d.StackType {
+	case VmInt:
+		ret, err = d.intFromJSON(raw)
+	case VmCell, VmSlice:
+		ret, err = d.cellFromJSON(raw)
+	default:
+		return nil, fmt.Errorf("unsupported '%s' type", d.StackType)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(ErrWrongValueFormat, "'%s' argument: %s", d.Name, err.Error())
+	}
+
+	return ret, nil
+}
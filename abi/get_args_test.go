@@ -0,0 +1,80 @@
+package abi_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/abi"
+)
+
+func TestVmValueDesc_FromJSON(t *testing.T) {
+	c := cell.BeginCell().MustStoreUInt(0xdeadbeef, 32).EndCell()
+	cBase64 := base64.StdEncoding.EncodeToString(c.ToBOC())
+
+	var testCases = []*struct {
+		desc     abi.VmValueDesc
+		raw      string
+		expected any
+	}{
+		{
+			desc:     abi.VmValueDesc{Name: "index", StackType: "int"},
+			raw:      `100`,
+			expected: big.NewInt(100),
+		}, {
+			desc:     abi.VmValueDesc{Name: "index", StackType: "int", Format: "bigInt"},
+			raw:      `"0x64"`,
+			expected: big.NewInt(100),
+		}, {
+			desc:     abi.VmValueDesc{Name: "count", StackType: "int", Format: "uint32"},
+			raw:      `42`,
+			expected: uint32(42),
+		}, {
+			desc:     abi.VmValueDesc{Name: "is_stable", StackType: "int", Format: "bool"},
+			raw:      `true`,
+			expected: true,
+		}, {
+			desc:     abi.VmValueDesc{Name: "hash", StackType: "int", Format: "bytes"},
+			raw:      `"0xdeadbeef"`,
+			expected: []byte{0xde, 0xad, 0xbe, 0xef},
+		}, {
+			desc:     abi.VmValueDesc{Name: "owner", StackType: "slice", Format: "addr"},
+			raw:      `"EQBMy6CNgBk8PrT5LNjPELxCX_LXBaVSqtbzRToUHlG3t-fg"`,
+			expected: address.MustParseAddr("EQBMy6CNgBk8PrT5LNjPELxCX_LXBaVSqtbzRToUHlG3t-fg"),
+		}, {
+			desc:     abi.VmValueDesc{Name: "domain", StackType: "slice", Format: "string"},
+			raw:      `"anton.ton"`,
+			expected: "anton.ton",
+		}, {
+			desc:     abi.VmValueDesc{Name: "content", StackType: "cell"},
+			raw:      `"` + cBase64 + `"`,
+			expected: c,
+		},
+	}
+
+	for _, test := range testCases {
+		j, err := test.desc.FromJSON(json.RawMessage(test.raw))
+		require.Nil(t, err, test.desc.Name)
+
+		switch exp := test.expected.(type) {
+		case *big.Int:
+			require.Equal(t, 0, exp.Cmp(j.(*big.Int)))
+		case *address.Address:
+			require.Equal(t, exp.String(), j.(*address.Address).String())
+		case *cell.Cell:
+			require.Equal(t, exp.Hash(), j.(*cell.Cell).Hash())
+		default:
+			require.Equal(t, exp, j)
+		}
+	}
+
+	_, err := (&abi.VmValueDesc{Name: "index", StackType: "int"}).FromJSON(json.RawMessage(`"abc"`))
+	require.True(t, errors.Is(err, abi.ErrWrongValueFormat))
+}
@@ -346,7 +346,7 @@ func vmParseCell(c *cell.Cell, desc *VmValueDesc) (any, error) {
 		return parsed, nil
 
 	default:
-		d, ok := registeredDefinitions[desc.Format]
+		d, ok := getDefinition(desc.Format)
 		if !ok {
 			t, ok := typeNameMap[desc.Format]
 			if !ok {
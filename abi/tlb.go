@@ -155,7 +155,7 @@ func tlbParseSettings(tag string) (reflect.Type, error) {
 		for _, dn := range strings.Split(tag[1:len(tag)-1], ",") {
 			// iterate through union definitions
 			// check that all definitions are known
-			_, ok := registeredDefinitions[TLBType(dn)]
+			_, ok := getDefinition(TLBType(dn))
 			if !ok {
 				return nil, fmt.Errorf("cannot find definition for '%s' type inside union", dn)
 			}
@@ -216,7 +216,7 @@ func tlbMapFormat(format TLBType, tag string) (reflect.Type, error) {
 		return t, nil
 
 	default:
-		d, ok := registeredDefinitions[format]
+		d, ok := getDefinition(format)
 		if !ok {
 			return nil, fmt.Errorf("cannot find definition for '%s' format", format)
 		}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 	"reflect"
+	"sync"
 
 	"github.com/pkg/errors"
 
@@ -167,9 +168,18 @@ var (
 		"dedustAsset":  reflect.TypeOf((*DedustAsset)(nil)),
 	}
 
-	registeredDefinitions = map[TLBType]TLBFieldsDesc{}
+	registeredDefinitions   = map[TLBType]TLBFieldsDesc{}
+	registeredDefinitionsMx sync.RWMutex // definitions can be registered at runtime
 )
 
+func getDefinition(dn TLBType) (TLBFieldsDesc, bool) {
+	registeredDefinitionsMx.RLock()
+	defer registeredDefinitionsMx.RUnlock()
+
+	d, ok := registeredDefinitions[dn]
+	return d, ok
+}
+
 func init() {
 	for n, t := range typeNameMap {
 		typeNameRMap[t] = n
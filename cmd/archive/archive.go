@@ -1,22 +1,13 @@
 package archive
 
 import (
-	"fmt"
-	"strconv"
-
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
-	"github.com/xssnick/tonutils-go/liteclient"
-	"github.com/xssnick/tonutils-go/ton"
-)
 
-func intToIP4(ipInt int64) string {
-	b0 := strconv.FormatInt((ipInt>>24)&0xff, 10)
-	b1 := strconv.FormatInt((ipInt>>16)&0xff, 10)
-	b2 := strconv.FormatInt((ipInt>>8)&0xff, 10)
-	b3 := strconv.FormatInt(ipInt&0xff, 10)
-	return b0 + "." + b1 + "." + b2 + "." + b3
-}
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/liteserver"
+)
 
 var Command = &cli.Command{
 	Name:  "archive",
@@ -29,6 +20,14 @@ var Command = &cli.Command{
 			Value:   false,
 			Usage:   "use testnet",
 		},
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "path or url to the global config, overrides the default one",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "print all liteservers with the oldest available block",
+		},
 	},
 
 	Action: func(ctx *cli.Context) error {
@@ -36,35 +35,27 @@ var Command = &cli.Command{
 		if ctx.Bool("testnet") {
 			url = "https://ton-blockchain.github.io/testnet-global.config.json"
 		}
+		if ctx.String("config") != "" {
+			url = ctx.String("config")
+		}
 
-		cfg, err := liteclient.GetConfigFromUrl(ctx.Context, url)
+		m, err := liteserver.NewManager(ctx.Context, &app.LiteserverConfig{GlobalConfig: url})
 		if err != nil {
-			return err
+			return errors.Wrap(err, "cannot connect to liteservers")
 		}
+		defer m.Close()
 
-		for i := range cfg.Liteservers {
-			ls := &cfg.Liteservers[i]
-
-			client := liteclient.NewConnectionPool()
-
-			addr := fmt.Sprintf("%s:%d", intToIP4(ls.IP), ls.Port)
-			if err := client.AddConnection(ctx.Context, addr, ls.ID.Key); err != nil {
-				continue
-			}
-
-			api := ton.NewAPIClient(client, ton.ProofCheckPolicyUnsafe).WithRetry()
-
-			master, err := api.GetMasterchainInfo(ctx.Context)
-			if err != nil {
+		for _, s := range m.Stats() {
+			if s.Archive {
+				log.Info().Str("addr", s.Host).Str("key", s.Key).Msg("new archive liteserver")
 				continue
 			}
-
-			_, err = api.LookupBlock(ctx.Context, master.Workchain, master.Shard, 3)
-			if err != nil {
-				continue
+			if ctx.Bool("all") && !s.Ejected {
+				log.Info().Str("addr", s.Host).Str("key", s.Key).
+					Uint32("oldest_master_seq_no", s.OldestMasterSeqNo).
+					Dur("latency", s.Latency).
+					Msg("liteserver")
 			}
-
-			log.Info().Str("addr", addr).Str("key", ls.ID.Key).Msg("new archive liteserver")
 		}
 
 		return nil
@@ -0,0 +1,68 @@
+package contract
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/abi/codegen"
+)
+
+var codegenCommand = &cli.Command{
+	Name:  "codegen",
+	Usage: "Generates Go package with types of parsed messages, get-method returns and definitions",
+
+	ArgsUsage: "[file1.json] [file2.json]",
+
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "stdin",
+			Usage:   "read from stdin instead of files",
+			Aliases: []string{"i"},
+		},
+		&cli.StringFlag{
+			Name:     "package",
+			Usage:    "name of the generated package",
+			Aliases:  []string{"p"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Usage:   "file to write the generated code, stdout if empty",
+			Aliases: []string{"o"},
+		},
+	},
+
+	Action: func(ctx *cli.Context) (err error) {
+		var interfacesDesc []*abi.InterfaceDesc
+
+		if ctx.Bool("stdin") {
+			interfacesDesc, err = readStdin()
+		} else {
+			filenames := ctx.Args().Slice()
+			if len(filenames) == 0 {
+				cli.ShowSubcommandHelpAndExit(ctx, 1)
+			}
+			interfacesDesc, err = readFiles(filenames)
+		}
+		if err != nil {
+			return err
+		}
+
+		src, err := codegen.Generate(ctx.String("package"), interfacesDesc)
+		if err != nil {
+			return err
+		}
+
+		if ctx.String("output") == "" {
+			_, err = os.Stdout.Write(src)
+			return err
+		}
+		if err := os.WriteFile(ctx.String("output"), src, 0o644); err != nil { //nolint:gosec // generated source code
+			return errors.Wrapf(err, "write %s", ctx.String("output"))
+		}
+		return nil
+	},
+}
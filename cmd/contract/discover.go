@@ -0,0 +1,112 @@
+package contract
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/allisson/go-env"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tonindexer/anton/internal/core/aggregate"
+	"github.com/tonindexer/anton/internal/core/repository"
+)
+
+func printOperations(title string, operations []*aggregate.DiscoveredOperation) {
+	if len(operations) == 0 {
+		return
+	}
+	_, _ = fmt.Printf("  %s:\n", title)
+	for _, op := range operations {
+		_, _ = fmt.Printf("    0x%08x\t%d\t%s\n", op.OperationID, op.Count, base64.StdEncoding.EncodeToString(op.SampleBody))
+	}
+}
+
+func printUnknownContracts(res *aggregate.DiscoverContractsRes) {
+	_, _ = fmt.Printf("transactions and messages since %s\n\n", res.Since.Format(time.RFC3339))
+
+	for _, c := range res.Results {
+		_, _ = fmt.Printf("code hash %x\n", c.CodeHash)
+		_, _ = fmt.Printf("  accounts: %d, transactions: %d\n", c.AccountCount, c.TransactionCount)
+		_, _ = fmt.Printf("  first seen: %s, last seen: %s\n", c.FirstSeen.Format(time.RFC3339), c.LastSeen.Format(time.RFC3339))
+
+		if len(c.GetMethods) > 0 {
+			_, _ = fmt.Println("  get-methods:")
+		}
+		for _, m := range c.GetMethods {
+			name := m.Name
+			if name == "" {
+				name = "?"
+			}
+			_, _ = fmt.Printf("    %d\t%s\n", m.Hash, name)
+		}
+
+		printOperations("incoming operations", c.InOperations)
+		printOperations("outgoing operations", c.OutOperations)
+		_, _ = fmt.Println()
+	}
+}
+
+var discoverCommand = &cli.Command{
+	Name:  "discover",
+	Usage: "Reports the most popular contracts without known interfaces, clustered by code hash",
+
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "period",
+			Usage: "count transactions and messages for this period",
+			Value: 7 * 24 * time.Hour,
+		},
+		&cli.IntFlag{
+			Name:  "min-accounts",
+			Usage: "minimal number of accounts with the same code",
+			Value: 1,
+		},
+		&cli.IntFlag{
+			Name:  "operations",
+			Usage: "number of the most frequent incoming and outgoing operations",
+			Value: 5,
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "number of code hashes",
+			Value: 10,
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the report in json",
+		},
+	},
+
+	Action: func(ctx *cli.Context) error {
+		conn, err := repository.ConnectDB(ctx.Context, env.GetString("DB_CH_URL", ""), env.GetString("DB_PG_URL", ""))
+		if err != nil {
+			return errors.Wrap(err, "cannot connect to a database")
+		}
+		defer conn.Close()
+
+		res, err := aggregate.DiscoverContracts(ctx.Context, conn.CH, conn.PG, &aggregate.DiscoverContractsReq{
+			Since:           time.Now().Add(-ctx.Duration("period")),
+			MinAccounts:     ctx.Int("min-accounts"),
+			OperationsLimit: ctx.Int("operations"),
+			Limit:           ctx.Int("limit"),
+		})
+		if err != nil {
+			return err
+		}
+
+		if ctx.Bool("json") {
+			raw, err := json.MarshalIndent(res, "", "  ")
+			if err != nil {
+				return errors.Wrap(err, "marshal report")
+			}
+			_, _ = fmt.Println(string(raw))
+			return nil
+		}
+
+		printUnknownContracts(res)
+		return nil
+	},
+}
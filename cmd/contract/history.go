@@ -0,0 +1,178 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/allisson/go-env"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app/registry"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/repository/account"
+	"github.com/tonindexer/anton/internal/core/repository/contract"
+	"github.com/tonindexer/anton/internal/core/repository/msg"
+	"github.com/tonindexer/anton/internal/core/repository/rescan"
+)
+
+func printRevisions(revisions []*core.ContractRevision) {
+	for _, rev := range revisions {
+		_, _ = fmt.Printf("version %d\t%s\t%s\t%s\n", rev.Version, rev.Action, rev.CreatedAt.Format("2006-01-02 15:04:05"), rev.Author)
+		if rev.Comment != "" {
+			_, _ = fmt.Printf("  %s\n", rev.Comment)
+		}
+		for _, line := range rev.Diff {
+			_, _ = fmt.Printf("  %s\n", line)
+		}
+		_, _ = fmt.Println()
+	}
+}
+
+func printRevision(rev *core.ContractRevision) error {
+	raw, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshal revision")
+	}
+	_, _ = fmt.Println(string(raw))
+	return nil
+}
+
+func rollbackInterface(ctx *cli.Context) error {
+	contractName := abi.ContractName(ctx.String("contract-name"))
+	if contractName == "" {
+		return errors.Wrap(core.ErrInvalidArg, "contract interface name is not set")
+	}
+	version := uint32(ctx.Uint("version"))
+
+	if !ctx.Bool("dry-run") {
+		pg, err := dbConnect()
+		if err != nil {
+			return err
+		}
+		defer pg.Close()
+
+		contractRepo := contract.NewRepository(pg)
+
+		plan, err := registry.NewRollbackPlan(ctx.Context, contractRepo, contractName, version)
+		if err != nil {
+			return err
+		}
+
+		rev := newRevision(ctx, core.RevisionRollback)
+		if rev.Comment == "" {
+			rev.Comment = fmt.Sprintf("rollback to version %d", version)
+		}
+		_, err = plan.Apply(ctx.Context, pg, contractRepo, rescan.NewRepository(pg), rev)
+		return err
+	}
+
+	conn, err := repository.ConnectDB(ctx.Context, env.GetString("DB_CH_URL", ""), env.GetString("DB_PG_URL", ""))
+	if err != nil {
+		return errors.Wrap(err, "cannot connect to a database")
+	}
+	defer conn.Close()
+
+	contractRepo := contract.NewRepository(conn.PG)
+
+	plan, err := registry.NewRollbackPlan(ctx.Context, contractRepo, contractName, version)
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlanParser(ctx.String("config"), contractRepo)
+	if err != nil {
+		return err
+	}
+
+	return plan.Report(ctx.Context, os.Stdout,
+		account.NewRepository(conn.CH, conn.PG),
+		msg.NewRepository(conn.CH, conn.PG),
+		p, ctx.Int("sample"))
+}
+
+var historyCommand = &cli.Command{
+	Name:  "history",
+	Usage: "Prints revisions of contract interface description",
+
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "contract-name",
+			Usage:    "contract interface name",
+			Aliases:  []string{"c"},
+			Required: true,
+		},
+		&cli.UintFlag{
+			Name:  "version",
+			Usage: "print the full description stored in the given revision",
+		},
+	},
+
+	Action: func(ctx *cli.Context) error {
+		contractName := abi.ContractName(ctx.String("contract-name"))
+
+		pg, err := dbConnect()
+		if err != nil {
+			return err
+		}
+		defer pg.Close()
+
+		contractRepo := contract.NewRepository(pg)
+
+		if ctx.IsSet("version") {
+			rev, err := contractRepo.GetRevision(ctx.Context, contractName, uint32(ctx.Uint("version")))
+			if err != nil {
+				return errors.Wrapf(err, "get version %d of '%s' interface", ctx.Uint("version"), contractName)
+			}
+			return printRevision(rev)
+		}
+
+		revisions, err := contractRepo.GetRevisions(ctx.Context, contractName)
+		if err != nil {
+			return errors.Wrapf(err, "get '%s' interface revisions", contractName)
+		}
+		if len(revisions) == 0 {
+			return errors.Wrapf(core.ErrNotFound, "no revisions of '%s' interface", contractName)
+		}
+
+		printRevisions(revisions)
+		return nil
+	},
+}
+
+var rollbackCommand = &cli.Command{
+	Name:  "rollback",
+	Usage: "Restores contract interface description from the given revision and adds rescan tasks for the difference",
+
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "contract-name",
+			Usage:    "contract interface for rollback",
+			Aliases:  []string{"c"},
+			Required: true,
+		},
+		&cli.UintFlag{
+			Name:     "version",
+			Usage:    "revision to restore",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "print the rollback plan without changing the database",
+		},
+		&cli.IntFlag{
+			Name:  "sample",
+			Usage: "number of affected account states or messages to parse with the restored description in dry-run mode",
+			Value: 5,
+		},
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "file with blockchain config boc, required to execute changed get-methods in dry-run mode",
+		},
+	}, revisionFlags...),
+
+	Action: rollbackInterface,
+}
@@ -1,29 +1,28 @@
 package contract
 
 import (
-	"context"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"io"
-	"math/big"
 	"os"
-	"reflect"
-	"strconv"
-	"strings"
 
 	"github.com/allisson/go-env"
 	"github.com/pkg/errors"
-	"github.com/rs/zerolog/log"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"github.com/urfave/cli/v2"
+	"github.com/xssnick/tonutils-go/tvm/cell"
 
 	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/parser"
+	"github.com/tonindexer/anton/internal/app/registry"
 	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/repository/account"
 	"github.com/tonindexer/anton/internal/core/repository/contract"
+	"github.com/tonindexer/anton/internal/core/repository/msg"
 	"github.com/tonindexer/anton/internal/core/repository/rescan"
 )
 
@@ -76,249 +75,150 @@ func readFiles(filenames []string) (ret []*abi.InterfaceDesc, err error) {
 	return
 }
 
-func ParseOperationDesc(t abi.ContractName, d *abi.OperationDesc) (*core.ContractOperation, error) {
-	var opId uint32
+var revisionFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "author",
+		Usage:   "author of the contract interface revision",
+		EnvVars: []string{"USER"},
+	},
+	&cli.StringFlag{
+		Name:    "comment",
+		Usage:   "comment to the contract interface revision",
+		Aliases: []string{"m"},
+	},
+}
 
-	if c := d.Code; strings.HasPrefix(c, "0x") {
-		n := new(big.Int)
-		_, ok := n.SetString(c[2:], 16)
-		if !ok {
-			return nil, fmt.Errorf("wrong hex %s operation id format: %s", d.Name, d.Code)
-		}
-		opId = uint32(n.Uint64())
-	} else {
-		n, err := strconv.ParseUint(c, 10, 32)
-		if err != nil {
-			return nil, errors.Wrapf(err, "parse %s operation id", d.Name)
-		}
-		opId = uint32(n)
+func newRevision(ctx *cli.Context, action core.ContractRevisionAction) *core.ContractRevision {
+	return &core.ContractRevision{
+		Action:  action,
+		Author:  ctx.String("author"),
+		Comment: ctx.String("comment"),
 	}
+}
 
-	// this is needed to map interface definitions into schema
-	x, err := d.New()
-	if err != nil {
-		return nil, errors.Wrapf(err, "creating new operation structure")
+var planFlags = []cli.Flag{
+	&cli.BoolFlag{
+		Name:    "stdin",
+		Usage:   "read from stdin instead of files",
+		Aliases: []string{"i"},
+	},
+	&cli.StringFlag{
+		Name:     "contract-name",
+		Usage:    "contract interface for update",
+		Aliases:  []string{"c"},
+		Required: true,
+	},
+	&cli.IntFlag{
+		Name:  "sample",
+		Usage: "number of affected account states or messages to parse with the new description in dry-run mode",
+		Value: 5,
+	},
+	&cli.StringFlag{
+		Name:  "config",
+		Usage: "file with blockchain config boc, required to execute changed get-methods in dry-run mode",
+	},
+}
+
+func newPlanParser(fn string, contractRepo core.ContractRepository) (app.ParserService, error) {
+	if fn == "" {
+		return nil, nil //nolint:nilnil // get-methods are not executed
 	}
-	_, err = abi.NewOperationDesc(x)
+	raw, err := os.ReadFile(fn)
 	if err != nil {
-		return nil, errors.Wrapf(err, "creating new operation descriptor")
+		return nil, errors.Wrap(err, "read blockchain config")
 	}
-
-	if d.Type == "" {
-		d.Type = string(core.Internal)
+	cfg, err := cell.FromBOC(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "blockchain config from boc")
 	}
-
-	return &core.ContractOperation{
-		OperationName: d.Name,
-		ContractName:  t,
-		MessageType:   core.MessageType(strings.ToUpper(d.Type)),
-		Outgoing:      false,
-		OperationID:   opId,
-		Schema:        *d,
-	}, nil
+	return parser.NewService(&app.ParserConfig{BlockchainConfig: cfg, ContractRepo: contractRepo}), nil
 }
 
-func ParseInterfaceDesc(d *abi.InterfaceDesc) (*core.ContractInterface, []*core.ContractOperation, error) {
-	var operations []*core.ContractOperation
+func updateInterface(ctx *cli.Context, dryRun bool) (err error) {
+	var interfacesDesc []*abi.InterfaceDesc
 
-	code, err := base64.StdEncoding.DecodeString(d.CodeBoc)
+	if ctx.Bool("stdin") {
+		interfacesDesc, err = readStdin()
+	} else {
+		filenames := ctx.Args().Slice()
+		if len(filenames) == 0 {
+			cli.ShowSubcommandHelpAndExit(ctx, 1)
+		}
+		interfacesDesc, err = readFiles(filenames)
+	}
 	if err != nil {
-		return nil, nil, errors.Wrapf(err, "decode code boc from base64")
+		return err
 	}
 
-	i := core.ContractInterface{
-		Name:           d.Name,
-		Addresses:      d.Addresses,
-		Code:           code,
-		GetMethodsDesc: d.GetMethods,
-	}
-	for it := range i.GetMethodsDesc {
-		i.GetMethodHashes = append(i.GetMethodHashes, abi.MethodNameHash(i.GetMethodsDesc[it].Name))
+	definitions, interfaces, _, err := registry.ParseInterfacesDesc(interfacesDesc)
+	if err != nil {
+		return err
 	}
-	if len(i.Code) == 0 {
-		i.Code = nil
+
+	contractName := abi.ContractName(ctx.String("contract-name"))
+	if contractName == "" {
+		return errors.Wrap(core.ErrInvalidArg, "contract interface name is not set")
 	}
 
-	for it := range d.InMessages {
-		op, err := ParseOperationDesc(i.Name, &d.InMessages[it])
-		if err != nil {
-			return nil, nil, err
+	var newInterface *core.ContractInterface
+	for _, i := range interfaces {
+		if i.Name == contractName {
+			newInterface = i
 		}
-		op.Outgoing = false
-		operations = append(operations, op)
+	}
+	if newInterface == nil {
+		return errors.Wrapf(core.ErrInvalidArg, "contract interface '%s' is found in abi description", contractName)
 	}
 
-	for it := range d.OutMessages {
-		op, err := ParseOperationDesc(i.Name, &d.OutMessages[it])
+	if !dryRun {
+		pg, err := dbConnect()
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
-		op.Outgoing = true
-		operations = append(operations, op)
-	}
+		defer pg.Close()
 
-	i.Operations = operations
+		contractRepo := contract.NewRepository(pg)
 
-	return &i, operations, nil
-}
-
-func ParseInterfacesDesc(descriptors []*abi.InterfaceDesc) (retD map[abi.TLBType]abi.TLBFieldsDesc, retI []*core.ContractInterface, retOp []*core.ContractOperation, _ error) {
-	retD = map[abi.TLBType]abi.TLBFieldsDesc{}
-	for _, desc := range descriptors {
-		err := abi.RegisterDefinitions(desc.Definitions)
+		oldInterface, err := contractRepo.GetInterface(ctx.Context, contractName)
 		if err != nil {
-			return nil, nil, nil, err
-		}
-		for dn, d := range desc.Definitions {
-			retD[dn] = d
+			return errors.Wrapf(err, "get '%s' interface", newInterface.Name)
 		}
-	}
-	for _, desc := range descriptors {
-		i, operations, err := ParseInterfaceDesc(desc)
+
+		plan, err := registry.NewUpdatePlan(ctx.Context, contractRepo, definitions, oldInterface, newInterface)
 		if err != nil {
-			return nil, nil, nil, err
+			return err
 		}
-		retI = append(retI, i)
-		retOp = append(retOp, operations...)
+		_, err = plan.Apply(ctx.Context, pg, contractRepo, rescan.NewRepository(pg), newRevision(ctx, core.RevisionUpdate))
+		return err
 	}
-	return
-}
 
-func diffDefinitions(ctx context.Context, contractRepo core.ContractRepository, current map[abi.TLBType]abi.TLBFieldsDesc) (added, changed map[abi.TLBType]abi.TLBFieldsDesc, err error) {
-	old, err := contractRepo.GetDefinitions(ctx)
+	conn, err := repository.ConnectDB(ctx.Context, env.GetString("DB_CH_URL", ""), env.GetString("DB_PG_URL", ""))
 	if err != nil {
-		return nil, nil, errors.Wrapf(err, "get definitions")
-	}
-
-	added, changed = map[abi.TLBType]abi.TLBFieldsDesc{}, map[abi.TLBType]abi.TLBFieldsDesc{}
-	for dt, d := range current {
-		od, ok := old[dt]
-		if !ok {
-			added[dt] = d
-			continue
-		}
-		if !reflect.DeepEqual(od, d) {
-			changed[dt] = d
-		}
-	}
-
-	return added, changed, nil
-}
-
-func diffSlices[V any](oldS, newS []V, getName func(v V) string) (added, changed, deleted []V) {
-	oldM, newM := map[string]V{}, map[string]V{}
-	for _, v := range oldS {
-		oldM[getName(v)] = v
-	}
-	for _, v := range newS {
-		newM[getName(v)] = v
-	}
-
-	for vn, v := range newM {
-		ov, ok := oldM[vn]
-		if !ok {
-			added = append(added, v)
-			continue
-		}
-		if !reflect.DeepEqual(ov, v) {
-			changed = append(changed, v)
-		}
+		return errors.Wrap(err, "cannot connect to a database")
 	}
-	for vn := range oldM {
-		_, ok := newM[vn]
-		if !ok {
-			deleted = append(deleted, oldM[vn])
-		}
-	}
-
-	return added, changed, deleted
-}
-
-func diffInterface(oldInterface, newInterface *core.ContractInterface) (interfaceChanged bool, added, changed, deleted []abi.GetMethodDesc) {
-	interfaceChanged = !reflect.DeepEqual(newInterface.Addresses, oldInterface.Addresses) ||
-		!reflect.DeepEqual(newInterface.Code, oldInterface.Code) ||
-		!reflect.DeepEqual(newInterface.GetMethodHashes, oldInterface.GetMethodHashes)
-
-	added, changed, deleted = diffSlices(oldInterface.GetMethodsDesc, newInterface.GetMethodsDesc, func(v abi.GetMethodDesc) string { return v.Name })
+	defer conn.Close()
 
-	return interfaceChanged, added, changed, deleted
-}
-
-func diffOperations(oldOperations, newOperations []*core.ContractOperation) (added, changed, deleted []*core.ContractOperation) {
-	return diffSlices(oldOperations, newOperations, func(v *core.ContractOperation) string { return v.OperationName })
-}
-
-func getGetMethodNames(desc []abi.GetMethodDesc) (names []string) {
-	for i := range desc {
-		if len(desc[i].Arguments) > 0 {
-			continue
-		}
-		names = append(names, desc[i].Name)
-	}
-	return
-}
+	contractRepo := contract.NewRepository(conn.PG)
 
-func rescanInterface(ctx context.Context, in abi.ContractName, repo core.RescanRepository, t core.RescanTaskType) error {
-	err := repo.AddRescanTask(ctx, &core.RescanTask{
-		Type:         t,
-		ContractName: in,
-	})
+	oldInterface, err := contractRepo.GetInterface(ctx.Context, contractName)
 	if err != nil {
-		return errors.Wrapf(err, "add rescan task for '%s' contract interface", in)
-	}
-
-	log.Info().
-		Str("rescan_type", string(t)).
-		Str("interface_name", string(in)).
-		Msg("added contract interface rescan task")
-
-	return nil
-}
-
-func rescanGetMethod(ctx context.Context, in abi.ContractName, repo core.RescanRepository, t core.RescanTaskType, getMethods []string) error {
-	if len(getMethods) == 0 {
-		return nil
+		return errors.Wrapf(err, "get '%s' interface", newInterface.Name)
 	}
 
-	err := repo.AddRescanTask(ctx, &core.RescanTask{
-		Type:              t,
-		ContractName:      in,
-		ChangedGetMethods: getMethods,
-	})
+	plan, err := registry.NewUpdatePlan(ctx.Context, contractRepo, definitions, oldInterface, newInterface)
 	if err != nil {
-		return errors.Wrapf(err, "add rescan task for '%s' get-method", getMethods)
-	}
-
-	for _, gm := range getMethods {
-		log.Info().
-			Str("rescan_type", string(t)).
-			Str("interface_name", string(in)).
-			Str("get_method", gm).
-			Msg("added get-method rescan task")
+		return err
 	}
 
-	return nil
-}
-
-func rescanOperation(ctx context.Context, repo core.RescanRepository, t core.RescanTaskType, op *core.ContractOperation) error {
-	err := repo.AddRescanTask(ctx, &core.RescanTask{
-		Type:         t,
-		ContractName: op.ContractName,
-		MessageType:  op.MessageType,
-		Outgoing:     op.Outgoing,
-		OperationID:  op.OperationID,
-	})
+	p, err := newPlanParser(ctx.String("config"), contractRepo)
 	if err != nil {
-		return errors.Wrapf(err, "add rescan task for '%s' operation", op.OperationName)
+		return err
 	}
 
-	log.Info().
-		Str("rescan_type", string(t)).
-		Str("interface_name", string(op.ContractName)).
-		Str("operation_name", op.OperationName).
-		Msg("added operation rescan task")
-
-	return nil
+	return plan.Report(ctx.Context, os.Stdout,
+		account.NewRepository(conn.CH, conn.PG),
+		msg.NewRepository(conn.CH, conn.PG),
+		p, ctx.Int("sample"))
 }
 
 var Command = &cli.Command{
@@ -332,13 +232,13 @@ var Command = &cli.Command{
 
 			ArgsUsage: "[file1.json] [file2.json]",
 
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.BoolFlag{
 					Name:    "stdin",
 					Usage:   "read from stdin instead of files",
 					Aliases: []string{"i"},
 				},
-			},
+			}, revisionFlags...),
 
 			Action: func(ctx *cli.Context) (err error) {
 				var interfacesDesc []*abi.InterfaceDesc
@@ -356,63 +256,15 @@ var Command = &cli.Command{
 					return err
 				}
 
-				definitions, interfaces, operations, err := ParseInterfacesDesc(interfacesDesc)
-				if err != nil {
-					return err
-				}
-
 				pg, err := dbConnect()
 				if err != nil {
 					return err
 				}
 
-				contractRepo := contract.NewRepository(pg)
-				rescanRepo := rescan.NewRepository(pg)
-
-				addedDef, changedDef, err := diffDefinitions(ctx.Context, contractRepo, definitions)
-				if err != nil {
-					return err
-				}
-				for dn, d := range changedDef {
-					if err := contractRepo.UpdateDefinition(ctx.Context, dn, d); err != nil {
-						return errors.Wrapf(err, "cannot update contract definition '%s'", dn)
-					}
-				}
-				for dn, d := range addedDef {
-					if err := contractRepo.AddDefinition(ctx.Context, dn, d); err != nil {
-						return errors.Wrapf(err, "cannot insert contract definition '%s'", dn)
-					}
-				}
-
-				for _, i := range interfaces {
-					if err := contractRepo.AddInterface(ctx.Context, i); err != nil {
-						log.Error().Err(err).Str("interface_name", string(i.Name)).Msg("cannot insert contract interface")
-						continue
-					}
-					err := rescanInterface(ctx.Context, i.Name, rescanRepo, core.AddInterface)
-					if err != nil {
-						log.Error().Err(err).Str("interface_name", string(i.Name)).Msg("cannot add interface rescan task")
-					}
-				}
-
-				for _, op := range operations {
-					if err := contractRepo.AddOperation(ctx.Context, op); err != nil {
-						log.Error().Err(err).
-							Str("interface_name", string(op.ContractName)).
-							Str("operation_name", op.OperationName).
-							Msg("cannot insert contract operation")
-						continue
-					}
-					err := rescanOperation(ctx.Context, rescanRepo, core.UpdOperation, op)
-					if err != nil {
-						log.Error().Err(err).
-							Str("interface_name", string(op.ContractName)).
-							Str("op_name", op.OperationName).
-							Msg("cannot add operation rescan task")
-					}
-				}
-
-				return nil
+				_, err = registry.AddInterfaces(ctx.Context, pg,
+					contract.NewRepository(pg), rescan.NewRepository(pg),
+					interfacesDesc, newRevision(ctx, core.RevisionAdd))
+				return err
 			},
 		},
 		{
@@ -421,150 +273,41 @@ var Command = &cli.Command{
 
 			ArgsUsage: "[file1.json] [file2.json]",
 
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.BoolFlag{
-					Name:    "stdin",
-					Usage:   "read from stdin instead of files",
-					Aliases: []string{"i"},
-				},
-				&cli.StringFlag{
-					Name:     "contract-name",
-					Usage:    "contract interface for update",
-					Aliases:  []string{"c"},
-					Required: true,
+					Name:  "dry-run",
+					Usage: "print the update plan without changing the database, the same as plan command",
 				},
-			},
-
-			Action: func(ctx *cli.Context) (err error) {
-				var interfacesDesc []*abi.InterfaceDesc
-
-				if ctx.Bool("stdin") {
-					interfacesDesc, err = readStdin()
-				} else {
-					filenames := ctx.Args().Slice()
-					if len(filenames) == 0 {
-						cli.ShowSubcommandHelpAndExit(ctx, 1)
-					}
-					interfacesDesc, err = readFiles(filenames)
-				}
-				if err != nil {
-					return err
-				}
-
-				definitions, interfaces, _, err := ParseInterfacesDesc(interfacesDesc)
-				if err != nil {
-					return err
-				}
-
-				contractName := abi.ContractName(ctx.String("contract-name"))
-				if contractName == "" {
-					return errors.Wrap(core.ErrInvalidArg, "contract interface name is not set")
-				}
-
-				var newInterface *core.ContractInterface
-				for _, i := range interfaces {
-					if i.Name == contractName {
-						newInterface = i
-					}
-				}
-				if newInterface == nil {
-					return errors.Wrapf(core.ErrInvalidArg, "contract interface '%s' is found in abi description", contractName)
-				}
-
-				pg, err := dbConnect()
-				if err != nil {
-					return err
-				}
-
-				contractRepo := contract.NewRepository(pg)
-				rescanRepo := rescan.NewRepository(pg)
-
-				oldInterface, err := contractRepo.GetInterface(ctx.Context, contractName)
-				if err != nil {
-					return errors.Wrapf(err, "get '%s' interface", newInterface.Name)
-				}
-
-				addedDef, changedDef, err := diffDefinitions(ctx.Context, contractRepo, definitions)
-				if err != nil {
-					return err
-				}
-				for dn, d := range changedDef {
-					if err := contractRepo.UpdateDefinition(ctx.Context, dn, d); err != nil {
-						return errors.Wrapf(err, "cannot update contract definition '%s'", dn)
-					}
-				}
-				for dn, d := range addedDef {
-					if err := contractRepo.AddDefinition(ctx.Context, dn, d); err != nil {
-						return errors.Wrapf(err, "cannot insert contract definition '%s'", dn)
-					}
-				}
-
-				iChanged, addedGm, changedGm, deletedGm := diffInterface(oldInterface, newInterface)
-				if iChanged || len(addedGm) > 0 || len(changedGm) > 0 || len(deletedGm) > 0 {
-					if err := contractRepo.UpdateInterface(ctx.Context, newInterface); err != nil {
-						return errors.Wrapf(err, "cannot update contract interface '%s'", newInterface.Name)
-					}
-				}
-
-				addedOp, changedOp, deletedOp := diffOperations(oldInterface.Operations, newInterface.Operations)
-				for _, op := range deletedOp {
-					if err := contractRepo.DeleteOperation(ctx.Context, op.OperationName); err != nil {
-						return errors.Wrapf(err, "cannot delete contract operation '%s'", op.OperationName)
-					}
-				}
-				for _, op := range changedOp {
-					if err := contractRepo.UpdateOperation(ctx.Context, op); err != nil {
-						return errors.Wrapf(err, "cannot update contract operation '%s'", op.OperationName)
-					}
-				}
-				for _, op := range addedOp {
-					if err := contractRepo.AddOperation(ctx.Context, op); err != nil {
-						return errors.Wrapf(err, "cannot insert contract operation '%s'", op.OperationName)
-					}
-				}
+			}, append(planFlags, revisionFlags...)...),
 
-				if iChanged {
-					if err := rescanInterface(ctx.Context, contractName, rescanRepo, core.UpdInterface); err != nil {
-						return err
-					}
-				}
+			Action: func(ctx *cli.Context) error {
+				return updateInterface(ctx, ctx.Bool("dry-run"))
+			},
+		},
+		{
+			Name:  "plan",
+			Usage: "Prints the difference between stored and new contract interfaces, rescan tasks to be created and affected data",
 
-				if err := rescanGetMethod(ctx.Context, contractName, rescanRepo, core.AddGetMethod, getGetMethodNames(addedGm)); err != nil {
-					return err
-				}
-				if err := rescanGetMethod(ctx.Context, contractName, rescanRepo, core.UpdGetMethod, getGetMethodNames(changedGm)); err != nil {
-					return err
-				}
-				if err := rescanGetMethod(ctx.Context, contractName, rescanRepo, core.DelGetMethod, getGetMethodNames(deletedGm)); err != nil {
-					return err
-				}
+			ArgsUsage: "[file1.json] [file2.json]",
 
-				for _, op := range deletedOp {
-					if err := rescanOperation(ctx.Context, rescanRepo, core.DelOperation, op); err != nil {
-						return err
-					}
-				}
-				for _, op := range append(addedOp, changedOp...) {
-					if err := rescanOperation(ctx.Context, rescanRepo, core.UpdOperation, op); err != nil {
-						return err
-					}
-				}
+			Flags: planFlags,
 
-				return nil
+			Action: func(ctx *cli.Context) error {
+				return updateInterface(ctx, true)
 			},
 		},
 		{
 			Name:  "deleteInterface",
 			Usage: "Deletes contract interface from the database and removes associated parsed data",
 
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:     "contract-name",
 					Usage:    "contract interface for deletion",
 					Aliases:  []string{"c"},
 					Required: true,
 				},
-			},
+			}, revisionFlags...),
 
 			Action: func(ctx *cli.Context) (err error) {
 				contractName := abi.ContractName(ctx.String("contract-name"))
@@ -577,31 +320,15 @@ var Command = &cli.Command{
 					return err
 				}
 
-				contractRepo := contract.NewRepository(pg)
-				rescanRepo := rescan.NewRepository(pg)
-
-				oldInterface, err := contractRepo.GetInterface(ctx.Context, contractName)
-				if err != nil {
-					return errors.Wrapf(err, "get '%s' interface", contractName)
-				}
-
-				if err := contractRepo.DeleteInterface(ctx.Context, contractName); err != nil {
-					return errors.Wrapf(err, "cannot delete '%s' interface", contractName)
-				}
-
-				for _, op := range oldInterface.Operations {
-					if err := rescanOperation(ctx.Context, rescanRepo, core.DelOperation, op); err != nil {
-						return err
-					}
-				}
-
-				err = rescanInterface(ctx.Context, contractName, rescanRepo, core.DelInterface)
-				if err != nil {
-					return err
-				}
-
-				return nil
+				_, err = registry.DeleteInterface(ctx.Context, pg,
+					contract.NewRepository(pg), rescan.NewRepository(pg),
+					contractName, newRevision(ctx, core.RevisionDelete))
+				return err
 			},
 		},
+		historyCommand,
+		rollbackCommand,
+		discoverCommand,
+		codegenCommand,
 	},
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +18,9 @@ import (
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/repository/checkpoint"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/prune"
 
 	"github.com/tonindexer/anton/migrations/chmigrations"
 	"github.com/tonindexer/anton/migrations/pgmigrations"
@@ -35,6 +39,9 @@ func newMigrators() (pg *migrate.Migrator, ch *chmigrate.Migrator, err error) {
 	}
 
 	mpg := migrate.NewMigrator(conn.PG, pgmigrations.Migrations)
+	if conn.CH == nil { // postgresql-only mode
+		return mpg, nil, nil
+	}
 	mch := chmigrate.NewMigrator(conn.CH, chmigrations.Migrations)
 
 	return mpg, mch, nil
@@ -52,9 +59,109 @@ func chUnlock(ctx context.Context, m *chmigrate.Migrator) {
 	}
 }
 
+// forEachCodeDataBatch calls the function with last_tx_lt ranges of account states,
+// each containing the given number of rows.
+// Ranges are taken from PostgreSQL, as inline code and data are stored there.
+func forEachCodeDataBatch(ctx context.Context, conn *repository.DB, lastTxLT uint64, limit int, f func(txLTFilter string) error) error {
+	for {
+		var nextTxLT uint64
+
+		err := conn.PG.NewRaw(`
+				SELECT last_tx_lt
+				FROM account_states
+				WHERE last_tx_lt >= ?
+				ORDER BY last_tx_lt ASC
+				OFFSET ? ROWS FETCH FIRST 1 ROWS ONLY`, lastTxLT, limit).
+			Scan(ctx, &nextTxLT)
+		if errors.Is(err, sql.ErrNoRows) {
+			nextTxLT = 0
+			log.Info().Uint64("last_tx_lt", lastTxLT).Msg("finishing")
+		} else if err != nil {
+			return errors.Wrap(err, "get next tx lt")
+		}
+
+		txLTFilter := fmt.Sprintf("last_tx_lt >= %d", lastTxLT)
+		if nextTxLT != 0 {
+			txLTFilter += fmt.Sprintf(" AND last_tx_lt < %d", nextTxLT)
+		}
+
+		if err := f(txLTFilter); err != nil {
+			return errors.Wrapf(err, "from %d to %d", lastTxLT, nextTxLT)
+		}
+
+		if nextTxLT == 0 {
+			log.Info().Msg("finished")
+			return nil
+		}
+		log.Info().Uint64("last_tx_lt", lastTxLT).Uint64("next_tx_lt", nextTxLT).Msg("processed new batch")
+
+		lastTxLT = nextTxLT
+	}
+}
+
+// transferCodeDataPG copies inline code and data of PostgreSQL account states
+// to the key-value tables, keeping one row per hash.
+func transferCodeDataPG(ctx context.Context, conn *repository.DB, txLTFilter string) error {
+	_, err := conn.PG.ExecContext(ctx, `
+			INSERT INTO account_states_code (code_hash, code)
+			SELECT DISTINCT ON (code_hash) code_hash, code
+			FROM account_states
+			WHERE `+txLTFilter+` AND code_hash IS NOT NULL AND length(code) > 0
+			ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return errors.Wrap(err, "transfer code in pg")
+	}
+
+	_, err = conn.PG.ExecContext(ctx, `
+			INSERT INTO account_states_data (data_hash, data)
+			SELECT DISTINCT ON (data_hash) data_hash, data
+			FROM account_states
+			WHERE `+txLTFilter+` AND data_hash IS NOT NULL AND length(data) > 0
+			ON CONFLICT DO NOTHING`)
+	if err != nil {
+		return errors.Wrap(err, "transfer data in pg")
+	}
+
+	return nil
+}
+
+// transferCodeDataCH copies inline code and data of ClickHouse account states to the key-value storage.
+func transferCodeDataCH(ctx context.Context, conn *repository.DB, txLTFilter string) error {
+	err := conn.CH.NewRaw(`
+			INSERT INTO account_states_code
+			SELECT code_hash, any(code)
+			FROM (
+				SELECT code_hash, code
+				FROM account_states
+				WHERE ` + txLTFilter + ` AND length(code) > 0
+			)
+			GROUP BY code_hash`).
+		Scan(ctx)
+	if err != nil {
+		return errors.Wrap(err, "transfer code in ch")
+	}
+
+	err = conn.CH.NewRaw(`
+			INSERT INTO account_states_data
+			SELECT data_hash, any(data)
+			FROM (
+				SELECT data_hash, data
+				FROM account_states
+				WHERE ` + txLTFilter + ` AND length(data) > 0
+			)
+			GROUP BY data_hash`).
+		Scan(ctx)
+	if err != nil {
+		return errors.Wrap(err, "transfer data in ch")
+	}
+
+	return nil
+}
+
 var Command = &cli.Command{
-	Name:  "migrate",
-	Usage: "Migrates database",
+	Name:    "migrate",
+	Aliases: []string{"db"},
+	Usage:   "Migrates database",
 
 	Subcommands: []*cli.Command{
 		{
@@ -68,6 +175,9 @@ var Command = &cli.Command{
 				if err := mpg.Init(c.Context); err != nil {
 					return err
 				}
+				if mch == nil {
+					return nil
+				}
 				if err := mch.Init(c.Context); err != nil {
 					return err
 				}
@@ -124,8 +234,10 @@ var Command = &cli.Command{
 					if err := mpg.Init(c.Context); err != nil {
 						return err
 					}
-					if err := mch.Init(c.Context); err != nil {
-						return err
+					if mch != nil {
+						if err := mch.Init(c.Context); err != nil {
+							return err
+						}
 					}
 				}
 
@@ -148,6 +260,9 @@ var Command = &cli.Command{
 
 				// clickhouse
 
+				if mch == nil {
+					return nil
+				}
 				if err := mch.Lock(c.Context); err != nil {
 					return err
 				}
@@ -195,6 +310,9 @@ var Command = &cli.Command{
 
 				// clickhouse
 
+				if mch == nil {
+					return nil
+				}
 				if err := mch.Lock(c.Context); err != nil {
 					return err
 				}
@@ -230,6 +348,9 @@ var Command = &cli.Command{
 				log.Info().Str("slice", spg.Unapplied().String()).Msg("pg unapplied")
 				log.Info().Str("group", spg.LastGroup().String()).Msg("pg last migration")
 
+				if mch == nil {
+					return nil
+				}
 				sch, err := mch.MigrationsWithStatus(c.Context)
 				if err != nil {
 					return err
@@ -252,6 +373,9 @@ var Command = &cli.Command{
 				if err := mpg.Lock(c.Context); err != nil {
 					return err
 				}
+				if mch == nil {
+					return nil
+				}
 				if err := mch.Lock(c.Context); err != nil {
 					return err
 				}
@@ -269,6 +393,9 @@ var Command = &cli.Command{
 				if err := mpg.Unlock(c.Context); err != nil {
 					return err
 				}
+				if mch == nil {
+					return nil
+				}
 				if err := mch.Unlock(c.Context); err != nil {
 					return err
 				}
@@ -293,6 +420,9 @@ var Command = &cli.Command{
 				}
 				log.Info().Str("group", pgGroup.String()).Msg("pg marked as applied")
 
+				if mch == nil {
+					return nil
+				}
 				chGroup, err := mch.Migrate(c.Context, chmigrate.WithNopMigration())
 				if err != nil {
 					return err
@@ -308,7 +438,7 @@ var Command = &cli.Command{
 		},
 		{
 			Name:  "transferCodeData",
-			Usage: "Moves account states code and data from ClickHouse to RocksDB",
+			Usage: "Copies inline account states code and data to the key-value storage tables",
 			Flags: []cli.Flag{
 				&cli.IntFlag{
 					Name:  "limit",
@@ -331,71 +461,20 @@ var Command = &cli.Command{
 				}
 				defer conn.Close()
 
-				lastTxLT := c.Uint64("start-from")
-				limit := c.Int("limit")
-				for {
-					var nextTxLT uint64
-
-					err := conn.CH.NewRaw(`
-							SELECT last_tx_lt
-							FROM account_states
-							WHERE last_tx_lt >= ?
-							ORDER BY last_tx_lt ASC
-							OFFSET ? ROW FETCH FIRST 1 ROWS ONLY`, lastTxLT, limit).
-						Scan(c.Context, &nextTxLT)
-					if errors.Is(err, sql.ErrNoRows) {
-						nextTxLT = 0
-						log.Info().Uint64("last_tx_lt", lastTxLT).Msg("finishing")
-					} else if err != nil {
-						return errors.Wrap(err, "get next tx lt")
-					}
-
-					f := fmt.Sprintf("last_tx_lt >= %d", lastTxLT)
-					if nextTxLT != 0 {
-						f += fmt.Sprintf(" AND last_tx_lt < %d", nextTxLT)
-					}
-
-					err = conn.CH.NewRaw(`
-							INSERT INTO account_states_code
-							SELECT code_hash, any(code)
-							FROM (
-								SELECT code_hash, code
-								FROM account_states
-								WHERE ` + f + ` AND length(code) > 0
-							)
-							GROUP BY code_hash`).
-						Scan(c.Context)
-					if err != nil {
-						return errors.Wrapf(err, "transfer code from %d to %d", lastTxLT, nextTxLT)
-					}
-
-					err = conn.CH.NewRaw(`
-							INSERT INTO account_states_data
-							SELECT data_hash, any(data)
-							FROM (
-								SELECT data_hash, data
-								FROM account_states
-								WHERE ` + f + ` AND length(data) > 0
-							)
-							GROUP BY data_hash`).
-						Scan(c.Context)
-					if err != nil {
-						return errors.Wrapf(err, "transfer data from %d to %d", lastTxLT, nextTxLT)
+				return forEachCodeDataBatch(c.Context, conn, c.Uint64("start-from"), c.Int("limit"), func(f string) error {
+					if err := transferCodeDataPG(c.Context, conn, f); err != nil {
+						return err
 					}
-
-					if nextTxLT == 0 {
-						log.Info().Msg("finished")
+					if conn.CH == nil {
 						return nil
-					} else {
-						log.Info().Uint64("last_tx_lt", lastTxLT).Uint64("next_tx_lt", nextTxLT).Msg("transferred new batch")
 					}
-					lastTxLT = nextTxLT
-				}
+					return transferCodeDataCH(c.Context, conn, f)
+				})
 			},
 		},
 		{
 			Name:  "clearCodeData",
-			Usage: "Removes account states code and data from PostgreSQL",
+			Usage: "Removes inline account states code and data from PostgreSQL",
 			Flags: []cli.Flag{
 				&cli.IntFlag{
 					Name:  "limit",
@@ -418,53 +497,216 @@ var Command = &cli.Command{
 				}
 				defer conn.Close()
 
-				lastTxLT := c.Uint64("start-from")
-				limit := c.Int("limit")
-				for {
-					var nextTxLT uint64
-
-					err := conn.CH.NewRaw(`
-							SELECT last_tx_lt
-							FROM account_states
-							WHERE last_tx_lt >= ?
-							ORDER BY last_tx_lt ASC
-							OFFSET ? ROW FETCH FIRST 1 ROWS ONLY`, lastTxLT, limit).
-						Scan(c.Context, &nextTxLT)
-					if errors.Is(err, sql.ErrNoRows) {
-						nextTxLT = 0
-						log.Info().Uint64("last_tx_lt", lastTxLT).Msg("finishing")
-					} else if err != nil {
-						return errors.Wrap(err, "get next tx lt")
+				return forEachCodeDataBatch(c.Context, conn, c.Uint64("start-from"), c.Int("limit"), func(f string) error {
+					// code and data are cleared only if they are already copied to the key-value storage
+					_, err := conn.PG.ExecContext(c.Context, `
+							UPDATE account_states AS s
+							SET code = NULL
+							WHERE `+f+` AND s.code IS NOT NULL
+								AND EXISTS (SELECT 1 FROM account_states_code AS kv WHERE kv.code_hash = s.code_hash)`)
+					if err != nil {
+						return errors.Wrap(err, "clear code")
 					}
 
-					q := conn.PG.NewUpdate().Model((*core.AccountState)(nil)).
-						Set("code = NULL").
-						Where("last_tx_lt >= ?", lastTxLT)
-					if nextTxLT != 0 {
-						q = q.Where("last_tx_lt < ?", nextTxLT)
+					_, err = conn.PG.ExecContext(c.Context, `
+							UPDATE account_states AS s
+							SET data = NULL
+							WHERE `+f+` AND s.data IS NOT NULL
+								AND EXISTS (SELECT 1 FROM account_states_data AS kv WHERE kv.data_hash = s.data_hash)`)
+					if err != nil {
+						return errors.Wrap(err, "clear data")
 					}
-					if _, err := q.Exec(c.Context); err != nil {
-						return errors.Wrapf(err, "clear code from %d to %d", lastTxLT, nextTxLT)
+
+					return nil
+				})
+			},
+		},
+		{
+			Name:  "verify",
+			Usage: "Compares blocks, transactions, messages and account states between PostgreSQL and ClickHouse",
+			Flags: []cli.Flag{
+				&cli.UintFlag{
+					Name:  "from",
+					Usage: "masterchain seq_no to start from, the next one after the checkpoint by default",
+				},
+				&cli.UintFlag{
+					Name:  "to",
+					Usage: "masterchain seq_no to finish at, the last written to ClickHouse by default",
+				},
+				&cli.UintFlag{
+					Name:  "batch",
+					Value: 100,
+					Usage: "masterchain blocks compared at once",
+				},
+				&cli.BoolFlag{
+					Name:  "repair",
+					Value: false,
+					Usage: "copy PostgreSQL rows to ClickHouse and remove extra rows from ClickHouse",
+				},
+				&cli.StringFlag{
+					Name:  "checkpoint",
+					Value: "default",
+					Usage: "name of the saved verification progress, empty to disable",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				chURL := env.GetString("DB_CH_URL", "")
+				pgURL := env.GetString("DB_PG_URL", "")
+
+				conn, err := repository.ConnectDB(c.Context, chURL, pgURL)
+				if err != nil {
+					return errors.Wrap(err, "cannot connect to the databases")
+				}
+				defer conn.Close()
+
+				repo := outbox.NewRepository(conn.CH, conn.PG)
+				checkpoints := checkpoint.NewRepository(conn.PG)
+
+				batch := uint32(c.Uint("batch"))
+				if batch == 0 {
+					return errors.Wrap(core.ErrInvalidArg, "batch must be positive")
+				}
+
+				from, to, err := repo.VerifiableRange(c.Context)
+				if errors.Is(err, core.ErrNotFound) {
+					log.Info().Msg("there are no blocks to verify")
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+
+				name := c.String("checkpoint")
+				if name != "" {
+					seqNo, err := checkpoints.GetVerifyCheckpoint(c.Context, name)
+					switch {
+					case err == nil:
+						from = seqNo + 1
+					case !errors.Is(err, core.ErrNotFound):
+						return err
+					}
+				}
+				if c.IsSet("from") {
+					from = uint32(c.Uint("from"))
+				}
+				if c.IsSet("to") && uint32(c.Uint("to")) < to {
+					to = uint32(c.Uint("to"))
+				}
+
+				var inconsistent int
+				for seqNo := from; seqNo <= to; seqNo += batch {
+					last := seqNo + batch - 1
+					if last > to {
+						last = to
 					}
 
-					q = conn.PG.NewUpdate().Model((*core.AccountState)(nil)).
-						Set("data = NULL").
-						Where("last_tx_lt >= ?", lastTxLT)
-					if nextTxLT != 0 {
-						q = q.Where("last_tx_lt < ?", nextTxLT)
+					diffs, err := repo.Verify(c.Context, seqNo, last, c.Bool("repair"))
+					if err != nil {
+						return errors.Wrapf(err, "verify from %d to %d", seqNo, last)
+					}
+					for _, d := range diffs {
+						for _, t := range d.Tables {
+							log.Warn().
+								Uint32("master_seq_no", d.MasterSeqNo).
+								Str("table", t.Table).
+								Int("pg", t.PG).Int("ch", t.CH).
+								Int("missing", t.Missing).Int("extra", t.Extra).Int("changed", t.Changed).
+								Bool("repaired", c.Bool("repair")).
+								Msg("inconsistent rows")
+						}
 					}
-					if _, err := q.Exec(c.Context); err != nil {
-						return errors.Wrapf(err, "clear data from %d to %d", lastTxLT, nextTxLT)
+					if !c.Bool("repair") {
+						inconsistent += len(diffs)
 					}
 
-					log.Info().Uint64("last_tx_lt", lastTxLT).Uint64("next_tx_lt", nextTxLT).Msg("cleared new batch")
+					// do not move the checkpoint past not repaired blocks
+					if name != "" && inconsistent == 0 {
+						if err := checkpoints.SetVerifyCheckpoint(c.Context, name, last); err != nil {
+							return err
+						}
+					}
 
-					if nextTxLT == 0 {
-						log.Info().Msg("finished")
-						return nil
+					log.Info().Uint32("from", seqNo).Uint32("to", last).Int("inconsistent", len(diffs)).Msg("verified new batch")
+				}
+
+				if inconsistent > 0 {
+					return fmt.Errorf("found %d inconsistent masterchain blocks", inconsistent)
+				}
+				log.Info().Msg("finished")
+				return nil
+			},
+		},
+		{
+			Name:  "prune",
+			Usage: "Removes old history according to retention rules",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "keep-states",
+					Usage: "keep only the given number of the latest states of accounts without interfaces",
+				},
+				&cli.IntFlag{
+					Name:  "code-data-days",
+					Usage: "remove code and data of superseded account states older than the given number of days",
+				},
+				&cli.IntFlag{
+					Name:  "message-body-days",
+					Usage: "remove bodies of messages with unknown operations older than the given number of days",
+				},
+				&cli.StringSliceFlag{
+					Name:  "ttl",
+					Usage: "set clickhouse table ttl in days, e.g., messages=180, zero removes ttl",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				chURL := env.GetString("DB_CH_URL", "")
+				pgURL := env.GetString("DB_PG_URL", "")
+
+				conn, err := repository.ConnectDB(c.Context, chURL, pgURL)
+				if err != nil {
+					return errors.Wrap(err, "cannot connect to the databases")
+				}
+				defer conn.Close()
+
+				repo := prune.NewRepository(conn.CH, conn.PG)
+				day := 24 * time.Hour
+
+				for _, ttl := range c.StringSlice("ttl") {
+					table, value, ok := strings.Cut(ttl, "=")
+					if !ok {
+						return errors.Wrapf(core.ErrInvalidArg, "wrong ttl format: %s", ttl)
+					}
+					days, err := strconv.Atoi(value)
+					if err != nil {
+						return errors.Wrapf(err, "parse %s ttl", table)
 					}
-					lastTxLT = nextTxLT
+					if err := repo.SetTTL(c.Context, table, days); err != nil {
+						return err
+					}
+					log.Info().Str("table", table).Int("days", days).Msg("set ttl")
+				}
+
+				if n := c.Int("keep-states"); n > 0 {
+					if err := repo.PruneAccountStates(c.Context, n); err != nil {
+						return err
+					}
+					log.Info().Int("keep_latest", n).Msg("pruned account states")
+				}
+
+				if days := c.Int("code-data-days"); days > 0 {
+					if err := repo.PruneCodeData(c.Context, time.Now().Add(-time.Duration(days)*day)); err != nil {
+						return err
+					}
+					log.Info().Int("days", days).Msg("pruned code and data")
+				}
+
+				if days := c.Int("message-body-days"); days > 0 {
+					if err := repo.PruneMessageBodies(c.Context, time.Now().Add(-time.Duration(days)*day)); err != nil {
+						return err
+					}
+					log.Info().Int("days", days).Msg("pruned message bodies")
 				}
+
+				return nil
 			},
 		},
 	},
@@ -0,0 +1,244 @@
+package emulate
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/allisson/go-env"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/liteserver"
+	"github.com/tonindexer/anton/internal/app/parser"
+	"github.com/tonindexer/anton/internal/app/query"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/repository/contract"
+	"github.com/tonindexer/anton/internal/core/repository/skip"
+)
+
+func decodeBOC(in string) ([]byte, error) {
+	s := strings.TrimSpace(in)
+	if b, err := hex.DecodeString(s); err == nil {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	if b, err := base64.URLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return nil, errors.Wrap(core.ErrInvalidArg, "cannot decode boc: expected hex or base64 encoding")
+}
+
+func readBOC(fn string) ([]byte, error) {
+	var (
+		in  []byte
+		err error
+	)
+	if fn == "-" {
+		in, err = io.ReadAll(os.Stdin)
+	} else {
+		in, err = os.ReadFile(fn)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read message")
+	}
+	if b, err := decodeBOC(string(in)); err == nil {
+		return b, nil
+	}
+	return in, nil // raw bag of cells
+}
+
+func messageRequest(ctx *cli.Context) (*app.EmulateMessageReq, error) {
+	var req app.EmulateMessageReq
+
+	if err := req.Address.UnmarshalText([]byte(ctx.String("address"))); err != nil {
+		return nil, errors.Wrap(err, "parse address")
+	}
+
+	var err error
+	if fn := ctx.String("message"); fn != "" {
+		req.Message, err = readBOC(fn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if b := ctx.String("body"); b != "" {
+		req.Body, err = decodeBOC(b)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode body")
+		}
+	}
+
+	req.Contract = abi.ContractName(ctx.String("contract"))
+	req.OperationName = ctx.String("operation")
+	if d := ctx.String("data"); d != "" {
+		req.Data = json.RawMessage(d)
+	}
+
+	if src := ctx.String("src"); src != "" {
+		req.SrcAddress = new(addr.Address)
+		if err := req.SrcAddress.UnmarshalText([]byte(src)); err != nil {
+			return nil, errors.Wrap(err, "parse source address")
+		}
+	}
+	req.Amount = ctx.Uint64("amount")
+	req.Bounce = ctx.Bool("bounce")
+	req.IgnoreSignature = ctx.Bool("ignore-signature")
+
+	if ctx.IsSet("tx-lt") {
+		lt := ctx.Uint64("tx-lt")
+		req.TxLT = &lt
+	}
+	if ctx.IsSet("block-seq-no") {
+		seq := uint32(ctx.Uint("block-seq-no"))
+		req.BlockSeqNo = &seq
+	}
+
+	return &req, nil
+}
+
+var Command = &cli.Command{
+	Name:  "emulate",
+	Usage: "Emulates transaction triggered by a message on the stored account state",
+
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "address",
+			Aliases:  []string{"a"},
+			Usage:    "destination account address",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "message",
+			Aliases: []string{"m"},
+			Usage:   "file with the whole message boc, '-' to read from stdin",
+		},
+		&cli.StringFlag{
+			Name:  "body",
+			Usage: "message body boc in hex or base64",
+		},
+		&cli.StringFlag{
+			Name:    "operation",
+			Aliases: []string{"o"},
+			Usage:   "operation name to build message body from the json data",
+		},
+		&cli.StringFlag{
+			Name:    "contract",
+			Aliases: []string{"c"},
+			Usage:   "contract interface of the operation, by default account interfaces are used",
+		},
+		&cli.StringFlag{
+			Name:  "data",
+			Usage: "json fields of the operation",
+		},
+		&cli.StringFlag{
+			Name:  "src",
+			Usage: "source address of an internal message, external message is emulated if not set",
+		},
+		&cli.Uint64Flag{
+			Name:  "amount",
+			Usage: "internal message amount in nanotons",
+		},
+		&cli.BoolFlag{
+			Name:  "bounce",
+			Usage: "bounce flag of an internal message",
+		},
+		&cli.BoolFlag{
+			Name:  "ignore-signature",
+			Usage: "ignore signature check of an external message",
+		},
+		&cli.Uint64Flag{
+			Name:  "tx-lt",
+			Usage: "use account state after the given transaction",
+		},
+		&cli.UintFlag{
+			Name:  "block-seq-no",
+			Usage: "use account state at the given block",
+		},
+	},
+
+	Action: func(ctx *cli.Context) error {
+		req, err := messageRequest(ctx)
+		if err != nil {
+			return err
+		}
+
+		conn, err := repository.ConnectDB(ctx.Context,
+			env.GetString("DB_CH_URL", ""),
+			env.GetString("DB_PG_URL", ""))
+		if err != nil {
+			return errors.Wrap(err, "cannot connect to a database")
+		}
+		defer conn.Close()
+
+		contractRepo := contract.NewRepository(conn.PG)
+
+		def, err := contractRepo.GetDefinitions(ctx.Context)
+		if err != nil {
+			return errors.Wrap(err, "get definitions")
+		}
+		err = abi.RegisterDefinitions(def)
+		if err != nil {
+			return errors.Wrap(err, "register definitions")
+		}
+
+		err = skip.Watch(ctx.Context, skip.NewRepository(conn.PG), time.Minute)
+		if err != nil {
+			return errors.Wrap(err, "cannot load skipped addresses")
+		}
+
+		servers, err := liteserver.ParseServers(env.GetString("LITESERVERS", ""))
+		if err != nil {
+			return err
+		}
+		api, err := liteserver.NewManager(ctx.Context, &app.LiteserverConfig{
+			Servers:      servers,
+			GlobalConfig: env.GetString("LITESERVERS_GLOBAL_CONFIG", ""),
+		})
+		if err != nil {
+			return errors.Wrap(err, "cannot connect to liteservers")
+		}
+		defer api.Close()
+
+		bcConfig, err := app.GetBlockchainConfig(ctx.Context, api)
+		if err != nil {
+			return errors.Wrap(err, "cannot get blockchain config")
+		}
+
+		qs, err := query.NewService(ctx.Context, &app.QueryConfig{
+			DB:  conn,
+			API: api,
+			Parser: parser.NewService(&app.ParserConfig{
+				BlockchainConfig: bcConfig,
+				ContractRepo:     contractRepo,
+			}),
+		})
+		if err != nil {
+			return err
+		}
+
+		tx, err := qs.EmulateMessage(ctx.Context, req)
+		if err != nil {
+			return err
+		}
+
+		out, err := json.MarshalIndent(tx, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "json marshal emulated transaction")
+		}
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
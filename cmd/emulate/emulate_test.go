@@ -0,0 +1,90 @@
+package emulate
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+const bodyBOC = `te6ccuEBAQEAMwBmAGFZXwe8AAAAAACFYI8walQ4AJvi30153Ex53ULaIU/S0hqruxuRQNfFygS/4vFtl92PwofAGw==`
+
+func parseRequest(t *testing.T, args ...string) (req *app.EmulateMessageReq, err error) {
+	a := &cli.App{
+		Flags: Command.Flags,
+		Action: func(ctx *cli.Context) error {
+			req, err = messageRequest(ctx)
+			return nil
+		},
+	}
+	require.Nil(t, a.Run(append([]string{Command.Name}, args...)))
+	return req, err
+}
+
+func TestMessageRequest(t *testing.T) {
+	raw, err := base64.StdEncoding.DecodeString(bodyBOC)
+	require.Nil(t, err)
+
+	msgFile := filepath.Join(t.TempDir(), "msg.boc")
+	require.Nil(t, os.WriteFile(msgFile, raw, 0o600))
+
+	const (
+		dst = "EQCpEVerpJdXTd6cLJcWXDQOqFrct67vwtd9_jIxm99zQZV6"
+		src = "EQBN8W-mvO4mPO6hbRCn6WkNVd2NyKBr4uUCX_F4tsvux5oO"
+	)
+
+	t.Run("operation", func(t *testing.T) {
+		req, err := parseRequest(t,
+			"-a", dst, "-c", "jetton_wallet", "-o", "jetton_burn", "--data", `{"query_id":1}`,
+			"--src", src, "--amount", "100", "--bounce", "--tx-lt", "42")
+		require.Nil(t, err)
+
+		require.Equal(t, dst, req.Address.Base64())
+		require.Equal(t, abi.ContractName("jetton_wallet"), req.Contract)
+		require.Equal(t, "jetton_burn", req.OperationName)
+		require.Equal(t, json.RawMessage(`{"query_id":1}`), req.Data)
+		require.NotNil(t, req.SrcAddress)
+		require.Equal(t, src, req.SrcAddress.Base64())
+		require.Equal(t, uint64(100), req.Amount)
+		require.True(t, req.Bounce)
+		require.NotNil(t, req.TxLT)
+		require.Equal(t, uint64(42), *req.TxLT)
+		require.Nil(t, req.BlockSeqNo)
+	})
+
+	t.Run("hex body of external message", func(t *testing.T) {
+		req, err := parseRequest(t, "-a", dst, "--body", hex.EncodeToString(raw), "--block-seq-no", "7")
+		require.Nil(t, err)
+		require.Equal(t, raw, req.Body)
+		require.Nil(t, req.SrcAddress)
+		require.Nil(t, req.TxLT)
+		require.NotNil(t, req.BlockSeqNo)
+		require.Equal(t, uint32(7), *req.BlockSeqNo)
+	})
+
+	t.Run("raw message file", func(t *testing.T) {
+		req, err := parseRequest(t, "-a", dst, "-m", msgFile)
+		require.Nil(t, err)
+		require.Equal(t, raw, req.Message)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		_, err := parseRequest(t, "-a", dst, "--body", "not a boc")
+		require.True(t, errors.Is(err, core.ErrInvalidArg), err)
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		_, err := parseRequest(t, "-a", "abc")
+		require.NotNil(t, err)
+	})
+}
@@ -0,0 +1,103 @@
+package indexer
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/allisson/go-env"
+	"github.com/pkg/errors"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+)
+
+func getList(key string) (ret []string) {
+	for _, s := range env.GetStringSlice(key, ",", nil) {
+		if s = strings.TrimSpace(s); s != "" {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func getWorkchains(key string) (ret []int32, err error) {
+	for _, s := range getList(key) {
+		w, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s workchain", key)
+		}
+		ret = append(ret, int32(w))
+	}
+	return ret, nil
+}
+
+func getAddresses(key string) (ret []*addr.Address, err error) {
+	for _, s := range getList(key) {
+		a := new(addr.Address)
+		if err := a.UnmarshalText([]byte(s)); err != nil {
+			return nil, errors.Wrapf(err, "parse %s address", key)
+		}
+		ret = append(ret, a)
+	}
+	return ret, nil
+}
+
+func getHashes(key string) (ret [][]byte, err error) {
+	for _, s := range getList(key) {
+		h, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s hash", key)
+		}
+		ret = append(ret, h)
+	}
+	return ret, nil
+}
+
+func getInterfaces(key string) (ret []abi.ContractName) {
+	for _, s := range getList(key) {
+		ret = append(ret, abi.ContractName(s))
+	}
+	return ret
+}
+
+// getIndexerFilter reads selective indexing rules from the environment,
+// it returns nil if no rules are set.
+func getIndexerFilter() (*app.IndexerFilter, error) {
+	var (
+		f   app.IndexerFilter
+		err error
+	)
+
+	if f.Workchains, err = getWorkchains("INDEX_WORKCHAINS"); err != nil {
+		return nil, err
+	}
+	if f.ExcludeWorkchains, err = getWorkchains("SKIP_WORKCHAINS"); err != nil {
+		return nil, err
+	}
+	if f.Addresses, err = getAddresses("INDEX_ADDRESSES"); err != nil {
+		return nil, err
+	}
+	if f.ExcludeAddresses, err = getAddresses("SKIP_ADDRESSES"); err != nil {
+		return nil, err
+	}
+	if f.CodeHashes, err = getHashes("INDEX_CODE_HASHES"); err != nil {
+		return nil, err
+	}
+	if f.ExcludeCodeHashes, err = getHashes("SKIP_CODE_HASHES"); err != nil {
+		return nil, err
+	}
+	f.Interfaces = getInterfaces("INDEX_INTERFACES")
+	f.ExcludeInterfaces = getInterfaces("SKIP_INTERFACES")
+	f.WatchedMessagesOnly = env.GetBool("INDEX_WATCHED_MESSAGES_ONLY", false)
+
+	if len(f.Workchains)+len(f.ExcludeWorkchains)+
+		len(f.Addresses)+len(f.ExcludeAddresses)+
+		len(f.CodeHashes)+len(f.ExcludeCodeHashes)+
+		len(f.Interfaces)+len(f.ExcludeInterfaces) == 0 && !f.WatchedMessagesOnly {
+		return nil, nil //nolint:nilnil // the whole network is indexed
+	}
+
+	return &f, nil
+}
@@ -8,25 +8,34 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/allisson/go-env"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/uptrace/bun"
 	"github.com/urfave/cli/v2"
-	"github.com/xssnick/tonutils-go/liteclient"
 	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
 
 	"github.com/tonindexer/anton/abi"
-	contractDesc "github.com/tonindexer/anton/cmd/contract"
 	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/dump"
 	"github.com/tonindexer/anton/internal/app/fetcher"
 	"github.com/tonindexer/anton/internal/app/indexer"
+	"github.com/tonindexer/anton/internal/app/liteserver"
 	"github.com/tonindexer/anton/internal/app/parser"
+	"github.com/tonindexer/anton/internal/app/registry"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/repository"
 	"github.com/tonindexer/anton/internal/core/repository/account"
+	"github.com/tonindexer/anton/internal/core/repository/block"
 	"github.com/tonindexer/anton/internal/core/repository/contract"
+	"github.com/tonindexer/anton/internal/core/repository/msg"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/skip"
+	"github.com/tonindexer/anton/internal/core/repository/tx"
+	"github.com/tonindexer/anton/metrics"
 )
 
 func getAllKnownContractFilenames(contractsDir string) (res []string, err error) {
@@ -71,7 +80,7 @@ func addKnownContracts(ctx context.Context, pg *bun.DB, contractsDir string) err
 			return errors.Wrapf(err, "unmarshal json")
 		}
 
-		definitions, interfaces, operations, err := contractDesc.ParseInterfacesDesc(descriptions)
+		definitions, interfaces, operations, err := registry.ParseInterfacesDesc(descriptions)
 		if err != nil {
 			return err
 		}
@@ -109,6 +118,52 @@ func addKnownContracts(ctx context.Context, pg *bun.DB, contractsDir string) err
 	return nil
 }
 
+func connectLiteservers(ctx *cli.Context) (ton.APIClientWrapped, func(), error) {
+	if ctx.String("replay") != "" {
+		if ctx.String("record") != "" {
+			return nil, nil, errors.New("cannot record and replay liteserver responses at the same time")
+		}
+		api, err := liteserver.NewReplayClient(ctx.String("replay"))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot replay liteserver responses")
+		}
+		return api, func() {}, nil
+	}
+
+	servers, err := liteserver.ParseServers(env.GetString("LITESERVERS", ""))
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &app.LiteserverConfig{
+		Servers:      servers,
+		GlobalConfig: env.GetString("LITESERVERS_GLOBAL_CONFIG", ""),
+	}
+
+	var rec *liteserver.Recorder
+	if ctx.String("record") != "" {
+		rec, err = liteserver.NewRecorder(ctx.String("record"))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "cannot record liteserver responses")
+		}
+		cfg.Dial = rec.Dial
+	}
+
+	api, err := liteserver.NewManager(ctx.Context, cfg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot connect to liteservers")
+	}
+
+	return api, func() {
+		api.Close()
+		if rec == nil {
+			return
+		}
+		if err := rec.Close(); err != nil {
+			log.Error().Err(err).Msg("cannot close liteserver responses recorder")
+		}
+	}, nil
+}
+
 var Command = &cli.Command{
 	Name:    "indexer",
 	Aliases: []string{"idx"},
@@ -121,6 +176,19 @@ var Command = &cli.Command{
 			Value:   "/var/anton/known",
 			Aliases: []string{"c"},
 		},
+		&cli.StringFlag{
+			Name:  "source",
+			Usage: "the block source: liteserver, or files:/path to index from local block dumps without a liteserver",
+			Value: "liteserver",
+		},
+		&cli.StringFlag{
+			Name:  "record",
+			Usage: "the directory to record all liteserver responses to",
+		},
+		&cli.StringFlag{
+			Name:  "replay",
+			Usage: "the directory with recorded liteserver responses, which are served instead of liteservers",
+		},
 	},
 
 	Action: func(ctx *cli.Context) error {
@@ -155,50 +223,108 @@ var Command = &cli.Command{
 			return errors.Wrap(err, "get definitions")
 		}
 
-		client := liteclient.NewConnectionPool()
-		api := ton.NewAPIClient(client, ton.ProofCheckPolicyUnsafe).WithRetry()
-		for _, addr := range strings.Split(env.GetString("LITESERVERS", ""), ",") {
-			split := strings.Split(addr, "|")
-			if len(split) != 2 {
-				return fmt.Errorf("wrong server address format '%s'", addr)
+		err = skip.Watch(ctx.Context, skip.NewRepository(conn.PG), time.Minute)
+		if err != nil {
+			return errors.Wrap(err, "cannot load skipped addresses")
+		}
+
+		accountRepo := account.NewRepository(conn.CH, conn.PG)
+
+		var (
+			source   app.BlockSource
+			closeAPI = func() {}
+			bcConfig *cell.Cell
+			p        *parser.Service
+		)
+		switch src := ctx.String("source"); {
+		case src == "liteserver":
+			var api ton.APIClientWrapped
+			api, closeAPI, err = connectLiteservers(ctx)
+			if err != nil {
+				return err
+			}
+			bcConfig, err = app.GetBlockchainConfig(ctx.Context, api)
+			if err != nil {
+				return errors.Wrap(err, "cannot get blockchain config")
+			}
+			p = parser.NewService(&app.ParserConfig{
+				BlockchainConfig: bcConfig,
+				ContractRepo:     contractRepo,
+			})
+			source = fetcher.NewService(&app.FetcherConfig{
+				API:          api,
+				AccountRepo:  accountRepo,
+				Parser:       p,
+				FetchWorkers: env.GetInt("FETCH_WORKERS", 32),
+				ParseWorkers: env.GetInt("ACCOUNT_PARSE_WORKERS", 0),
+			})
+
+		case strings.HasPrefix(src, "files:"):
+			path := strings.TrimPrefix(src, "files:")
+			bcConfig, err = dump.BlockchainConfig(path)
+			if err != nil {
+				return errors.Wrap(err, "cannot get blockchain config")
 			}
-			host, key := split[0], split[1]
-			if err := client.AddConnection(ctx.Context, host, key); err != nil {
-				return errors.Wrapf(err, "cannot add connection with %s host and %s key", host, key)
+			p = parser.NewService(&app.ParserConfig{
+				BlockchainConfig: bcConfig,
+				ContractRepo:     contractRepo,
+			})
+			source, err = dump.NewService(&app.DumpConfig{
+				Path:        path,
+				AccountRepo: accountRepo,
+				Parser:      p,
+			})
+			if err != nil {
+				return errors.Wrap(err, "cannot read block dumps")
 			}
+
+		default:
+			return fmt.Errorf("unknown block source '%s'", src)
 		}
-		bcConfig, err := app.GetBlockchainConfig(ctx.Context, api)
+
+		filter, err := getIndexerFilter()
 		if err != nil {
-			return errors.Wrap(err, "cannot get blockchain config")
+			return errors.Wrap(err, "cannot read indexer filter")
+		}
+		if filter != nil {
+			log.Info().Interface("filter", filter).Msg("selective indexing is enabled")
 		}
 
-		p := parser.NewService(&app.ParserConfig{
-			BlockchainConfig: bcConfig,
-			ContractRepo:     contractRepo,
-		})
-		f := fetcher.NewService(&app.FetcherConfig{
-			API:         api,
-			AccountRepo: account.NewRepository(conn.CH, conn.PG),
-			Parser:      p,
-		})
 		i := indexer.NewService(&app.IndexerConfig{
-			DB:        conn,
-			API:       api,
-			Parser:    p,
-			Fetcher:   f,
-			FromBlock: uint32(env.GetInt32("FROM_BLOCK", 1)),
-			Workers:   env.GetInt("WORKERS", 4),
+			DB:            conn,
+			BlockRepo:     block.NewRepository(conn.CH, conn.PG),
+			TxRepo:        tx.NewRepository(conn.CH, conn.PG),
+			MessageRepo:   msg.NewRepository(conn.CH, conn.PG),
+			AccountRepo:   accountRepo,
+			OutboxRepo:    outbox.NewRepository(conn.CH, conn.PG),
+			Source:        source,
+			Parser:        p,
+			Filter:        filter,
+			FromBlock:     uint32(env.GetInt32("FROM_BLOCK", 1)),
+			Workers:       env.GetInt("WORKERS", 4),
+			ParseWorkers:  env.GetInt("MESSAGE_PARSE_WORKERS", 0),
+			FlushBlocks:   env.GetInt("INSERT_BLOCKS", 1),
+			FlushInterval: env.GetDuration("INSERT_INTERVAL_MS", 1000, time.Millisecond),
 		})
 		if err = i.Start(); err != nil {
 			return err
 		}
 
+		if listen := env.GetString("METRICS_LISTEN", "0.0.0.0:9100"); listen != "" {
+			go func() {
+				if err := metrics.ListenAndServe(listen); err != nil {
+					log.Error().Err(err).Msg("cannot serve metrics")
+				}
+			}()
+		}
+
 		c := make(chan os.Signal, 1)
 		done := make(chan struct{}, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		go func() {
 			<-c
 			i.Stop()
+			closeAPI()
 			conn.Close()
 			done <- struct{}{}
 		}()
@@ -0,0 +1,172 @@
+package inspect
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app/registry"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+var _ core.ContractRepository = (*staticContracts)(nil)
+
+// staticContracts is a read-only contract repository
+// built from interface descriptions in json files.
+type staticContracts struct {
+	definitions map[abi.TLBType]abi.TLBFieldsDesc
+	interfaces  []*core.ContractInterface
+	operations  []*core.ContractOperation
+}
+
+func contractFilenames(dir string) (res []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s directory", dir)
+	}
+
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".json") {
+			res = append(res, filepath.Join(dir, e.Name()))
+		}
+	}
+	return res, nil
+}
+
+func loadContracts(filenames []string) (*staticContracts, error) {
+	var descriptions []*abi.InterfaceDesc
+
+	for _, fn := range filenames {
+		var d []*abi.InterfaceDesc
+
+		j, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", fn)
+		}
+
+		if err := json.Unmarshal(j, &d); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal %s", fn)
+		}
+
+		descriptions = append(descriptions, d...)
+	}
+
+	definitions, interfaces, operations, err := registry.ParseInterfacesDesc(descriptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse interfaces")
+	}
+
+	return &staticContracts{
+		definitions: definitions,
+		interfaces:  interfaces,
+		operations:  operations,
+	}, nil
+}
+
+func (s *staticContracts) AddDefinition(context.Context, bun.IDB, abi.TLBType, abi.TLBFieldsDesc) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) UpdateDefinition(context.Context, bun.IDB, abi.TLBType, abi.TLBFieldsDesc) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) DeleteDefinition(context.Context, bun.IDB, abi.TLBType) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) GetDefinitions(context.Context) (map[abi.TLBType]abi.TLBFieldsDesc, error) {
+	return s.definitions, nil
+}
+
+func (s *staticContracts) AddInterface(context.Context, bun.IDB, *core.ContractInterface) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) UpdateInterface(context.Context, bun.IDB, *core.ContractInterface) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) DeleteInterface(context.Context, bun.IDB, abi.ContractName) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) GetInterface(_ context.Context, name abi.ContractName) (*core.ContractInterface, error) {
+	for _, i := range s.interfaces {
+		if i.Name == name {
+			return i, nil
+		}
+	}
+	return nil, core.ErrNotFound
+}
+
+func (s *staticContracts) GetInterfaces(context.Context) ([]*core.ContractInterface, error) {
+	return s.interfaces, nil
+}
+
+func (s *staticContracts) GetMethodDescription(ctx context.Context, name abi.ContractName, method string) (abi.GetMethodDesc, error) {
+	i, err := s.GetInterface(ctx, name)
+	if err != nil {
+		return abi.GetMethodDesc{}, err
+	}
+	for it := range i.GetMethodsDesc {
+		if i.GetMethodsDesc[it].Name == method {
+			return i.GetMethodsDesc[it], nil
+		}
+	}
+	return abi.GetMethodDesc{}, core.ErrNotFound
+}
+
+func (s *staticContracts) AddOperation(context.Context, bun.IDB, *core.ContractOperation) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) UpdateOperation(context.Context, bun.IDB, *core.ContractOperation) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) DeleteOperation(context.Context, bun.IDB, string) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) GetOperations(context.Context) ([]*core.ContractOperation, error) {
+	return s.operations, nil
+}
+
+func (s *staticContracts) GetOperationsByID(_ context.Context, t core.MessageType, interfaces []abi.ContractName, outgoing bool, id uint32) (ret []*core.ContractOperation, _ error) {
+	if len(interfaces) == 0 {
+		return nil, errors.Wrap(core.ErrNotFound, "no contract interfaces")
+	}
+
+	for _, op := range s.operations {
+		if op.MessageType != t || op.Outgoing != outgoing || op.OperationID != id {
+			continue
+		}
+		for _, i := range interfaces {
+			if op.ContractName == i {
+				ret = append(ret, op)
+				break
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+func (s *staticContracts) AddRevision(context.Context, bun.IDB, *core.ContractRevision) error {
+	return core.ErrNotImplemented
+}
+
+func (s *staticContracts) GetRevisions(context.Context, abi.ContractName) ([]*core.ContractRevision, error) {
+	return nil, core.ErrNotImplemented
+}
+
+func (s *staticContracts) GetRevision(context.Context, abi.ContractName, uint32) (*core.ContractRevision, error) {
+	return nil, core.ErrNotImplemented
+}
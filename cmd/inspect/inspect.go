@@ -0,0 +1,401 @@
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/fetcher"
+	"github.com/tonindexer/anton/internal/app/parser"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+const (
+	KindBody        = "body"
+	KindMessage     = "message"
+	KindAccount     = "account"
+	KindTransaction = "tx"
+)
+
+type result struct {
+	Kind        string             `json:"kind"`
+	Interfaces  []abi.ContractName `json:"interfaces,omitempty"`
+	Operation   string             `json:"operation_name,omitempty"`
+	Data        json.RawMessage    `json:"data,omitempty"`
+	Account     *core.AccountState `json:"account,omitempty"`
+	Message     *core.Message      `json:"message,omitempty"`
+	Transaction *core.Transaction  `json:"transaction,omitempty"`
+	Errors      []string           `json:"errors,omitempty"`
+}
+
+func (r *result) addError(err error) {
+	r.Errors = append(r.Errors, err.Error())
+}
+
+// decodeBOC accepts raw, hex or base64 encoded bag of cells.
+func decodeBOC(in []byte) (*cell.Cell, error) {
+	if c, err := cell.FromBOC(in); err == nil {
+		return c, nil
+	}
+
+	s := strings.TrimSpace(string(in))
+	if b, err := hex.DecodeString(s); err == nil {
+		return cell.FromBOC(b)
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return cell.FromBOC(b)
+	}
+	if b, err := base64.URLEncoding.DecodeString(s); err == nil {
+		return cell.FromBOC(b)
+	}
+
+	return nil, errors.Wrap(core.ErrInvalidArg, "cannot decode boc: expected raw, hex or base64 encoding")
+}
+
+func readBOC(fn string) (*cell.Cell, error) {
+	var (
+		in  []byte
+		err error
+	)
+	if fn == "" || fn == "-" {
+		in, err = io.ReadAll(os.Stdin)
+	} else {
+		in, err = os.ReadFile(fn)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read input")
+	}
+	return decodeBOC(bytes.TrimSpace(in))
+}
+
+func candidateInterfaces(ctx context.Context, repo core.ContractRepository, names []string) ([]abi.ContractName, error) {
+	var ret []abi.ContractName
+
+	if len(names) != 0 {
+		for _, n := range names {
+			if _, err := repo.GetInterface(ctx, abi.ContractName(n)); err != nil {
+				return nil, errors.Wrapf(err, "get '%s' interface", n)
+			}
+			ret = append(ret, abi.ContractName(n))
+		}
+		return ret, nil
+	}
+
+	interfaces, err := repo.GetInterfaces(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get interfaces")
+	}
+	for _, i := range interfaces {
+		ret = append(ret, i.Name)
+	}
+	return ret, nil
+}
+
+func operationID(body *cell.Cell) (uint32, error) {
+	op, err := body.BeginParse().LoadUInt(32)
+	if err != nil {
+		return 0, errors.Wrap(err, "load operation id")
+	}
+	return uint32(op), nil
+}
+
+func inspectBody(ctx context.Context, repo core.ContractRepository, body *cell.Cell, t core.MessageType, candidates []abi.ContractName) *result {
+	ret := &result{Kind: KindBody}
+
+	opID, err := operationID(body)
+	if err != nil {
+		ret.addError(err)
+		return ret
+	}
+
+	for _, outgoing := range []bool{false, true} {
+		operations, err := repo.GetOperationsByID(ctx, t, candidates, outgoing, opID)
+		if err != nil {
+			ret.addError(errors.Wrap(err, "get contract operations"))
+			return ret
+		}
+
+		for _, op := range operations {
+			parsed, err := op.Schema.FromCell(body)
+			if err != nil {
+				ret.addError(errors.Wrapf(err, "parse '%s' operation of '%s'", op.OperationName, op.ContractName))
+				continue
+			}
+			ret.Data, err = json.Marshal(parsed)
+			if err != nil {
+				ret.addError(errors.Wrap(err, "json marshal parsed payload"))
+				continue
+			}
+			ret.Interfaces = []abi.ContractName{op.ContractName}
+			ret.Operation = op.OperationName
+			return ret
+		}
+	}
+
+	if len(ret.Errors) == 0 {
+		ret.addError(errors.Wrapf(app.ErrImpossibleParsing, "unknown operation 0x%x", opID))
+	}
+
+	return ret
+}
+
+func parseMessage(ctx context.Context, p app.ParserService, msg *core.Message, candidates []abi.ContractName) error {
+	if len(msg.Body) == 0 {
+		return nil
+	}
+
+	msg.SrcState = &core.AccountState{Address: msg.SrcAddress, Types: candidates}
+	msg.DstState = &core.AccountState{Address: msg.DstAddress, Types: candidates}
+	defer func() { msg.SrcState, msg.DstState = nil, nil }()
+
+	if err := p.ParseMessagePayload(ctx, msg); err != nil {
+		msg.Error = err.Error()
+		return err
+	}
+	return nil
+}
+
+func inspectMessage(ctx context.Context, p app.ParserService, c *cell.Cell, candidates []abi.ContractName) *result {
+	ret := &result{Kind: KindMessage}
+
+	var raw tlb.Message
+	if err := tlb.LoadFromCell(&raw, c.BeginParse()); err != nil {
+		ret.addError(errors.Wrap(err, "load message from cell"))
+		return ret
+	}
+
+	msg, err := fetcher.MapMessage(&tlb.Transaction{}, raw)
+	if err != nil {
+		ret.addError(errors.Wrap(err, "map message"))
+		return ret
+	}
+	ret.Message = msg
+
+	if err := parseMessage(ctx, p, msg, candidates); err != nil {
+		ret.addError(err)
+	}
+	ret.Operation, ret.Data = msg.OperationName, msg.DataJSON
+	for _, n := range []abi.ContractName{msg.SrcContract, msg.DstContract} {
+		if n != "" {
+			ret.Interfaces = append(ret.Interfaces, n)
+		}
+	}
+
+	return ret
+}
+
+func inspectTransaction(ctx context.Context, p app.ParserService, c *cell.Cell, workchain int32, candidates []abi.ContractName) *result {
+	ret := &result{Kind: KindTransaction}
+
+	var raw tlb.Transaction
+	if err := tlb.LoadFromCell(&raw, c.BeginParse()); err != nil {
+		ret.addError(errors.Wrap(err, "load transaction from cell"))
+		return ret
+	}
+	raw.Hash = c.Hash()
+
+	tx, err := fetcher.MapTransaction(&ton.BlockIDExt{Workchain: workchain}, &raw)
+	if err != nil {
+		ret.addError(errors.Wrap(err, "map transaction"))
+		return ret
+	}
+	ret.Transaction = tx
+
+	messages := tx.OutMsg
+	if tx.InMsg != nil {
+		messages = append([]*core.Message{tx.InMsg}, messages...)
+	}
+	for _, msg := range messages {
+		if err := parseMessage(ctx, p, msg, candidates); err != nil {
+			ret.addError(errors.Wrapf(err, "parse message %x", msg.Hash))
+		}
+	}
+	if tx.InMsg != nil {
+		ret.Operation, ret.Data = tx.InMsg.OperationName, tx.InMsg.DataJSON
+		if tx.InMsg.DstContract != "" {
+			ret.Interfaces = append(ret.Interfaces, tx.InMsg.DstContract)
+		}
+	}
+
+	return ret
+}
+
+func inspectAccount(ctx context.Context, p app.ParserService, c *cell.Cell) *result {
+	ret := &result{Kind: KindAccount}
+
+	var st tlb.AccountState
+	if err := st.LoadFromCell(c.BeginParse()); err != nil {
+		ret.addError(errors.Wrap(err, "load account state from cell"))
+		return ret
+	}
+
+	raw := &tlb.Account{IsActive: st.IsValid, State: &st, LastTxLT: st.LastTransactionLT}
+	if st.Status == tlb.AccountStatusActive && st.StateInit != nil {
+		raw.Code, raw.Data = st.StateInit.Code, st.StateInit.Data
+	}
+
+	acc := fetcher.MapAccount(&ton.BlockIDExt{}, raw)
+	if st.Address != nil {
+		acc.Workchain = int32(st.Address.Workchain())
+	}
+	ret.Account = acc
+
+	if raw.Code == nil {
+		ret.addError(errors.Wrap(app.ErrImpossibleParsing, "no account code"))
+		return ret
+	}
+
+	var err error
+	acc.GetMethodHashes, err = abi.GetMethodHashes(raw.Code)
+	if err != nil {
+		ret.addError(errors.Wrap(err, "get method hashes"))
+	}
+
+	noOthers := func(context.Context, addr.Address) (*core.AccountState, error) {
+		return nil, errors.Wrap(core.ErrNotFound, "other accounts are not available offline")
+	}
+	if err := p.ParseAccountData(ctx, acc, noOthers); err != nil {
+		ret.addError(errors.Wrap(err, "parse account data"))
+	}
+	ret.Interfaces = acc.Types
+
+	for _, executions := range acc.ExecutedGetMethods {
+		for it := range executions {
+			if executions[it].Error != "" {
+				ret.addError(fmt.Errorf("%s: %s", executions[it].Name, executions[it].Error))
+			}
+		}
+	}
+
+	return ret
+}
+
+func readConfig(fn string) (*cell.Cell, error) {
+	if fn == "" {
+		return nil, errors.Wrap(core.ErrInvalidArg, "blockchain config boc is required to execute get-methods")
+	}
+	c, err := readBOC(fn)
+	if err != nil {
+		return nil, errors.Wrap(err, "read blockchain config")
+	}
+	return c, nil
+}
+
+var Command = &cli.Command{
+	Name:      "inspect",
+	Usage:     "Decodes a bag of cells offline using known contract interfaces",
+	ArgsUsage: "[file.boc]",
+
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "kind",
+			Aliases:  []string{"k"},
+			Usage:    fmt.Sprintf("kind of the given boc: %s, %s, %s or %s", KindBody, KindMessage, KindAccount, KindTransaction),
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "contracts-dir",
+			Usage: "directory with contract interface descriptions",
+			Value: "/var/anton/known",
+		},
+		&cli.StringSliceFlag{
+			Name:  "contracts",
+			Usage: "contract interface description files to use instead of the contracts directory",
+		},
+		&cli.StringSliceFlag{
+			Name:    "contract-name",
+			Aliases: []string{"c"},
+			Usage:   "limit message parsing to the given contract interfaces",
+		},
+		&cli.StringFlag{
+			Name:  "message-type",
+			Usage: "message type used to match operations of a message body",
+			Value: string(core.Internal),
+		},
+		&cli.IntFlag{
+			Name:  "workchain",
+			Usage: "workchain of the transaction account",
+			Value: 0,
+		},
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "file with blockchain config boc, required to execute get-methods of account states",
+		},
+	},
+
+	Action: func(ctx *cli.Context) error {
+		filenames := ctx.StringSlice("contracts")
+		if len(filenames) == 0 {
+			var err error
+			filenames, err = contractFilenames(ctx.String("contracts-dir"))
+			if err != nil {
+				return err
+			}
+		}
+		if len(filenames) == 0 {
+			return fmt.Errorf("json files are not found inside contracts directory")
+		}
+
+		repo, err := loadContracts(filenames)
+		if err != nil {
+			return err
+		}
+
+		candidates, err := candidateInterfaces(ctx.Context, repo, ctx.StringSlice("contract-name"))
+		if err != nil {
+			return err
+		}
+
+		c, err := readBOC(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		cfg := cell.BeginCell().EndCell()
+		if ctx.String("kind") == KindAccount {
+			cfg, err = readConfig(ctx.String("config"))
+			if err != nil {
+				return err
+			}
+		}
+		p := parser.NewService(&app.ParserConfig{BlockchainConfig: cfg, ContractRepo: repo})
+
+		var ret *result
+		switch k := ctx.String("kind"); k {
+		case KindBody:
+			t := core.MessageType(strings.ToUpper(ctx.String("message-type")))
+			ret = inspectBody(ctx.Context, repo, c, t, candidates)
+		case KindMessage:
+			ret = inspectMessage(ctx.Context, p, c, candidates)
+		case KindAccount:
+			ret = inspectAccount(ctx.Context, p, c)
+		case KindTransaction:
+			ret = inspectTransaction(ctx.Context, p, c, int32(ctx.Int("workchain")), candidates)
+		default:
+			return errors.Wrapf(core.ErrInvalidArg, "unknown boc kind '%s'", k)
+		}
+
+		out, err := json.MarshalIndent(ret, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "json marshal result")
+		}
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
@@ -0,0 +1,148 @@
+package inspect
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+const (
+	// tx hash e5782dd2b1e2186038c1f92db2cdb709bd12eba25a295ec4db9561aa3928c317
+	jettonMintBOC = `te6cckECBgEAAY4AAWMAAAAVpRNS/gQ80YGAFSIq9XSS6um704WS4suGgdULW5b13fha77/GRjN77mgoZVPxAQEBbReNRRmlE1L+BDzRgUHc1lACADvjYKkD7+YYy/VvGEAr2NDd0ROzABirjBhcbeEorNtYoX14QAYCAZdJKpgbgBB56R97rZGAXZwg4u1afeJ934d0DPUZtObWsRZvvxY00AHfGwVIH38wxl+reMIBXsaG7oidmADFXGDC428JRWbaxQF9eEAgAwJf0zuweeADvjYKkD7+YYy/VvGEAr2NDd0ROzABirjBhcbeEorNtYqCVrO8SiAvrwgEBQQAl6iXCtCADvjYKkD7+YYy/VvGEAr2NDd0ROzABirjBhcbeEorNtYwAd8bBUgffzDGX6t4wgFexobuiJ2YAMVcYMLjbwlFZtrFAJiWgCAAl+kWu++ADvjYKkD7+YYy/VvGEAr2NDd0ROzABirjBhcbeEorNtYwAd8bBUgffzDGX6t4wgFexobuiJ2YAMVcYMLjbwlFZtrFAJiWgCAnWbE8`
+
+	// tx hash 8ad6febf40eed096d8d911466c069ebc693e4d5e641a4e806a19d5831233141b
+	jettonBurnBOC = `te6ccuEBAQEAMwBmAGFZXwe8AAAAAACFYI8walQ4AJvi30153Ex53ULaIU/S0hqruxuRQNfFygS/4vFtl92PwofAGw==`
+
+	nftChangeContentBOC = `te6cckEBBQEAxQACGAAAAAQAAAAAAAAAAAIBAEsAAABkgAe4JggE07o9i50C5vJ2aQiIbUYwl8/YMW27aEtS1YEfMAIABAMAaGh0dHBzOi8vcy5nZXRnZW1zLmlvL25mdC9jLzYzYTgwMDVlY2MxM2M0OTE0YjMxNGIyMy8AogFodHRwczovL3MuZ2V0Z2Vtcy5pby9uZnQvYy82M2E4MDA1ZWNjMTNjNDkxNGIzMTRiMjMvZWRpdC9tZXRhLTE2NzIyODIyMDQ1ODkuanNvbml2vhQ=`
+)
+
+func mustBase64(t *testing.T, s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	require.Nil(t, err)
+	return b
+}
+
+func TestDecodeBOC(t *testing.T) {
+	raw := mustBase64(t, jettonBurnBOC)
+
+	c, err := cell.FromBOC(raw)
+	require.Nil(t, err)
+
+	var testCases = []*struct {
+		name string
+		in   []byte
+		err  error
+	}{
+		{name: "raw", in: raw},
+		{name: "hex", in: []byte(hex.EncodeToString(raw))},
+		{name: "base64", in: []byte(jettonBurnBOC)},
+		{name: "base64 url", in: []byte(base64.URLEncoding.EncodeToString(raw))},
+		{name: "surrounding spaces", in: []byte(" " + jettonBurnBOC + "\n")},
+		{name: "invalid", in: []byte("not a boc"), err: core.ErrInvalidArg},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeBOC(test.in)
+			if test.err != nil {
+				require.True(t, errors.Is(err, test.err), err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, c.Hash(), got.Hash())
+		})
+	}
+}
+
+func TestInspectBody(t *testing.T) {
+	ctx := context.Background()
+
+	filenames, err := contractFilenames("../../abi/known")
+	require.Nil(t, err)
+
+	repo, err := loadContracts(filenames)
+	require.Nil(t, err)
+
+	var testCases = []*struct {
+		name       string
+		boc        string
+		interfaces []string
+		operation  string
+		contract   abi.ContractName
+		data       string
+		err        error
+	}{
+		{
+			name:       "jetton mint",
+			boc:        jettonMintBOC,
+			interfaces: []string{"jetton_minter"},
+			operation:  "jetton_mint",
+			contract:   "jetton_minter",
+			data:       `{"query_id":11894942291761877377,"to_address":"EQCpEVerpJdXTd6cLJcWXDQOqFrct67vwtd9_jIxm99zQZV6","amount":"850000000","master_msg":{"op_code":395134233,"query_id":11894942291761877377,"jetton_amount":"500000000"}}`,
+		},
+		{
+			name:       "jetton burn with optional fields",
+			boc:        jettonBurnBOC,
+			interfaces: []string{"jetton_wallet"},
+			operation:  "jetton_burn",
+			contract:   "jetton_wallet",
+			data:       `{"query_id":8741007,"amount":"435523","response_destination":"EQBN8W-mvO4mPO6hbRCn6WkNVd2NyKBr4uUCX_F4tsvux5oO"}`,
+		},
+		{
+			name:       "nft collection change content",
+			boc:        nftChangeContentBOC,
+			interfaces: []string{"nft_collection"},
+			operation:  "nft_collection_change_content",
+			contract:   "nft_collection",
+			data:       `{"query_id":0,"content":"te6cckEBAwEAjQACAAECAKIBaHR0cHM6Ly9zLmdldGdlbXMuaW8vbmZ0L2MvNjNhODAwNWVjYzEzYzQ5MTRiMzE0YjIzL2VkaXQvbWV0YS0xNjcyMjgyMjA0NTg5Lmpzb24AaGh0dHBzOi8vcy5nZXRnZW1zLmlvL25mdC9jLzYzYTgwMDVlY2MxM2M0OTE0YjMxNGIyMy+TM8WI"}`,
+		},
+		{
+			name:       "jetton mint to other interface",
+			boc:        jettonMintBOC,
+			interfaces: []string{"nft_item"},
+			err:        app.ErrImpossibleParsing,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := decodeBOC([]byte(test.boc))
+			require.Nil(t, err)
+
+			candidates, err := candidateInterfaces(ctx, repo, test.interfaces)
+			require.Nil(t, err)
+
+			res := inspectBody(ctx, repo, c, core.Internal, candidates)
+			require.Equal(t, KindBody, res.Kind)
+
+			if test.err != nil {
+				require.Len(t, res.Errors, 1)
+				require.Contains(t, res.Errors[0], test.err.Error())
+				require.Empty(t, res.Operation)
+				return
+			}
+
+			require.Empty(t, res.Errors)
+			require.Equal(t, test.operation, res.Operation)
+			require.Equal(t, []abi.ContractName{test.contract}, res.Interfaces)
+			require.JSONEq(t, test.data, string(res.Data))
+		})
+	}
+}
+
+func TestCandidateInterfaces_Unknown(t *testing.T) {
+	repo, err := loadContracts([]string{"../../abi/known/tep74_jetton.json"})
+	require.Nil(t, err)
+
+	_, err = candidateInterfaces(context.Background(), repo, []string{"unknown_contract"})
+	require.True(t, errors.Is(err, core.ErrNotFound), err)
+}
@@ -1,20 +1,19 @@
 package rescan
 
 import (
-	"fmt"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 
 	"github.com/allisson/go-env"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
-	"github.com/xssnick/tonutils-go/liteclient"
-	"github.com/xssnick/tonutils-go/ton"
 
 	"github.com/tonindexer/anton/abi"
 	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/liteserver"
 	"github.com/tonindexer/anton/internal/app/parser"
 	"github.com/tonindexer/anton/internal/app/rescan"
 	"github.com/tonindexer/anton/internal/core/repository"
@@ -23,6 +22,8 @@ import (
 	"github.com/tonindexer/anton/internal/core/repository/contract"
 	"github.com/tonindexer/anton/internal/core/repository/msg"
 	rescanRepository "github.com/tonindexer/anton/internal/core/repository/rescan"
+	"github.com/tonindexer/anton/internal/core/repository/skip"
+	"github.com/tonindexer/anton/metrics"
 )
 
 var Command = &cli.Command{
@@ -30,6 +31,8 @@ var Command = &cli.Command{
 
 	Usage: "Updates account states and messages data",
 
+	Subcommands: cli.Commands{tasksCommand},
+
 	Action: func(ctx *cli.Context) error {
 		chURL := env.GetString("DB_CH_URL", "")
 		pgURL := env.GetString("DB_PG_URL", "")
@@ -58,17 +61,21 @@ var Command = &cli.Command{
 			return errors.Wrap(err, "get definitions")
 		}
 
-		client := liteclient.NewConnectionPool()
-		api := ton.NewAPIClient(client, ton.ProofCheckPolicyUnsafe).WithRetry()
-		for _, addr := range strings.Split(env.GetString("LITESERVERS", ""), ",") {
-			split := strings.Split(addr, "|")
-			if len(split) != 2 {
-				return fmt.Errorf("wrong server address format '%s'", addr)
-			}
-			host, key := split[0], split[1]
-			if err := client.AddConnection(ctx.Context, host, key); err != nil {
-				return errors.Wrapf(err, "cannot add connection with %s host and %s key", host, key)
-			}
+		err = skip.Watch(ctx.Context, skip.NewRepository(conn.PG), time.Minute)
+		if err != nil {
+			return errors.Wrap(err, "cannot load skipped addresses")
+		}
+
+		servers, err := liteserver.ParseServers(env.GetString("LITESERVERS", ""))
+		if err != nil {
+			return err
+		}
+		api, err := liteserver.NewManager(ctx.Context, &app.LiteserverConfig{
+			Servers:      servers,
+			GlobalConfig: env.GetString("LITESERVERS_GLOBAL_CONFIG", ""),
+		})
+		if err != nil {
+			return errors.Wrap(err, "cannot connect to liteservers")
 		}
 		bcConfig, err := app.GetBlockchainConfig(ctx.Context, api)
 		if err != nil {
@@ -87,18 +94,28 @@ var Command = &cli.Command{
 			MessageRepo:  msg.NewRepository(conn.CH, conn.PG),
 			Parser:       p,
 			Workers:      env.GetInt("RESCAN_WORKERS", 4),
+			Tasks:        env.GetInt("RESCAN_TASKS", 2),
 			SelectLimit:  env.GetInt("RESCAN_SELECT_LIMIT", 1000),
 		})
 		if err = i.Start(); err != nil {
 			return err
 		}
 
+		if listen := env.GetString("METRICS_LISTEN", "0.0.0.0:9100"); listen != "" {
+			go func() {
+				if err := metrics.ListenAndServe(listen); err != nil {
+					log.Error().Err(err).Msg("cannot serve metrics")
+				}
+			}()
+		}
+
 		c := make(chan os.Signal, 1)
 		done := make(chan struct{}, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		go func() {
 			<-c
 			i.Stop()
+			api.Close()
 			conn.Close()
 			done <- struct{}{}
 		}()
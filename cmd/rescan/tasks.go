@@ -0,0 +1,354 @@
+package rescan
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/allisson/go-env"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+	rescanRepository "github.com/tonindexer/anton/internal/core/repository/rescan"
+)
+
+func dbConnect() (*bun.DB, error) {
+	pg := bun.NewDB(
+		sql.OpenDB(
+			pgdriver.NewConnector(
+				pgdriver.WithDSN(env.GetString("DB_PG_URL", "")),
+			),
+		),
+		pgdialect.New(),
+	)
+	if err := pg.Ping(); err != nil {
+		return nil, errors.Wrapf(err, "cannot ping postgresql")
+	}
+	return pg, nil
+}
+
+func parseTaskID(ctx *cli.Context, args int) (int, error) {
+	if ctx.Args().Len() != args {
+		cli.ShowSubcommandHelpAndExit(ctx, 1)
+	}
+
+	id, err := strconv.Atoi(ctx.Args().First())
+	if err != nil {
+		return 0, errors.Wrapf(core.ErrInvalidArg, "parse task id (%s)", err.Error())
+	}
+	return id, nil
+}
+
+// withRepo runs the given function with a rescan repository connected to postgres
+func withRepo(f func(repo core.RescanRepository) error) error {
+	pg, err := dbConnect()
+	if err != nil {
+		return err
+	}
+	defer pg.Close()
+
+	return f(rescanRepository.NewRepository(pg))
+}
+
+func taskAction(f func(ctx *cli.Context, repo core.RescanRepository, id int) error) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		id, err := parseTaskID(ctx, 1)
+		if err != nil {
+			return err
+		}
+		return withRepo(func(repo core.RescanRepository) error {
+			return f(ctx, repo, id)
+		})
+	}
+}
+
+func printTasks(tasks []*core.RescanTask) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tTYPE\tCONTRACT\tSTATUS\tPRIORITY\tPROGRESS\tERRORS\tCHECKPOINT\tUPDATED")
+	for _, t := range tasks {
+		contract := string(t.ContractName)
+		if contract == "" {
+			contract = "-"
+		}
+		checkpoint := "-"
+		switch {
+		case t.LastAddress != nil:
+			checkpoint = fmt.Sprintf("%s/%d", t.LastAddress.Base64(), t.LastTxLt)
+		case t.LastHash != nil:
+			checkpoint = hex.EncodeToString(t.LastHash)
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d/%d (%.1f%%)\t%d\t%s\t%s\n",
+			t.ID, t.Type, contract, t.Status, t.Priority,
+			t.Processed, t.Total, t.Progress*100, t.ErrorsCount,
+			checkpoint, t.UpdatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func readAddresses(ctx *cli.Context) (ret []*addr.Address, err error) {
+	list := ctx.StringSlice("address")
+
+	if f := ctx.String("addresses-file"); f != "" {
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "read addresses file")
+		}
+		list = append(list, strings.Fields(string(raw))...)
+	}
+
+	for _, s := range list {
+		a := new(addr.Address)
+		if err := a.UnmarshalText([]byte(s)); err != nil {
+			return nil, errors.Wrapf(core.ErrInvalidArg, "parse address %s (%s)", s, err.Error())
+		}
+		ret = append(ret, a)
+	}
+	return ret, nil
+}
+
+func readHashes(ctx *cli.Context) (ret [][]byte, err error) {
+	for _, s := range ctx.StringSlice("hash") {
+		h, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, errors.Wrapf(core.ErrInvalidArg, "parse message hash %s (%s)", s, err.Error())
+		}
+		ret = append(ret, h)
+	}
+	return ret, nil
+}
+
+func readTime(ctx *cli.Context, name string) (time.Time, error) {
+	s := ctx.String(name)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(core.ErrInvalidArg, "parse %s (%s)", name, err.Error())
+	}
+	return t.UTC(), nil
+}
+
+// newTargetedTask makes rescan_accounts or rescan_messages task from the command flags
+func newTargetedTask(ctx *cli.Context) (*core.RescanTask, error) {
+	var (
+		task = &core.RescanTask{Priority: ctx.Int("priority")}
+		err  error
+	)
+
+	if ctx.Args().Len() != 1 {
+		cli.ShowSubcommandHelpAndExit(ctx, 1)
+	}
+	switch ctx.Args().First() {
+	case "accounts":
+		task.Type = core.RescanAccounts
+	case "messages":
+		task.Type = core.RescanMessages
+	default:
+		return nil, errors.Wrapf(core.ErrInvalidArg, "unknown rescan target '%s'", ctx.Args().First())
+	}
+
+	if task.Addresses, err = readAddresses(ctx); err != nil {
+		return nil, err
+	}
+	if task.MessageHashes, err = readHashes(ctx); err != nil {
+		return nil, err
+	}
+	if len(task.MessageHashes) > 0 && task.Type != core.RescanMessages {
+		return nil, errors.Wrap(core.ErrInvalidArg, "message hashes can be set only for messages rescan")
+	}
+
+	task.FromMasterSeqNo = uint32(ctx.Uint("from-block"))
+	task.ToMasterSeqNo = uint32(ctx.Uint("to-block"))
+	if task.ToMasterSeqNo != 0 && task.ToMasterSeqNo < task.FromMasterSeqNo {
+		return nil, errors.Wrap(core.ErrInvalidArg, "to-block is less than from-block")
+	}
+
+	if task.FromTime, err = readTime(ctx, "from-time"); err != nil {
+		return nil, err
+	}
+	if task.ToTime, err = readTime(ctx, "to-time"); err != nil {
+		return nil, err
+	}
+	if d := ctx.Duration("since"); d > 0 {
+		task.FromTime = time.Now().UTC().Add(-d)
+	}
+
+	if len(task.Addresses) == 0 && len(task.MessageHashes) == 0 &&
+		task.FromMasterSeqNo == 0 && task.ToMasterSeqNo == 0 &&
+		task.FromTime.IsZero() && task.ToTime.IsZero() {
+		return nil, errors.Wrap(core.ErrInvalidArg, "rescan scope is not set")
+	}
+
+	return task, nil
+}
+
+var tasksCommand = &cli.Command{
+	Name:  "tasks",
+	Usage: "Manages rescan tasks",
+
+	Subcommands: cli.Commands{
+		{
+			Name:      "add",
+			Usage:     "Adds a rescan task for account states or messages in the given addresses, blocks, time or hashes",
+			ArgsUsage: "accounts|messages",
+
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "address",
+					Usage:   "rescan account states or messages of the address",
+					Aliases: []string{"a"},
+				},
+				&cli.StringFlag{
+					Name:  "addresses-file",
+					Usage: "file with addresses separated by spaces or new lines",
+				},
+				&cli.UintFlag{
+					Name:  "from-block",
+					Usage: "first masterchain block seq_no",
+				},
+				&cli.UintFlag{
+					Name:  "to-block",
+					Usage: "last masterchain block seq_no",
+				},
+				&cli.StringFlag{
+					Name:  "from-time",
+					Usage: "start of the time window in RFC3339 format",
+				},
+				&cli.StringFlag{
+					Name:  "to-time",
+					Usage: "end of the time window in RFC3339 format",
+				},
+				&cli.DurationFlag{
+					Name:  "since",
+					Usage: "start of the time window relative to now, e.g., 48h",
+				},
+				&cli.StringSliceFlag{
+					Name:  "hash",
+					Usage: "hex hash of the message to rescan",
+				},
+				&cli.IntFlag{
+					Name:    "priority",
+					Usage:   "task priority, tasks with higher priority are taken first",
+					Aliases: []string{"p"},
+				},
+			},
+
+			Action: func(ctx *cli.Context) error {
+				task, err := newTargetedTask(ctx)
+				if err != nil {
+					return err
+				}
+
+				pg, err := dbConnect()
+				if err != nil {
+					return err
+				}
+				defer pg.Close()
+
+				if err := rescanRepository.NewRepository(pg).AddRescanTask(ctx.Context, pg, task); err != nil {
+					return err
+				}
+				fmt.Printf("rescan task %d is added\n", task.ID)
+				return nil
+			},
+		},
+		{
+			Name:  "ls",
+			Usage: "Lists unfinished rescan tasks",
+
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "all",
+					Usage:   "include finished and canceled tasks",
+					Aliases: []string{"a"},
+				},
+			},
+
+			Action: func(ctx *cli.Context) error {
+				return withRepo(func(repo core.RescanRepository) error {
+					tasks, err := repo.GetRescanTasks(ctx.Context, ctx.Bool("all"))
+					if err != nil {
+						return err
+					}
+					return printTasks(tasks)
+				})
+			},
+		},
+		{
+			Name:      "show",
+			Usage:     "Shows the rescan task with its last error",
+			ArgsUsage: "id",
+
+			Action: taskAction(func(ctx *cli.Context, repo core.RescanRepository, id int) error {
+				task, err := repo.GetRescanTask(ctx.Context, id)
+				if err != nil {
+					return err
+				}
+				if err := printTasks([]*core.RescanTask{task}); err != nil {
+					return err
+				}
+				if task.LastError != "" {
+					fmt.Printf("\nlast error: %s\n", task.LastError)
+				}
+				return nil
+			}),
+		},
+		{
+			Name:      "pause",
+			Usage:     "Pauses the rescan task, its checkpoint is kept",
+			ArgsUsage: "id",
+
+			Action: taskAction(func(ctx *cli.Context, repo core.RescanRepository, id int) error {
+				return repo.SetRescanTaskPaused(ctx.Context, id, true)
+			}),
+		},
+		{
+			Name:      "resume",
+			Usage:     "Resumes the paused rescan task from its checkpoint",
+			ArgsUsage: "id",
+
+			Action: taskAction(func(ctx *cli.Context, repo core.RescanRepository, id int) error {
+				return repo.SetRescanTaskPaused(ctx.Context, id, false)
+			}),
+		},
+		{
+			Name:      "cancel",
+			Usage:     "Cancels the rescan task, it is never taken again",
+			ArgsUsage: "id",
+
+			Action: taskAction(func(ctx *cli.Context, repo core.RescanRepository, id int) error {
+				return repo.CancelRescanTask(ctx.Context, id)
+			}),
+		},
+		{
+			Name:      "priority",
+			Usage:     "Sets the rescan task priority, tasks with higher priority are taken first",
+			ArgsUsage: "id priority",
+
+			Action: func(ctx *cli.Context) error {
+				id, err := parseTaskID(ctx, 2)
+				if err != nil {
+					return err
+				}
+				priority, err := strconv.Atoi(ctx.Args().Get(1))
+				if err != nil {
+					return errors.Wrapf(core.ErrInvalidArg, "parse priority (%s)", err.Error())
+				}
+				return withRepo(func(repo core.RescanRepository) error {
+					return repo.SetRescanTaskPriority(ctx.Context, id, priority)
+				})
+			},
+		},
+	},
+}
@@ -0,0 +1,178 @@
+package skip
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/allisson/go-env"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/urfave/cli/v2"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/repository/skip"
+)
+
+func dbConnect() (*bun.DB, error) {
+	pg := bun.NewDB(
+		sql.OpenDB(
+			pgdriver.NewConnector(
+				pgdriver.WithDSN(env.GetString("DB_PG_URL", "")),
+			),
+		),
+		pgdialect.New(),
+	)
+	if err := pg.Ping(); err != nil {
+		return nil, errors.Wrapf(err, "cannot ping postgresql")
+	}
+	return pg, nil
+}
+
+func parseAddress(ctx *cli.Context) (*addr.Address, error) {
+	if ctx.Args().Len() != 1 {
+		cli.ShowSubcommandHelpAndExit(ctx, 1)
+	}
+
+	a := new(addr.Address)
+	if err := a.UnmarshalText([]byte(ctx.Args().First())); err != nil {
+		return nil, errors.Wrapf(core.ErrInvalidArg, "parse address (%s)", err.Error())
+	}
+	return a, nil
+}
+
+func parseScopes(scopes []string) (ret []core.SkipScope, err error) {
+	for _, s := range scopes {
+		switch scope := core.SkipScope(s); scope {
+		case core.SkipFetching, core.SkipEmulation, core.SkipParsing:
+			ret = append(ret, scope)
+		default:
+			return nil, errors.Wrapf(core.ErrInvalidArg, "unknown skip scope '%s'", s)
+		}
+	}
+	return ret, nil
+}
+
+var Command = &cli.Command{
+	Name:  "skip",
+	Usage: "Manages addresses, which data is not fetched, emulated or parsed",
+
+	Subcommands: cli.Commands{
+		{
+			Name:      "add",
+			Usage:     "Adds the address to the skip list or updates its rule",
+			ArgsUsage: "address",
+
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:    "scope",
+					Usage:   "what is skipped for the address: fetch, emulation or parsing",
+					Aliases: []string{"s"},
+					Value:   cli.NewStringSlice(string(core.SkipFetching), string(core.SkipEmulation), string(core.SkipParsing)),
+				},
+				&cli.StringFlag{
+					Name:     "reason",
+					Usage:    "why the address is skipped",
+					Aliases:  []string{"r"},
+					Required: true,
+				},
+				&cli.DurationFlag{
+					Name:  "expires-in",
+					Usage: "remove the rule after the given duration, e.g., 24h",
+				},
+			},
+
+			Action: func(ctx *cli.Context) error {
+				a, err := parseAddress(ctx)
+				if err != nil {
+					return err
+				}
+				scopes, err := parseScopes(ctx.StringSlice("scope"))
+				if err != nil {
+					return err
+				}
+
+				rule := &core.SkippedAddress{
+					Address: *a,
+					Scopes:  scopes,
+					Reason:  ctx.String("reason"),
+				}
+				if d := ctx.Duration("expires-in"); d > 0 {
+					expires := time.Now().Add(d)
+					rule.ExpiresAt = &expires
+				}
+
+				pg, err := dbConnect()
+				if err != nil {
+					return err
+				}
+				defer pg.Close()
+
+				return skip.NewRepository(pg).AddSkippedAddress(ctx.Context, rule)
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "Removes the address from the skip list",
+			ArgsUsage: "address",
+
+			Action: func(ctx *cli.Context) error {
+				a, err := parseAddress(ctx)
+				if err != nil {
+					return err
+				}
+
+				pg, err := dbConnect()
+				if err != nil {
+					return err
+				}
+				defer pg.Close()
+
+				return skip.NewRepository(pg).DelSkippedAddress(ctx.Context, *a)
+			},
+		},
+		{
+			Name:  "ls",
+			Usage: "Lists skipped addresses",
+
+			Action: func(ctx *cli.Context) error {
+				pg, err := dbConnect()
+				if err != nil {
+					return err
+				}
+				defer pg.Close()
+
+				list, err := skip.NewRepository(pg).GetSkippedAddresses(ctx.Context)
+				if err != nil {
+					return err
+				}
+
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				_, _ = fmt.Fprintln(w, "ADDRESS\tSCOPES\tEXPIRES\tREASON")
+				for _, s := range list {
+					var scopes []string
+					for _, scope := range s.Scopes {
+						scopes = append(scopes, string(scope))
+					}
+
+					expires := "never"
+					switch {
+					case s.Expired(time.Now()):
+						expires = "expired"
+					case s.ExpiresAt != nil:
+						expires = s.ExpiresAt.Format(time.RFC3339)
+					}
+
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Address.Base64(), strings.Join(scopes, ","), expires, s.Reason)
+				}
+				return w.Flush()
+			},
+		},
+	},
+}
@@ -1,24 +1,25 @@
 package web
 
 import (
-	"fmt"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 
 	"github.com/allisson/go-env"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
-	"github.com/xssnick/tonutils-go/liteclient"
-	"github.com/xssnick/tonutils-go/ton"
 
 	"github.com/tonindexer/anton/abi"
 	"github.com/tonindexer/anton/internal/api/http"
 	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/liteserver"
+	"github.com/tonindexer/anton/internal/app/parser"
 	"github.com/tonindexer/anton/internal/app/query"
+	"github.com/tonindexer/anton/internal/app/registry"
 	"github.com/tonindexer/anton/internal/core/repository"
 	"github.com/tonindexer/anton/internal/core/repository/contract"
+	"github.com/tonindexer/anton/internal/core/repository/skip"
 )
 
 var Command = &cli.Command{
@@ -35,7 +36,9 @@ var Command = &cli.Command{
 			return errors.Wrap(err, "cannot connect to a database")
 		}
 
-		def, err := contract.NewRepository(conn.PG).GetDefinitions(ctx.Context)
+		contractRepo := contract.NewRepository(conn.PG)
+
+		def, err := contractRepo.GetDefinitions(ctx.Context)
 		if err != nil {
 			return errors.Wrap(err, "get definitions")
 		}
@@ -44,22 +47,37 @@ var Command = &cli.Command{
 			return errors.Wrap(err, "get definitions")
 		}
 
-		client := liteclient.NewConnectionPool()
-		api := ton.NewAPIClient(client, ton.ProofCheckPolicyUnsafe).WithRetry()
-		for _, addr := range strings.Split(env.GetString("LITESERVERS", ""), ",") {
-			split := strings.Split(addr, "|")
-			if len(split) != 2 {
-				return fmt.Errorf("wrong server address format '%s'", addr)
-			}
-			host, key := split[0], split[1]
-			if err := client.AddConnection(ctx.Context, host, key); err != nil {
-				return errors.Wrapf(err, "cannot add connection with %s host and %s key", host, key)
-			}
+		err = skip.Watch(ctx.Context, skip.NewRepository(conn.PG), time.Minute)
+		if err != nil {
+			return errors.Wrap(err, "cannot load skipped addresses")
+		}
+
+		servers, err := liteserver.ParseServers(env.GetString("LITESERVERS", ""))
+		if err != nil {
+			return err
+		}
+		api, err := liteserver.NewManager(ctx.Context, &app.LiteserverConfig{
+			Servers:      servers,
+			GlobalConfig: env.GetString("LITESERVERS_GLOBAL_CONFIG", ""),
+		})
+		if err != nil {
+			return errors.Wrap(err, "cannot connect to liteservers")
+		}
+
+		bcConfig, err := app.GetBlockchainConfig(ctx.Context, api)
+		if err != nil {
+			return errors.Wrap(err, "cannot get blockchain config")
 		}
 
+		p := parser.NewService(&app.ParserConfig{
+			BlockchainConfig: bcConfig,
+			ContractRepo:     contractRepo,
+		})
+
 		qs, err := query.NewService(ctx.Context, &app.QueryConfig{
-			DB:  conn,
-			API: api,
+			DB:     conn,
+			API:    api,
+			Parser: p,
 		})
 		if err != nil {
 			return err
@@ -70,10 +88,19 @@ var Command = &cli.Command{
 		)
 		srv.RegisterRoutes(http.NewController(qs))
 
+		if token := env.GetString("API_ADMIN_TOKEN", ""); token != "" {
+			rs := registry.NewService(&app.RegistryConfig{
+				DB:     conn,
+				Parser: p,
+			})
+			srv.RegisterAdminRoutes(http.NewAdminController(rs), token)
+		}
+
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		go func() {
 			<-c
+			api.Close()
 			conn.Close()
 			os.Exit(0)
 		}()
@@ -0,0 +1,406 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/aggregate"
+)
+
+// defaultRevisionAuthor is recorded in revisions if the author query parameter is not set
+const defaultRevisionAuthor = "api"
+
+var _ AdminController = (*AdminHandler)(nil)
+
+type AdminHandler struct {
+	svc app.RegistryService
+}
+
+func NewAdminController(svc app.RegistryService) *AdminHandler {
+	return &AdminHandler{svc: svc}
+}
+
+type DefinitionReq struct {
+	Name   abi.TLBType       `json:"name"`
+	Fields abi.TLBFieldsDesc `json:"fields"`
+}
+
+func registryErr(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, core.ErrNotFound):
+		ctx.IndentedJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, core.ErrAlreadyExists):
+		ctx.IndentedJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		internalErr(ctx, err)
+	}
+}
+
+func newRevision(ctx *gin.Context) *core.ContractRevision {
+	rev := &core.ContractRevision{
+		Author:  ctx.Query("author"),
+		Comment: ctx.Query("comment"),
+	}
+	if rev.Author == "" {
+		rev.Author = defaultRevisionAuthor
+	}
+	return rev
+}
+
+func writeChange(ctx *gin.Context, status int, ret *app.RegistryChange, err error) {
+	if err != nil {
+		registryErr(ctx, err)
+		return
+	}
+	ctx.IndentedJSON(status, ret)
+}
+
+func bindInterfaceDesc(ctx *gin.Context) (*abi.InterfaceDesc, bool) {
+	var desc abi.InterfaceDesc
+
+	if err := ctx.ShouldBindJSON(&desc); err != nil {
+		paramErr(ctx, "interface", err)
+		return nil, false
+	}
+	if desc.Name == "" {
+		paramErr(ctx, "name", errors.Wrap(core.ErrInvalidArg, "empty interface name"))
+		return nil, false
+	}
+	if name := ctx.Param("name"); name != "" && abi.ContractName(name) != desc.Name {
+		paramErr(ctx, "name", errors.Wrapf(core.ErrInvalidArg, "interface name differs from '%s' path parameter", name))
+		return nil, false
+	}
+
+	return &desc, true
+}
+
+// AddInterface godoc
+//
+//	@Summary		add contract interface
+//	@Description	Adds contract interface with its operations and definitions, records revision and adds rescan tasks
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		interface	body	abi.InterfaceDesc	true	"contract interface description"
+//	@Param   		author		query	string				false	"revision author"
+//	@Param   		comment		query	string				false	"revision comment"
+//	@Success		201		{object}		app.RegistryChange
+//	@Router			/contracts/interfaces [post]
+func (c *AdminHandler) AddInterface(ctx *gin.Context) {
+	desc, ok := bindInterfaceDesc(ctx)
+	if !ok {
+		return
+	}
+
+	ret, err := c.svc.AddInterface(ctx, desc, newRevision(ctx))
+	writeChange(ctx, http.StatusCreated, ret, err)
+}
+
+// UpdateInterface godoc
+//
+//	@Summary		update contract interface
+//	@Description	Updates contract interface, records revision and adds rescan tasks for the difference
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		name		path	string				true	"contract interface name"
+//	@Param   		interface	body	abi.InterfaceDesc	true	"contract interface description"
+//	@Param   		author		query	string				false	"revision author"
+//	@Param   		comment		query	string				false	"revision comment"
+//	@Success		200		{object}		app.RegistryChange
+//	@Router			/contracts/interfaces/{name} [put]
+func (c *AdminHandler) UpdateInterface(ctx *gin.Context) {
+	desc, ok := bindInterfaceDesc(ctx)
+	if !ok {
+		return
+	}
+
+	ret, err := c.svc.UpdateInterface(ctx, desc, newRevision(ctx))
+	writeChange(ctx, http.StatusOK, ret, err)
+}
+
+// DeleteInterface godoc
+//
+//	@Summary		delete contract interface
+//	@Description	Deletes contract interface with its operations and adds rescan tasks removing parsed data
+//	@Tags			admin
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		name		path	string		true	"contract interface name"
+//	@Param   		author		query	string		false	"revision author"
+//	@Param   		comment		query	string		false	"revision comment"
+//	@Success		200		{object}		app.RegistryChange
+//	@Router			/contracts/interfaces/{name} [delete]
+func (c *AdminHandler) DeleteInterface(ctx *gin.Context) {
+	ret, err := c.svc.DeleteInterface(ctx, abi.ContractName(ctx.Param("name")), newRevision(ctx))
+	writeChange(ctx, http.StatusOK, ret, err)
+}
+
+// RollbackInterface godoc
+//
+//	@Summary		rollback contract interface
+//	@Description	Restores contract interface description from the given revision and adds rescan tasks for the difference
+//	@Tags			admin
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		name		path	string		true	"contract interface name"
+//	@Param   		version		query	int			true	"revision to restore"
+//	@Param   		author		query	string		false	"revision author"
+//	@Param   		comment		query	string		false	"revision comment"
+//	@Success		200		{object}		app.RegistryChange
+//	@Router			/contracts/interfaces/{name}/rollback [post]
+func (c *AdminHandler) RollbackInterface(ctx *gin.Context) {
+	version, err := strconv.ParseUint(ctx.Query("version"), 10, 32)
+	if err != nil {
+		paramErr(ctx, "version", err)
+		return
+	}
+
+	ret, err := c.svc.RollbackInterface(ctx, abi.ContractName(ctx.Param("name")), uint32(version), newRevision(ctx))
+	writeChange(ctx, http.StatusOK, ret, err)
+}
+
+func bindOperationReq(ctx *gin.Context) (*app.ContractOperationReq, bool) {
+	var req app.ContractOperationReq
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		paramErr(ctx, "operation", err)
+		return nil, false
+	}
+	req.ContractName = abi.ContractName(ctx.Param("name"))
+
+	if op := ctx.Param("operation"); op != "" && op != req.Schema.Name {
+		paramErr(ctx, "operation", errors.Wrapf(core.ErrInvalidArg, "operation name differs from '%s' path parameter", op))
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// AddOperation godoc
+//
+//	@Summary		add contract operation
+//	@Description	Adds operation to the contract interface, records revision and adds rescan task
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		name		path	string						true	"contract interface name"
+//	@Param   		operation	body	app.ContractOperationReq	true	"operation description"
+//	@Param   		author		query	string						false	"revision author"
+//	@Param   		comment		query	string						false	"revision comment"
+//	@Success		201		{object}		app.RegistryChange
+//	@Router			/contracts/interfaces/{name}/operations [post]
+func (c *AdminHandler) AddOperation(ctx *gin.Context) {
+	req, ok := bindOperationReq(ctx)
+	if !ok {
+		return
+	}
+
+	ret, err := c.svc.AddOperation(ctx, req, newRevision(ctx))
+	writeChange(ctx, http.StatusCreated, ret, err)
+}
+
+// UpdateOperation godoc
+//
+//	@Summary		update contract operation
+//	@Description	Updates operation of the contract interface, records revision and adds rescan task
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		name		path	string						true	"contract interface name"
+//	@Param   		operation	path	string						true	"operation name"
+//	@Param   		schema		body	app.ContractOperationReq	true	"operation description"
+//	@Param   		author		query	string						false	"revision author"
+//	@Param   		comment		query	string						false	"revision comment"
+//	@Success		200		{object}		app.RegistryChange
+//	@Router			/contracts/interfaces/{name}/operations/{operation} [put]
+func (c *AdminHandler) UpdateOperation(ctx *gin.Context) {
+	req, ok := bindOperationReq(ctx)
+	if !ok {
+		return
+	}
+
+	ret, err := c.svc.UpdateOperation(ctx, req, newRevision(ctx))
+	writeChange(ctx, http.StatusOK, ret, err)
+}
+
+// DeleteOperation godoc
+//
+//	@Summary		delete contract operation
+//	@Description	Deletes operation of the contract interface, records revision and adds rescan task removing parsed data
+//	@Tags			admin
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		name		path	string		true	"contract interface name"
+//	@Param   		operation	path	string		true	"operation name"
+//	@Param   		author		query	string		false	"revision author"
+//	@Param   		comment		query	string		false	"revision comment"
+//	@Success		200		{object}		app.RegistryChange
+//	@Router			/contracts/interfaces/{name}/operations/{operation} [delete]
+func (c *AdminHandler) DeleteOperation(ctx *gin.Context) {
+	ret, err := c.svc.DeleteOperation(ctx, abi.ContractName(ctx.Param("name")), ctx.Param("operation"), newRevision(ctx))
+	writeChange(ctx, http.StatusOK, ret, err)
+}
+
+func bindDefinitionReq(ctx *gin.Context) (*DefinitionReq, bool) {
+	var req DefinitionReq
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		paramErr(ctx, "definition", err)
+		return nil, false
+	}
+	if name := ctx.Param("name"); name != "" {
+		if req.Name != "" && req.Name != abi.TLBType(name) {
+			paramErr(ctx, "name", errors.Wrapf(core.ErrInvalidArg, "definition name differs from '%s' path parameter", name))
+			return nil, false
+		}
+		req.Name = abi.TLBType(name)
+	}
+	if req.Name == "" {
+		paramErr(ctx, "name", errors.Wrap(core.ErrInvalidArg, "empty definition name"))
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// AddDefinition godoc
+//
+//	@Summary		add definition
+//	@Description	Adds TL-B definition, which can be used in contract operations and get-methods
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		definition	body	DefinitionReq	true	"definition"
+//	@Success		201
+//	@Router			/contracts/definitions [post]
+func (c *AdminHandler) AddDefinition(ctx *gin.Context) {
+	req, ok := bindDefinitionReq(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.svc.AddDefinition(ctx, req.Name, req.Fields); err != nil {
+		registryErr(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusCreated)
+}
+
+// UpdateDefinition godoc
+//
+//	@Summary		update definition
+//	@Description	Updates TL-B definition, records revisions of interfaces using it and adds rescan tasks
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		name		path	string			true	"definition name"
+//	@Param   		definition	body	DefinitionReq	true	"definition"
+//	@Param   		author		query	string			false	"revision author"
+//	@Param   		comment		query	string			false	"revision comment"
+//	@Success		200		{object}		app.RegistryChange
+//	@Router			/contracts/definitions/{name} [put]
+func (c *AdminHandler) UpdateDefinition(ctx *gin.Context) {
+	req, ok := bindDefinitionReq(ctx)
+	if !ok {
+		return
+	}
+
+	ret, err := c.svc.UpdateDefinition(ctx, req.Name, req.Fields, newRevision(ctx))
+	writeChange(ctx, http.StatusOK, ret, err)
+}
+
+// DeleteDefinition godoc
+//
+//	@Summary		delete definition
+//	@Description	Deletes TL-B definition, which is not used by any operation, get-method or definition
+//	@Tags			admin
+//	@Security		AdminToken
+//	@Param   		name		path	string		true	"definition name"
+//	@Success		204
+//	@Router			/contracts/definitions/{name} [delete]
+func (c *AdminHandler) DeleteDefinition(ctx *gin.Context) {
+	if err := c.svc.DeleteDefinition(ctx, abi.TLBType(ctx.Param("name"))); err != nil {
+		registryErr(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// ValidateSchema godoc
+//
+//	@Summary		validate contract interface
+//	@Description	Parses sample messages and executes get-methods on sample accounts with the submitted interface without saving it
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		request		body	app.ValidateSchemaReq	true	"interface description and samples"
+//	@Success		200		{object}		app.ValidateSchemaRes
+//	@Router			/contracts/validate [post]
+func (c *AdminHandler) ValidateSchema(ctx *gin.Context) {
+	var req app.ValidateSchemaReq
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		paramErr(ctx, "request", err)
+		return
+	}
+	if req.Interface.Name == "" {
+		paramErr(ctx, "name", errors.Wrap(core.ErrInvalidArg, "empty interface name"))
+		return
+	}
+
+	ret, err := c.svc.ValidateSchema(ctx, &req)
+	if err != nil {
+		registryErr(ctx, err)
+		return
+	}
+	ctx.IndentedJSON(http.StatusOK, ret)
+}
+
+// DiscoverContracts godoc
+//
+//	@Summary		unknown contracts
+//	@Description	Clusters accounts without matched contract interfaces by code hash
+//	@Description	and returns their activity, get-methods and the most frequent operations
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		AdminToken
+//	@Param   		since				query	string  	false	"count transactions and messages since this time (RFC 3339), the last week by default"
+//	@Param   		min_accounts		query	int 		false	"minimal number of accounts in the cluster"		default(1)
+//	@Param   		operations_limit	query	int 		false	"number of the most frequent operations"		default(5) maximum(100)
+//	@Param   		limit	     		query   int 		false	"limit"											default(10) maximum(100)
+//	@Success		200		{object}		aggregate.DiscoverContractsRes
+//	@Router			/contracts/discover [get]
+func (c *AdminHandler) DiscoverContracts(ctx *gin.Context) {
+	var req aggregate.DiscoverContractsReq
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		paramErr(ctx, "discover_filter", err)
+		return
+	}
+	if req.Limit > 100 || req.OperationsLimit > 100 {
+		paramErr(ctx, "limit", errors.Wrapf(core.ErrInvalidArg, "limit is too big"))
+		return
+	}
+
+	ret, err := c.svc.DiscoverContracts(ctx, &req)
+	if err != nil {
+		internalErr(ctx, err)
+		return
+	}
+	ctx.IndentedJSON(http.StatusOK, ret)
+}
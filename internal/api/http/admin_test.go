@@ -0,0 +1,448 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/aggregate"
+)
+
+const testAdminToken = "secret"
+
+// mockRegistry records the last call arguments and returns the given error
+type mockRegistry struct {
+	app.RegistryService
+
+	err error
+
+	called  string
+	desc    *abi.InterfaceDesc
+	name    string
+	version uint32
+	op      *app.ContractOperationReq
+	fields  abi.TLBFieldsDesc
+	rev     *core.ContractRevision
+	req     *app.ValidateSchemaReq
+	limit   int
+}
+
+func (m *mockRegistry) change(called string, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.called, m.rev = called, rev
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &app.RegistryChange{Revisions: []*core.ContractRevision{rev}}, nil
+}
+
+func (m *mockRegistry) AddInterface(_ context.Context, desc *abi.InterfaceDesc, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.desc = desc
+	return m.change("AddInterface", rev)
+}
+
+func (m *mockRegistry) UpdateInterface(_ context.Context, desc *abi.InterfaceDesc, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.desc = desc
+	return m.change("UpdateInterface", rev)
+}
+
+func (m *mockRegistry) DeleteInterface(_ context.Context, name abi.ContractName, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.name = string(name)
+	return m.change("DeleteInterface", rev)
+}
+
+func (m *mockRegistry) RollbackInterface(_ context.Context, name abi.ContractName, version uint32, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.name, m.version = string(name), version
+	return m.change("RollbackInterface", rev)
+}
+
+func (m *mockRegistry) AddOperation(_ context.Context, req *app.ContractOperationReq, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.op = req
+	return m.change("AddOperation", rev)
+}
+
+func (m *mockRegistry) UpdateOperation(_ context.Context, req *app.ContractOperationReq, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.op = req
+	return m.change("UpdateOperation", rev)
+}
+
+func (m *mockRegistry) DeleteOperation(_ context.Context, name abi.ContractName, opName string, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.name = string(name) + "/" + opName
+	return m.change("DeleteOperation", rev)
+}
+
+func (m *mockRegistry) AddDefinition(_ context.Context, dn abi.TLBType, d abi.TLBFieldsDesc) error {
+	m.called, m.name, m.fields = "AddDefinition", string(dn), d
+	return m.err
+}
+
+func (m *mockRegistry) UpdateDefinition(_ context.Context, dn abi.TLBType, d abi.TLBFieldsDesc, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	m.name, m.fields = string(dn), d
+	return m.change("UpdateDefinition", rev)
+}
+
+func (m *mockRegistry) DeleteDefinition(_ context.Context, dn abi.TLBType) error {
+	m.called, m.name = "DeleteDefinition", string(dn)
+	return m.err
+}
+
+func (m *mockRegistry) ValidateSchema(_ context.Context, req *app.ValidateSchemaReq) (*app.ValidateSchemaRes, error) {
+	m.called, m.req = "ValidateSchema", req
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &app.ValidateSchemaRes{}, nil
+}
+
+func (m *mockRegistry) DiscoverContracts(_ context.Context, req *aggregate.DiscoverContractsReq) (*aggregate.DiscoverContractsRes, error) {
+	m.called, m.limit = "DiscoverContracts", req.Limit
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &aggregate.DiscoverContractsRes{}, nil
+}
+
+func testAdminServer(svc app.RegistryService) *Server {
+	gin.SetMode(gin.TestMode)
+
+	s := NewServer(":8080")
+	s.RegisterAdminRoutes(NewAdminController(svc), testAdminToken)
+	return s
+}
+
+func serveAdmin(s *Server, method, path, auth, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, basePath+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthorizeAdmin(t *testing.T) {
+	var testCases = []*struct {
+		name   string
+		auth   string
+		status int
+	}{
+		{name: "missing token", status: http.StatusUnauthorized},
+		{name: "bad token", auth: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "token prefix", auth: "Bearer secre", status: http.StatusUnauthorized},
+		{name: "token without bearer scheme", auth: testAdminToken, status: http.StatusUnauthorized},
+		{name: "empty bearer token", auth: "Bearer ", status: http.StatusUnauthorized},
+		{name: "valid token", auth: "Bearer " + testAdminToken, status: http.StatusNoContent},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockRegistry{}
+			s := testAdminServer(svc)
+
+			w := serveAdmin(s, http.MethodDelete, "/contracts/definitions/some_def", test.auth, "")
+			require.Equal(t, test.status, w.Code, w.Body.String())
+
+			if test.status == http.StatusUnauthorized {
+				require.Empty(t, svc.called)
+				require.Contains(t, w.Body.String(), "invalid admin token")
+				return
+			}
+			require.Equal(t, "DeleteDefinition", svc.called)
+		})
+	}
+
+	t.Run("discover contracts without token", func(t *testing.T) {
+		svc := &mockRegistry{}
+		s := testAdminServer(svc)
+
+		w := serveAdmin(s, http.MethodGet, "/contracts/discover", "", "")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Empty(t, svc.called)
+	})
+
+	t.Run("empty configured token", func(t *testing.T) {
+		svc := &mockRegistry{}
+
+		gin.SetMode(gin.TestMode)
+		s := NewServer(":8080")
+		s.RegisterAdminRoutes(NewAdminController(svc), "")
+
+		w := serveAdmin(s, http.MethodDelete, "/contracts/definitions/some_def", "Bearer ", "")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Empty(t, svc.called)
+	})
+}
+
+func TestAdminHandler(t *testing.T) {
+	const auth = "Bearer " + testAdminToken
+
+	var testCases = []*struct {
+		name   string
+		method string
+		path   string
+		body   string
+		err    error // returned by the service
+
+		status int
+		called string
+		check  func(t *testing.T, svc *mockRegistry)
+	}{
+		{
+			name:   "add interface",
+			method: http.MethodPost,
+			path:   "/contracts/interfaces?author=alice&comment=new",
+			body:   `{"interface_name":"test_contract","get_methods":[{"name":"get_x"}]}`,
+			status: http.StatusCreated,
+			called: "AddInterface",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, abi.ContractName("test_contract"), svc.desc.Name)
+				require.Len(t, svc.desc.GetMethods, 1)
+				require.Equal(t, "alice", svc.rev.Author)
+				require.Equal(t, "new", svc.rev.Comment)
+			},
+		},
+		{
+			name:   "add interface without name",
+			method: http.MethodPost,
+			path:   "/contracts/interfaces",
+			body:   `{"get_methods":[{"name":"get_x"}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "add existing interface",
+			method: http.MethodPost,
+			path:   "/contracts/interfaces",
+			body:   `{"interface_name":"test_contract"}`,
+			err:    errors.Wrap(core.ErrAlreadyExists, "interface"),
+			status: http.StatusConflict,
+			called: "AddInterface",
+		},
+		{
+			name:   "update interface",
+			method: http.MethodPut,
+			path:   "/contracts/interfaces/test_contract",
+			body:   `{"interface_name":"test_contract"}`,
+			status: http.StatusOK,
+			called: "UpdateInterface",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, abi.ContractName("test_contract"), svc.desc.Name)
+				require.Equal(t, defaultRevisionAuthor, svc.rev.Author)
+			},
+		},
+		{
+			name:   "update interface with other name",
+			method: http.MethodPut,
+			path:   "/contracts/interfaces/test_contract",
+			body:   `{"interface_name":"other_contract"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "update interface with invalid description",
+			method: http.MethodPut,
+			path:   "/contracts/interfaces/test_contract",
+			body:   `{"interface_name":"test_contract"}`,
+			err:    errors.Wrap(core.ErrInvalidArg, "invalid description"),
+			status: http.StatusBadRequest,
+			called: "UpdateInterface",
+		},
+		{
+			name:   "delete unknown interface",
+			method: http.MethodDelete,
+			path:   "/contracts/interfaces/test_contract",
+			err:    errors.Wrap(core.ErrNotFound, "interface"),
+			status: http.StatusNotFound,
+			called: "DeleteInterface",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, "test_contract", svc.name)
+			},
+		},
+		{
+			name:   "rollback interface",
+			method: http.MethodPost,
+			path:   "/contracts/interfaces/test_contract/rollback?version=3",
+			status: http.StatusOK,
+			called: "RollbackInterface",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, "test_contract", svc.name)
+				require.Equal(t, uint32(3), svc.version)
+			},
+		},
+		{
+			name:   "rollback interface without version",
+			method: http.MethodPost,
+			path:   "/contracts/interfaces/test_contract/rollback",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "add operation",
+			method: http.MethodPost,
+			path:   "/contracts/interfaces/test_contract/operations",
+			body:   `{"outgoing":true,"schema":{"op_name":"test_op","op_code":"0x1","body":[]}}`,
+			status: http.StatusCreated,
+			called: "AddOperation",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, abi.ContractName("test_contract"), svc.op.ContractName)
+				require.Equal(t, "test_op", svc.op.Schema.Name)
+				require.True(t, svc.op.Outgoing)
+			},
+		},
+		{
+			name:   "update operation",
+			method: http.MethodPut,
+			path:   "/contracts/interfaces/test_contract/operations/test_op",
+			body:   `{"schema":{"op_name":"test_op","op_code":"0x1","body":[]}}`,
+			status: http.StatusOK,
+			called: "UpdateOperation",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, abi.ContractName("test_contract"), svc.op.ContractName)
+			},
+		},
+		{
+			name:   "update operation with other name",
+			method: http.MethodPut,
+			path:   "/contracts/interfaces/test_contract/operations/test_op",
+			body:   `{"schema":{"op_name":"other_op","op_code":"0x1","body":[]}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "delete operation",
+			method: http.MethodDelete,
+			path:   "/contracts/interfaces/test_contract/operations/test_op",
+			status: http.StatusOK,
+			called: "DeleteOperation",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, "test_contract/test_op", svc.name)
+			},
+		},
+		{
+			name:   "add definition",
+			method: http.MethodPost,
+			path:   "/contracts/definitions",
+			body:   `{"name":"test_def","fields":[{"name":"x","tlb_type":"## 32"}]}`,
+			status: http.StatusCreated,
+			called: "AddDefinition",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, "test_def", svc.name)
+				require.Len(t, svc.fields, 1)
+			},
+		},
+		{
+			name:   "add definition without name",
+			method: http.MethodPost,
+			path:   "/contracts/definitions",
+			body:   `{"fields":[{"name":"x","tlb_type":"## 32"}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "add existing definition",
+			method: http.MethodPost,
+			path:   "/contracts/definitions",
+			body:   `{"name":"test_def","fields":[]}`,
+			err:    errors.Wrap(core.ErrAlreadyExists, "definition"),
+			status: http.StatusConflict,
+			called: "AddDefinition",
+		},
+		{
+			name:   "update definition",
+			method: http.MethodPut,
+			path:   "/contracts/definitions/test_def",
+			body:   `{"fields":[{"name":"x","tlb_type":"## 64"}]}`,
+			status: http.StatusOK,
+			called: "UpdateDefinition",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, "test_def", svc.name)
+			},
+		},
+		{
+			name:   "update definition with other name",
+			method: http.MethodPut,
+			path:   "/contracts/definitions/test_def",
+			body:   `{"name":"other_def","fields":[]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "delete definition",
+			method: http.MethodDelete,
+			path:   "/contracts/definitions/test_def",
+			status: http.StatusNoContent,
+			called: "DeleteDefinition",
+		},
+		{
+			name:   "delete used definition",
+			method: http.MethodDelete,
+			path:   "/contracts/definitions/test_def",
+			err:    errors.Wrap(core.ErrInvalidArg, "definition is used"),
+			status: http.StatusBadRequest,
+			called: "DeleteDefinition",
+		},
+		{
+			name:   "validate schema",
+			method: http.MethodPost,
+			path:   "/contracts/validate",
+			body:   `{"interface":{"interface_name":"test_contract"},"addresses":["EQCpEVerpJdXTd6cLJcWXDQOqFrct67vwtd9_jIxm99zQZV6"]}`,
+			status: http.StatusOK,
+			called: "ValidateSchema",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, abi.ContractName("test_contract"), svc.req.Interface.Name)
+				require.Len(t, svc.req.Addresses, 1)
+			},
+		},
+		{
+			name:   "validate schema without interface name",
+			method: http.MethodPost,
+			path:   "/contracts/validate",
+			body:   `{"interface":{}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "validate invalid schema",
+			method: http.MethodPost,
+			path:   "/contracts/validate",
+			body:   `{"interface":{"interface_name":"test_contract"}}`,
+			err:    errors.Wrap(core.ErrInvalidArg, "invalid description"),
+			status: http.StatusBadRequest,
+			called: "ValidateSchema",
+		},
+		{
+			name:   "discover contracts",
+			method: http.MethodGet,
+			path:   "/contracts/discover?limit=20&min_accounts=10",
+			status: http.StatusOK,
+			called: "DiscoverContracts",
+			check: func(t *testing.T, svc *mockRegistry) {
+				require.Equal(t, 20, svc.limit)
+			},
+		},
+		{
+			name:   "discover contracts with too big limit",
+			method: http.MethodGet,
+			path:   "/contracts/discover?limit=1000",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockRegistry{err: test.err}
+			s := testAdminServer(svc)
+
+			w := serveAdmin(s, test.method, test.path, auth, test.body)
+			require.Equal(t, test.status, w.Code, w.Body.String())
+			require.Equal(t, test.called, svc.called)
+
+			if test.check != nil {
+				test.check(t, svc)
+			}
+		})
+	}
+}
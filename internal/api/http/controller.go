@@ -146,6 +146,29 @@ func (c *Controller) GetStatistics(ctx *gin.Context) {
 	ctx.IndentedJSON(http.StatusOK, ret)
 }
 
+type GetLiteserversRes struct {
+	Total   int                    `json:"total"`
+	Results []*app.LiteserverStats `json:"results"`
+}
+
+// GetLiteservers godoc
+//
+//	@Summary		liteservers statistics
+//	@Description	Returns latency, error rate and the oldest available block of each used liteserver
+//	@Tags			statistics
+//	@Accept			json
+//	@Produce		json
+//	@Success		200		{object}		GetLiteserversRes
+//	@Router			/statistics/liteservers [get]
+func (c *Controller) GetLiteservers(ctx *gin.Context) {
+	ret, err := c.svc.GetLiteservers(ctx)
+	if err != nil {
+		internalErr(ctx, err)
+		return
+	}
+	ctx.IndentedJSON(http.StatusOK, GetLiteserversRes{Total: len(ret), Results: ret})
+}
+
 type GetInterfacesRes struct {
 	Total   int                       `json:"total"`
 	Results []*core.ContractInterface `json:"results"`
@@ -215,6 +238,68 @@ func (c *Controller) GetDefinitions(ctx *gin.Context) {
 	ctx.IndentedJSON(http.StatusOK, GetDefinitionsRes{Total: len(ret), Results: ret})
 }
 
+type GetRescanTasksRes struct {
+	Total   int                `json:"total"`
+	Results []*core.RescanTask `json:"results"`
+}
+
+// GetRescanTasks godoc
+//
+//	@Summary		rescan tasks
+//	@Description	Returns rescan tasks with their status, checkpoint and estimated progress
+//	@Tags			rescan
+//	@Accept			json
+//	@Produce		json
+//	@Param   		all		query	bool  	false	"include finished and canceled tasks"
+//	@Success		200		{object}		GetRescanTasksRes
+//	@Router			/rescan/tasks [get]
+func (c *Controller) GetRescanTasks(ctx *gin.Context) {
+	var all bool
+	if a := ctx.Query("all"); a != "" {
+		var err error
+		if all, err = strconv.ParseBool(a); err != nil {
+			paramErr(ctx, "all", err)
+			return
+		}
+	}
+
+	ret, err := c.svc.GetRescanTasks(ctx, all)
+	if err != nil {
+		internalErr(ctx, err)
+		return
+	}
+	ctx.IndentedJSON(http.StatusOK, GetRescanTasksRes{Total: len(ret), Results: ret})
+}
+
+// GetRescanTask godoc
+//
+//	@Summary		rescan task
+//	@Description	Returns rescan task by id
+//	@Tags			rescan
+//	@Accept			json
+//	@Produce		json
+//	@Param   		id		path	int  	true	"rescan task id"
+//	@Success		200		{object}		core.RescanTask
+//	@Router			/rescan/tasks/{id} [get]
+func (c *Controller) GetRescanTask(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		paramErr(ctx, "id", err)
+		return
+	}
+
+	ret, err := c.svc.GetRescanTask(ctx, id)
+	if errors.Is(err, core.ErrNotFound) {
+		ctx.IndentedJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalErr(ctx, err)
+		return
+	}
+	ctx.IndentedJSON(http.StatusOK, ret)
+}
+
 // GetBlocks godoc
 //
 //	@Summary		block info
@@ -390,6 +475,54 @@ func (c *Controller) GetAccounts(ctx *gin.Context) {
 	ctx.IndentedJSON(http.StatusOK, ret)
 }
 
+// ExecuteGetMethod godoc
+//
+//	@Summary		execute get-method
+//	@Description	Executes get-method with arbitrary arguments on the latest or historical account state.
+//	@Description	Historical state is chosen by tx_lt or by block_seq_no, which is a block seqno in the account workchain (a shard block for basechain accounts)
+//	@Tags			account
+//	@Accept			json
+//	@Produce		json
+//	@Param   		address				path	string  					true	"account address"
+//	@Param   		name				path	string  					true	"get-method name"
+//	@Param   		request				body	app.ExecuteGetMethodReq		false	"get-method arguments and account state"
+//	@Success		200		{object}	app.ExecuteGetMethodRes
+//	@Router			/accounts/{address}/get-methods/{name} [post]
+func (c *Controller) ExecuteGetMethod(ctx *gin.Context) {
+	var req app.ExecuteGetMethodReq
+
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			paramErr(ctx, "request", err)
+			return
+		}
+	}
+
+	a, err := unmarshalAddress(ctx.Param("address"))
+	if err != nil {
+		paramErr(ctx, "address", err)
+		return
+	}
+	if a == nil {
+		paramErr(ctx, "address", errors.Wrap(core.ErrInvalidArg, "empty address"))
+		return
+	}
+	req.Address = *a
+	req.GetMethod = ctx.Param("name")
+
+	ret, err := c.svc.ExecuteGetMethod(ctx, &req)
+	if errors.Is(err, core.ErrNotFound) {
+		ctx.IndentedJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalErr(ctx, err)
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusOK, ret)
+}
+
 // AggregateAccounts godoc
 //
 //	@Summary		aggregated account data
@@ -578,6 +711,42 @@ func (c *Controller) AggregateTransactionsHistory(ctx *gin.Context) {
 	ctx.IndentedJSON(http.StatusOK, ret)
 }
 
+// EmulateMessage godoc
+//
+//	@Summary		emulate message
+//	@Description	Emulates transaction triggered by the message on the latest or historical account state.
+//	@Description	The message is given as a bag of cells or built from the operation name and fields.
+//	@Tags			transaction
+//	@Accept			json
+//	@Produce		json
+//	@Param   		request				body	app.EmulateMessageReq		true	"message and account state"
+//	@Success		200		{object}	core.Transaction
+//	@Router			/emulate [post]
+func (c *Controller) EmulateMessage(ctx *gin.Context) {
+	var req app.EmulateMessageReq
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		paramErr(ctx, "request", err)
+		return
+	}
+	if req.Address == (addr.Address{}) {
+		paramErr(ctx, "address", errors.Wrap(core.ErrInvalidArg, "empty address"))
+		return
+	}
+
+	ret, err := c.svc.EmulateMessage(ctx, &req)
+	if errors.Is(err, core.ErrNotFound) {
+		ctx.IndentedJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		internalErr(ctx, err)
+		return
+	}
+
+	ctx.IndentedJSON(http.StatusOK, ret)
+}
+
 // GetMessages godoc
 //
 //	@Summary		transaction messages
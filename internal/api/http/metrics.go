@@ -0,0 +1,27 @@
+package http
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tonindexer/anton/metrics"
+)
+
+var requestDuration = metrics.NewHistogram(
+	"anton_http_request_duration_seconds", "Duration of HTTP requests.", metrics.DefBuckets, "method", "route", "status")
+
+// observeRequest measures handlers latency, requests to unknown routes
+// are observed under a single label value to keep the number of series bounded.
+func observeRequest(ctx *gin.Context) {
+	start := time.Now()
+
+	ctx.Next()
+
+	route := ctx.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	requestDuration.ObserveSince(start, ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status()))
+}
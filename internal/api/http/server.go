@@ -1,11 +1,14 @@
 package http
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 
 	_ "github.com/tonindexer/anton/api/http"
+	"github.com/tonindexer/anton/metrics"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -15,6 +18,7 @@ import (
 
 type QueryController interface {
 	GetStatistics(*gin.Context)
+	GetLiteservers(*gin.Context)
 
 	GetBlocks(*gin.Context)
 
@@ -22,11 +26,13 @@ type QueryController interface {
 	GetLabels(*gin.Context)
 
 	GetAccounts(*gin.Context)
+	ExecuteGetMethod(*gin.Context)
 	AggregateAccounts(*gin.Context)
 	AggregateAccountsHistory(*gin.Context)
 
 	GetTransactions(*gin.Context)
 	AggregateTransactionsHistory(*gin.Context)
+	EmulateMessage(*gin.Context)
 
 	GetMessages(*gin.Context)
 	AggregateMessages(*gin.Context)
@@ -35,6 +41,27 @@ type QueryController interface {
 	GetInterfaces(*gin.Context)
 	GetOperations(*gin.Context)
 	GetDefinitions(*gin.Context)
+
+	GetRescanTasks(*gin.Context)
+	GetRescanTask(*gin.Context)
+}
+
+type AdminController interface {
+	AddInterface(*gin.Context)
+	UpdateInterface(*gin.Context)
+	DeleteInterface(*gin.Context)
+	RollbackInterface(*gin.Context)
+
+	AddOperation(*gin.Context)
+	UpdateOperation(*gin.Context)
+	DeleteOperation(*gin.Context)
+
+	AddDefinition(*gin.Context)
+	UpdateDefinition(*gin.Context)
+	DeleteDefinition(*gin.Context)
+
+	ValidateSchema(*gin.Context)
+	DiscoverContracts(*gin.Context)
 }
 
 type Server struct {
@@ -47,13 +74,17 @@ func NewServer(host string) *Server {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	router.Use(cors.New(config))
+	router.Use(observeRequest)
 	return &Server{listenHost: host, router: router}
 }
 
 func (s *Server) RegisterRoutes(t QueryController) {
+	s.router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	base := s.router.Group(basePath)
 
 	base.GET("/statistics", t.GetStatistics)
+	base.GET("/statistics/liteservers", t.GetLiteservers)
 
 	base.GET("/blocks", t.GetBlocks)
 
@@ -61,11 +92,13 @@ func (s *Server) RegisterRoutes(t QueryController) {
 	base.GET("/labels/categories", t.GetLabelCategories)
 
 	base.GET("/accounts", t.GetAccounts)
+	base.POST("/accounts/:address/get-methods/:name", t.ExecuteGetMethod)
 	base.GET("/accounts/aggregated", t.AggregateAccounts)
 	base.GET("/accounts/aggregated/history", t.AggregateAccountsHistory)
 
 	base.GET("/transactions", t.GetTransactions)
 	base.GET("/transactions/aggregated/history", t.AggregateTransactionsHistory)
+	base.POST("/emulate", t.EmulateMessage)
 
 	base.GET("/messages", t.GetMessages)
 	base.GET("/messages/aggregated", t.AggregateMessages)
@@ -78,6 +111,9 @@ func (s *Server) RegisterRoutes(t QueryController) {
 	base.GET("/contracts/operations", t.GetOperations)
 	base.GET("/contracts/definitions", t.GetDefinitions)
 
+	base.GET("/rescan/tasks", t.GetRescanTasks)
+	base.GET("/rescan/tasks/:id", t.GetRescanTask)
+
 	base.GET("/swagger/*any", ginSwagger.WrapHandler(
 		swaggerFiles.Handler,
 		ginSwagger.URL(basePath+"/swagger/doc.json"),
@@ -88,6 +124,40 @@ func (s *Server) RegisterRoutes(t QueryController) {
 	})
 }
 
+// authorizeAdmin checks the bearer token in the Authorization header
+func authorizeAdmin(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.GetHeader("Authorization")
+		got := strings.TrimPrefix(header, "Bearer ")
+		if got == header || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RegisterAdminRoutes registers contract registry endpoints available with the admin token.
+func (s *Server) RegisterAdminRoutes(t AdminController, token string) {
+	admin := s.router.Group(basePath, authorizeAdmin(token))
+
+	admin.POST("/contracts/interfaces", t.AddInterface)
+	admin.PUT("/contracts/interfaces/:name", t.UpdateInterface)
+	admin.DELETE("/contracts/interfaces/:name", t.DeleteInterface)
+	admin.POST("/contracts/interfaces/:name/rollback", t.RollbackInterface)
+
+	admin.POST("/contracts/interfaces/:name/operations", t.AddOperation)
+	admin.PUT("/contracts/interfaces/:name/operations/:operation", t.UpdateOperation)
+	admin.DELETE("/contracts/interfaces/:name/operations/:operation", t.DeleteOperation)
+
+	admin.POST("/contracts/definitions", t.AddDefinition)
+	admin.PUT("/contracts/definitions/:name", t.UpdateDefinition)
+	admin.DELETE("/contracts/definitions/:name", t.DeleteDefinition)
+
+	admin.POST("/contracts/validate", t.ValidateSchema)
+	admin.GET("/contracts/discover", t.DiscoverContracts)
+}
+
 func (s *Server) Run() error {
 	return s.router.Run(s.listenHost)
 }
@@ -0,0 +1,17 @@
+package app
+
+import (
+	"github.com/tonindexer/anton/internal/core/filter"
+)
+
+type DumpConfig struct {
+	// Path is a directory with block dumps: archive node packages (*.pack)
+	// or separate BOC files named as package entries, e.g., block_(-1,8000000000000000,100).
+	// Shard states after the block are read from state_(-1,8000000000000000,100) files,
+	// without them transactions are indexed without account states.
+	Path string
+
+	AccountRepo filter.AccountRepository
+
+	Parser ParserService
+}
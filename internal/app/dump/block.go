@@ -0,0 +1,159 @@
+package dump
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/internal/app"
+)
+
+func (s *Service) lookupMaster(seqNo uint32) (*loadedBlock, error) {
+	return s.loadBlock(blockKey{workchain: s.masterWorkchain, shard: s.masterShard, seqNo: seqNo})
+}
+
+func (s *Service) getShardsInfo(master *loadedBlock) ([]*ton.BlockIDExt, error) {
+	if master.block.Extra == nil || master.block.Extra.Custom == nil {
+		return nil, fmt.Errorf("masterchain block %d has no shards", master.id.SeqNo)
+	}
+	shards, err := ton.LoadShardsFromHashes(master.block.Extra.Custom.ShardHashes, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "load shards from hashes")
+	}
+	return shards, nil
+}
+
+func getShardID(shard *ton.BlockIDExt) string {
+	return fmt.Sprintf("%d|%d", shard.Workchain, shard.Shard)
+}
+
+func (s *Service) getNotSeenShards(shard *ton.BlockIDExt, shardLastSeqNo map[string]uint32) (ret []*ton.BlockIDExt, err error) {
+	if no, ok := shardLastSeqNo[getShardID(shard)]; ok && no == shard.SeqNo {
+		return nil, nil
+	}
+
+	b, err := s.loadBlock(key(shard))
+	if err != nil {
+		return nil, err
+	}
+
+	parents, err := b.block.BlockInfo.GetParentBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("get parent blocks (%d:%x:%d): %w", shard.Workchain, uint64(shard.Shard), shard.Shard, err)
+	}
+
+	for _, parent := range parents {
+		ext, err := s.getNotSeenShards(parent, shardLastSeqNo)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, ext...)
+	}
+
+	ret = append(ret, b.id)
+	return ret, nil
+}
+
+// UnseenBlocks returns the masterchain block and shard blocks, which were created after the previous masterchain block.
+// If the previous masterchain block is not dumped, only shard blocks referenced by the given master are returned.
+func (s *Service) UnseenBlocks(_ context.Context, masterSeqNo uint32) (master *ton.BlockIDExt, shards []*ton.BlockIDExt, err error) {
+	curMaster, err := s.lookupMaster(masterSeqNo)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "lookup master")
+	}
+	curShards, err := s.getShardsInfo(curMaster)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get masterchain shards info")
+	}
+
+	prevMaster, err := s.lookupMaster(masterSeqNo - 1)
+	if errors.Is(err, ton.ErrBlockNotFound) {
+		for _, shard := range curShards {
+			b, err := s.loadBlock(key(shard))
+			if err != nil {
+				return nil, nil, err
+			}
+			shards = append(shards, b.id)
+		}
+		return curMaster.id, shards, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "lookup previous master")
+	}
+	prevShards, err := s.getShardsInfo(prevMaster)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get previous masterchain shards info")
+	}
+
+	shardLastSeqNo := map[string]uint32{}
+	for _, shard := range prevShards {
+		shardLastSeqNo[getShardID(shard)] = shard.SeqNo
+	}
+
+	for _, shard := range curShards {
+		notSeen, err := s.getNotSeenShards(shard, shardLastSeqNo)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "get not seen shards")
+		}
+		shards = append(shards, notSeen...)
+	}
+
+	return curMaster.id, shards, nil
+}
+
+// BlockchainConfig returns the config from the latest dumped masterchain state or key block.
+func BlockchainConfig(path string) (*cell.Cell, error) {
+	s, err := NewService(&app.DumpConfig{Path: path})
+	if err != nil {
+		return nil, err
+	}
+
+	var stateSeqNo []uint32
+	for k := range s.files.states {
+		if k.workchain == s.masterWorkchain {
+			stateSeqNo = append(stateSeqNo, k.seqNo)
+		}
+	}
+	if len(stateSeqNo) > 0 {
+		sort.Slice(stateSeqNo, func(i, j int) bool { return stateSeqNo[i] > stateSeqNo[j] })
+
+		state, err := s.loadState(&ton.BlockIDExt{Workchain: s.masterWorkchain, Shard: s.masterShard, SeqNo: stateSeqNo[0]})
+		if err != nil {
+			return nil, err
+		}
+		if state.McStateExtra != nil {
+			var mcStateExtra tlb.McStateExtra
+			if err := tlb.LoadFromCell(&mcStateExtra, state.McStateExtra.BeginParse()); err != nil {
+				return nil, errors.Wrap(err, "load masterchain state extra")
+			}
+			return mcStateExtra.ConfigParams.Config.Params.AsCell(), nil
+		}
+	}
+
+	var masterSeqNo []uint32
+	for k := range s.files.blocks {
+		if k.workchain == s.masterWorkchain {
+			masterSeqNo = append(masterSeqNo, k.seqNo)
+		}
+	}
+	sort.Slice(masterSeqNo, func(i, j int) bool { return masterSeqNo[i] > masterSeqNo[j] })
+
+	for _, seq := range masterSeqNo {
+		b, err := s.lookupMaster(seq)
+		if err != nil {
+			return nil, err
+		}
+		extra := b.block.Extra
+		if extra == nil || extra.Custom == nil || !extra.Custom.KeyBlock || extra.Custom.ConfigParams == nil {
+			continue
+		}
+		return extra.Custom.ConfigParams.Config.Params.AsCell(), nil
+	}
+
+	return nil, errors.Errorf("no masterchain states or key blocks are found in %s", path)
+}
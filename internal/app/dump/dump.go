@@ -0,0 +1,122 @@
+package dump
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+
+	"github.com/tonindexer/anton/internal/app"
+)
+
+var _ app.BlockSource = (*Service)(nil)
+
+// maxCachedBlocks limits the number of parsed blocks kept in memory,
+// blocks are read several times while looking for unseen shards and transactions
+const maxCachedBlocks = 1024
+
+type loadedBlock struct {
+	id    *ton.BlockIDExt
+	block *tlb.Block
+}
+
+type Service struct {
+	*app.DumpConfig
+
+	masterWorkchain int32
+	masterShard     int64
+
+	files *files
+
+	mx     sync.Mutex
+	blocks map[blockKey]*loadedBlock
+}
+
+func NewService(cfg *app.DumpConfig) (*Service, error) {
+	f, err := readFiles(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.blocks) == 0 {
+		return nil, errors.Errorf("no blocks are found in %s", cfg.Path)
+	}
+
+	log.Info().
+		Str("path", cfg.Path).
+		Int("blocks", len(f.blocks)).
+		Int("states", len(f.states)).
+		Msg("read block dumps")
+
+	return &Service{
+		DumpConfig:      cfg,
+		masterWorkchain: -1,
+		masterShard:     -0x8000000000000000,
+		files:           f,
+		blocks:          make(map[blockKey]*loadedBlock),
+	}, nil
+}
+
+func (s *Service) loadBlock(k blockKey) (*loadedBlock, error) {
+	s.mx.Lock()
+	b, ok := s.blocks[k]
+	s.mx.Unlock()
+	if ok {
+		return b, nil
+	}
+
+	e, ok := s.files.blocks[k]
+	if !ok {
+		return nil, errors.Wrapf(ton.ErrBlockNotFound, "block (%d, %x, %d) is not dumped", k.workchain, uint64(k.shard), k.seqNo)
+	}
+
+	root, fileHash, err := e.readBOC()
+	if err != nil {
+		return nil, err
+	}
+
+	var block tlb.Block
+	if err := tlb.LoadFromCell(&block, root.BeginParse()); err != nil {
+		return nil, errors.Wrapf(err, "load block (%d, %x, %d)", k.workchain, uint64(k.shard), k.seqNo)
+	}
+
+	b = &loadedBlock{
+		id: &ton.BlockIDExt{
+			Workchain: k.workchain,
+			Shard:     k.shard,
+			SeqNo:     k.seqNo,
+			RootHash:  root.Hash(),
+			FileHash:  fileHash,
+		},
+		block: &block,
+	}
+
+	s.mx.Lock()
+	if len(s.blocks) >= maxCachedBlocks {
+		s.blocks = make(map[blockKey]*loadedBlock)
+	}
+	s.blocks[k] = b
+	s.mx.Unlock()
+
+	return b, nil
+}
+
+func (s *Service) loadState(b *ton.BlockIDExt) (*tlb.ShardStateUnsplit, error) {
+	e, ok := s.files.states[key(b)]
+	if !ok {
+		return nil, nil
+	}
+
+	root, _, err := e.readBOC()
+	if err != nil {
+		return nil, err
+	}
+
+	var state tlb.ShardStateUnsplit
+	if err := tlb.LoadFromCell(&state, root.BeginParse()); err != nil {
+		return nil, errors.Wrapf(err, "load shard state (%d, %x, %d)", b.Workchain, uint64(b.Shard), b.SeqNo)
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,197 @@
+package dump
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const (
+	packageMagic = 0xae8fdd01
+	entryMagic   = 0x1e8b
+)
+
+type blockKey struct {
+	workchain int32
+	shard     int64
+	seqNo     uint32
+}
+
+func key(b *ton.BlockIDExt) blockKey {
+	return blockKey{workchain: b.Workchain, shard: b.Shard, seqNo: b.SeqNo}
+}
+
+// entry is a location of the file or the archive package entry
+type entry struct {
+	path   string
+	offset int64
+	size   int64
+}
+
+func (e *entry) read() ([]byte, error) {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", e.path)
+	}
+	defer func() { _ = f.Close() }()
+
+	data := make([]byte, e.size)
+	if _, err := f.ReadAt(data, e.offset); err != nil {
+		return nil, errors.Wrapf(err, "read %s at %d", e.path, e.offset)
+	}
+	return data, nil
+}
+
+// readBOC returns the root cell and the file hash of the entry
+func (e *entry) readBOC() (*cell.Cell, []byte, error) {
+	data, err := e.read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := cell.FromBOC(data)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "parse boc from %s", e.path)
+	}
+
+	fileHash := sha256.Sum256(data)
+	return c, fileHash[:], nil
+}
+
+// entryName matches names of archive package entries, e.g.,
+// block_(-1,8000000000000000,100):<root hash>:<file hash>
+var entryName = regexp.MustCompile(`^(block|state)_\((-?\d+),([0-9a-fA-F]{1,16}),(\d+)\)`)
+
+func parseEntryName(name string) (kind string, k blockKey, ok bool) {
+	m := entryName.FindStringSubmatch(name)
+	if m == nil {
+		return "", blockKey{}, false
+	}
+
+	workchain, err := strconv.ParseInt(m[2], 10, 32)
+	if err != nil {
+		return "", blockKey{}, false
+	}
+	shard, err := strconv.ParseUint(m[3], 16, 64)
+	if err != nil {
+		return "", blockKey{}, false
+	}
+	seqNo, err := strconv.ParseUint(m[4], 10, 32)
+	if err != nil {
+		return "", blockKey{}, false
+	}
+
+	return m[1], blockKey{workchain: int32(workchain), shard: int64(shard), seqNo: uint32(seqNo)}, true
+}
+
+type files struct {
+	blocks map[blockKey]*entry
+	states map[blockKey]*entry
+}
+
+func (f *files) add(name string, e *entry) {
+	kind, k, ok := parseEntryName(name)
+	if !ok {
+		return
+	}
+	switch kind {
+	case "block":
+		f.blocks[k] = e
+	case "state":
+		f.states[k] = e
+	}
+}
+
+// readPackage indexes entries of the archive node package
+func (f *files) readPackage(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "open %s", path)
+	}
+	defer func() { _ = file.Close() }()
+
+	r := bufio.NewReader(file)
+
+	var magic uint32
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return errors.Wrapf(err, "read %s package header", path)
+	}
+	if magic != packageMagic {
+		return errors.Errorf("wrong %s package magic %x", path, magic)
+	}
+
+	offset := int64(4)
+	for {
+		var header struct {
+			Magic    uint16
+			NameSize uint16
+			DataSize uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return errors.Wrapf(err, "read %s entry header at %d", path, offset)
+		}
+		if header.Magic != entryMagic {
+			return errors.Errorf("wrong %s entry magic %x at %d", path, header.Magic, offset)
+		}
+
+		name := make([]byte, header.NameSize)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return errors.Wrapf(err, "read %s entry name at %d", path, offset)
+		}
+		offset += 8 + int64(header.NameSize)
+
+		f.add(string(name), &entry{path: path, offset: offset, size: int64(header.DataSize)})
+
+		if _, err := r.Discard(int(header.DataSize)); err != nil {
+			return errors.Wrapf(err, "skip %s entry %s", path, name)
+		}
+		offset += int64(header.DataSize)
+	}
+}
+
+// readFiles indexes archive packages and block files in the directory
+func readFiles(dir string) (*files, error) {
+	f := &files{
+		blocks: make(map[blockKey]*entry),
+		states: make(map[blockKey]*entry),
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		if strings.HasSuffix(d.Name(), ".pack") {
+			return f.readPackage(path)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f.add(d.Name(), &entry{path: path, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", dir)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,82 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+func writePackage(t *testing.T, path string, entries map[string][]byte, order []string) {
+	var buf bytes.Buffer
+
+	require.Nil(t, binary.Write(&buf, binary.LittleEndian, uint32(packageMagic)))
+	for _, name := range order {
+		require.Nil(t, binary.Write(&buf, binary.LittleEndian, uint16(entryMagic)))
+		require.Nil(t, binary.Write(&buf, binary.LittleEndian, uint16(len(name))))
+		require.Nil(t, binary.Write(&buf, binary.LittleEndian, uint32(len(entries[name]))))
+		buf.WriteString(name)
+		buf.Write(entries[name])
+	}
+
+	require.Nil(t, os.WriteFile(path, buf.Bytes(), 0o600))
+}
+
+func TestParseEntryName(t *testing.T) {
+	kind, k, ok := parseEntryName("block_(-1,8000000000000000,29661500):2A43:8F1B")
+	require.True(t, ok)
+	require.Equal(t, "block", kind)
+	require.Equal(t, blockKey{workchain: -1, shard: -0x8000000000000000, seqNo: 29661500}, k)
+
+	kind, k, ok = parseEntryName("state_(0,6000000000000000,35000000)")
+	require.True(t, ok)
+	require.Equal(t, "state", kind)
+	require.Equal(t, blockKey{workchain: 0, shard: 0x6000000000000000, seqNo: 35000000}, k)
+
+	_, _, ok = parseEntryName("proof_(-1,8000000000000000,29661500):2A43:8F1B")
+	require.False(t, ok)
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	masterBOC := cell.BeginCell().MustStoreUInt(1, 32).EndCell().ToBOC()
+	shardBOC := cell.BeginCell().MustStoreUInt(2, 32).EndCell().ToBOC()
+	stateBOC := cell.BeginCell().MustStoreUInt(3, 32).EndCell().ToBOC()
+
+	masterName := "block_(-1,8000000000000000,100):00:00"
+	proofName := "proof_(-1,8000000000000000,100):00:00"
+	shardName := "block_(0,8000000000000000,200):00:00"
+
+	writePackage(t, filepath.Join(dir, "archive.00100.pack"),
+		map[string][]byte{masterName: masterBOC, proofName: {1, 2, 3}, shardName: shardBOC},
+		[]string{masterName, proofName, shardName})
+
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "states"), 0o700))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "states", "state_(0,8000000000000000,200)"), stateBOC, 0o600))
+
+	f, err := readFiles(dir)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(f.blocks))
+	require.Equal(t, 1, len(f.states))
+
+	for k, exp := range map[blockKey][]byte{
+		{workchain: -1, shard: -0x8000000000000000, seqNo: 100}: masterBOC,
+		{workchain: 0, shard: -0x8000000000000000, seqNo: 200}:  shardBOC,
+	} {
+		e, ok := f.blocks[k]
+		require.True(t, ok)
+
+		got, err := e.read()
+		require.Nil(t, err)
+		require.Equal(t, exp, got)
+	}
+
+	c, _, err := f.states[blockKey{workchain: 0, shard: -0x8000000000000000, seqNo: 200}].readBOC()
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), c.BeginParse().MustLoadUInt(32))
+}
@@ -0,0 +1,254 @@
+package dump
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/fetcher"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/filter"
+)
+
+// loadTransactions extracts transactions from the block account blocks
+func loadTransactions(block *tlb.Block) ([]*tlb.Transaction, error) {
+	if block.Extra == nil || block.Extra.ShardAccountBlocks == nil {
+		return nil, nil
+	}
+
+	var shardAccounts tlb.ShardAccountBlocks
+	if err := tlb.LoadFromCell(&shardAccounts, block.Extra.ShardAccountBlocks.BeginParse()); err != nil {
+		return nil, errors.Wrap(err, "load shard account blocks")
+	}
+	if shardAccounts.Accounts == nil {
+		return nil, nil
+	}
+
+	accounts, err := shardAccounts.Accounts.LoadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "load account blocks")
+	}
+
+	var ret []*tlb.Transaction
+	for _, acc := range accounts {
+		if err := tlb.LoadFromCell(new(tlb.CurrencyCollection), acc.Value); err != nil {
+			return nil, errors.Wrap(err, "load account block currency collection")
+		}
+
+		var accBlock tlb.AccountBlock
+		if err := tlb.LoadFromCell(&accBlock, acc.Value); err != nil {
+			return nil, errors.Wrap(err, "load account block")
+		}
+		if accBlock.Transactions == nil {
+			continue
+		}
+
+		transactions, err := accBlock.Transactions.LoadAll()
+		if err != nil {
+			return nil, errors.Wrapf(err, "load %x account transactions", accBlock.Addr)
+		}
+
+		for _, tx := range transactions {
+			if err := tlb.LoadFromCell(new(tlb.CurrencyCollection), tx.Value); err != nil {
+				return nil, errors.Wrap(err, "load transaction currency collection")
+			}
+
+			c, err := tx.Value.LoadRefCell()
+			if err != nil {
+				return nil, errors.Wrap(err, "load transaction ref")
+			}
+
+			var raw tlb.Transaction
+			if err := tlb.LoadFromCell(&raw, c.BeginParse()); err != nil {
+				return nil, errors.Wrapf(err, "load transaction (hash = %x)", c.Hash())
+			}
+			raw.Hash = c.Hash()
+
+			ret = append(ret, &raw)
+		}
+	}
+
+	return ret, nil
+}
+
+// loadAccount gets the account from the shard state in the same way as liteserver does
+func loadAccount(state *tlb.ShardStateUnsplit, a addr.Address) (*tlb.Account, error) {
+	if state.Accounts.ShardAccounts == nil {
+		return &tlb.Account{}, nil
+	}
+
+	val := state.Accounts.ShardAccounts.Get(cell.BeginCell().MustStoreSlice(a.MustToTonutils().Data(), 256).EndCell())
+	if val == nil {
+		return &tlb.Account{}, nil
+	}
+
+	loader := val.BeginParse()
+	if err := tlb.LoadFromCell(new(tlb.DepthBalanceInfo), loader); err != nil {
+		return nil, errors.Wrap(err, "load depth balance info")
+	}
+
+	var shardAcc tlb.ShardAccount
+	if err := tlb.LoadFromCell(&shardAcc, loader); err != nil {
+		return nil, errors.Wrap(err, "load shard account")
+	}
+
+	var st tlb.AccountState
+	if err := st.LoadFromCell(shardAcc.Account.BeginParse()); err != nil {
+		return nil, errors.Wrap(err, "load account state")
+	}
+	if !st.IsValid {
+		return &tlb.Account{}, nil
+	}
+
+	acc := &tlb.Account{
+		IsActive:   true,
+		State:      &st,
+		LastTxLT:   shardAcc.LastTransLT,
+		LastTxHash: shardAcc.LastTransHash,
+	}
+	if st.Status == tlb.AccountStatusActive && st.StateInit != nil {
+		acc.Code = st.StateInit.Code
+		acc.Data = st.StateInit.Data
+	}
+
+	return acc, nil
+}
+
+func (s *Service) getLastSeenAccountState(ctx context.Context, a addr.Address, lastLT uint64) (*core.AccountState, error) {
+	lastLT++
+
+	accountRes, err := s.AccountRepo.FilterAccounts(ctx, &filter.AccountsReq{
+		WithCodeData: true,
+		Addresses:    []*addr.Address{&a},
+		Order:        "DESC",
+		AfterTxLT:    &lastLT,
+		Limit:        1,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "filter accounts")
+	}
+	if len(accountRes.Rows) < 1 {
+		return nil, errors.Wrap(core.ErrNotFound, "could not find needed account state")
+	}
+
+	return accountRes.Rows[0], nil
+}
+
+func (s *Service) makeGetOtherAccountFunc(b *ton.BlockIDExt, state *tlb.ShardStateUnsplit, lastLT uint64) func(ctx context.Context, a addr.Address) (*core.AccountState, error) {
+	return func(ctx context.Context, a addr.Address) (*core.AccountState, error) {
+		// first attempt is to look for an account in the same shard state
+		if a.Workchain() == int8(b.Workchain) {
+			raw, err := loadAccount(state, a)
+			if err != nil {
+				return nil, err
+			}
+			if raw.State != nil {
+				return fetcher.MapAccount(b, raw), nil
+			}
+		}
+
+		// second attempt is to look for the latest account state in the database
+		if s.AccountRepo == nil {
+			return nil, errors.Wrap(core.ErrNotFound, "no account repository")
+		}
+		return s.getLastSeenAccountState(ctx, a, lastLT)
+	}
+}
+
+func (s *Service) getAccount(ctx context.Context, b *ton.BlockIDExt, state *tlb.ShardStateUnsplit, a addr.Address) (*core.AccountState, error) {
+	if core.SkipAddress(a, core.SkipFetching) {
+		return nil, errors.Wrap(core.ErrNotFound, "skip account")
+	}
+
+	raw, err := loadAccount(state, a)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load account")
+	}
+
+	acc := fetcher.MapAccount(b, raw)
+	if acc.Status == core.NonExist {
+		return nil, errors.Wrap(core.ErrNotFound, "account does not exists")
+	}
+
+	// library cells are not resolved, as libraries are not stored in the block dumps
+	if raw.Code != nil && raw.Code.GetType() != cell.LibraryCellType {
+		acc.GetMethodHashes, _ = abi.GetMethodHashes(raw.Code)
+	}
+
+	getOtherAccount := s.makeGetOtherAccountFunc(b, state, acc.LastTxLT)
+
+	err = s.Parser.ParseAccountData(ctx, acc, getOtherAccount)
+	if err != nil && !errors.Is(err, app.ErrImpossibleParsing) {
+		return nil, errors.Wrapf(err, "parse account data (%s)", acc.Address.String())
+	}
+
+	return acc, nil
+}
+
+// BlockTransactions reads transactions from the dumped block.
+// Account states are taken from the dumped shard state, if it exists.
+func (s *Service) BlockTransactions(ctx context.Context, _, b *ton.BlockIDExt) ([]*core.Transaction, error) {
+	defer app.TimeTrack(time.Now(), "BlockTransactions(%d, %d)", b.Workchain, b.SeqNo)
+
+	loaded, err := s.loadBlock(key(b))
+	if err != nil {
+		return nil, err
+	}
+
+	rawTransactions, err := loadTransactions(loaded.block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load block transactions (workchain = %d, seq = %d)", b.Workchain, b.SeqNo)
+	}
+
+	state, err := s.loadState(b)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil && len(rawTransactions) > 0 {
+		log.Debug().Int32("workchain", b.Workchain).Uint32("seq", b.SeqNo).Msg("shard state is not dumped, skipping account states")
+	}
+
+	accounts := make(map[addr.Address]*core.AccountState)
+
+	transactions := make([]*core.Transaction, 0, len(rawTransactions))
+	for _, raw := range rawTransactions {
+		tx, err := fetcher.MapTransaction(b, raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "map transaction (hash = %x)", raw.Hash)
+		}
+
+		if state != nil {
+			acc, ok := accounts[tx.Address]
+			if !ok {
+				acc, err = s.getAccount(ctx, b, state, tx.Address)
+				if err != nil && !errors.Is(err, core.ErrNotFound) {
+					return nil, errors.Wrapf(err, "get account (addr = %s)", tx.Address.String())
+				}
+				accounts[tx.Address] = acc
+			}
+
+			if acc != nil {
+				tx.Account = acc
+				tx.Account.UpdatedAt = tx.CreatedAt
+				if tx.InMsg != nil {
+					tx.InMsg.DstState = tx.Account
+				}
+				for _, out := range tx.OutMsg {
+					out.SrcState = tx.Account
+				}
+			}
+		}
+
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
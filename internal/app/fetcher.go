@@ -18,6 +18,11 @@ type FetcherConfig struct {
 	AccountRepo filter.AccountRepository
 
 	Parser ParserService
+
+	// FetchWorkers limits the number of transactions with account states requested at the same time
+	FetchWorkers int
+	// ParseWorkers limits the number of account states parsed at the same time
+	ParseWorkers int
 }
 
 func TimeTrack(start time.Time, fun string, args ...any) {
@@ -28,9 +33,17 @@ func TimeTrack(start time.Time, fun string, args ...any) {
 	log.Debug().Str("func", fmt.Sprintf(fun, args...)).Float64("elapsed", elapsed).Msg("timer")
 }
 
+// BlockSource provides masterchain blocks with not yet seen shard blocks,
+// and block transactions with messages and account states.
+// It is implemented by the liteserver fetcher and by the block dumps reader.
+type BlockSource interface {
+	UnseenBlocks(ctx context.Context, masterSeqNo uint32) (master *ton.BlockIDExt, shards []*ton.BlockIDExt, err error)
+	BlockTransactions(ctx context.Context, master, b *ton.BlockIDExt) ([]*core.Transaction, error)
+}
+
 type FetcherService interface {
+	BlockSource
+
 	LookupMaster(ctx context.Context, api ton.APIClientWrapped, seqNo uint32) (*ton.BlockIDExt, error)
-	UnseenBlocks(ctx context.Context, masterSeqNo uint32) (master *ton.BlockIDExt, shards []*ton.BlockIDExt, err error)
 	UnseenShards(ctx context.Context, master *ton.BlockIDExt) (shards []*ton.BlockIDExt, err error)
-	BlockTransactions(ctx context.Context, master, b *ton.BlockIDExt) ([]*core.Transaction, error)
 }
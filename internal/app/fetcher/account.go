@@ -68,13 +68,13 @@ func (s *Service) makeGetOtherAccountFunc(master, b *ton.BlockIDExt, lastLT uint
 	return getOtherAccountFunc
 }
 
-func (s *Service) getAccount(ctx context.Context, master, b *ton.BlockIDExt, a addr.Address) (*core.AccountState, error) {
+func (s *Service) getAccount(ctx context.Context, b *ton.BlockIDExt, a addr.Address) (*core.AccountState, error) {
 	acc, ok := s.accounts.get(b, a)
 	if ok {
 		return acc, nil
 	}
 
-	if core.SkipAddress(a) {
+	if core.SkipAddress(a, core.SkipFetching) {
 		return nil, errors.Wrap(core.ErrNotFound, "skip account")
 	}
 
@@ -115,15 +115,21 @@ func (s *Service) getAccount(ctx context.Context, master, b *ton.BlockIDExt, a a
 		return nil, errors.Wrap(core.ErrNotFound, "account does not exists")
 	}
 
+	s.accounts.set(b, acc)
+	return acc, nil
+}
+
+func (s *Service) parseAccount(ctx context.Context, master, b *ton.BlockIDExt, acc *core.AccountState) error {
+	defer app.TimeTrack(time.Now(), "parseAccount(%d, %d, %d, %s)", b.Workchain, b.Shard, b.SeqNo, acc.Address.String())
+
 	// sometimes, to parse the full account data we need to get other contracts states
 	// for example, to get nft item data
 	getOtherAccount := s.makeGetOtherAccountFunc(master, b, acc.LastTxLT)
 
-	err = s.Parser.ParseAccountData(ctx, acc, getOtherAccount)
+	err := s.Parser.ParseAccountData(ctx, acc, getOtherAccount)
 	if err != nil && !errors.Is(err, app.ErrImpossibleParsing) {
-		return nil, errors.Wrapf(err, "parse account data (%s)", acc.Address.String())
+		return errors.Wrapf(err, "parse account data (%s)", acc.Address.String())
 	}
 
-	s.accounts.set(b, acc)
-	return acc, nil
+	return nil
 }
@@ -3,6 +3,7 @@ package fetcher
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -74,7 +75,17 @@ func (s *Service) getNotSeenShards(ctx context.Context, shard *ton.BlockIDExt, s
 func (s *Service) UnseenBlocks(ctx context.Context, masterSeqNo uint32) (master *ton.BlockIDExt, shards []*ton.BlockIDExt, err error) {
 	curMaster, err := s.LookupMaster(ctx, s.API, masterSeqNo)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "lookup master")
+		if !errors.Is(err, ton.ErrBlockNotFound) && !strings.Contains(err.Error(), "block is not applied") {
+			return nil, nil, errors.Wrap(err, "lookup master")
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		curMaster, err = s.LookupMaster(waitCtx, s.API.WaitForBlock(masterSeqNo), masterSeqNo)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "wait for master block")
+		}
 	}
 
 	shards, err = s.UnseenShards(ctx, curMaster)
@@ -1,6 +1,8 @@
 package fetcher
 
 import (
+	"runtime"
+
 	"github.com/tonindexer/anton/internal/app"
 )
 
@@ -15,9 +17,21 @@ type Service struct {
 	accounts  *accountCache
 	blocks    *blocksCache
 	libraries *librariesCache
+
+	// slots limit concurrent requests and parsing across all fetched blocks
+	fetchSlots chan struct{}
+	parseSlots chan struct{}
 }
 
 func NewService(cfg *app.FetcherConfig) *Service {
+	// validate config
+	if cfg.FetchWorkers < 1 {
+		cfg.FetchWorkers = 32
+	}
+	if cfg.ParseWorkers < 1 {
+		cfg.ParseWorkers = runtime.NumCPU()
+	}
+
 	return &Service{
 		FetcherConfig:   cfg,
 		masterWorkchain: -1,
@@ -25,5 +39,7 @@ func NewService(cfg *app.FetcherConfig) *Service {
 		accounts:        newAccountCache(),
 		blocks:          newBlocksCache(),
 		libraries:       newLibrariesCache(),
+		fetchSlots:      make(chan struct{}, cfg.FetchWorkers),
+		parseSlots:      make(chan struct{}, cfg.ParseWorkers),
 	}
 }
@@ -148,7 +148,7 @@ func parseOperationID(body []byte) (opId uint32, comment string, err error) {
 	return opId, comment, nil
 }
 
-func mapMessage(tx *tlb.Transaction, message tlb.Message) (*core.Message, error) {
+func MapMessage(tx *tlb.Transaction, message tlb.Message) (*core.Message, error) {
 	var (
 		msg = new(core.Message)
 		err error
@@ -215,7 +215,7 @@ func mapTransactionDescription(desc any, tx *core.Transaction) {
 	}
 }
 
-func mapTransaction(b *ton.BlockIDExt, raw *tlb.Transaction) (*core.Transaction, error) {
+func MapTransaction(b *ton.BlockIDExt, raw *tlb.Transaction) (*core.Transaction, error) {
 	tx := &core.Transaction{
 		Hash: raw.Hash,
 
@@ -242,7 +242,7 @@ func mapTransaction(b *ton.BlockIDExt, raw *tlb.Transaction) (*core.Transaction,
 		tx.BlockSeqNo = b.SeqNo
 	}
 	if raw.IO.In != nil && raw.IO.In.Msg != nil {
-		in, err := mapMessage(raw, *raw.IO.In)
+		in, err := MapMessage(raw, *raw.IO.In)
 		if err != nil {
 			return nil, errors.Wrap(err, "map incoming message")
 		}
@@ -259,7 +259,7 @@ func mapTransaction(b *ton.BlockIDExt, raw *tlb.Transaction) (*core.Transaction,
 			return nil, errors.Wrap(err, "getting outgoing tx messages")
 		}
 		for _, m := range messages {
-			out, err := mapMessage(raw, m)
+			out, err := MapMessage(raw, m)
 			if err != nil {
 				return nil, errors.Wrap(err, "map outgoing message")
 			}
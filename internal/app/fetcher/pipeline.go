@@ -0,0 +1,68 @@
+package fetcher
+
+import (
+	"context"
+	"sync"
+)
+
+// errOnce keeps the first error of the pipeline and cancels its context
+type errOnce struct {
+	once   sync.Once
+	err    error
+	cancel context.CancelFunc
+}
+
+func (e *errOnce) set(err error) {
+	if err == nil {
+		return
+	}
+	e.once.Do(func() {
+		e.err = err
+		e.cancel()
+	})
+}
+
+// runStage starts workers, which apply f to the input values and send results to the output channel.
+// Service-wide slots limit the number of f calls running at the same time across all pipelines.
+// After the context is canceled, workers drain the input without processing, so previous stages do not block.
+// The output channel is closed, when the input is closed and all workers are done.
+func runStage[In, Out any](
+	ctx context.Context,
+	workers int,
+	slots chan struct{},
+	in <-chan In,
+	out chan<- Out,
+	f func(In) (Out, error),
+	fail func(error),
+) {
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for v := range in {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					continue
+				}
+
+				res, err := f(v)
+				<-slots
+
+				if err != nil {
+					fail(err)
+					continue
+				}
+				out <- res
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+}
@@ -0,0 +1,72 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runTestPipeline(ctx context.Context, n int, f func(int) (int, error)) ([]int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		in     = make(chan int)
+		out    = make(chan int)
+		slots  = make(chan struct{}, 2)
+		failed = &errOnce{cancel: cancel}
+	)
+
+	go func() {
+		defer close(in)
+		for i := 0; i < n; i++ {
+			in <- i
+		}
+	}()
+
+	runStage(ctx, 4, slots, in, out, f, failed.set)
+
+	var ret []int
+	for v := range out {
+		ret = append(ret, v)
+	}
+	sort.Ints(ret)
+
+	return ret, failed.err
+}
+
+func TestRunStage(t *testing.T) {
+	var running, maxRunning int32
+
+	got, err := runTestPipeline(context.Background(), 100, func(v int) (int, error) {
+		cur := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if cur <= m || atomic.CompareAndSwapInt32(&maxRunning, m, cur) {
+				break
+			}
+		}
+		return v * 2, nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, 100, len(got))
+	require.Equal(t, 198, got[99])
+	require.LessOrEqual(t, maxRunning, int32(2))
+}
+
+func TestRunStage_Error(t *testing.T) {
+	errTest := errors.New("test")
+
+	_, err := runTestPipeline(context.Background(), 1000, func(v int) (int, error) {
+		if v == 10 {
+			return 0, errTest
+		}
+		return v, nil
+	})
+	require.ErrorIs(t, err, errTest)
+}
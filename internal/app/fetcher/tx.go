@@ -14,65 +14,25 @@ import (
 	"github.com/tonindexer/anton/internal/core"
 )
 
-func (s *Service) getTransaction(ctx context.Context, master, b *ton.BlockIDExt, id ton.TransactionShortInfo) (*core.Transaction, error) {
-	var tx *core.Transaction
-	var acc *core.AccountState
-
-	type ret struct {
-		res any
-		err error
-	}
-
+func (s *Service) getTransaction(ctx context.Context, b *ton.BlockIDExt, id ton.TransactionShortInfo) (*core.Transaction, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	a := address.NewAddress(0, byte(b.Workchain), id.Account)
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	txCh := make(chan ret)
-	go func() {
-		rawTx, err := s.API.GetTransaction(ctx, b, a, id.LT)
-		if err == nil {
-			tx, err := mapTransaction(b, rawTx)
-			txCh <- ret{res: tx, err: errors.Wrapf(err, "map transaction (hash = %x)", rawTx.Hash)}
-			return
-		}
-		txCh <- ret{
-			err: errors.Wrapf(err, "get transaction (workchain = %d, seq = %d, addr = %s, lt = %d)",
-				b.Workchain, b.SeqNo, a.String(), id.LT),
-		}
-	}()
-
-	accCh := make(chan ret)
-	go func() {
-		acc, err := s.getAccount(ctx, master, b, *addr.MustFromTonutils(a))
-		accCh <- ret{res: acc, err: errors.Wrapf(err, "get account (addr = %s)", a)}
-	}()
-
-	go func() {
-		wg.Wait()
-		close(txCh)
-		close(accCh)
-	}()
-
-	for i := 0; i < 2; i++ {
-		select {
-		case accRet := <-accCh:
-			if err := accRet.err; err != nil && !errors.Is(err, core.ErrNotFound) {
-				return nil, err
-			}
-			if accRet.res != nil {
-				acc = accRet.res.(*core.AccountState) //nolint:forcetypeassert // that's ok
-			}
+	rawTx, err := s.API.GetTransaction(ctx, b, a, id.LT)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get transaction (workchain = %d, seq = %d, addr = %s, lt = %d)",
+			b.Workchain, b.SeqNo, a.String(), id.LT)
+	}
+	tx, err := MapTransaction(b, rawTx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "map transaction (hash = %x)", rawTx.Hash)
+	}
 
-		case txRet := <-txCh:
-			if err := txRet.err; err != nil {
-				return nil, err
-			}
-			tx = txRet.res.(*core.Transaction) //nolint:forcetypeassert // that's ok
-		}
+	acc, err := s.getAccount(ctx, b, *addr.MustFromTonutils(a))
+	if err != nil && !errors.Is(err, core.ErrNotFound) {
+		return nil, errors.Wrapf(err, "get account (addr = %s)", a)
 	}
 
 	tx.Account = acc
@@ -88,77 +48,90 @@ func (s *Service) getTransaction(ctx context.Context, master, b *ton.BlockIDExt,
 	return tx, nil
 }
 
-func (s *Service) getTransactions(ctx context.Context, master, b *ton.BlockIDExt, ids []ton.TransactionShortInfo) ([]*core.Transaction, error) {
-	var wg sync.WaitGroup
-
-	type ret struct {
-		tx  *core.Transaction
-		err error
-	}
-
-	defer app.TimeTrack(time.Now(), "getTransactions(%d, %d)", b.Workchain, b.SeqNo)
-
-	ch := make(chan ret, len(ids))
+func (s *Service) fetchTxIDs(ctx context.Context, b *ton.BlockIDExt, after *ton.TransactionID3) ([]ton.TransactionShortInfo, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
 
-	wg.Add(len(ids))
+	return s.API.GetBlockTransactionsV2(ctx, b, 100, after)
+}
 
-	for i := range ids {
-		go func(id ton.TransactionShortInfo) {
-			defer wg.Done()
-			tx, err := s.getTransaction(ctx, master, b, id)
-			ch <- ret{tx: tx, err: err}
-		}(ids[i])
-	}
+// listTxIDs sends ids of all block transactions to the channel page by page
+func (s *Service) listTxIDs(ctx context.Context, b *ton.BlockIDExt, ids chan<- ton.TransactionShortInfo) error {
+	var after *ton.TransactionID3
 
-	go func() {
-		wg.Wait()
-		close(ch)
-	}()
+	for more := true; more; {
+		fetchedIDs, hasMore, err := s.fetchTxIDs(ctx, b, after)
+		if err != nil {
+			return errors.Wrapf(err, "get block transactions (workchain = %d, seq = %d)", b.Workchain, b.SeqNo)
+		}
+		if more = hasMore; more {
+			after = fetchedIDs[len(fetchedIDs)-1].ID3()
+		}
 
-	results := make([]*core.Transaction, 0, len(ids))
-	for r := range ch {
-		if r.err != nil {
-			return nil, errors.Wrapf(r.err, "get transaction")
+		for _, id := range fetchedIDs {
+			select {
+			case ids <- id:
+			case <-ctx.Done():
+				return nil
+			}
 		}
-		results = append(results, r.tx)
 	}
 
-	return results, nil
+	return nil
 }
 
-func (s *Service) fetchTxIDs(ctx context.Context, b *ton.BlockIDExt, after *ton.TransactionID3) ([]ton.TransactionShortInfo, bool, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
+// BlockTransactions fetches block transactions in a pipeline:
+// transaction ids are listed page by page, then transactions are fetched with account states,
+// then account states are parsed. Stages are connected with bounded channels,
+// so a slow stage holds back the previous ones.
+func (s *Service) BlockTransactions(ctx context.Context, master, b *ton.BlockIDExt) ([]*core.Transaction, error) {
+	defer app.TimeTrack(time.Now(), "BlockTransactions(%d, %d)", b.Workchain, b.SeqNo)
 
-	return s.API.GetBlockTransactionsV2(ctx, b, 100, after)
-}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-func (s *Service) BlockTransactions(ctx context.Context, master, b *ton.BlockIDExt) ([]*core.Transaction, error) {
 	var (
-		after        *ton.TransactionID3
-		fetchedIDs   []ton.TransactionShortInfo
-		transactions []*core.Transaction
-		more         = true
-		err          error
+		ids     = make(chan ton.TransactionShortInfo, s.FetchWorkers)
+		fetched = make(chan *core.Transaction, s.ParseWorkers)
+		parsed  = make(chan *core.Transaction, s.ParseWorkers)
+		failed  = &errOnce{cancel: cancel}
+
+		// the same account state can be shared by several transactions in the block
+		parsedAccounts   = make(map[*core.AccountState]struct{})
+		parsedAccountsMx sync.Mutex
 	)
 
-	defer app.TimeTrack(time.Now(), "BlockTransactions(%d, %d)", b.Workchain, b.SeqNo)
+	go func() {
+		defer close(ids)
+		failed.set(s.listTxIDs(ctx, b, ids))
+	}()
 
-	for more {
-		fetchedIDs, more, err = s.fetchTxIDs(ctx, b, after)
-		if err != nil {
-			return nil, errors.Wrapf(err, "get block transactions (workchain = %d, seq = %d)", b.Workchain, b.SeqNo)
-		}
-		if more {
-			after = fetchedIDs[len(fetchedIDs)-1].ID3()
+	runStage(ctx, s.FetchWorkers, s.fetchSlots, ids, fetched, func(id ton.TransactionShortInfo) (*core.Transaction, error) {
+		return s.getTransaction(ctx, b, id)
+	}, failed.set)
+
+	runStage(ctx, s.ParseWorkers, s.parseSlots, fetched, parsed, func(tx *core.Transaction) (*core.Transaction, error) {
+		if tx.Account == nil {
+			return tx, nil
 		}
 
-		rawTx, err := s.getTransactions(ctx, master, b, fetchedIDs)
-		if err != nil {
-			return nil, err
+		parsedAccountsMx.Lock()
+		_, ok := parsedAccounts[tx.Account]
+		parsedAccounts[tx.Account] = struct{}{}
+		parsedAccountsMx.Unlock()
+		if ok {
+			return tx, nil
 		}
 
-		transactions = append(transactions, rawTx...)
+		return tx, s.parseAccount(ctx, master, b, tx.Account)
+	}, failed.set)
+
+	var transactions []*core.Transaction
+	for tx := range parsed {
+		transactions = append(transactions, tx)
+	}
+	if failed.err != nil {
+		return nil, failed.err
 	}
 
 	return transactions, nil
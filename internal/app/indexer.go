@@ -1,21 +1,62 @@
 package app
 
 import (
-	"github.com/xssnick/tonutils-go/ton"
+	"time"
 
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/repository"
 )
 
+// IndexerFilter selects accounts, which states, transactions and messages are saved.
+// Allowlists of addresses, code hashes and interfaces are combined,
+// so an account is watched if it matches any of them.
+// Denylists take precedence over allowlists.
+// Blocks are saved regardless of the filter, so gaps in the indexed chain are still detectable.
+type IndexerFilter struct {
+	Workchains        []int32
+	ExcludeWorkchains []int32
+
+	Addresses        []*addr.Address
+	ExcludeAddresses []*addr.Address
+
+	CodeHashes        [][]byte
+	ExcludeCodeHashes [][]byte
+
+	Interfaces        []abi.ContractName
+	ExcludeInterfaces []abi.ContractName
+
+	// WatchedMessagesOnly drops messages, which do not come from or to watched accounts.
+	// Otherwise, all messages are saved.
+	WatchedMessagesOnly bool
+}
+
 type IndexerConfig struct {
 	DB *repository.DB
 
-	API ton.APIClientWrapped
+	BlockRepo   core.BlockRepository
+	TxRepo      core.TransactionRepository
+	MessageRepo repository.Message
+	AccountRepo core.AccountRepository
+	OutboxRepo  core.OutboxRepository
 
-	Fetcher FetcherService
-	Parser  ParserService
+	Source BlockSource
+	Parser ParserService
+
+	// Filter is optional, the whole network is indexed without it
+	Filter *IndexerFilter
 
 	FromBlock uint32
-	Workers   int
+	// Workers is a number of masterchain blocks fetched at the same time
+	Workers int
+	// ParseWorkers limits the number of messages parsed at the same time
+	ParseWorkers int
+
+	// FlushBlocks is a number of masterchain blocks inserted in a single database transaction
+	FlushBlocks int
+	// FlushInterval limits the time a parsed block waits for the next ones to be inserted with
+	FlushInterval time.Duration
 }
 
 type IndexerService interface {
@@ -3,11 +3,9 @@ package indexer
 import (
 	"context"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/xssnick/tonutils-go/ton"
 
@@ -15,28 +13,6 @@ import (
 	"github.com/tonindexer/anton/internal/core"
 )
 
-func (s *Service) getUnseenBlocks(ctx context.Context, seq uint32) (master *ton.BlockIDExt, shards []*ton.BlockIDExt, err error) {
-	master, shards, err = s.Fetcher.UnseenBlocks(ctx, seq)
-	if err != nil {
-		if !errors.Is(err, ton.ErrBlockNotFound) && !(err != nil && strings.Contains(err.Error(), "block is not applied")) {
-			return nil, nil, errors.Wrap(err, "cannot fetch unseen blocks")
-		}
-
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		defer cancel()
-
-		master, err = s.Fetcher.LookupMaster(ctx, s.API.WaitForBlock(seq), seq)
-		if err != nil {
-			return nil, nil, errors.Wrap(err, "wait for master block")
-		}
-		shards, err = s.Fetcher.UnseenShards(ctx, master)
-		if err != nil {
-			return nil, nil, errors.Wrap(err, "get unseen shards")
-		}
-	}
-	return master, shards, nil
-}
-
 func (s *Service) fetchMaster(seq uint32) *core.Block {
 	type processedBlock struct {
 		block *core.Block
@@ -45,10 +21,10 @@ func (s *Service) fetchMaster(seq uint32) *core.Block {
 
 	defer app.TimeTrack(time.Now(), "fetchMaster(%d)", seq)
 
-	for {
+	for s.running() {
 		ctx := context.Background()
 
-		master, shards, err := s.getUnseenBlocks(ctx, seq)
+		master, shards, err := s.Source.UnseenBlocks(ctx, seq)
 		if err != nil {
 			log.Error().Err(err).Uint32("master_seq", seq).Msg("get unseen blocks")
 			time.Sleep(time.Second)
@@ -63,7 +39,7 @@ func (s *Service) fetchMaster(seq uint32) *core.Block {
 		go func() {
 			defer wg.Done()
 
-			tx, err := s.Fetcher.BlockTransactions(ctx, master, master)
+			tx, err := s.Source.BlockTransactions(ctx, master, master)
 
 			ch <- processedBlock{
 				block: &core.Block{
@@ -83,7 +59,7 @@ func (s *Service) fetchMaster(seq uint32) *core.Block {
 			go func(shard *ton.BlockIDExt) {
 				defer wg.Done()
 
-				tx, err := s.Fetcher.BlockTransactions(ctx, master, shard)
+				tx, err := s.Source.BlockTransactions(ctx, master, shard)
 
 				ch <- processedBlock{
 					block: &core.Block{
@@ -136,6 +112,8 @@ func (s *Service) fetchMaster(seq uint32) *core.Block {
 			return gotMaster
 		}
 	}
+
+	return nil
 }
 
 func (s *Service) fetchMastersConcurrent(fromBlock uint32) []*core.Block {
@@ -179,7 +157,11 @@ func (s *Service) fetchMasterLoop(fromBlock uint32, results chan<- *core.Block)
 			if fromBlock != blocks[i].SeqNo {
 				break
 			}
-			results <- blocks[i]
+			select {
+			case results <- blocks[i]:
+			case <-s.done:
+				return
+			}
 			fromBlock++
 		}
 	}
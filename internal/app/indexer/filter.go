@@ -0,0 +1,159 @@
+package indexer
+
+import (
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+type accountFilter struct {
+	workchains, excludeWorkchains map[int32]bool
+	addresses, excludeAddresses   map[addr.Address]bool
+	codeHashes, excludeCodeHashes map[string]bool
+	interfaces, excludeInterfaces map[abi.ContractName]bool
+
+	watchedMessagesOnly bool
+}
+
+func newAccountFilter(cfg *app.IndexerFilter) *accountFilter {
+	if cfg == nil {
+		return nil
+	}
+
+	f := &accountFilter{
+		workchains:          map[int32]bool{},
+		excludeWorkchains:   map[int32]bool{},
+		addresses:           map[addr.Address]bool{},
+		excludeAddresses:    map[addr.Address]bool{},
+		codeHashes:          map[string]bool{},
+		excludeCodeHashes:   map[string]bool{},
+		interfaces:          map[abi.ContractName]bool{},
+		excludeInterfaces:   map[abi.ContractName]bool{},
+		watchedMessagesOnly: cfg.WatchedMessagesOnly,
+	}
+	for _, w := range cfg.Workchains {
+		f.workchains[w] = true
+	}
+	for _, w := range cfg.ExcludeWorkchains {
+		f.excludeWorkchains[w] = true
+	}
+	for _, a := range cfg.Addresses {
+		f.addresses[*a] = true
+	}
+	for _, a := range cfg.ExcludeAddresses {
+		f.excludeAddresses[*a] = true
+	}
+	for _, h := range cfg.CodeHashes {
+		f.codeHashes[string(h)] = true
+	}
+	for _, h := range cfg.ExcludeCodeHashes {
+		f.excludeCodeHashes[string(h)] = true
+	}
+	for _, i := range cfg.Interfaces {
+		f.interfaces[i] = true
+	}
+	for _, i := range cfg.ExcludeInterfaces {
+		f.excludeInterfaces[i] = true
+	}
+
+	return f
+}
+
+func (f *accountFilter) allowlisted() bool {
+	return len(f.addresses) > 0 || len(f.codeHashes) > 0 || len(f.interfaces) > 0
+}
+
+// watched checks if the account data should be saved.
+// Code hashes and interfaces are matched only if the account state is known.
+func (f *accountFilter) watched(a addr.Address, state *core.AccountState) bool {
+	wc := int32(a.Workchain())
+	if len(f.workchains) > 0 && !f.workchains[wc] {
+		return false
+	}
+	if f.excludeWorkchains[wc] || f.excludeAddresses[a] {
+		return false
+	}
+
+	if state != nil {
+		if len(state.CodeHash) > 0 && f.excludeCodeHashes[string(state.CodeHash)] {
+			return false
+		}
+		for _, t := range state.Types {
+			if f.excludeInterfaces[t] {
+				return false
+			}
+		}
+	}
+
+	if !f.allowlisted() || f.addresses[a] {
+		return true
+	}
+	if state == nil {
+		return false
+	}
+	if len(state.CodeHash) > 0 && f.codeHashes[string(state.CodeHash)] {
+		return true
+	}
+	for _, t := range state.Types {
+		if f.interfaces[t] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchedMessage checks if the message comes from or to a watched account
+func (f *accountFilter) watchedMessage(msg *core.Message) bool {
+	if msg.Type != core.ExternalIn && f.watched(msg.SrcAddress, msg.SrcState) {
+		return true
+	}
+	if msg.Type != core.ExternalOut && f.watched(msg.DstAddress, msg.DstState) {
+		return true
+	}
+	return false
+}
+
+// filterTransactions drops transactions of not watched accounts
+// and counts the dropped data in the block records.
+func (s *Service) filterTransactions(blocks []*core.Block) (ret []*core.Transaction) {
+	for _, b := range blocks {
+		for _, tx := range b.Transactions {
+			if s.filter.watched(tx.Address, tx.Account) {
+				ret = append(ret, tx)
+				continue
+			}
+			b.FilteredTransactionsCount++
+			if tx.Account != nil {
+				b.FilteredAccountsCount++
+			}
+		}
+	}
+	return ret
+}
+
+// countFilteredMessages counts the dropped messages in the block records,
+// a message is accounted to the first block it appears in.
+func countFilteredMessages(blocks []*core.Block, saved []*core.Message) {
+	seen := make(map[string]bool, len(saved))
+	for _, msg := range saved {
+		seen[string(msg.Hash)] = true
+	}
+
+	for _, b := range blocks {
+		for _, tx := range b.Transactions {
+			messages := tx.OutMsg
+			if tx.InMsg != nil {
+				messages = append([]*core.Message{tx.InMsg}, messages...)
+			}
+			for _, msg := range messages {
+				if seen[string(msg.Hash)] {
+					continue
+				}
+				seen[string(msg.Hash)] = true
+				b.FilteredMessagesCount++
+			}
+		}
+	}
+}
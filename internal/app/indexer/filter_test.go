@@ -0,0 +1,79 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+func TestAccountFilter_Watched(t *testing.T) {
+	var (
+		wallet = addr.MustFromBase64("EQDj5AA8mQvM5wJEQsFFFof79y3ZsuX6wowktWQFhz_Anton")
+		minter = addr.MustFromBase64("EQBCPrKazoIMW0CBYbHitNdrh2Lf_s70EtqdSqp0Y4k9Ul6N")
+		master = addr.MustFromString("-1:3333333333333333333333333333333333333333333333333333333333333333")
+	)
+
+	minterState := &core.AccountState{Address: *minter, CodeHash: []byte{1}, Types: []abi.ContractName{"jetton_minter"}}
+
+	f := newAccountFilter(&app.IndexerFilter{ExcludeWorkchains: []int32{-1}})
+	require.True(t, f.watched(*wallet, nil))
+	require.False(t, f.watched(*master, nil))
+
+	f = newAccountFilter(&app.IndexerFilter{Addresses: []*addr.Address{wallet}})
+	require.True(t, f.watched(*wallet, nil))
+	require.False(t, f.watched(*minter, minterState))
+
+	f = newAccountFilter(&app.IndexerFilter{Interfaces: []abi.ContractName{"jetton_minter"}})
+	require.True(t, f.watched(*minter, minterState))
+	require.False(t, f.watched(*minter, nil))
+	require.False(t, f.watched(*wallet, &core.AccountState{Address: *wallet, CodeHash: []byte{2}}))
+
+	f = newAccountFilter(&app.IndexerFilter{
+		CodeHashes:       [][]byte{{1}},
+		ExcludeAddresses: []*addr.Address{minter},
+	})
+	require.False(t, f.watched(*minter, minterState))
+
+	f = newAccountFilter(&app.IndexerFilter{
+		Addresses:           []*addr.Address{wallet},
+		WatchedMessagesOnly: true,
+	})
+	require.True(t, f.watchedMessage(&core.Message{Type: core.Internal, SrcAddress: *minter, DstAddress: *wallet}))
+	require.False(t, f.watchedMessage(&core.Message{Type: core.ExternalOut, SrcAddress: *minter, DstAddress: *wallet}))
+	require.True(t, f.watchedMessage(&core.Message{Type: core.ExternalIn, DstAddress: *wallet}))
+	require.False(t, f.watchedMessage(&core.Message{Type: core.ExternalIn, DstAddress: *minter}))
+}
+
+func TestService_FilterTransactions(t *testing.T) {
+	var (
+		wallet = addr.MustFromBase64("EQDj5AA8mQvM5wJEQsFFFof79y3ZsuX6wowktWQFhz_Anton")
+		minter = addr.MustFromBase64("EQBCPrKazoIMW0CBYbHitNdrh2Lf_s70EtqdSqp0Y4k9Ul6N")
+	)
+
+	toMinter := &core.Message{Type: core.Internal, Hash: []byte{1}, SrcAddress: *wallet, DstAddress: *minter}
+	fromMinter := &core.Message{Type: core.Internal, Hash: []byte{2}, SrcAddress: *minter, DstAddress: *minter}
+
+	b := &core.Block{Transactions: []*core.Transaction{
+		{Address: *wallet, Account: &core.AccountState{Address: *wallet}, OutMsg: []*core.Message{toMinter}},
+		{Address: *minter, Account: &core.AccountState{Address: *minter}, InMsg: toMinter, OutMsg: []*core.Message{fromMinter}},
+	}}
+
+	s := &Service{filter: newAccountFilter(&app.IndexerFilter{
+		Addresses:           []*addr.Address{wallet},
+		WatchedMessagesOnly: true,
+	})}
+
+	got := s.filterTransactions([]*core.Block{b})
+	require.Equal(t, 1, len(got))
+	require.Equal(t, *wallet, got[0].Address)
+	require.Equal(t, 1, b.FilteredTransactionsCount)
+	require.Equal(t, 1, b.FilteredAccountsCount)
+
+	countFilteredMessages([]*core.Block{b}, []*core.Message{toMinter})
+	require.Equal(t, 1, b.FilteredMessagesCount)
+}
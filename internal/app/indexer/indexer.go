@@ -2,6 +2,7 @@ package indexer
 
 import (
 	"context"
+	"runtime"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -9,11 +10,6 @@ import (
 
 	"github.com/tonindexer/anton/internal/app"
 	"github.com/tonindexer/anton/internal/core"
-	"github.com/tonindexer/anton/internal/core/repository"
-	"github.com/tonindexer/anton/internal/core/repository/account"
-	"github.com/tonindexer/anton/internal/core/repository/block"
-	"github.com/tonindexer/anton/internal/core/repository/msg"
-	"github.com/tonindexer/anton/internal/core/repository/tx"
 )
 
 var _ app.IndexerService = (*Service)(nil)
@@ -21,14 +17,13 @@ var _ app.IndexerService = (*Service)(nil)
 type Service struct {
 	*app.IndexerConfig
 
-	blockRepo   core.BlockRepository
-	txRepo      core.TransactionRepository
-	msgRepo     repository.Message
-	accountRepo core.AccountRepository
+	filter  *accountFilter
+	pending *pendingMessages
 
-	run bool
-	mx  sync.RWMutex
-	wg  sync.WaitGroup
+	run  bool
+	done chan struct{} // closed on stop to unblock sending to the next stage
+	mx   sync.RWMutex
+	wg   sync.WaitGroup
 }
 
 func NewService(cfg *app.IndexerConfig) *Service {
@@ -43,12 +38,17 @@ func NewService(cfg *app.IndexerConfig) *Service {
 	if s.FromBlock < 2 {
 		s.FromBlock = 2
 	}
+	if s.ParseWorkers < 1 {
+		s.ParseWorkers = runtime.NumCPU()
+	}
+	if s.FlushBlocks < 1 {
+		s.FlushBlocks = 1
+	}
 
-	ch, pg := s.DB.CH, s.DB.PG
-	s.txRepo = tx.NewRepository(ch, pg)
-	s.msgRepo = msg.NewRepository(ch, pg)
-	s.blockRepo = block.NewRepository(ch, pg)
-	s.accountRepo = account.NewRepository(ch, pg)
+	s.filter = newAccountFilter(s.Filter)
+
+	// parsed blocks channel capacity, blocks being inserted together and the one being parsed
+	s.pending = newPendingMessages(s.Workers + s.FlushBlocks + 1)
 
 	return s
 }
@@ -65,7 +65,11 @@ func (s *Service) Start() error {
 
 	fromBlock := s.FromBlock
 
-	lastMaster, err := s.blockRepo.GetLastMasterBlock(ctx)
+	if err := s.flushPendingBatches(ctx); err != nil {
+		return err
+	}
+
+	lastMaster, err := s.BlockRepo.GetLastMasterBlock(ctx)
 	switch {
 	case err == nil:
 		fromBlock = lastMaster.SeqNo + 1
@@ -75,19 +79,28 @@ func (s *Service) Start() error {
 
 	s.mx.Lock()
 	s.run = true
+	s.done = make(chan struct{})
 	s.mx.Unlock()
 
+	// fetched blocks are parsed and saved in the order of masterchain blocks,
+	// bounded channels between stages hold back a faster stage
 	blocksChan := make(chan *core.Block, s.Workers*2)
+	parsedChan := make(chan *parsedBlock, s.Workers)
 
 	s.wg.Add(1)
 	go s.fetchMasterLoop(fromBlock, blocksChan)
 
 	s.wg.Add(1)
-	go s.saveBlocksLoop(blocksChan)
+	go s.parseBlocksLoop(blocksChan, parsedChan)
+
+	s.wg.Add(1)
+	go s.saveBlocksLoop(parsedChan)
 
 	log.Info().
 		Uint32("from_block", fromBlock).
 		Int("workers", s.Workers).
+		Int("parse_workers", s.ParseWorkers).
+		Int("flush_blocks", s.FlushBlocks).
 		Msg("started")
 
 	return nil
@@ -96,6 +109,7 @@ func (s *Service) Start() error {
 func (s *Service) Stop() {
 	s.mx.Lock()
 	s.run = false
+	close(s.done)
 	s.mx.Unlock()
 
 	s.wg.Wait()
@@ -0,0 +1,50 @@
+package indexer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/metrics"
+)
+
+var (
+	blocksTotal = metrics.NewCounter(
+		"anton_indexer_blocks_total", "Number of saved blocks.", "workchain")
+	transactionsTotal = metrics.NewCounter(
+		"anton_indexer_transactions_total", "Number of saved transactions.")
+	messagesTotal = metrics.NewCounter(
+		"anton_indexer_messages_total", "Number of saved messages.")
+	accountStatesTotal = metrics.NewCounter(
+		"anton_indexer_account_states_total", "Number of saved account states.")
+
+	masterSeqNo = metrics.NewGauge(
+		"anton_indexer_masterchain_seq_no", "Sequence number of the last saved masterchain block.")
+	masterBlockAge = metrics.NewGauge(
+		"anton_indexer_masterchain_block_age_seconds", "Time passed from the creation of the last saved masterchain block to its saving.")
+
+	insertDuration = metrics.NewHistogram(
+		"anton_indexer_insert_duration_seconds", "Duration of repository calls inserting the indexed data.", metrics.DefBuckets, "call")
+)
+
+func observeSavedBlock(master *core.Block, blocks []*core.Block, acc, msg, tx int) {
+	for _, b := range blocks {
+		blocksTotal.Inc(strconv.Itoa(int(b.Workchain)))
+	}
+	transactionsTotal.Add(float64(tx))
+	messagesTotal.Add(float64(msg))
+	accountStatesTotal.Add(float64(acc))
+
+	masterSeqNo.Set(float64(master.SeqNo))
+
+	// masterchain blocks always have tick-tock transactions
+	var createdAt time.Time
+	for _, t := range master.Transactions {
+		if t.CreatedAt.After(createdAt) {
+			createdAt = t.CreatedAt
+		}
+	}
+	if !createdAt.IsZero() {
+		masterBlockAge.Set(time.Since(createdAt).Seconds())
+	}
+}
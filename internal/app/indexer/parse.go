@@ -0,0 +1,160 @@
+package indexer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+// parsedBlock is a masterchain block with shard blocks ready to be inserted
+type parsedBlock struct {
+	master       *core.Block
+	blocks       []*core.Block
+	accounts     []*core.AccountState
+	messages     []*core.Message
+	transactions []*core.Transaction
+}
+
+// pendingMessages keeps messages of the last parsed masterchain blocks,
+// as they can be not inserted yet, when sources of the next blocks messages are looked up
+type pendingMessages struct {
+	blocks [][]*core.Message
+	hashes map[string]*core.Message
+	size   int
+}
+
+func newPendingMessages(size int) *pendingMessages {
+	return &pendingMessages{hashes: map[string]*core.Message{}, size: size}
+}
+
+func (p *pendingMessages) add(messages []*core.Message) {
+	p.blocks = append(p.blocks, messages)
+	for _, msg := range messages {
+		p.hashes[string(msg.Hash)] = msg
+	}
+
+	if len(p.blocks) <= p.size {
+		return
+	}
+	for _, msg := range p.blocks[0] {
+		if p.hashes[string(msg.Hash)] == msg {
+			delete(p.hashes, string(msg.Hash))
+		}
+	}
+	p.blocks = p.blocks[1:]
+}
+
+func (p *pendingMessages) getSource(hash []byte) (*core.Message, bool) {
+	msg, ok := p.hashes[string(hash)]
+	if !ok || msg.SrcTxLT == 0 {
+		return nil, false
+	}
+	return msg, true
+}
+
+func (s *Service) parseMessage(ctx context.Context, message *core.Message) {
+	err := s.Parser.ParseMessagePayload(ctx, message)
+	if errors.Is(err, app.ErrImpossibleParsing) {
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).
+			Hex("msg_hash", message.Hash).
+			Hex("src_tx_hash", message.SrcTxHash).
+			Str("src_addr", message.SrcAddress.String()).
+			Hex("dst_tx_hash", message.DstTxHash).
+			Str("dst_addr", message.DstAddress.String()).
+			Uint32("op_id", message.OperationID).
+			Msg("parse message payload")
+	}
+}
+
+func (s *Service) parseMessages(ctx context.Context, messages []*core.Message) {
+	defer app.TimeTrack(time.Now(), "parseMessages(%d)", len(messages))
+
+	var wg sync.WaitGroup
+
+	ch := make(chan *core.Message)
+
+	wg.Add(s.ParseWorkers)
+	for i := 0; i < s.ParseWorkers; i++ {
+		go func() {
+			defer wg.Done()
+			for msg := range ch {
+				s.parseMessage(ctx, msg)
+			}
+		}()
+	}
+
+	for _, msg := range messages {
+		ch <- msg
+	}
+	close(ch)
+
+	wg.Wait()
+}
+
+func (s *Service) parseBlock(ctx context.Context, master *core.Block) *parsedBlock {
+	newBlocks := append([]*core.Block{master}, master.Shards...)
+
+	var newTransactions []*core.Transaction
+	for i := range newBlocks {
+		newTransactions = append(newTransactions, newBlocks[i].Transactions...)
+	}
+
+	newMessages := s.uniqMessages(ctx, newTransactions)
+	s.pending.add(newMessages)
+
+	if s.filter != nil {
+		if s.filter.watchedMessagesOnly {
+			countFilteredMessages(newBlocks, newMessages)
+		}
+		newTransactions = s.filterTransactions(newBlocks)
+	}
+
+	// messages are parsed before the database transaction is opened
+	s.parseMessages(ctx, newMessages)
+
+	return &parsedBlock{
+		master:       master,
+		blocks:       newBlocks,
+		accounts:     s.uniqAccounts(newTransactions),
+		messages:     newMessages,
+		transactions: newTransactions,
+	}
+}
+
+func (s *Service) parseBlocksLoop(blocks <-chan *core.Block, results chan<- *parsedBlock) {
+	defer s.wg.Done()
+
+	t := time.NewTicker(100 * time.Millisecond)
+	defer t.Stop()
+
+	for s.running() {
+		var b *core.Block
+
+		select {
+		case b = <-blocks:
+		case <-t.C:
+			continue
+		}
+
+		log.Debug().
+			Uint32("master_seq_no", b.SeqNo).
+			Int("master_tx", len(b.Transactions)).
+			Int("shards", len(b.Shards)).
+			Msg("new master")
+
+		select {
+		case results <- s.parseBlock(context.Background(), b):
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package indexer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+func TestPendingMessages(t *testing.T) {
+	p := newPendingMessages(2)
+
+	out := &core.Message{Hash: []byte{1}, SrcTxLT: 10}
+	in := &core.Message{Hash: []byte{2}, DstTxLT: 20}
+
+	p.add([]*core.Message{out, in})
+
+	got, ok := p.getSource([]byte{1})
+	require.True(t, ok)
+	require.Equal(t, out, got)
+
+	_, ok = p.getSource([]byte{2})
+	require.False(t, ok)
+
+	p.add(nil)
+	_, ok = p.getSource([]byte{1})
+	require.True(t, ok)
+
+	p.add(nil)
+	_, ok = p.getSource([]byte{1})
+	require.False(t, ok)
+}
+
+func TestService_parseBlocksLoop_Stop(t *testing.T) {
+	s := &Service{
+		IndexerConfig: &app.IndexerConfig{ParseWorkers: 1},
+		pending:       newPendingMessages(1),
+		run:           true,
+		done:          make(chan struct{}),
+	}
+
+	blocks := make(chan *core.Block, 1)
+	results := make(chan *parsedBlock) // nobody reads parsed blocks
+
+	s.wg.Add(1)
+	go s.parseBlocksLoop(blocks, results)
+
+	blocks <- &core.Block{Workchain: -1, SeqNo: 2}
+	require.Eventually(t, func() bool { return len(blocks) == 0 }, time.Second, 10*time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("parse loop is blocked on sending the parsed block")
+	}
+}
@@ -14,71 +14,118 @@ import (
 	"github.com/tonindexer/anton/internal/core"
 )
 
-func (s *Service) insertData(
-	ctx context.Context,
-	acc []*core.AccountState,
-	msg []*core.Message,
-	tx []*core.Transaction,
-	b []*core.Block,
-) error {
-	dbTx, err := s.DB.PG.Begin()
+// insertData commits rows of several masterchain blocks to PostgreSQL with their outbox batch keys,
+// and only then writes them to ClickHouse, so ClickHouse never gets rows missing in PostgreSQL.
+// Rows are copied on the connection, which the PostgreSQL transaction is begun on.
+func (s *Service) insertData(ctx context.Context, batches []*core.OutboxBatch) error {
+	var (
+		acc []*core.AccountState
+		msg []*core.Message
+		tx  []*core.Transaction
+		b   []*core.Block
+	)
+	for _, batch := range batches {
+		acc = append(acc, batch.Accounts...)
+		msg = append(msg, batch.Messages...)
+		tx = append(tx, batch.Transactions...)
+		b = append(b, batch.Blocks...)
+	}
+
+	conn, err := s.DB.PG.Conn(ctx)
 	if err != nil {
-		return errors.Wrap(err, "cannot begin db tx")
+		return errors.Wrap(err, "cannot get db conn")
 	}
 	defer func() {
-		_ = dbTx.Rollback()
+		_ = conn.Close()
 	}()
 
-	for _, message := range msg {
-		err := s.Parser.ParseMessagePayload(ctx, message)
-		if errors.Is(err, app.ErrImpossibleParsing) {
-			continue
-		}
-		if err != nil {
-			log.Error().Err(err).
-				Hex("msg_hash", message.Hash).
-				Hex("src_tx_hash", message.SrcTxHash).
-				Str("src_addr", message.SrcAddress.String()).
-				Hex("dst_tx_hash", message.DstTxHash).
-				Str("dst_addr", message.DstAddress.String()).
-				Uint32("op_id", message.OperationID).
-				Msg("parse message payload")
-		}
+	dbTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot begin db tx")
 	}
+	defer func() {
+		_ = dbTx.Rollback()
+	}()
 
 	if err := func() error {
-		defer app.TimeTrack(time.Now(), "AddAccountStates(%d)", len(acc))
-		return s.accountRepo.AddAccountStates(ctx, dbTx, acc)
+		defer app.TimeTrack(time.Now(), "CopyAccountStates(%d)", len(acc))
+		defer insertDuration.ObserveSince(time.Now(), "CopyAccountStates")
+		return s.AccountRepo.CopyAccountStates(ctx, conn, acc)
 	}(); err != nil {
-		return errors.Wrap(err, "add account states")
+		return errors.Wrap(err, "copy account states")
 	}
 
 	if err := func() error {
-		defer app.TimeTrack(time.Now(), "AddMessages(%d)", len(msg))
-		sort.Slice(msg, func(i, j int) bool { return msg[i].CreatedLT < msg[j].CreatedLT })
-		return s.msgRepo.AddMessages(ctx, dbTx, msg)
+		defer app.TimeTrack(time.Now(), "CopyMessages(%d)", len(msg))
+		defer insertDuration.ObserveSince(time.Now(), "CopyMessages")
+		// stable, as the same message from the later block updates the earlier one
+		sort.SliceStable(msg, func(i, j int) bool { return msg[i].CreatedLT < msg[j].CreatedLT })
+		return s.MessageRepo.CopyMessages(ctx, conn, msg)
 	}(); err != nil {
-		return errors.Wrap(err, "add messages")
+		return errors.Wrap(err, "copy messages")
 	}
 
 	if err := func() error {
-		defer app.TimeTrack(time.Now(), "AddTransactions(%d)", len(tx))
-		return s.txRepo.AddTransactions(ctx, dbTx, tx)
+		defer app.TimeTrack(time.Now(), "CopyTransactions(%d)", len(tx))
+		defer insertDuration.ObserveSince(time.Now(), "CopyTransactions")
+		return s.TxRepo.CopyTransactions(ctx, conn, tx)
 	}(); err != nil {
-		return errors.Wrap(err, "add transactions")
+		return errors.Wrap(err, "copy transactions")
 	}
 
 	if err := func() error {
-		defer app.TimeTrack(time.Now(), "AddBlocks(%d)", len(b))
-		return s.blockRepo.AddBlocks(ctx, dbTx, b)
+		defer app.TimeTrack(time.Now(), "CopyBlocks(%d)", len(b))
+		defer insertDuration.ObserveSince(time.Now(), "CopyBlocks")
+		return s.BlockRepo.CopyBlocks(ctx, conn, b)
 	}(); err != nil {
-		return errors.Wrap(err, "add blocks")
+		return errors.Wrap(err, "copy blocks")
+	}
+
+	for _, batch := range batches {
+		if err := s.OutboxRepo.AddBatch(ctx, dbTx, batch); err != nil {
+			return errors.Wrap(err, "add outbox batch")
+		}
 	}
 
 	if err := dbTx.Commit(); err != nil {
 		return errors.Wrap(err, "cannot commit db tx")
 	}
 
+	for _, batch := range batches {
+		if err := func() error {
+			defer app.TimeTrack(time.Now(), "FlushBatch(%d)", batch.SeqNo)
+			defer insertDuration.ObserveSince(time.Now(), "FlushBatch")
+			return s.OutboxRepo.FlushBatch(ctx, batch)
+		}(); err != nil {
+			return errors.Wrap(err, "flush outbox batch")
+		}
+	}
+
+	return nil
+}
+
+// flushPendingBatches writes to ClickHouse the batches committed to PostgreSQL,
+// which were not flushed before the indexer stopped.
+func (s *Service) flushPendingBatches(ctx context.Context) error {
+	batches, err := s.OutboxRepo.GetPendingBatches(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get pending outbox batches")
+	}
+
+	for _, b := range batches {
+		log.Info().
+			Int32("workchain", b.Workchain).Int64("shard", b.Shard).Uint32("seq_no", b.SeqNo).
+			Int("blocks", len(b.Blocks)).
+			Int("transactions", len(b.Transactions)).
+			Int("messages", len(b.Messages)).
+			Int("accounts", len(b.Accounts)).
+			Msg("flushing pending outbox batch")
+
+		if err := s.OutboxRepo.FlushBatch(ctx, b); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -132,7 +179,14 @@ func (s *Service) addMessage(msg *core.Message, uniqMsg map[string]*core.Message
 }
 
 func (s *Service) getMessageSource(ctx context.Context, msg *core.Message) (skip bool) {
-	source, err := s.msgRepo.GetMessage(context.Background(), msg.Hash)
+	// source can be in the previous block, which is not inserted yet
+	if source, ok := s.pending.getSource(msg.Hash); ok {
+		msg.SrcTxLT, msg.SrcShard, msg.SrcBlockSeqNo, msg.SrcState =
+			source.SrcTxLT, source.SrcShard, source.SrcBlockSeqNo, source.SrcState
+		return false
+	}
+
+	source, err := s.MessageRepo.GetMessage(context.Background(), msg.Hash)
 	if err == nil {
 		msg.SrcTxLT, msg.SrcShard, msg.SrcBlockSeqNo, msg.SrcState =
 			source.SrcTxLT, source.SrcShard, source.SrcBlockSeqNo, source.SrcState
@@ -142,12 +196,17 @@ func (s *Service) getMessageSource(ctx context.Context, msg *core.Message) (skip
 		panic(errors.Wrapf(err, "get message with hash %s", msg.Hash))
 	}
 
+	// messages from not watched accounts are not saved with the indexer filter
+	if s.filter != nil && s.filter.watchedMessagesOnly {
+		return false
+	}
+
 	// some masterchain messages does not have source
 	if msg.SrcAddress.Workchain() == -1 || msg.DstAddress.Workchain() == -1 {
 		return false
 	}
 
-	blocks, err := s.blockRepo.CountMasterBlocks(ctx)
+	blocks, err := s.BlockRepo.CountMasterBlocks(ctx)
 	if err != nil {
 		panic(errors.Wrap(err, "count masterchain blocks"))
 	}
@@ -181,6 +240,9 @@ func (s *Service) uniqMessages(ctx context.Context, transactions []*core.Transac
 	}
 
 	for _, msg := range uniqMsg {
+		if s.filter != nil && s.filter.watchedMessagesOnly && !s.filter.watchedMessage(msg) {
+			continue
+		}
 		if msg.Type == core.Internal && (msg.SrcTxLT == 0 && msg.DstTxLT != 0) {
 			if s.getMessageSource(ctx, msg) {
 				continue
@@ -195,45 +257,68 @@ func (s *Service) uniqMessages(ctx context.Context, transactions []*core.Transac
 
 var lastLog = time.Now()
 
-func (s *Service) saveBlock(ctx context.Context, master *core.Block) {
-	newBlocks := append([]*core.Block{master}, master.Shards...)
-
-	var newTransactions []*core.Transaction
-	for i := range newBlocks {
-		newTransactions = append(newTransactions, newBlocks[i].Transactions...)
+func (s *Service) saveBlocks(ctx context.Context, blocks []*parsedBlock) {
+	batches := make([]*core.OutboxBatch, 0, len(blocks))
+	for _, b := range blocks {
+		batches = append(batches, &core.OutboxBatch{
+			Workchain:    b.master.Workchain,
+			Shard:        b.master.Shard,
+			SeqNo:        b.master.SeqNo,
+			Blocks:       b.blocks,
+			Transactions: b.transactions,
+			Messages:     b.messages,
+			Accounts:     b.accounts,
+		})
 	}
-
-	if err := s.insertData(ctx, s.uniqAccounts(newTransactions), s.uniqMessages(ctx, newTransactions), newTransactions, newBlocks); err != nil {
+	if err := s.insertData(ctx, batches); err != nil {
 		panic(err)
 	}
 
+	for _, b := range blocks {
+		observeSavedBlock(b.master, b.blocks, len(b.accounts), len(b.messages), len(b.transactions))
+	}
+
 	lvl := log.Debug()
 	if time.Since(lastLog) > 10*time.Minute {
 		lvl = log.Info()
 		lastLog = time.Now()
 	}
-	lvl.Uint32("last_inserted_seq", master.SeqNo).Msg("inserted new block")
+	lvl.Uint32("last_inserted_seq", blocks[len(blocks)-1].master.SeqNo).Int("blocks", len(blocks)).Msg("inserted new blocks")
 }
 
-func (s *Service) saveBlocksLoop(results <-chan *core.Block) {
+// saveBlocksLoop inserts parsed blocks together, when there are FlushBlocks of them,
+// or when the first one has waited for FlushInterval.
+// Blocks collected before the stop are inserted, the ones left in the channel are fetched again on start.
+func (s *Service) saveBlocksLoop(results <-chan *parsedBlock) {
+	defer s.wg.Done()
+
 	t := time.NewTicker(100 * time.Millisecond)
 	defer t.Stop()
 
-	for s.running() {
-		var b *core.Block
+	var (
+		blocks []*parsedBlock
+		since  time.Time
+	)
 
+	for s.running() {
 		select {
-		case b = <-results:
+		case b := <-results:
+			if len(blocks) == 0 {
+				since = time.Now()
+			}
+			blocks = append(blocks, b)
 		case <-t.C:
+		}
+
+		if len(blocks) == 0 || (len(blocks) < s.FlushBlocks && time.Since(since) < s.FlushInterval) {
 			continue
 		}
 
-		log.Debug().
-			Uint32("master_seq_no", b.SeqNo).
-			Int("master_tx", len(b.Transactions)).
-			Int("shards", len(b.Shards)).
-			Msg("new master")
+		s.saveBlocks(context.Background(), blocks)
+		blocks = nil
+	}
 
-		s.saveBlock(context.Background(), b)
+	if len(blocks) > 0 {
+		s.saveBlocks(context.Background(), blocks)
 	}
 }
@@ -0,0 +1,178 @@
+package indexer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/repository/account"
+	"github.com/tonindexer/anton/internal/core/repository/block"
+	"github.com/tonindexer/anton/internal/core/repository/msg"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/tx"
+	"github.com/tonindexer/anton/internal/core/rndm"
+)
+
+// copiedBlocks records sequence numbers of masterchain blocks copied together.
+type copiedBlocks struct {
+	core.BlockRepository
+
+	mx     sync.Mutex
+	copied [][]uint32
+}
+
+func (r *copiedBlocks) CopyBlocks(ctx context.Context, conn bun.Conn, blocks []*core.Block) error {
+	var seqNo []uint32
+	for _, b := range blocks {
+		if b.Workchain == -1 {
+			seqNo = append(seqNo, b.SeqNo)
+		}
+	}
+
+	r.mx.Lock()
+	r.copied = append(r.copied, seqNo)
+	r.mx.Unlock()
+
+	return r.BlockRepository.CopyBlocks(ctx, conn, blocks)
+}
+
+func (r *copiedBlocks) get() [][]uint32 {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	return append([][]uint32(nil), r.copied...)
+}
+
+func newTestService(db *repository.DB, cfg *app.IndexerConfig) *Service {
+	cfg.DB = db
+	cfg.BlockRepo = block.NewRepository(db.CH, db.PG)
+	cfg.TxRepo = tx.NewRepository(db.CH, db.PG)
+	cfg.MessageRepo = msg.NewRepository(db.CH, db.PG)
+	cfg.AccountRepo = account.NewRepository(db.CH, db.PG)
+	cfg.OutboxRepo = outbox.NewRepository(db.CH, db.PG)
+	return NewService(cfg)
+}
+
+func randomParsedBlock() *parsedBlock {
+	master, shard := rndm.MasterBlock(), rndm.Block(0)
+
+	// nothing is filtered, which is the usual case
+	return &parsedBlock{
+		master:       master,
+		blocks:       []*core.Block{master, shard},
+		accounts:     rndm.AccountStates(3),
+		messages:     rndm.Messages(3),
+		transactions: rndm.BlockTransactions(shard.ID(), 3),
+	}
+}
+
+func TestService_insertData(t *testing.T) {
+	ctx := context.Background()
+
+	db := initDB(t)
+	s := newTestService(db, &app.IndexerConfig{})
+
+	b := randomParsedBlock()
+
+	s.saveBlocks(ctx, []*parsedBlock{b})
+
+	for _, exp := range b.blocks {
+		got := new(core.Block)
+		err := db.PG.NewSelect().Model(got).
+			Where("workchain = ?", exp.Workchain).
+			Where("shard = ?", exp.Shard).
+			Where("seq_no = ?", exp.SeqNo).
+			Scan(ctx)
+		require.Nil(t, err)
+		require.Equal(t, 0, got.FilteredTransactionsCount)
+		require.Equal(t, 0, got.FilteredMessagesCount)
+		require.Equal(t, 0, got.FilteredAccountsCount)
+	}
+
+	for _, c := range []struct {
+		model any
+		count int
+	}{
+		{(*core.Block)(nil), len(b.blocks)},
+		{(*core.Transaction)(nil), len(b.transactions)},
+		{(*core.Message)(nil), len(b.messages)},
+		{(*core.AccountState)(nil), len(b.accounts)},
+	} {
+		count, err := db.PG.NewSelect().Model(c.model).Count(ctx)
+		require.Nil(t, err)
+		require.Equal(t, c.count, count)
+
+		// rows are flushed to ClickHouse after commit
+		count, err = db.CH.NewSelect().Model(c.model).Count(ctx)
+		require.Nil(t, err)
+		require.Equal(t, c.count, count)
+	}
+
+	pending, err := s.OutboxRepo.GetPendingBatches(ctx)
+	require.Nil(t, err)
+	require.Empty(t, pending)
+}
+
+func TestService_saveBlocksLoop_Flush(t *testing.T) {
+	db := initDB(t)
+
+	start := func(flushBlocks int, flushInterval time.Duration) (*Service, *copiedBlocks, chan *parsedBlock) {
+		s := newTestService(db, &app.IndexerConfig{FlushBlocks: flushBlocks, FlushInterval: flushInterval})
+		blocks := &copiedBlocks{BlockRepository: s.BlockRepo}
+		s.BlockRepo = blocks
+		s.run, s.done = true, make(chan struct{})
+
+		results := make(chan *parsedBlock)
+
+		s.wg.Add(1)
+		go s.saveBlocksLoop(results)
+
+		return s, blocks, results
+	}
+
+	send := func(results chan<- *parsedBlock, n int) (seqNo []uint32) {
+		for i := 0; i < n; i++ {
+			b := randomParsedBlock()
+			results <- b
+			seqNo = append(seqNo, b.master.SeqNo)
+		}
+		return seqNo
+	}
+
+	t.Run("flush on blocks count", func(t *testing.T) {
+		s, blocks, results := start(3, time.Hour)
+
+		seqNo := send(results, 5)
+		require.Eventually(t, func() bool { return len(blocks.get()) == 1 }, 10*time.Second, 10*time.Millisecond)
+		require.Equal(t, [][]uint32{seqNo[:3]}, blocks.get())
+
+		// blocks collected before the stop are inserted
+		s.Stop()
+		require.Equal(t, [][]uint32{seqNo[:3], seqNo[3:]}, blocks.get())
+	})
+
+	t.Run("flush on interval", func(t *testing.T) {
+		s, blocks, results := start(100, 300*time.Millisecond)
+
+		seqNo := send(results, 2)
+		time.Sleep(100 * time.Millisecond)
+		require.Empty(t, blocks.get())
+
+		require.Eventually(t, func() bool { return len(blocks.get()) == 1 }, 10*time.Second, 10*time.Millisecond)
+		require.Equal(t, [][]uint32{seqNo}, blocks.get())
+
+		seqNo = append(seqNo, send(results, 1)...)
+		require.Eventually(t, func() bool { return len(blocks.get()) == 2 }, 10*time.Second, 10*time.Millisecond)
+		require.Equal(t, [][]uint32{seqNo[:2], seqNo[2:]}, blocks.get())
+
+		s.Stop()
+		require.Len(t, blocks.get(), 2)
+	})
+}
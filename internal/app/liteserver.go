@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/xssnick/tonutils-go/ton"
+)
+
+type Liteserver struct {
+	Host string
+	Key  string
+}
+
+type LiteserverConfig struct {
+	Servers []*Liteserver
+
+	// GlobalConfig is a path or url to the global config file,
+	// liteservers from it are added to the given ones
+	GlobalConfig string
+
+	// Dial connects to the liteserver, by default each server gets a separate connection pool
+	Dial func(ctx context.Context, ls *Liteserver) (ton.APIClientWrapped, error)
+
+	// CheckInterval is a period of health checks and the oldest available block lookups
+	CheckInterval time.Duration
+
+	// MaxFailures is a number of consecutive failed requests, after which the server is ejected
+	MaxFailures int
+	// EjectTimeout is a time after which the ejected server is checked and re-admitted
+	EjectTimeout time.Duration
+}
+
+type LiteserverStats struct {
+	Host string `json:"host"`
+	Key  string `json:"key"`
+
+	Archive         bool   `json:"archive"`
+	LastMasterSeqNo uint32 `json:"last_master_seq_no"`
+	// OldestMasterSeqNo is the oldest masterchain block available on the server
+	OldestMasterSeqNo uint32 `json:"oldest_master_seq_no"`
+
+	Requests  uint64        `json:"requests"`
+	Failures  uint64        `json:"failures"`
+	ErrorRate float64       `json:"error_rate"`
+	Latency   time.Duration `json:"latency"`
+
+	Ejected      bool      `json:"ejected"`
+	EjectedUntil time.Time `json:"ejected_until,omitempty"`
+}
+
+// LiteserverManager routes requests to healthy liteservers
+// and historical requests to the servers having the requested block.
+type LiteserverManager interface {
+	ton.APIClientWrapped
+
+	Stats() []*LiteserverStats
+	Close()
+}
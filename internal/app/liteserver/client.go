@@ -0,0 +1,244 @@
+package liteserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+var _ ton.APIClientWrapped = (*client)(nil)
+
+type blockRef struct {
+	workchain int32
+	seqNo     uint32
+}
+
+func ref(b *ton.BlockIDExt) *blockRef {
+	if b == nil {
+		return nil
+	}
+	return &blockRef{workchain: b.Workchain, seqNo: b.SeqNo}
+}
+
+// client routes requests to the manager servers,
+// options like WaitForBlock or WithTimeout are applied to every used server
+type client struct {
+	m *Manager
+
+	maxAttempts int
+	waitSeqNo   *uint32
+	timeout     time.Duration
+}
+
+func (c *client) wrap(api ton.APIClientWrapped) ton.APIClientWrapped {
+	if c.timeout > 0 {
+		api = api.WithTimeout(c.timeout)
+	}
+	if c.waitSeqNo != nil {
+		api = api.WaitForBlock(*c.waitSeqNo)
+	}
+	return api
+}
+
+func (c *client) do(ctx context.Context, b *blockRef, f func(api ton.APIClientWrapped) error) error {
+	return c.m.do(ctx, c, b, f)
+}
+
+func (c *client) Client() ton.LiteClient {
+	return &liteClient{c: c}
+}
+
+func (c *client) GetTime(ctx context.Context) (ret uint32, err error) {
+	err = c.do(ctx, nil, func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetTime(ctx)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) GetLibraries(ctx context.Context, list ...[]byte) (ret []*cell.Cell, err error) {
+	err = c.do(ctx, nil, func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetLibraries(ctx, list...)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) LookupBlock(ctx context.Context, workchain int32, shard int64, seqno uint32) (ret *ton.BlockIDExt, err error) {
+	b := &blockRef{workchain: workchain, seqNo: seqno}
+	err = c.do(ctx, b, func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.LookupBlock(ctx, workchain, shard, seqno)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) GetBlockData(ctx context.Context, block *ton.BlockIDExt) (ret *tlb.Block, err error) {
+	err = c.do(ctx, ref(block), func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetBlockData(ctx, block)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) GetBlockTransactionsV2(ctx context.Context, block *ton.BlockIDExt, count uint32, after ...*ton.TransactionID3) (ret []ton.TransactionShortInfo, more bool, err error) {
+	err = c.do(ctx, ref(block), func(api ton.APIClientWrapped) (err error) {
+		ret, more, err = api.GetBlockTransactionsV2(ctx, block, count, after...)
+		return err
+	})
+	return ret, more, err
+}
+
+func (c *client) GetBlockShardsInfo(ctx context.Context, master *ton.BlockIDExt) (ret []*ton.BlockIDExt, err error) {
+	err = c.do(ctx, ref(master), func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetBlockShardsInfo(ctx, master)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) GetBlockchainConfig(ctx context.Context, block *ton.BlockIDExt, onlyParams ...int32) (ret *ton.BlockchainConfig, err error) {
+	err = c.do(ctx, ref(block), func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetBlockchainConfig(ctx, block, onlyParams...)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) GetMasterchainInfo(ctx context.Context) (ret *ton.BlockIDExt, err error) {
+	err = c.do(ctx, nil, func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetMasterchainInfo(ctx)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) GetAccount(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (ret *tlb.Account, err error) {
+	err = c.do(ctx, ref(block), func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetAccount(ctx, block, addr)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) SendExternalMessage(ctx context.Context, msg *tlb.ExternalMessage) error {
+	return c.do(ctx, nil, func(api ton.APIClientWrapped) error {
+		return api.SendExternalMessage(ctx, msg)
+	})
+}
+
+func (c *client) RunGetMethod(ctx context.Context, block *ton.BlockIDExt, addr *address.Address, method string, params ...interface{}) (ret *ton.ExecutionResult, err error) {
+	err = c.do(ctx, ref(block), func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.RunGetMethod(ctx, block, addr, method, params...)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) ListTransactions(ctx context.Context, addr *address.Address, num uint32, lt uint64, txHash []byte) (ret []*tlb.Transaction, err error) {
+	err = c.do(ctx, nil, func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.ListTransactions(ctx, addr, num, lt, txHash)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) GetTransaction(ctx context.Context, block *ton.BlockIDExt, addr *address.Address, lt uint64) (ret *tlb.Transaction, err error) {
+	err = c.do(ctx, ref(block), func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetTransaction(ctx, block, addr, lt)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) GetBlockProof(ctx context.Context, known, target *ton.BlockIDExt) (ret *ton.PartialBlockProof, err error) {
+	err = c.do(ctx, ref(known), func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.GetBlockProof(ctx, known, target)
+		return err
+	})
+	return ret, err
+}
+
+func (c *client) CurrentMasterchainInfo(ctx context.Context) (ret *ton.BlockIDExt, err error) {
+	err = c.do(ctx, nil, func(api ton.APIClientWrapped) (err error) {
+		ret, err = api.CurrentMasterchainInfo(ctx)
+		return err
+	})
+	return ret, err
+}
+
+// SubscribeOnTransactions is a long-running request, so it is sent to the best server at the moment
+func (c *client) SubscribeOnTransactions(workerCtx context.Context, addr *address.Address, lastProcessedLT uint64, channel chan<- *tlb.Transaction) {
+	c.wrap(c.m.candidates(nil)[0].api).SubscribeOnTransactions(workerCtx, addr, lastProcessedLT, channel)
+}
+
+func (c *client) VerifyProofChain(ctx context.Context, from, to *ton.BlockIDExt) error {
+	return c.do(ctx, ref(from), func(api ton.APIClientWrapped) error {
+		return api.VerifyProofChain(ctx, from, to)
+	})
+}
+
+func (c *client) WaitForBlock(seqno uint32) ton.APIClientWrapped {
+	ret := *c
+	ret.waitSeqNo = &seqno
+	return &ret
+}
+
+// WithRetry limits the number of servers tried for a request,
+// by default all servers are tried
+func (c *client) WithRetry(maxRetries ...int) ton.APIClientWrapped {
+	ret := *c
+	ret.maxAttempts = 0
+	if len(maxRetries) > 0 {
+		ret.maxAttempts = maxRetries[0]
+	}
+	return &ret
+}
+
+func (c *client) WithTimeout(timeout time.Duration) ton.APIClientWrapped {
+	ret := *c
+	ret.timeout = timeout
+	return &ret
+}
+
+func (c *client) SetTrustedBlock(block *ton.BlockIDExt) {
+	for _, n := range c.m.nodes {
+		n.api.SetTrustedBlock(block)
+	}
+}
+
+func (c *client) SetTrustedBlockFromConfig(cfg *liteclient.GlobalConfig) {
+	for _, n := range c.m.nodes {
+		n.api.SetTrustedBlockFromConfig(cfg)
+	}
+}
+
+// liteClient sends raw liteserver queries through the manager
+type liteClient struct {
+	c *client
+}
+
+func (l *liteClient) QueryLiteserver(ctx context.Context, payload tl.Serializable, result tl.Serializable) error {
+	return l.c.do(ctx, nil, func(api ton.APIClientWrapped) error {
+		return api.Client().QueryLiteserver(ctx, payload, result)
+	})
+}
+
+func (l *liteClient) StickyContext(ctx context.Context) context.Context {
+	return ctx
+}
+
+func (l *liteClient) StickyContextNextNode(ctx context.Context) (context.Context, error) {
+	return ctx, errors.New("servers are switched by the liteserver manager")
+}
+
+func (l *liteClient) StickyNodeID(context.Context) uint32 {
+	return 0
+}
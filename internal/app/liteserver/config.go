@@ -0,0 +1,86 @@
+package liteserver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/ton"
+
+	"github.com/tonindexer/anton/internal/app"
+)
+
+// ParseServers parses comma separated list of liteservers in the host:port|key format.
+func ParseServers(list string) ([]*app.Liteserver, error) {
+	var ret []*app.Liteserver
+
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		split := strings.Split(addr, "|")
+		if len(split) != 2 {
+			return nil, fmt.Errorf("wrong server address format '%s'", addr)
+		}
+		ret = append(ret, &app.Liteserver{Host: split[0], Key: split[1]})
+	}
+
+	return ret, nil
+}
+
+func intToIP4(ipInt int64) string {
+	b0 := strconv.FormatInt((ipInt>>24)&0xff, 10)
+	b1 := strconv.FormatInt((ipInt>>16)&0xff, 10)
+	b2 := strconv.FormatInt((ipInt>>8)&0xff, 10)
+	b3 := strconv.FormatInt(ipInt&0xff, 10)
+	return b0 + "." + b1 + "." + b2 + "." + b3
+}
+
+// GlobalConfigServers returns liteservers from the global config file, given by path or url.
+func GlobalConfigServers(ctx context.Context, path string) ([]*app.Liteserver, error) {
+	var (
+		cfg *liteclient.GlobalConfig
+		err error
+	)
+
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		cfg, err = liteclient.GetConfigFromUrl(ctx, path)
+	} else {
+		cfg, err = liteclient.GetConfigFromFile(path)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get global config from %s", path)
+	}
+
+	var ret []*app.Liteserver
+	for i := range cfg.Liteservers {
+		ls := &cfg.Liteservers[i]
+		ret = append(ret, &app.Liteserver{
+			Host: fmt.Sprintf("%s:%d", intToIP4(ls.IP), ls.Port),
+			Key:  ls.ID.Key,
+		})
+	}
+
+	return ret, nil
+}
+
+func connect(ctx context.Context, ls *app.Liteserver) (*liteclient.ConnectionPool, error) {
+	client := liteclient.NewConnectionPool()
+	if err := client.AddConnection(ctx, ls.Host, ls.Key); err != nil {
+		return nil, errors.Wrapf(err, "cannot add connection with %s host and %s key", ls.Host, ls.Key)
+	}
+	return client, nil
+}
+
+// Dial connects to the liteserver using a separate connection pool,
+// so that the manager decides which server is used for a request.
+func Dial(ctx context.Context, ls *app.Liteserver) (ton.APIClientWrapped, error) {
+	client, err := connect(ctx, ls)
+	if err != nil {
+		return nil, err
+	}
+	return ton.NewAPIClient(client, ton.ProofCheckPolicyUnsafe), nil
+}
@@ -0,0 +1,311 @@
+package liteserver
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/xssnick/tonutils-go/ton"
+
+	"github.com/tonindexer/anton/internal/app"
+)
+
+var _ app.LiteserverManager = (*Manager)(nil)
+
+var ErrNoLiteservers = errors.New("no available liteservers")
+
+type Manager struct {
+	*client
+
+	cfg   *app.LiteserverConfig
+	nodes []*node
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewManager(ctx context.Context, cfg *app.LiteserverConfig) (*Manager, error) {
+	servers := cfg.Servers
+	if cfg.GlobalConfig != "" {
+		fromConfig, err := GlobalConfigServers(ctx, cfg.GlobalConfig)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, fromConfig...)
+	}
+	if cfg.Dial == nil {
+		cfg.Dial = Dial
+	}
+	if cfg.CheckInterval == 0 {
+		cfg.CheckInterval = time.Minute
+	}
+	if cfg.MaxFailures == 0 {
+		cfg.MaxFailures = 5
+	}
+	if cfg.EjectTimeout == 0 {
+		cfg.EjectTimeout = 30 * time.Second
+	}
+
+	m := &Manager{cfg: cfg}
+	m.client = &client{m: m}
+
+	var unique []*app.Liteserver
+	seen := make(map[string]bool)
+	for _, ls := range servers {
+		if !seen[ls.Host] {
+			unique = append(unique, ls)
+		}
+		seen[ls.Host] = true
+	}
+
+	var wg sync.WaitGroup
+	nodes := make([]*node, len(unique))
+	for it := range unique {
+		wg.Add(1)
+		go func(it int, ls *app.Liteserver) {
+			defer wg.Done()
+			api, err := cfg.Dial(ctx, ls)
+			if err != nil {
+				log.Error().Err(err).Str("host", ls.Host).Msg("cannot connect to liteserver")
+				return
+			}
+			nodes[it] = &node{host: ls.Host, key: ls.Key, api: api}
+		}(it, unique[it])
+	}
+	wg.Wait()
+
+	for _, n := range nodes {
+		if n != nil {
+			m.nodes = append(m.nodes, n)
+		}
+	}
+	if len(m.nodes) == 0 {
+		return nil, errors.Wrap(ErrNoLiteservers, "cannot connect to any liteserver")
+	}
+
+	m.checkNodes(ctx, true)
+
+	m.done = make(chan struct{})
+	m.wg.Add(1)
+	go m.checkLoop()
+
+	return m, nil
+}
+
+func (m *Manager) Close() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+func (m *Manager) Stats() (ret []*app.LiteserverStats) {
+	for _, n := range m.nodes {
+		ret = append(ret, n.stats())
+	}
+	return ret
+}
+
+// findOldestMasterSeqNo looks up the oldest masterchain block available on the server.
+// Starting from the previously found block, it bisects until the block is found.
+func findOldestMasterSeqNo(ctx context.Context, api ton.APIClientWrapped, master *ton.BlockIDExt, from uint32) (*ton.BlockIDExt, error) {
+	const archiveSeqNo = 3 // the same check is used to find archive nodes
+
+	if from < archiveSeqNo {
+		from = archiveSeqNo
+	}
+
+	lo, hi := from, master.SeqNo
+	oldest := master
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		b, err := api.LookupBlock(ctx, master.Workchain, master.Shard, mid)
+		if err != nil && !unavailable(err) {
+			return nil, errors.Wrapf(err, "lookup %d master block", mid)
+		}
+		if err != nil {
+			lo = mid + 1
+			continue
+		}
+
+		oldest, hi = b, mid
+	}
+
+	return oldest, nil
+}
+
+func (m *Manager) checkNode(ctx context.Context, n *node) {
+	start := time.Now()
+
+	master, err := n.api.GetMasterchainInfo(ctx)
+	n.record(time.Since(start), err != nil)
+	if err != nil {
+		if n.eject(m.cfg.MaxFailures, m.cfg.EjectTimeout) {
+			log.Warn().Err(err).Str("host", n.host).Msg("liteserver is ejected")
+		}
+		return
+	}
+	if n.readmit() {
+		log.Info().Str("host", n.host).Msg("liteserver is re-admitted")
+	}
+
+	n.mx.RLock()
+	from := n.oldestMasterSeqNo
+	n.mx.RUnlock()
+
+	oldest, err := findOldestMasterSeqNo(ctx, n.api, master, from)
+	if err != nil {
+		n.setBlocks(master.SeqNo, 0, nil, false)
+		log.Error().Err(err).Str("host", n.host).Msg("cannot find the oldest liteserver block")
+		return
+	}
+
+	var oldestShards map[int32]uint32
+	if shards, err := n.api.GetBlockShardsInfo(ctx, oldest); err == nil {
+		oldestShards = make(map[int32]uint32)
+		for _, s := range shards {
+			if seq, ok := oldestShards[s.Workchain]; !ok || s.SeqNo < seq {
+				oldestShards[s.Workchain] = s.SeqNo
+			}
+		}
+	}
+
+	n.setBlocks(master.SeqNo, oldest.SeqNo, oldestShards, oldest.SeqNo <= 3)
+
+	log.Debug().
+		Str("host", n.host).
+		Uint32("last_master_seq_no", master.SeqNo).
+		Uint32("oldest_master_seq_no", oldest.SeqNo).
+		Msg("checked liteserver")
+}
+
+// checkNodes checks all servers if full is set, otherwise only ejected ones with passed timeout
+func (m *Manager) checkNodes(ctx context.Context, full bool) {
+	var wg sync.WaitGroup
+
+	now := time.Now()
+	for _, n := range m.nodes {
+		if !full && !n.readmitRequired(now) {
+			continue
+		}
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			m.checkNode(ctx, n)
+		}(n)
+	}
+
+	wg.Wait()
+}
+
+func (m *Manager) checkLoop() {
+	defer m.wg.Done()
+
+	period := m.cfg.CheckInterval
+	if m.cfg.EjectTimeout < period {
+		period = m.cfg.EjectTimeout
+	}
+
+	t := time.NewTicker(period)
+	defer t.Stop()
+
+	lastFull := time.Now()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-t.C:
+		}
+
+		full := time.Since(lastFull) >= m.cfg.CheckInterval
+
+		ctx, cancel := context.WithTimeout(context.Background(), period)
+		m.checkNodes(ctx, full)
+		cancel()
+
+		if full {
+			lastFull = time.Now()
+		}
+	}
+}
+
+// candidates returns servers in the order they should be requested:
+// healthy servers having the block ordered by latency and error rate,
+// then healthy servers which probably do not have the block, archive ones first,
+// and ejected servers at the end
+func (m *Manager) candidates(b *blockRef) []*node {
+	var good, bad, ejected []*node
+
+	for _, n := range m.nodes {
+		switch {
+		case n.ejected():
+			ejected = append(ejected, n)
+		case b != nil && !n.hasBlock(b.workchain, b.seqNo):
+			bad = append(bad, n)
+		default:
+			good = append(good, n)
+		}
+	}
+
+	byScore := func(nodes []*node) {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].score() < nodes[j].score() })
+	}
+	byScore(good)
+	byScore(ejected)
+	sort.SliceStable(bad, func(i, j int) bool { return bad[i].isArchive() && !bad[j].isArchive() })
+
+	return append(append(good, bad...), ejected...)
+}
+
+// unavailable checks if the request cannot be served by the server, but it can be served by other ones
+func unavailable(err error) bool {
+	var lsErr ton.LSError
+	return errors.As(err, &lsErr) ||
+		errors.Is(err, ton.ErrBlockNotFound) ||
+		errors.Is(err, ton.ErrNoNewBlocks) ||
+		errors.Is(err, ton.ErrNoProof)
+}
+
+// final checks if the error is caused by the request itself and does not depend on the server
+func final(err error) bool {
+	var execErr ton.ContractExecError
+	return errors.As(err, &execErr) ||
+		errors.Is(err, ton.ErrMessageNotAccepted) ||
+		errors.Is(err, ton.ErrNoTransactionsWereFound)
+}
+
+func (m *Manager) do(ctx context.Context, c *client, b *blockRef, f func(api ton.APIClientWrapped) error) error {
+	nodes := m.candidates(b)
+	if c.maxAttempts > 0 && len(nodes) > c.maxAttempts {
+		nodes = nodes[:c.maxAttempts]
+	}
+
+	err := ErrNoLiteservers
+	for _, n := range nodes {
+		start := time.Now()
+
+		err = f(c.wrap(n.api))
+		switch {
+		case err == nil, final(err):
+			n.record(time.Since(start), false)
+			return err
+
+		case ctx.Err() != nil:
+			return err
+
+		case unavailable(err):
+			n.record(time.Since(start), false)
+
+		default:
+			n.record(time.Since(start), true)
+			if n.eject(m.cfg.MaxFailures, m.cfg.EjectTimeout) {
+				log.Warn().Err(err).Str("host", n.host).Msg("liteserver is ejected")
+			}
+		}
+	}
+
+	return err
+}
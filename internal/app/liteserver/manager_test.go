@@ -0,0 +1,206 @@
+package liteserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+
+	"github.com/tonindexer/anton/internal/app"
+)
+
+var errConnection = errors.New("connection closed")
+
+// fakeServer serves masterchain blocks from oldest to last,
+// shard blocks have twice larger sequence numbers
+type fakeServer struct {
+	ton.APIClientWrapped
+
+	oldest, last uint32
+	latency      time.Duration
+
+	mx       sync.Mutex
+	down     bool
+	accounts int
+}
+
+func (s *fakeServer) setDown(down bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.down = down
+}
+
+func (s *fakeServer) isDown() bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	time.Sleep(s.latency)
+	return s.down
+}
+
+func (s *fakeServer) served() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.accounts
+}
+
+func (s *fakeServer) hasBlock(b *ton.BlockIDExt) bool {
+	if b.Workchain == -1 {
+		return b.SeqNo >= s.oldest && b.SeqNo <= s.last
+	}
+	return b.SeqNo >= s.oldest*2 && b.SeqNo <= s.last*2
+}
+
+func (s *fakeServer) GetMasterchainInfo(context.Context) (*ton.BlockIDExt, error) {
+	if s.isDown() {
+		return nil, errConnection
+	}
+	return &ton.BlockIDExt{Workchain: -1, Shard: -0x8000000000000000, SeqNo: s.last}, nil
+}
+
+func (s *fakeServer) LookupBlock(_ context.Context, workchain int32, shard int64, seqNo uint32) (*ton.BlockIDExt, error) {
+	if s.isDown() {
+		return nil, errConnection
+	}
+	b := &ton.BlockIDExt{Workchain: workchain, Shard: shard, SeqNo: seqNo}
+	if !s.hasBlock(b) {
+		return nil, ton.ErrBlockNotFound
+	}
+	return b, nil
+}
+
+func (s *fakeServer) GetBlockShardsInfo(_ context.Context, master *ton.BlockIDExt) ([]*ton.BlockIDExt, error) {
+	if s.isDown() {
+		return nil, errConnection
+	}
+	return []*ton.BlockIDExt{{Workchain: 0, Shard: -0x8000000000000000, SeqNo: master.SeqNo * 2}}, nil
+}
+
+func (s *fakeServer) GetAccount(_ context.Context, b *ton.BlockIDExt, _ *address.Address) (*tlb.Account, error) {
+	if s.isDown() {
+		return nil, errConnection
+	}
+	if !s.hasBlock(b) {
+		return nil, ton.LSError{Code: 651, Text: "block is not in db"}
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.accounts++
+
+	return &tlb.Account{IsActive: true}, nil
+}
+
+func newManager(t *testing.T, cfg *app.LiteserverConfig, servers map[string]*fakeServer) *Manager {
+	for host := range servers {
+		cfg.Servers = append(cfg.Servers, &app.Liteserver{Host: host})
+	}
+	cfg.Dial = func(_ context.Context, ls *app.Liteserver) (ton.APIClientWrapped, error) {
+		s, ok := servers[ls.Host]
+		if !ok {
+			return nil, fmt.Errorf("unknown %s host", ls.Host)
+		}
+		return s, nil
+	}
+
+	m, err := NewManager(context.Background(), cfg)
+	require.Nil(t, err)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+func getStats(m *Manager, host string) *app.LiteserverStats {
+	for _, s := range m.Stats() {
+		if s.Host == host {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestManager_ArchiveRouting(t *testing.T) {
+	ctx := context.Background()
+
+	archive := &fakeServer{oldest: 1, last: 10000, latency: 5 * time.Millisecond}
+	pruned := &fakeServer{oldest: 9000, last: 10000}
+
+	m := newManager(t, &app.LiteserverConfig{}, map[string]*fakeServer{"archive": archive, "pruned": pruned})
+
+	stats := getStats(m, "archive")
+	require.True(t, stats.Archive)
+	require.Equal(t, uint32(3), stats.OldestMasterSeqNo)
+
+	stats = getStats(m, "pruned")
+	require.False(t, stats.Archive)
+	require.Equal(t, uint32(9000), stats.OldestMasterSeqNo)
+	require.Equal(t, uint32(10000), stats.LastMasterSeqNo)
+
+	// recent blocks are requested from the faster server
+	_, err := m.GetAccount(ctx, &ton.BlockIDExt{Workchain: -1, SeqNo: 9500}, nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, pruned.served())
+	require.Equal(t, 0, archive.served())
+
+	// historical blocks are requested from the archive server
+	_, err = m.GetAccount(ctx, &ton.BlockIDExt{Workchain: -1, SeqNo: 100}, nil)
+	require.Nil(t, err)
+	_, err = m.GetAccount(ctx, &ton.BlockIDExt{Workchain: 0, SeqNo: 100}, nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, pruned.served())
+	require.Equal(t, 2, archive.served())
+
+	// shard blocks are compared with shards of the oldest master block
+	_, err = m.GetAccount(ctx, &ton.BlockIDExt{Workchain: 0, SeqNo: 18500}, nil)
+	require.Nil(t, err)
+	require.Equal(t, 2, pruned.served())
+}
+
+func TestManager_Failover(t *testing.T) {
+	ctx := context.Background()
+
+	first := &fakeServer{oldest: 1, last: 100}
+	second := &fakeServer{oldest: 1, last: 100, latency: time.Millisecond}
+
+	m := newManager(t, &app.LiteserverConfig{
+		CheckInterval: time.Hour,
+		MaxFailures:   3,
+		EjectTimeout:  50 * time.Millisecond,
+	}, map[string]*fakeServer{"first": first, "second": second})
+
+	first.setDown(true)
+
+	for i := 0; i < 5; i++ {
+		_, err := m.GetAccount(ctx, &ton.BlockIDExt{Workchain: -1, SeqNo: 50}, nil)
+		require.Nil(t, err)
+	}
+	require.Equal(t, 5, second.served())
+
+	stats := getStats(m, "first")
+	require.True(t, stats.Ejected)
+	require.Equal(t, uint64(3), stats.Failures)
+
+	// the server is re-admitted after it becomes healthy
+	first.setDown(false)
+	require.Eventually(t, func() bool {
+		return !getStats(m, "first").Ejected
+	}, time.Second, 10*time.Millisecond)
+
+	// unavailable blocks are not counted as failures
+	_, err := m.GetAccount(ctx, &ton.BlockIDExt{Workchain: -1, SeqNo: 500}, nil)
+	require.True(t, errors.Is(err, ton.LSError{Code: 651, Text: "block is not in db"}))
+	require.Equal(t, uint64(3), getStats(m, "first").Failures)
+	require.Equal(t, uint64(0), getStats(m, "second").Failures)
+
+	// all servers are down
+	first.setDown(true)
+	second.setDown(true)
+	_, err = m.GetAccount(ctx, &ton.BlockIDExt{Workchain: -1, SeqNo: 50}, nil)
+	require.True(t, errors.Is(err, errConnection))
+}
@@ -0,0 +1,15 @@
+package liteserver
+
+import (
+	"github.com/tonindexer/anton/metrics"
+)
+
+var (
+	requestDuration = metrics.NewHistogram(
+		"anton_liteserver_request_duration_seconds", "Duration of liteserver requests.", metrics.DefBuckets, "host")
+	requestErrors = metrics.NewCounter(
+		"anton_liteserver_request_errors_total", "Number of failed liteserver requests.", "host")
+
+	masterSeqNo = metrics.NewGauge(
+		"anton_liteserver_masterchain_seq_no", "Sequence number of the last masterchain block known to the liteserver.", "host")
+)
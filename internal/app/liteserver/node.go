@@ -0,0 +1,167 @@
+package liteserver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/ton"
+
+	"github.com/tonindexer/anton/internal/app"
+)
+
+// ewmaWeight is a weight of the latest request in latency and error rate moving averages
+const ewmaWeight = 0.1
+
+type node struct {
+	host string
+	key  string
+	api  ton.APIClientWrapped
+
+	mx sync.RWMutex
+
+	archive           bool
+	lastMasterSeqNo   uint32
+	oldestMasterSeqNo uint32
+	oldestShardSeqNo  map[int32]uint32
+
+	requests            uint64
+	failures            uint64
+	consecutiveFailures int
+	errorRate           float64
+	latency             time.Duration
+
+	ejectedUntil time.Time
+}
+
+func (n *node) record(latency time.Duration, failed bool) {
+	requestDuration.Observe(latency.Seconds(), n.host)
+	if failed {
+		requestErrors.Inc(n.host)
+	}
+
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	n.requests++
+
+	fail := 0.
+	if failed {
+		fail = 1.
+		n.failures++
+		n.consecutiveFailures++
+	} else {
+		n.consecutiveFailures = 0
+	}
+	n.errorRate = n.errorRate*(1-ewmaWeight) + fail*ewmaWeight
+
+	if failed {
+		return
+	}
+	if n.latency == 0 {
+		n.latency = latency
+	} else {
+		n.latency = time.Duration(float64(n.latency)*(1-ewmaWeight) + float64(latency)*ewmaWeight)
+	}
+}
+
+// eject removes the server from routing if it has failed too many times in a row
+func (n *node) eject(maxFailures int, timeout time.Duration) bool {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	if n.consecutiveFailures < maxFailures || time.Now().Before(n.ejectedUntil) {
+		return false
+	}
+	n.ejectedUntil = time.Now().Add(timeout)
+	return true
+}
+
+func (n *node) readmit() bool {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	if n.ejectedUntil.IsZero() {
+		return false
+	}
+	n.ejectedUntil = time.Time{}
+	n.consecutiveFailures = 0
+	return true
+}
+
+func (n *node) ejected() bool {
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+
+	return !n.ejectedUntil.IsZero()
+}
+
+func (n *node) readmitRequired(now time.Time) bool {
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+
+	return !n.ejectedUntil.IsZero() && now.After(n.ejectedUntil)
+}
+
+func (n *node) isArchive() bool {
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+
+	return n.archive
+}
+
+// hasBlock reports whether the block is supposed to be available on the server
+func (n *node) hasBlock(workchain int32, seqNo uint32) bool {
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+
+	if n.archive {
+		return true
+	}
+	if workchain == -1 {
+		return seqNo >= n.oldestMasterSeqNo
+	}
+	return seqNo >= n.oldestShardSeqNo[workchain]
+}
+
+// score is used to order servers, the lower is the better
+func (n *node) score() float64 {
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+
+	return float64(n.latency) * (1 + 10*n.errorRate)
+}
+
+func (n *node) setBlocks(last, oldest uint32, oldestShards map[int32]uint32, archive bool) {
+	masterSeqNo.Set(float64(last), n.host)
+
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	n.lastMasterSeqNo = last
+	if oldest == 0 {
+		return
+	}
+	n.oldestMasterSeqNo, n.archive = oldest, archive
+	if oldestShards != nil {
+		n.oldestShardSeqNo = oldestShards
+	}
+}
+
+func (n *node) stats() *app.LiteserverStats {
+	n.mx.RLock()
+	defer n.mx.RUnlock()
+
+	return &app.LiteserverStats{
+		Host:              n.host,
+		Key:               n.key,
+		Archive:           n.archive,
+		LastMasterSeqNo:   n.lastMasterSeqNo,
+		OldestMasterSeqNo: n.oldestMasterSeqNo,
+		Requests:          n.requests,
+		Failures:          n.failures,
+		ErrorRate:         n.errorRate,
+		Latency:           n.latency,
+		Ejected:           !n.ejectedUntil.IsZero(),
+		EjectedUntil:      n.ejectedUntil,
+	}
+}
@@ -0,0 +1,325 @@
+package liteserver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/ton"
+
+	"github.com/tonindexer/anton/internal/app"
+)
+
+// ErrNotRecorded is returned on replay of a request missing in the records.
+var ErrNotRecorded = errors.New("liteserver response is not recorded")
+
+// recordsFile is a gzip stream of records, every record consists of
+// the request hash, the response length as uvarint, and the serialized response
+const recordsFile = "responses.gz"
+
+type recordKey [sha256.Size]byte
+
+// requestKey hashes the serialized request.
+// The waitMasterchainSeqno prefix is skipped, as its timeout depends on the request context.
+func requestKey(payload tl.Serializable) (recordKey, error) {
+	data, err := tl.Serialize(payload, true)
+	if err != nil {
+		return recordKey{}, errors.Wrapf(err, "serialize %T request", payload)
+	}
+
+	var wait ton.WaitMasterchainSeqno
+	if rest, err := tl.Parse(&wait, data, true); err == nil {
+		data = rest
+	}
+
+	return sha256.Sum256(data), nil
+}
+
+func readRecords(dir string, f func(key recordKey, resp []byte)) error {
+	file, err := os.Open(filepath.Join(dir, recordsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "open records")
+	}
+	defer func() { _ = file.Close() }()
+
+	zr, err := gzip.NewReader(file)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "new gzip reader")
+	}
+
+	r := bufio.NewReader(zr)
+	for {
+		var key recordKey
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil // the last record can be cut on the recorder crash
+			}
+			return errors.Wrap(err, "read request hash")
+		}
+
+		l, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errors.Wrap(err, "read response length")
+		}
+
+		resp := make([]byte, l)
+		if _, err := io.ReadFull(r, resp); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return errors.Wrap(err, "read response")
+		}
+
+		f(key, resp)
+	}
+}
+
+var lsErrorID = tl.CRC("liteServer.error code:int message:string = liteServer.Error")
+
+// isErrorResponse checks if the serialized response is a liteserver error
+func isErrorResponse(resp []byte) bool {
+	return len(resp) >= 4 && binary.LittleEndian.Uint32(resp) == lsErrorID
+}
+
+// isStateRequest checks if the response to the request depends on the time it is sent
+func isStateRequest(payload tl.Serializable) bool {
+	switch payload.(type) {
+	case ton.GetMasterchainInf, ton.GetMasterchainInfoExt, ton.GetTime:
+		return true
+	default:
+		return false
+	}
+}
+
+// Recorder writes liteserver responses to the directory, so they can be served by Replayer.
+// Records are appended to the existing ones. Only the first successful response to a request is recorded,
+// so that replayed responses are consistent with each other (e.g., the last masterchain block and its config).
+// Liteserver errors are recorded too, but they are replaced by the later successful responses.
+// Responses to the requests on the current state, like the last masterchain block, are recorded once
+// and then served from the records, so the recording run sees the same state as the replay.
+type Recorder struct {
+	mx   sync.Mutex
+	file *os.File
+	zw   *gzip.Writer
+
+	// written maps a request to the flag, whether it has a successful response
+	written map[recordKey]bool
+	// state keeps responses to the requests on the current state
+	state map[recordKey][]byte
+}
+
+func NewRecorder(dir string) (*Recorder, error) {
+	r := &Recorder{
+		written: make(map[recordKey]bool),
+		state:   make(map[recordKey][]byte),
+	}
+
+	stateKeys := make(map[recordKey]bool)
+	for _, req := range []tl.Serializable{ton.GetMasterchainInf{}, ton.GetMasterchainInfoExt{}, ton.GetTime{}} {
+		key, err := requestKey(req)
+		if err != nil {
+			return nil, err
+		}
+		stateKeys[key] = true
+	}
+
+	err := readRecords(dir, func(key recordKey, resp []byte) {
+		success := !isErrorResponse(resp)
+		if stateKeys[key] && success && !r.written[key] {
+			r.state[key] = resp
+		}
+		r.written[key] = r.written[key] || success
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.Wrapf(err, "create %s directory", dir)
+	}
+	r.file, err = os.OpenFile(filepath.Join(dir, recordsFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "open records")
+	}
+	r.zw = gzip.NewWriter(r.file)
+
+	return r, nil
+}
+
+// Dial connects to the liteserver and records its responses,
+// it can be used as a liteserver manager dial function.
+func (r *Recorder) Dial(ctx context.Context, ls *app.Liteserver) (ton.APIClientWrapped, error) {
+	client, err := connect(ctx, ls)
+	if err != nil {
+		return nil, err
+	}
+	return ton.NewAPIClient(r.Wrap(client), ton.ProofCheckPolicyUnsafe), nil
+}
+
+// Wrap returns the client recording all responses to the queries.
+func (r *Recorder) Wrap(client ton.LiteClient) ton.LiteClient {
+	return &recordingClient{LiteClient: client, r: r}
+}
+
+func (r *Recorder) record(key recordKey, resp []byte) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	success := !isErrorResponse(resp)
+	if done, ok := r.written[key]; ok && (done || !success) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(key[:])
+	buf.Write(binary.AppendUvarint(nil, uint64(len(resp))))
+	buf.Write(resp)
+
+	if _, err := r.zw.Write(buf.Bytes()); err != nil {
+		return errors.Wrap(err, "write record")
+	}
+	if err := r.zw.Flush(); err != nil {
+		return errors.Wrap(err, "flush records")
+	}
+
+	r.written[key] = success
+	return nil
+}
+
+func (r *Recorder) getState(key recordKey) ([]byte, bool) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	resp, ok := r.state[key]
+	return resp, ok
+}
+
+func (r *Recorder) setState(key recordKey, resp []byte) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if _, ok := r.state[key]; !ok && !isErrorResponse(resp) {
+		r.state[key] = resp
+	}
+}
+
+func (r *Recorder) Close() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if err := r.zw.Close(); err != nil {
+		return errors.Wrap(err, "close gzip writer")
+	}
+	return r.file.Close()
+}
+
+type recordingClient struct {
+	ton.LiteClient
+	r *Recorder
+}
+
+func (c *recordingClient) QueryLiteserver(ctx context.Context, payload tl.Serializable, result tl.Serializable) error {
+	key, err := requestKey(payload)
+	if err != nil {
+		return err
+	}
+
+	if isStateRequest(payload) {
+		if resp, ok := c.r.getState(key); ok {
+			if _, err := tl.Parse(result, resp, true); err != nil {
+				return errors.Wrapf(err, "parse %T response", payload)
+			}
+			return nil
+		}
+	}
+
+	if err := c.LiteClient.QueryLiteserver(ctx, payload, result); err != nil {
+		return err
+	}
+
+	resp, err := tl.Serialize(reflect.ValueOf(result).Elem().Interface(), true)
+	if err != nil {
+		return errors.Wrapf(err, "serialize %T response", result)
+	}
+
+	if isStateRequest(payload) {
+		c.r.setState(key, resp)
+	}
+
+	return c.r.record(key, resp)
+}
+
+// Replayer serves liteserver responses written by Recorder without any network requests.
+type Replayer struct {
+	responses map[recordKey][]byte
+}
+
+func NewReplayer(dir string) (*Replayer, error) {
+	r := &Replayer{responses: make(map[recordKey][]byte)}
+
+	err := readRecords(dir, func(key recordKey, resp []byte) {
+		r.responses[key] = resp
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(r.responses) == 0 {
+		return nil, errors.Wrapf(ErrNotRecorded, "no records in %s", dir)
+	}
+
+	return r, nil
+}
+
+// NewReplayClient returns the client serving recorded responses from the directory.
+func NewReplayClient(dir string) (ton.APIClientWrapped, error) {
+	r, err := NewReplayer(dir)
+	if err != nil {
+		return nil, err
+	}
+	return ton.NewAPIClient(r, ton.ProofCheckPolicyUnsafe), nil
+}
+
+func (r *Replayer) QueryLiteserver(_ context.Context, payload tl.Serializable, result tl.Serializable) error {
+	key, err := requestKey(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, ok := r.responses[key]
+	if !ok {
+		return errors.Wrapf(ErrNotRecorded, "%T request", payload)
+	}
+
+	if _, err := tl.Parse(result, resp, true); err != nil {
+		return errors.Wrapf(err, "parse %T response", payload)
+	}
+	return nil
+}
+
+func (r *Replayer) StickyContext(ctx context.Context) context.Context {
+	return ctx
+}
+
+func (r *Replayer) StickyContextNextNode(ctx context.Context) (context.Context, error) {
+	return ctx, errors.New("replayer has no other nodes")
+}
+
+func (r *Replayer) StickyNodeID(context.Context) uint32 {
+	return 0
+}
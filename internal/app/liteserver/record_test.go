@@ -0,0 +1,126 @@
+package liteserver
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/ton"
+)
+
+// fakeLiteClient answers masterchain info and block lookup queries
+type fakeLiteClient struct {
+	ton.LiteClient
+
+	mx      sync.Mutex
+	last    uint32
+	queries int
+}
+
+func (c *fakeLiteClient) QueryLiteserver(_ context.Context, payload tl.Serializable, result tl.Serializable) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.queries++
+
+	data, err := tl.Serialize(payload, true)
+	if err != nil {
+		return err
+	}
+	var wait ton.WaitMasterchainSeqno
+	if rest, err := tl.Parse(&wait, data, true); err == nil {
+		data = rest
+	}
+
+	var req tl.Serializable
+	if _, err := tl.Parse(&req, data, true); err != nil {
+		return err
+	}
+
+	var resp tl.Serializable
+	switch r := req.(type) {
+	case ton.GetMasterchainInf:
+		resp = ton.MasterchainInfo{
+			Last:          &ton.BlockIDExt{Workchain: -1, Shard: -0x8000000000000000, SeqNo: c.last, RootHash: make([]byte, 32), FileHash: make([]byte, 32)},
+			StateRootHash: make([]byte, 32),
+			Init:          &ton.ZeroStateIDExt{RootHash: make([]byte, 32), FileHash: make([]byte, 32)},
+		}
+	case ton.LookupBlock:
+		if uint32(r.ID.Seqno) > c.last {
+			resp = ton.LSError{Code: 651, Text: "block is not in db"}
+			break
+		}
+		resp = ton.BlockHeader{
+			ID: &ton.BlockIDExt{Workchain: r.ID.Workchain, Shard: r.ID.Shard, SeqNo: uint32(r.ID.Seqno), RootHash: make([]byte, 32), FileHash: make([]byte, 32)},
+		}
+	default:
+		return errors.Errorf("unexpected %T request", req)
+	}
+
+	reflect.ValueOf(result).Elem().Set(reflect.ValueOf(resp))
+	return nil
+}
+
+func TestRecorder_Replay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	_, err := NewReplayClient(dir)
+	require.True(t, errors.Is(err, ErrNotRecorded))
+
+	server := &fakeLiteClient{last: 100}
+
+	rec, err := NewRecorder(dir)
+	require.Nil(t, err)
+
+	api := ton.NewAPIClient(rec.Wrap(server), ton.ProofCheckPolicyUnsafe)
+
+	master, err := api.GetMasterchainInfo(ctx)
+	require.Nil(t, err)
+	require.Equal(t, uint32(100), master.SeqNo)
+
+	_, err = api.WaitForBlock(100).LookupBlock(ctx, -1, -0x8000000000000000, 50)
+	require.Nil(t, err)
+	_, err = api.LookupBlock(ctx, -1, -0x8000000000000000, 150)
+	require.True(t, errors.Is(err, ton.ErrBlockNotFound))
+	require.Nil(t, rec.Close())
+
+	// records are appended, liteserver errors are replaced by successful responses,
+	// and the current state is served from the records
+	server.last = 200
+	rec, err = NewRecorder(dir)
+	require.Nil(t, err)
+	api = ton.NewAPIClient(rec.Wrap(server), ton.ProofCheckPolicyUnsafe)
+	master, err = api.GetMasterchainInfo(ctx)
+	require.Nil(t, err)
+	require.Equal(t, uint32(100), master.SeqNo)
+	_, err = api.LookupBlock(ctx, -1, -0x8000000000000000, 150)
+	require.Nil(t, err)
+	require.Nil(t, rec.Close())
+
+	queries := server.queries
+
+	replay, err := NewReplayClient(dir)
+	require.Nil(t, err)
+
+	master, err = replay.GetMasterchainInfo(ctx)
+	require.Nil(t, err)
+	require.Equal(t, uint32(100), master.SeqNo)
+
+	b, err := replay.LookupBlock(ctx, -1, -0x8000000000000000, 50)
+	require.Nil(t, err)
+	require.Equal(t, uint32(50), b.SeqNo)
+
+	b, err = replay.LookupBlock(ctx, -1, -0x8000000000000000, 150)
+	require.Nil(t, err)
+	require.Equal(t, uint32(150), b.SeqNo)
+
+	_, err = replay.LookupBlock(ctx, -1, -0x8000000000000000, 51)
+	require.True(t, errors.Is(err, ErrNotRecorded))
+
+	require.Equal(t, queries, server.queries)
+}
@@ -90,6 +90,24 @@ type ParserService interface {
 		others func(context.Context, addr.Address) (*core.AccountState, error),
 	) error
 
+	// EmulateGetMethod runs get-method of the contract interface on the given account state with arbitrary arguments.
+	EmulateGetMethod(
+		ctx context.Context,
+		contract abi.ContractName,
+		getMethod *abi.GetMethodDesc,
+		acc *core.AccountState,
+		args []any,
+	) (abi.GetMethodExecution, error)
+
+	// EmulateMessage runs the transaction triggered by the given message on the account state.
+	// It returns the raw emulated transaction and the resulting account state.
+	EmulateMessage(
+		ctx context.Context,
+		acc *core.AccountState,
+		message *cell.Cell,
+		ignoreSignature bool,
+	) (*tlb.Transaction, *tlb.Account, error)
+
 	ParseMessagePayload(
 		ctx context.Context,
 		message *core.Message, // source and destination account states must be known
@@ -119,6 +119,7 @@ func (s *Service) ParseAccountData(
 		acc.Types = append(acc.Types, i.Name)
 	}
 	acc.ExecutedGetMethods = map[abi.ContractName][]abi.GetMethodExecution{}
+	acc.InterfaceVersions = nil
 
 	s.callPossibleGetMethods(ctx, acc, others, interfaces)
 
@@ -150,6 +151,7 @@ func (s *Service) ParseAccountContractData(
 		acc.ExecutedGetMethods = map[abi.ContractName][]abi.GetMethodExecution{}
 	}
 	delete(acc.ExecutedGetMethods, contractDesc.Name)
+	delete(acc.InterfaceVersions, contractDesc.Name)
 
 	s.callPossibleGetMethods(ctx, acc, others, []*core.ContractInterface{contractDesc})
 
@@ -209,3 +211,13 @@ func (s *Service) ExecuteAccountGetMethod(
 
 	return s.callGetMethod(ctx, acc, i, d, others)
 }
+
+func (s *Service) EmulateGetMethod(
+	ctx context.Context,
+	contract abi.ContractName,
+	getMethod *abi.GetMethodDesc,
+	acc *core.AccountState,
+	args []any,
+) (abi.GetMethodExecution, error) {
+	return s.emulateGetMethod(ctx, contract, getMethod, acc, args)
+}
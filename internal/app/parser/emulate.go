@@ -0,0 +1,232 @@
+package parser
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tonkeeper/tongo/boc"
+	tongotlb "github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/txemulator"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/internal/core"
+)
+
+func toTongoCell(c *cell.Cell) (*boc.Cell, error) {
+	return boc.DeserializeSinglRootBase64(base64.StdEncoding.EncodeToString(c.ToBOC()))
+}
+
+func fromTongoCell(c *boc.Cell) (*cell.Cell, error) {
+	b, err := c.ToBoc()
+	if err != nil {
+		return nil, errors.Wrap(err, "serialize tongo cell")
+	}
+	return cell.FromBOC(b)
+}
+
+// accountCell builds Account cell from the stored account state.
+// Storage statistics are not stored, so storage fees are not charged on the emulated transaction.
+func accountCell(acc *core.AccountState, now uint32) (*cell.Cell, error) {
+	if acc.Status == core.NonExist || acc.Status == "" {
+		return cell.BeginCell().MustStoreUInt(0, 1).EndCell(), nil // account_none$0
+	}
+
+	balance := big.NewInt(0)
+	if acc.Balance != nil {
+		balance = acc.Balance.ToMathBig()
+	}
+
+	// account$1 addr storage_stat:StorageInfo storage:AccountStorage
+	b := cell.BeginCell().MustStoreUInt(1, 1).MustStoreAddr(acc.Address.MustToTonutils())
+	b.MustStoreVarUInt(0, 7).MustStoreVarUInt(0, 7).MustStoreVarUInt(0, 7) // storage used: cells, bits, public cells
+	b.MustStoreUInt(uint64(now), 32)                                       // last paid
+	b.MustStoreBoolBit(false)                                              // due payment
+	b.MustStoreUInt(acc.LastTxLT, 64)
+	b.MustStoreBigCoins(balance).MustStoreDict(nil) // balance and extra currencies
+
+	switch acc.Status {
+	case core.Uninit:
+		b.MustStoreUInt(0b00, 2)
+
+	case core.Frozen:
+		if len(acc.StateHash) != 32 {
+			return nil, fmt.Errorf("wrong frozen account state hash length: %d", len(acc.StateHash))
+		}
+		b.MustStoreUInt(0b01, 2).MustStoreSlice(acc.StateHash, 256)
+
+	case core.Active:
+		var code, data *cell.Cell
+		var err error
+
+		if len(acc.Code) > 0 {
+			if code, err = cell.FromBOC(acc.Code); err != nil {
+				return nil, errors.Wrap(err, "account code from boc")
+			}
+		}
+		if len(acc.Data) > 0 {
+			if data, err = cell.FromBOC(acc.Data); err != nil {
+				return nil, errors.Wrap(err, "account data from boc")
+			}
+		}
+
+		// account_active$1 split_depth:(Maybe) special:(Maybe) code:(Maybe ^Cell) data:(Maybe ^Cell) library:(HashmapE)
+		b.MustStoreUInt(1, 1).MustStoreBoolBit(false).MustStoreBoolBit(false)
+		b.MustStoreMaybeRef(code).MustStoreMaybeRef(data).MustStoreDict(nil)
+
+	default:
+		return nil, fmt.Errorf("unknown account status: %s", acc.Status)
+	}
+
+	return b.EndCell(), nil
+}
+
+func shardAccount(acc *core.AccountState, now uint32) (ret tongotlb.ShardAccount, err error) {
+	a, err := accountCell(acc, now)
+	if err != nil {
+		return ret, err
+	}
+
+	lastTxHash := acc.LastTxHash
+	if len(lastTxHash) != 32 {
+		lastTxHash = make([]byte, 32)
+	}
+
+	c, err := toTongoCell(cell.BeginCell().
+		MustStoreRef(a).
+		MustStoreSlice(lastTxHash, 256).
+		MustStoreUInt(acc.LastTxLT, 64).
+		EndCell())
+	if err != nil {
+		return ret, errors.Wrap(err, "shard account to tongo cell")
+	}
+
+	if err = tongotlb.Unmarshal(c, &ret); err != nil {
+		return ret, errors.Wrap(err, "unmarshal shard account")
+	}
+
+	return ret, nil
+}
+
+func loadEmulatedAccount(sa *tongotlb.ShardAccount) (*tlb.Account, error) {
+	c := boc.NewCell()
+	if err := tongotlb.Marshal(c, sa); err != nil {
+		return nil, errors.Wrap(err, "marshal shard account")
+	}
+	saCell, err := fromTongoCell(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw tlb.ShardAccount
+	if err := tlb.LoadFromCell(&raw, saCell.BeginParse()); err != nil {
+		return nil, errors.Wrap(err, "load shard account")
+	}
+
+	acc := &tlb.Account{LastTxLT: raw.LastTransLT, LastTxHash: raw.LastTransHash}
+
+	isAccount, err := raw.Account.BeginParse().LoadBoolBit()
+	if err != nil {
+		return nil, errors.Wrap(err, "load account tag")
+	}
+	if !isAccount {
+		return acc, nil // account_none$0
+	}
+
+	var st tlb.AccountState
+	if err := st.LoadFromCell(raw.Account.BeginParse()); err != nil {
+		return nil, errors.Wrap(err, "load account state")
+	}
+	acc.IsActive, acc.State = true, &st
+	if st.Status == tlb.AccountStatusActive && st.StateInit != nil {
+		acc.Code, acc.Data = st.StateInit.Code, st.StateInit.Data
+	}
+
+	return acc, nil
+}
+
+func (s *Service) EmulateMessage(_ context.Context, acc *core.AccountState, msg *cell.Cell, ignoreSignature bool) (*tlb.Transaction, *tlb.Account, error) {
+	if core.SkipAddress(acc.Address, core.SkipEmulation) {
+		return nil, nil, errors.Wrapf(core.ErrInvalidArg, "emulation of %s is disabled", acc.Address.Base64())
+	}
+
+	now := uint32(time.Now().Unix())
+
+	sa, err := shardAccount(acc, now)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "make shard account")
+	}
+
+	var message tongotlb.Message
+	msgCell, err := toTongoCell(msg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "message to tongo cell")
+	}
+	if err := tongotlb.Unmarshal(msgCell, &message); err != nil {
+		return nil, nil, errors.Wrapf(core.ErrInvalidArg, "unmarshal message (%s)", err.Error())
+	}
+
+	cfg, err := boc.DeserializeSinglRootBase64(s.bcConfigBase64)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "deserialize blockchain config")
+	}
+	e, err := txemulator.NewEmulator(cfg, txemulator.LogTruncated)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "new transaction emulator")
+	}
+	if err := e.SetUnixtime(now); err != nil {
+		return nil, nil, err
+	}
+	if err := e.SetLT(acc.LastTxLT + 1); err != nil {
+		return nil, nil, err
+	}
+	if err := e.SetIgnoreSignatureCheck(ignoreSignature); err != nil {
+		return nil, nil, err
+	}
+	if len(acc.Libraries) > 0 {
+		libs, err := boc.DeserializeSinglRootBase64(base64.StdEncoding.EncodeToString(acc.Libraries))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "deserialize account libraries")
+		}
+		if err := e.SetLibs(libs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	res, err := e.Emulate(sa, message)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "emulate transaction")
+	}
+	if !res.Success || res.Emulation == nil {
+		if res.Error != nil {
+			return nil, nil, errors.Wrapf(core.ErrInvalidArg, "message is not accepted: exit code %d, %s", res.Error.ExitCode, res.Error.Text)
+		}
+		return nil, nil, errors.Wrap(core.ErrInvalidArg, "message is not accepted")
+	}
+
+	txBOC, err := base64.StdEncoding.DecodeString(res.Emulation.RawTransaction)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "decode transaction boc")
+	}
+	txCell, err := cell.FromBOC(txBOC)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "transaction from boc")
+	}
+
+	var rawTx tlb.Transaction
+	if err := tlb.LoadFromCell(&rawTx, txCell.BeginParse()); err != nil {
+		return nil, nil, errors.Wrap(err, "load transaction")
+	}
+	rawTx.Hash = txCell.Hash()
+
+	newAcc, err := loadEmulatedAccount(&res.Emulation.ShardAccount)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "load emulated account state")
+	}
+
+	return &rawTx, newAcc, nil
+}
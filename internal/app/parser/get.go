@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/big"
 	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -31,12 +32,15 @@ func getMethodByName(i *core.ContractInterface, n string) *abi.GetMethodDesc {
 	return nil
 }
 
-func (s *Service) emulateGetMethod(ctx context.Context, d *abi.GetMethodDesc, acc *core.AccountState, args []any) (ret abi.GetMethodExecution, err error) {
+func (s *Service) emulateGetMethod(ctx context.Context, contract abi.ContractName, d *abi.GetMethodDesc, acc *core.AccountState, args []any) (ret abi.GetMethodExecution, err error) {
 	var argsStack abi.VmStack
 
 	if len(acc.Code) == 0 || len(acc.Data) == 0 {
 		return ret, errors.Wrapf(app.ErrImpossibleParsing, "no account code or data for %s (%d)", acc.Address.Base64(), acc.LastTxLT)
 	}
+	if core.SkipAddress(acc.Address, core.SkipEmulation) {
+		return ret, errors.Wrapf(app.ErrImpossibleParsing, "skip %s emulation", acc.Address.Base64())
+	}
 
 	if len(d.Arguments) != len(args) {
 		return ret, errors.New("length of passed and described arguments does not match")
@@ -58,7 +62,9 @@ func (s *Service) emulateGetMethod(ctx context.Context, d *abi.GetMethodDesc, ac
 		return ret, errors.Wrap(err, "new emulator")
 	}
 
+	start := time.Now()
 	retStack, err := e.RunGetMethod(ctx, d.Name, argsStack, d.ReturnValues)
+	observeEmulation(contract, start, err)
 
 	ret = abi.GetMethodExecution{
 		Name: d.Name,
@@ -98,7 +104,7 @@ func (s *Service) emulateGetMethodNoArgs(ctx context.Context, i *core.ContractIn
 		panic(fmt.Errorf("%s `%s` get-method has arguments", i.Name, gmName))
 	}
 
-	stack, err := s.emulateGetMethod(ctx, gm, acc, nil)
+	stack, err := s.emulateGetMethod(ctx, i.Name, gm, acc, nil)
 	if err != nil {
 		return ret, errors.Wrapf(err, "%s `%s`", i.Name, gmName)
 	}
@@ -126,6 +132,13 @@ func appendGetMethodExecution(acc *core.AccountState, contract abi.ContractName,
 	acc.ExecutedGetMethods[contract] = append(acc.ExecutedGetMethods[contract], *exec)
 }
 
+func setInterfaceVersion(acc *core.AccountState, i *core.ContractInterface) {
+	if acc.InterfaceVersions == nil {
+		acc.InterfaceVersions = map[abi.ContractName]uint32{}
+	}
+	acc.InterfaceVersions[i.Name] = i.Version
+}
+
 func mapContentDataNFT(ret *core.AccountState, c any) {
 	if c == nil {
 		return
@@ -157,7 +170,7 @@ func (s *Service) getNFTItemContent(ctx context.Context, collection *core.Accoun
 
 	args := []any{idx.Bytes(), itemContent}
 
-	exec, err := s.emulateGetMethod(ctx, &desc, collection, args)
+	exec, err := s.emulateGetMethod(ctx, known.NFTCollection, &desc, collection, args)
 	if err != nil {
 		log.Error().Err(err).Msg("execute get_nft_content nft_collection get-method")
 		return
@@ -176,7 +189,7 @@ func (s *Service) getNFTItemContent(ctx context.Context, collection *core.Accoun
 func (s *Service) checkMinter(ctx context.Context, minter, item *core.AccountState, i abi.ContractName, desc *abi.GetMethodDesc, args []any) {
 	item.Fake = true
 
-	exec, err := s.emulateGetMethod(ctx, desc, minter, args)
+	exec, err := s.emulateGetMethod(ctx, i, desc, minter, args)
 	if err != nil {
 		log.Error().Err(err).Msgf("execute %s %s get-method", desc.Name, i)
 		return
@@ -290,6 +303,7 @@ func (s *Service) callGetMethod(
 	}
 
 	appendGetMethodExecution(acc, i.Name, &exec)
+	setInterfaceVersion(acc, i)
 	if exec.Error != "" {
 		return nil
 	}
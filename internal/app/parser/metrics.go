@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"time"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/metrics"
+)
+
+var (
+	getMethodEmulations = metrics.NewCounter(
+		"anton_parser_get_method_emulations_total", "Number of emulated get-methods.", "interface")
+	getMethodErrors = metrics.NewCounter(
+		"anton_parser_get_method_errors_total", "Number of get-method emulations finished with an error.", "interface")
+	getMethodDuration = metrics.NewHistogram(
+		"anton_parser_get_method_duration_seconds", "Duration of get-method emulations.", metrics.DefBuckets, "interface")
+
+	messageParseErrors = metrics.NewCounter(
+		"anton_parser_message_parse_errors_total", "Number of messages, which payload cannot be parsed.", "operation_id")
+)
+
+func observeEmulation(contract abi.ContractName, start time.Time, err error) {
+	getMethodDuration.ObserveSince(start, string(contract))
+	getMethodEmulations.Inc(string(contract))
+	if err != nil {
+		getMethodErrors.Inc(string(contract))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
 	"github.com/xssnick/tonutils-go/tvm/cell"
 
 	"github.com/tonindexer/anton/abi"
@@ -36,26 +37,26 @@ type mockContractRepo struct {
 	interfaces []*core.ContractInterface
 }
 
-func (m *mockContractRepo) AddDefinition(context.Context, abi.TLBType, abi.TLBFieldsDesc) error {
+func (m *mockContractRepo) AddDefinition(context.Context, bun.IDB, abi.TLBType, abi.TLBFieldsDesc) error {
 	panic("implement me")
 }
-func (m *mockContractRepo) UpdateDefinition(context.Context, abi.TLBType, abi.TLBFieldsDesc) error {
+func (m *mockContractRepo) UpdateDefinition(context.Context, bun.IDB, abi.TLBType, abi.TLBFieldsDesc) error {
 	panic("implement me")
 }
-func (m *mockContractRepo) DeleteDefinition(context.Context, abi.TLBType) error {
+func (m *mockContractRepo) DeleteDefinition(context.Context, bun.IDB, abi.TLBType) error {
 	panic("implement me")
 }
 func (m *mockContractRepo) GetDefinitions(context.Context) (map[abi.TLBType]abi.TLBFieldsDesc, error) {
 	panic("implement me")
 }
 
-func (m *mockContractRepo) AddInterface(_ context.Context, _ *core.ContractInterface) error {
+func (m *mockContractRepo) AddInterface(_ context.Context, _ bun.IDB, _ *core.ContractInterface) error {
 	panic("implement me")
 }
-func (m *mockContractRepo) UpdateInterface(context.Context, *core.ContractInterface) error {
+func (m *mockContractRepo) UpdateInterface(context.Context, bun.IDB, *core.ContractInterface) error {
 	panic("implement me")
 }
-func (m *mockContractRepo) DeleteInterface(context.Context, abi.ContractName) error {
+func (m *mockContractRepo) DeleteInterface(context.Context, bun.IDB, abi.ContractName) error {
 	panic("implement me")
 }
 func (m *mockContractRepo) GetInterfaces(_ context.Context) ([]*core.ContractInterface, error) {
@@ -118,13 +119,13 @@ func (m *mockContractRepo) GetMethodDescription(_ context.Context, contract abi.
 	panic(fmt.Errorf("unknown %s get-method description for %s contract", contract, gm))
 }
 
-func (m *mockContractRepo) AddOperation(_ context.Context, _ *core.ContractOperation) error {
+func (m *mockContractRepo) AddOperation(_ context.Context, _ bun.IDB, _ *core.ContractOperation) error {
 	panic("implement me")
 }
-func (m *mockContractRepo) UpdateOperation(context.Context, *core.ContractOperation) error {
+func (m *mockContractRepo) UpdateOperation(context.Context, bun.IDB, *core.ContractOperation) error {
 	panic("implement me")
 }
-func (m *mockContractRepo) DeleteOperation(context.Context, string) error {
+func (m *mockContractRepo) DeleteOperation(context.Context, bun.IDB, string) error {
 	panic("implement me")
 }
 func (m *mockContractRepo) GetOperations(_ context.Context) ([]*core.ContractOperation, error) {
@@ -133,6 +134,15 @@ func (m *mockContractRepo) GetOperations(_ context.Context) ([]*core.ContractOpe
 func (m *mockContractRepo) GetOperationsByID(_ context.Context, _ core.MessageType, _ []abi.ContractName, _ bool, _ uint32) ([]*core.ContractOperation, error) {
 	panic("implement me")
 }
+func (m *mockContractRepo) AddRevision(context.Context, bun.IDB, *core.ContractRevision) error {
+	panic("implement me")
+}
+func (m *mockContractRepo) GetRevisions(context.Context, abi.ContractName) ([]*core.ContractRevision, error) {
+	panic("implement me")
+}
+func (m *mockContractRepo) GetRevision(context.Context, abi.ContractName, uint32) (*core.ContractRevision, error) {
+	panic("implement me")
+}
 
 func newService(t *testing.T) *Service {
 	walletV3R2Code, err := base64.StdEncoding.DecodeString("te6cckEBAQEAcQAA3v8AIN0gggFMl7ohggEznLqxn3Gw7UTQ0x/THzHXC//jBOCk8mCDCNcYINMf0x/TH/gjE7vyY+1E0NMf0x/T/9FRMrryoVFEuvKiBPkBVBBV+RDyo/gAkyDXSpbTB9QC+wDo0QGkyMsfyx/L/8ntVBC9ba0=")
@@ -3,6 +3,7 @@ package parser
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/pkg/errors"
 	"github.com/xssnick/tonutils-go/tvm/cell"
@@ -14,6 +15,7 @@ import (
 
 func parseOperationAttempt(msg *core.Message, op *core.ContractOperation) error {
 	msg.OperationName = op.OperationName
+	msg.SchemaVersion = op.Version
 	if op.Outgoing {
 		msg.SrcContract = op.ContractName
 	} else {
@@ -45,6 +47,9 @@ func (s *Service) parseDirectedMessage(ctx context.Context, acc *core.AccountSta
 	if len(acc.Types) == 0 {
 		return errors.Wrap(app.ErrImpossibleParsing, "no interfaces")
 	}
+	if core.SkipAddress(acc.Address, core.SkipParsing) {
+		return errors.Wrap(app.ErrImpossibleParsing, "skip message parsing")
+	}
 
 	outgoing := acc.Address == msg.SrcAddress
 	if outgoing && len(acc.Types) == 1 {
@@ -110,5 +115,8 @@ func (s *Service) ParseMessagePayload(ctx context.Context, msg *core.Message) er
 		return nil
 	}
 
+	if !errors.Is(err, app.ErrImpossibleParsing) {
+		messageParseErrors.Inc(fmt.Sprintf("0x%08x", msg.OperationID))
+	}
 	return err
 }
@@ -2,10 +2,12 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/xssnick/tonutils-go/ton"
 
 	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate"
 	"github.com/tonindexer/anton/internal/core/aggregate/history"
@@ -16,16 +18,79 @@ import (
 type QueryConfig struct {
 	DB *repository.DB
 
-	API ton.APIClientWrapped
+	API    ton.APIClientWrapped
+	Parser ParserService
+}
+
+type ExecuteGetMethodReq struct {
+	Address   addr.Address `json:"-"`
+	GetMethod string       `json:"-"`
+
+	// Contract is used to choose get-method description,
+	// by default it is looked up among account interfaces
+	Contract  abi.ContractName  `json:"contract,omitempty"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+
+	// optional historical account state, the latest one is used by default;
+	// BlockSeqNo is a sequence number of a block in the account workchain,
+	// so for basechain accounts it is a shard block seqno, not a masterchain one
+	TxLT       *uint64 `json:"tx_lt,omitempty"`
+	BlockSeqNo *uint32 `json:"block_seq_no,omitempty"`
+}
+
+type ExecuteGetMethodRes struct {
+	Address    addr.Address `json:"address"`
+	Workchain  int32        `json:"workchain"`
+	Shard      int64        `json:"shard"`
+	BlockSeqNo uint32       `json:"block_seq_no"`
+	LastTxLT   uint64       `json:"last_tx_lt"`
+
+	Contract  abi.ContractName       `json:"contract"`
+	Execution abi.GetMethodExecution `json:"execution"`
+}
+
+type EmulateMessageReq struct {
+	Address addr.Address `json:"address"`
+
+	// Message is a bag of cells with the whole message to the account.
+	// If it is not set, the message is built from the body
+	// or from the operation name and fields.
+	Message []byte `json:"message,omitempty"`
+
+	Body          []byte           `json:"body,omitempty"`
+	Contract      abi.ContractName `json:"contract,omitempty"`
+	OperationName string           `json:"operation_name,omitempty"`
+	Data          json.RawMessage  `json:"data,omitempty"`
+
+	// SrcAddress is a sender of an internal message,
+	// external incoming message is built if it is not set
+	SrcAddress *addr.Address `json:"src_address,omitempty"`
+	Amount     uint64        `json:"amount,omitempty"` // in nanotons
+	Bounce     bool          `json:"bounce,omitempty"`
+
+	IgnoreSignature bool `json:"ignore_signature,omitempty"`
+
+	// optional historical account state, the latest one is used by default;
+	// BlockSeqNo is a sequence number of a block in the account workchain,
+	// so for basechain accounts it is a shard block seqno, not a masterchain one
+	TxLT       *uint64 `json:"tx_lt,omitempty"`
+	BlockSeqNo *uint32 `json:"block_seq_no,omitempty"`
 }
 
 type QueryService interface {
 	GetStatistics(ctx context.Context) (*aggregate.Statistics, error)
+	GetLiteservers(ctx context.Context) ([]*LiteserverStats, error)
 
 	GetDefinitions(context.Context) (map[abi.TLBType]abi.TLBFieldsDesc, error)
 	GetInterfaces(ctx context.Context) ([]*core.ContractInterface, error)
 	GetOperations(ctx context.Context) ([]*core.ContractOperation, error)
 
+	GetRescanTasks(ctx context.Context, all bool) ([]*core.RescanTask, error)
+	GetRescanTask(ctx context.Context, id int) (*core.RescanTask, error)
+
+	ExecuteGetMethod(ctx context.Context, req *ExecuteGetMethodReq) (*ExecuteGetMethodRes, error)
+	EmulateMessage(ctx context.Context, req *EmulateMessageReq) (*core.Transaction, error)
+
 	filter.BlockRepository
 
 	GetLabelCategories(context.Context) ([]core.LabelCategory, error)
@@ -0,0 +1,206 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/fetcher"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+func (s *Service) getIncomingOperation(ctx context.Context, acc *core.AccountState, req *app.EmulateMessageReq) (*core.ContractOperation, error) {
+	contracts := acc.Types
+	if req.Contract != "" {
+		contracts = []abi.ContractName{req.Contract}
+	}
+
+	msgType := core.Internal
+	if req.SrcAddress == nil {
+		msgType = core.ExternalIn
+	}
+
+	operations, err := s.contractRepo.GetOperations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get contract operations")
+	}
+
+	for _, op := range operations {
+		if op.OperationName != req.OperationName || op.Outgoing || op.MessageType != msgType {
+			continue
+		}
+		for _, c := range contracts {
+			if op.ContractName == c {
+				return op, nil
+			}
+		}
+	}
+
+	return nil, errors.Wrapf(core.ErrInvalidArg, "cannot find incoming %s '%s' operation for %v interfaces", msgType, req.OperationName, contracts)
+}
+
+func (s *Service) emulatedMessageBody(ctx context.Context, acc *core.AccountState, req *app.EmulateMessageReq) (*cell.Cell, error) {
+	switch {
+	case req.OperationName != "":
+		op, err := s.getIncomingOperation(ctx, acc, req)
+		if err != nil {
+			return nil, err
+		}
+
+		payload, err := op.Schema.New()
+		if err != nil {
+			return nil, errors.Wrapf(err, "create %s operation payload", op.OperationName)
+		}
+		if len(req.Data) > 0 {
+			if err := json.Unmarshal(req.Data, payload); err != nil {
+				return nil, errors.Wrapf(core.ErrInvalidArg, "unmarshal %s operation data (%s)", op.OperationName, err.Error())
+			}
+		}
+
+		body, err := tlb.ToCell(payload)
+		if err != nil {
+			return nil, errors.Wrapf(core.ErrInvalidArg, "build %s operation body (%s)", op.OperationName, err.Error())
+		}
+		return body, nil
+
+	case len(req.Body) > 0:
+		body, err := cell.FromBOC(req.Body)
+		if err != nil {
+			return nil, errors.Wrapf(core.ErrInvalidArg, "message body from boc (%s)", err.Error())
+		}
+		return body, nil
+
+	default:
+		return cell.BeginCell().EndCell(), nil
+	}
+}
+
+func (s *Service) emulatedMessage(ctx context.Context, acc *core.AccountState, req *app.EmulateMessageReq) (*cell.Cell, error) {
+	if len(req.Message) > 0 {
+		msg, err := cell.FromBOC(req.Message)
+		if err != nil {
+			return nil, errors.Wrapf(core.ErrInvalidArg, "message from boc (%s)", err.Error())
+		}
+		return msg, nil
+	}
+
+	body, err := s.emulatedMessageBody(ctx, acc, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg any
+	if req.SrcAddress == nil {
+		msg = &tlb.ExternalMessage{
+			DstAddr:   acc.Address.MustToTonutils(),
+			ImportFee: tlb.ZeroCoins,
+			Body:      body,
+		}
+	} else {
+		msg = &tlb.InternalMessage{
+			IHRDisabled: true,
+			Bounce:      req.Bounce,
+			SrcAddr:     req.SrcAddress.MustToTonutils(),
+			DstAddr:     acc.Address.MustToTonutils(),
+			Amount:      tlb.FromNanoTONU(req.Amount),
+			IHRFee:      tlb.ZeroCoins,
+			FwdFee:      tlb.ZeroCoins,
+			CreatedLT:   acc.LastTxLT,
+			CreatedAt:   uint32(time.Now().Unix()),
+			Body:        body,
+		}
+	}
+
+	c, err := tlb.ToCell(msg)
+	if err != nil {
+		return nil, errors.Wrapf(core.ErrInvalidArg, "build message (%s)", err.Error())
+	}
+	return c, nil
+}
+
+func (s *Service) getLatestAccountState(ctx context.Context, a addr.Address) (*core.AccountState, error) {
+	acc, err := s.getAccountState(ctx, a, nil, nil)
+	if errors.Is(err, core.ErrNotFound) {
+		return nil, nil //nolint:nilnil // account is not indexed
+	}
+	return acc, err
+}
+
+func (s *Service) parseEmulatedMessage(ctx context.Context, msg *core.Message, src, dst *core.AccountState) {
+	msg.SrcState, msg.DstState = src, dst
+
+	err := s.Parser.ParseMessagePayload(ctx, msg)
+	if err != nil && !errors.Is(err, app.ErrImpossibleParsing) {
+		msg.Error = err.Error()
+	}
+
+	msg.SrcState, msg.DstState = nil, nil
+}
+
+func (s *Service) EmulateMessage(ctx context.Context, req *app.EmulateMessageReq) (*core.Transaction, error) {
+	if s.Parser == nil {
+		return nil, errors.Wrap(core.ErrNotImplemented, "no parser service")
+	}
+
+	acc, err := s.getAccountState(ctx, req.Address, req.TxLT, req.BlockSeqNo)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.emulatedMessage(ctx, acc, req)
+	if err != nil {
+		return nil, err
+	}
+
+	rawTx, rawAcc, err := s.Parser.EmulateMessage(ctx, acc, msg, req.IgnoreSignature)
+	if err != nil {
+		return nil, errors.Wrap(err, "emulate message")
+	}
+
+	b := &ton.BlockIDExt{Workchain: acc.Workchain, Shard: acc.Shard, SeqNo: acc.BlockSeqNo}
+
+	tx, err := fetcher.MapTransaction(b, rawTx)
+	if err != nil {
+		return nil, errors.Wrap(err, "map emulated transaction")
+	}
+
+	tx.Account = fetcher.MapAccount(b, rawAcc)
+	tx.Account.Address = acc.Address
+	tx.Account.Libraries = acc.Libraries
+	if rawAcc.Code != nil {
+		tx.Account.GetMethodHashes, _ = abi.GetMethodHashes(rawAcc.Code)
+	}
+
+	if tx.InMsg != nil {
+		s.parseEmulatedMessage(ctx, tx.InMsg, nil, acc)
+	}
+	for _, out := range tx.OutMsg {
+		var dst *core.AccountState
+		if out.Type == core.Internal {
+			if dst, err = s.getLatestAccountState(ctx, out.DstAddress); err != nil {
+				return nil, errors.Wrapf(err, "get %s account state", out.DstAddress.Base64())
+			}
+		}
+		s.parseEmulatedMessage(ctx, out, acc, dst)
+	}
+
+	if tx.Account.Status == core.Active {
+		others := func(ctx context.Context, a addr.Address) (*core.AccountState, error) {
+			return s.getAccountState(ctx, a, nil, nil)
+		}
+		err = s.Parser.ParseAccountData(ctx, tx.Account, others)
+		if err != nil && !errors.Is(err, app.ErrImpossibleParsing) {
+			return nil, errors.Wrap(err, "parse emulated account data")
+		}
+	}
+
+	return tx, nil
+}
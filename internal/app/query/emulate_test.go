@@ -0,0 +1,198 @@
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/registry"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+// tx hash e5782dd2b1e2186038c1f92db2cdb709bd12eba25a295ec4db9561aa3928c317
+const jettonMintBOC = `te6cckECBgEAAY4AAWMAAAAVpRNS/gQ80YGAFSIq9XSS6um704WS4suGgdULW5b13fha77/GRjN77mgoZVPxAQEBbReNRRmlE1L+BDzRgUHc1lACADvjYKkD7+YYy/VvGEAr2NDd0ROzABirjBhcbeEorNtYoX14QAYCAZdJKpgbgBB56R97rZGAXZwg4u1afeJ934d0DPUZtObWsRZvvxY00AHfGwVIH38wxl+reMIBXsaG7oidmADFXGDC428JRWbaxQF9eEAgAwJf0zuweeADvjYKkD7+YYy/VvGEAr2NDd0ROzABirjBhcbeEorNtYqCVrO8SiAvrwgEBQQAl6iXCtCADvjYKkD7+YYy/VvGEAr2NDd0ROzABirjBhcbeEorNtYwAd8bBUgffzDGX6t4wgFexobuiJ2YAMVcYMLjbwlFZtrFAJiWgCAAl+kWu++ADvjYKkD7+YYy/VvGEAr2NDd0ROzABirjBhcbeEorNtYwAd8bBUgffzDGX6t4wgFexobuiJ2YAMVcYMLjbwlFZtrFAJiWgCAnWbE8`
+
+const jettonMintData = `{"query_id":11894942291761877377,"to_address":"EQCpEVerpJdXTd6cLJcWXDQOqFrct67vwtd9_jIxm99zQZV6","amount":"850000000","master_msg":{"op_code":395134233,"query_id":11894942291761877377,"jetton_amount":"500000000"}}`
+
+type mockContractRepo struct {
+	core.ContractRepository
+	operations []*core.ContractOperation
+}
+
+func (m *mockContractRepo) GetOperations(context.Context) ([]*core.ContractOperation, error) {
+	return m.operations, nil
+}
+
+func jettonOperations(t *testing.T) []*core.ContractOperation {
+	var desc []*abi.InterfaceDesc
+
+	j, err := os.ReadFile("../../../abi/known/tep74_jetton.json")
+	require.Nil(t, err)
+	require.Nil(t, json.Unmarshal(j, &desc))
+
+	_, _, operations, err := registry.ParseInterfacesDesc(desc)
+	require.Nil(t, err)
+
+	return operations
+}
+
+func mustCellFromBase64(t *testing.T, s string) *cell.Cell {
+	b, err := base64.StdEncoding.DecodeString(s)
+	require.Nil(t, err)
+	c, err := cell.FromBOC(b)
+	require.Nil(t, err)
+	return c
+}
+
+func TestService_emulatedMessage(t *testing.T) {
+	ctx := context.Background()
+
+	s := &Service{contractRepo: &mockContractRepo{operations: jettonOperations(t)}}
+
+	minter := &core.AccountState{
+		Address:  *addr.MustFromBase64("EQCpEVerpJdXTd6cLJcWXDQOqFrct67vwtd9_jIxm99zQZV6"),
+		Types:    []abi.ContractName{"jetton_minter"},
+		LastTxLT: 42,
+	}
+	src := addr.MustFromBase64("EQBN8W-mvO4mPO6hbRCn6WkNVd2NyKBr4uUCX_F4tsvux5oO")
+
+	mintBody := mustCellFromBase64(t, jettonMintBOC)
+
+	var testCases = []*struct {
+		name     string
+		req      *app.EmulateMessageReq
+		err      error
+		external bool
+		body     []byte // expected body hash
+		data     string // or expected body fields
+	}{
+		{
+			name: "operation from json data",
+			req: &app.EmulateMessageReq{
+				OperationName: "jetton_mint",
+				Data:          json.RawMessage(jettonMintData),
+				SrcAddress:    src,
+				Amount:        1e9,
+				Bounce:        true,
+			},
+			data: jettonMintData,
+		},
+		{
+			name: "operation of the given contract",
+			req: &app.EmulateMessageReq{
+				Contract:      "jetton_wallet",
+				OperationName: "jetton_mint",
+				Data:          json.RawMessage(jettonMintData),
+				SrcAddress:    src,
+			},
+			err: core.ErrInvalidArg,
+		},
+		{
+			name: "internal operation in external message",
+			req: &app.EmulateMessageReq{
+				OperationName: "jetton_mint",
+				Data:          json.RawMessage(jettonMintData),
+			},
+			err: core.ErrInvalidArg,
+		},
+		{
+			name: "invalid operation data",
+			req: &app.EmulateMessageReq{
+				OperationName: "jetton_mint",
+				Data:          json.RawMessage(`{"query_id":"abc"}`),
+				SrcAddress:    src,
+			},
+			err: core.ErrInvalidArg,
+		},
+		{
+			name: "raw body",
+			req: &app.EmulateMessageReq{
+				Body:       mintBody.ToBOC(),
+				SrcAddress: src,
+			},
+			body: mintBody.Hash(),
+		},
+		{
+			name: "invalid body",
+			req: &app.EmulateMessageReq{
+				Body:       []byte("not a boc"),
+				SrcAddress: src,
+			},
+			err: core.ErrInvalidArg,
+		},
+		{
+			name:     "external message with empty body",
+			req:      &app.EmulateMessageReq{},
+			external: true,
+			body:     cell.BeginCell().EndCell().Hash(),
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := s.emulatedMessage(ctx, minter, test.req)
+			if test.err != nil {
+				require.True(t, errors.Is(err, test.err), err)
+				return
+			}
+			require.Nil(t, err)
+
+			var msg tlb.Message
+			require.Nil(t, tlb.LoadFromCell(&msg, c.BeginParse()))
+
+			if test.external {
+				require.Equal(t, tlb.MsgTypeExternalIn, msg.MsgType)
+				ext := msg.AsExternalIn()
+				require.Equal(t, minter.Address.Base64(), addr.MustFromTonutils(ext.DstAddr).Base64())
+				require.Equal(t, test.body, ext.Body.Hash())
+				return
+			}
+
+			require.Equal(t, tlb.MsgTypeInternal, msg.MsgType)
+			in := msg.AsInternal()
+			require.Equal(t, src.Base64(), addr.MustFromTonutils(in.SrcAddr).Base64())
+			require.Equal(t, minter.Address.Base64(), addr.MustFromTonutils(in.DstAddr).Base64())
+			require.Equal(t, test.req.Amount, in.Amount.Nano().Uint64())
+			require.Equal(t, test.req.Bounce, in.Bounce)
+			require.Equal(t, minter.LastTxLT, in.CreatedLT)
+			if test.data == "" {
+				require.Equal(t, test.body, in.Body.Hash())
+				return
+			}
+
+			op, err := s.getIncomingOperation(ctx, minter, test.req)
+			require.Nil(t, err)
+			parsed, err := op.Schema.FromCell(in.Body)
+			require.Nil(t, err)
+			got, err := json.Marshal(parsed)
+			require.Nil(t, err)
+			require.JSONEq(t, test.data, string(got))
+		})
+	}
+
+	t.Run("whole message", func(t *testing.T) {
+		want, err := s.emulatedMessage(ctx, minter, &app.EmulateMessageReq{SrcAddress: src, Body: mintBody.ToBOC()})
+		require.Nil(t, err)
+
+		got, err := s.emulatedMessage(ctx, minter, &app.EmulateMessageReq{Message: want.ToBOC()})
+		require.Nil(t, err)
+		require.Equal(t, want.Hash(), got.Hash())
+	})
+}
+
+func TestService_EmulateMessage_NoParser(t *testing.T) {
+	s := &Service{QueryConfig: &app.QueryConfig{}}
+
+	_, err := s.EmulateMessage(context.Background(), &app.EmulateMessageReq{})
+	require.True(t, errors.Is(err, core.ErrNotImplemented), err)
+}
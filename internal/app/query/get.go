@@ -0,0 +1,177 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/lru"
+)
+
+const getMethodCacheCapacity = 4096
+
+type getMethodCacheKey struct {
+	codeHash string
+	dataHash string
+	contract abi.ContractName
+	method   string
+	args     string
+}
+
+type getMethodCache struct {
+	lru *lru.Cache[getMethodCacheKey, abi.GetMethodExecution]
+}
+
+func newGetMethodCache() *getMethodCache {
+	return &getMethodCache{
+		lru: lru.New[getMethodCacheKey, abi.GetMethodExecution](getMethodCacheCapacity),
+	}
+}
+
+func makeGetMethodCacheKey(acc *core.AccountState, contract abi.ContractName, method string, args []json.RawMessage) (getMethodCacheKey, error) {
+	var compacted []string
+
+	for _, a := range args {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, a); err != nil {
+			return getMethodCacheKey{}, errors.Wrapf(core.ErrInvalidArg, "compact json argument (%s)", err.Error())
+		}
+		compacted = append(compacted, buf.String())
+	}
+
+	return getMethodCacheKey{
+		codeHash: hex.EncodeToString(acc.CodeHash),
+		dataHash: hex.EncodeToString(acc.DataHash),
+		contract: contract,
+		method:   method,
+		args:     strings.Join(compacted, ","),
+	}, nil
+}
+
+// getAccountState returns the account state after the given transaction or at the given block,
+// the latest known state is returned by default.
+// Block sequence number is compared with the seqno of the block the account state was found in,
+// so it refers to the account workchain: a shard block for basechain accounts.
+// Master seqno is not resolved, as ClickHouse blocks do not keep references to masterchain blocks.
+func (s *Service) getAccountState(ctx context.Context, a addr.Address, txLT *uint64, blockSeqNo *uint32) (*core.AccountState, error) {
+	f := &filter.AccountsReq{
+		WithCodeData: true,
+		Addresses:    []*addr.Address{&a},
+		Order:        "DESC",
+		Limit:        1,
+	}
+
+	switch {
+	case txLT != nil && blockSeqNo != nil:
+		return nil, errors.Wrap(core.ErrInvalidArg, "account state can be chosen either by tx_lt or by block_seq_no")
+	case txLT != nil:
+		after := *txLT + 1
+		f.AfterTxLT = &after
+	case blockSeqNo != nil:
+		workchain := int32(a.Workchain())
+		f.Workchain = &workchain
+		f.BlockSeqNoLeq = blockSeqNo
+	default:
+		f.LatestState = true
+	}
+
+	res, err := s.FilterAccounts(ctx, f)
+	if err != nil {
+		return nil, errors.Wrap(err, "filter account states")
+	}
+	if len(res.Rows) == 0 {
+		return nil, errors.Wrapf(core.ErrNotFound, "no %s account state", a.Base64())
+	}
+
+	return res.Rows[0], nil
+}
+
+func (s *Service) getMethodDescription(ctx context.Context, acc *core.AccountState, req *app.ExecuteGetMethodReq) (abi.ContractName, *abi.GetMethodDesc, error) {
+	contracts := acc.Types
+	if req.Contract != "" {
+		contracts = []abi.ContractName{req.Contract}
+	}
+
+	for _, c := range contracts {
+		d, err := s.contractRepo.GetMethodDescription(ctx, c, req.GetMethod)
+		if errors.Is(err, core.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "get %s get-method description of %s contract", req.GetMethod, c)
+		}
+		return c, &d, nil
+	}
+
+	return "", nil, errors.Wrapf(core.ErrInvalidArg, "cannot find '%s' get-method description for %v interfaces", req.GetMethod, contracts)
+}
+
+func (s *Service) ExecuteGetMethod(ctx context.Context, req *app.ExecuteGetMethodReq) (*app.ExecuteGetMethodRes, error) {
+	if s.Parser == nil {
+		return nil, errors.Wrap(core.ErrNotImplemented, "no parser service")
+	}
+
+	acc, err := s.getAccountState(ctx, req.Address, req.TxLT, req.BlockSeqNo)
+	if err != nil {
+		return nil, err
+	}
+
+	contract, desc, err := s.getMethodDescription(ctx, acc, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(desc.Arguments) != len(req.Arguments) {
+		return nil, errors.Wrapf(core.ErrInvalidArg, "%s get-method expects %d arguments, got %d", desc.Name, len(desc.Arguments), len(req.Arguments))
+	}
+
+	key, err := makeGetMethodCacheKey(acc, contract, desc.Name, req.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	exec, ok := s.getMethodCache.lru.Get(key)
+	if !ok {
+		var args []any
+		for it := range desc.Arguments {
+			a, err := desc.Arguments[it].FromJSON(req.Arguments[it])
+			if err != nil {
+				return nil, errors.Wrap(core.ErrInvalidArg, err.Error())
+			}
+			args = append(args, a)
+		}
+
+		exec, err = s.Parser.EmulateGetMethod(ctx, contract, desc, acc, args)
+		if errors.Is(err, app.ErrImpossibleParsing) {
+			return nil, errors.Wrap(core.ErrInvalidArg, err.Error())
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "emulate %s get-method", desc.Name)
+		}
+		exec.Arguments = desc.Arguments
+		exec.ReturnValues = desc.ReturnValues
+
+		s.getMethodCache.lru.Put(key, exec)
+	}
+
+	exec.Address = &acc.Address
+
+	return &app.ExecuteGetMethodRes{
+		Address:    acc.Address,
+		Workchain:  acc.Workchain,
+		Shard:      acc.Shard,
+		BlockSeqNo: acc.BlockSeqNo,
+		LastTxLT:   acc.LastTxLT,
+		Contract:   contract,
+		Execution:  exec,
+	}, nil
+}
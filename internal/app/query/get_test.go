@@ -0,0 +1,126 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/rndm"
+)
+
+type mockGetMethodContractRepo struct {
+	core.ContractRepository
+	desc abi.GetMethodDesc
+}
+
+func (m *mockGetMethodContractRepo) GetInterfaces(context.Context) ([]*core.ContractInterface, error) {
+	return nil, nil
+}
+
+func (m *mockGetMethodContractRepo) GetMethodDescription(_ context.Context, _ abi.ContractName, method string) (abi.GetMethodDesc, error) {
+	if method != m.desc.Name {
+		return abi.GetMethodDesc{}, core.ErrNotFound
+	}
+	return m.desc, nil
+}
+
+type mockAccountRepo struct {
+	repository.Account
+	state *core.AccountState
+}
+
+func (m *mockAccountRepo) FilterAccounts(context.Context, *filter.AccountsReq) (*filter.AccountsRes, error) {
+	return &filter.AccountsRes{Total: 1, Rows: []*core.AccountState{m.state}}, nil
+}
+
+type mockGetMethodParser struct {
+	app.ParserService
+	emulated int
+}
+
+func (m *mockGetMethodParser) EmulateGetMethod(_ context.Context, _ abi.ContractName, desc *abi.GetMethodDesc, _ *core.AccountState, args []any) (abi.GetMethodExecution, error) {
+	m.emulated++
+	return abi.GetMethodExecution{Name: desc.Name, Receives: args, Returns: []any{m.emulated}}, nil
+}
+
+func TestService_ExecuteGetMethod(t *testing.T) {
+	ctx := context.Background()
+
+	desc := abi.GetMethodDesc{
+		Name:         "get_nft_address_by_index",
+		Arguments:    []abi.VmValueDesc{{Name: "index", StackType: abi.VmInt}},
+		ReturnValues: []abi.VmValueDesc{{Name: "address", StackType: abi.VmSlice, Format: abi.TLBAddr}},
+	}
+
+	a := rndm.Address()
+	state := rndm.AddressState(a, []abi.ContractName{"nft_collection"}, nil)
+	state.ExecutedGetMethods = nil
+	accounts := &mockAccountRepo{state: state}
+	parser := &mockGetMethodParser{}
+
+	s := &Service{
+		QueryConfig:    &app.QueryConfig{Parser: parser},
+		contractRepo:   &mockGetMethodContractRepo{desc: desc},
+		accountRepo:    accounts,
+		pruneRepo:      &mockPruneRepo{},
+		getMethodCache: newGetMethodCache(),
+	}
+
+	execute := func(t *testing.T, args ...string) *app.ExecuteGetMethodRes {
+		req := &app.ExecuteGetMethodReq{Address: *a, GetMethod: desc.Name}
+		for _, arg := range args {
+			req.Arguments = append(req.Arguments, json.RawMessage(arg))
+		}
+		res, err := s.ExecuteGetMethod(ctx, req)
+		require.Nil(t, err)
+		return res
+	}
+
+	var testCases = []*struct {
+		name     string
+		args     string
+		newData  bool
+		emulated int
+	}{
+		{name: "first execution", args: `100`, emulated: 1},
+		{name: "same arguments", args: `100`, emulated: 1},
+		{name: "same arguments in another format", args: ` 100 `, emulated: 1},
+		{name: "different arguments", args: `101`, emulated: 2},
+		{name: "cached different arguments", args: `101`, emulated: 2},
+		{name: "different account data", args: `100`, newData: true, emulated: 3},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if test.newData {
+				accounts.state.DataHash = rndm.Bytes(32)
+			}
+
+			res := execute(t, test.args)
+			require.Equal(t, test.emulated, parser.emulated)
+			require.Equal(t, accounts.state.Address, res.Address)
+			require.Equal(t, abi.ContractName("nft_collection"), res.Contract)
+			require.Equal(t, desc.Arguments, res.Execution.Arguments)
+			require.Equal(t, desc.ReturnValues, res.Execution.ReturnValues)
+		})
+	}
+
+	t.Run("wrong number of arguments", func(t *testing.T) {
+		_, err := s.ExecuteGetMethod(ctx, &app.ExecuteGetMethodReq{Address: *a, GetMethod: desc.Name})
+		require.ErrorIs(t, err, core.ErrInvalidArg)
+		require.Equal(t, 3, parser.emulated)
+	})
+
+	t.Run("both tx lt and block seq no", func(t *testing.T) {
+		lt, seq := uint64(1), uint32(1)
+		_, err := s.ExecuteGetMethod(ctx, &app.ExecuteGetMethodReq{Address: *a, GetMethod: desc.Name, TxLT: &lt, BlockSeqNo: &seq})
+		require.ErrorIs(t, err, core.ErrInvalidArg)
+	})
+}
@@ -0,0 +1,142 @@
+package query
+
+import (
+	"context"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/filter"
+)
+
+// statesPruned checks if the pruning removed states of the returned accounts.
+func (s *Service) statesPruned(ctx context.Context, p *core.Pruning, req *filter.AccountsReq, rows []*core.AccountState) (bool, error) {
+	if req.LatestState {
+		return false, nil
+	}
+
+	var addresses []*addr.Address
+	seen := map[addr.Address]bool{}
+	for _, r := range rows {
+		if len(r.Types) > 0 || seen[r.Address] {
+			continue
+		}
+		seen[r.Address] = true
+		addresses = append(addresses, &r.Address)
+	}
+	if len(addresses) == 0 {
+		return false, nil
+	}
+
+	pruned, err := s.pruneRepo.GetPrunedAddresses(ctx, p, addresses)
+	if err != nil {
+		return false, err
+	}
+	return len(pruned) > 0, nil
+}
+
+func codeDataPruned(p *core.Pruning, req *filter.AccountsReq, rows []*core.AccountState) bool {
+	if !req.WithCodeData {
+		return false
+	}
+	for _, r := range rows {
+		if !r.UpdatedAt.Before(p.Before) {
+			continue
+		}
+		if (len(r.CodeHash) > 0 && len(r.Code) == 0) || (len(r.DataHash) > 0 && len(r.Data) == 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func bodiesPruned(p *core.Pruning, messages []*core.Message) bool {
+	for _, m := range messages {
+		if m != nil && len(m.Body) == 0 && m.OperationName == "" && m.CreatedAt.Before(p.Before) {
+			return true
+		}
+	}
+	return false
+}
+
+// addAccountPrunings lists retention rules, which removed requested states or their code and data.
+func (s *Service) addAccountPrunings(ctx context.Context, req *filter.AccountsReq, res *filter.AccountsRes) error {
+	prunings, err := s.pruneRepo.GetPrunings(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range prunings {
+		var pruned bool
+		switch p.Rule {
+		case core.PruneAccountStates:
+			if pruned, err = s.statesPruned(ctx, p, req, res.Rows); err != nil {
+				return err
+			}
+		case core.PruneCodeData:
+			pruned = codeDataPruned(p, req, res.Rows)
+		}
+		if pruned {
+			res.Pruned = append(res.Pruned, p)
+		}
+	}
+	return nil
+}
+
+// addTransactionPrunings lists retention rules, which removed states or messages of the returned transactions,
+// and ClickHouse TTL, which removed counted transactions.
+func (s *Service) addTransactionPrunings(ctx context.Context, req *filter.TransactionsReq, res *filter.TransactionsRes) error {
+	prunings, err := s.pruneRepo.GetPrunings(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range prunings {
+		var pruned bool
+		switch p.Rule {
+		case core.PruneTransactionsTTL:
+			pruned = true
+		case core.PruneAccountStates:
+			if !req.WithAccountState {
+				continue
+			}
+			for _, tx := range res.Rows {
+				if tx.Account == nil && tx.CreatedAt.Before(p.Before) {
+					pruned = true
+					break
+				}
+			}
+		case core.PruneMessageBodies:
+			if !req.WithMessages {
+				continue
+			}
+			for _, tx := range res.Rows {
+				if bodiesPruned(p, append([]*core.Message{tx.InMsg}, tx.OutMsg...)) {
+					pruned = true
+					break
+				}
+			}
+		}
+		if pruned {
+			res.Pruned = append(res.Pruned, p)
+		}
+	}
+	return nil
+}
+
+// addMessagePrunings lists retention rules, which removed bodies of the returned messages,
+// and ClickHouse TTL, which removed counted messages.
+func (s *Service) addMessagePrunings(ctx context.Context, res *filter.MessagesRes) error {
+	prunings, err := s.pruneRepo.GetPrunings(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range prunings {
+		switch p.Rule {
+		case core.PruneMessagesTTL:
+			res.Pruned = append(res.Pruned, p)
+		case core.PruneMessageBodies:
+			if bodiesPruned(p, res.Rows) {
+				res.Pruned = append(res.Pruned, p)
+			}
+		}
+	}
+	return nil
+}
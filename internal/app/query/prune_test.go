@@ -0,0 +1,122 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/rndm"
+)
+
+type mockPruneRepo struct {
+	core.PruneRepository
+	prunings []*core.Pruning
+	pruned   map[addr.Address]bool
+}
+
+func (m *mockPruneRepo) GetPrunings(context.Context) ([]*core.Pruning, error) {
+	return m.prunings, nil
+}
+
+func (m *mockPruneRepo) GetPrunedAddresses(_ context.Context, _ *core.Pruning, addresses []*addr.Address) (ret []*addr.Address, err error) {
+	for _, a := range addresses {
+		if m.pruned[*a] {
+			ret = append(ret, a)
+		}
+	}
+	return ret, nil
+}
+
+func TestService_addAccountPrunings(t *testing.T) {
+	ctx := context.Background()
+
+	states := &core.Pruning{Rule: core.PruneAccountStates, KeepLatest: 2, Before: time.Now()}
+	codeData := &core.Pruning{Rule: core.PruneCodeData, Before: time.Now()}
+
+	wallet, nft := rndm.Address(), rndm.Address()
+	repo := &mockPruneRepo{prunings: []*core.Pruning{states, codeData}, pruned: map[addr.Address]bool{}}
+	s := &Service{pruneRepo: repo}
+
+	rows := append(rndm.AddressStates(wallet, 2), rndm.AddressState(nft, []abi.ContractName{"nft_item"}, nil))
+
+	t.Run("address with fewer states", func(t *testing.T) {
+		res := &filter.AccountsRes{Rows: rows}
+		require.Nil(t, s.addAccountPrunings(ctx, &filter.AccountsReq{}, res))
+		require.Empty(t, res.Pruned)
+	})
+
+	t.Run("pruned address", func(t *testing.T) {
+		repo.pruned[*wallet] = true
+		repo.pruned[*nft] = true
+
+		res := &filter.AccountsRes{Rows: rows}
+		require.Nil(t, s.addAccountPrunings(ctx, &filter.AccountsReq{}, res))
+		require.Equal(t, []*core.Pruning{states}, res.Pruned)
+
+		res = &filter.AccountsRes{Rows: rows}
+		require.Nil(t, s.addAccountPrunings(ctx, &filter.AccountsReq{LatestState: true}, res))
+		require.Empty(t, res.Pruned)
+
+		res = &filter.AccountsRes{Rows: rows[2:]}
+		require.Nil(t, s.addAccountPrunings(ctx, &filter.AccountsReq{}, res))
+		require.Empty(t, res.Pruned)
+	})
+
+	t.Run("pruned code", func(t *testing.T) {
+		row := rndm.AddressState(rndm.Address(), nil, nil)
+		row.UpdatedAt, row.Code = codeData.Before.Add(-time.Hour), nil
+
+		res := &filter.AccountsRes{Rows: []*core.AccountState{row}}
+		require.Nil(t, s.addAccountPrunings(ctx, &filter.AccountsReq{WithCodeData: true}, res))
+		require.Equal(t, []*core.Pruning{codeData}, res.Pruned)
+	})
+}
+
+func TestService_addTransactionPrunings(t *testing.T) {
+	ctx := context.Background()
+
+	before := time.Now()
+	states := &core.Pruning{Rule: core.PruneAccountStates, KeepLatest: 1, Before: before}
+	bodies := &core.Pruning{Rule: core.PruneMessageBodies, Before: before}
+	ttl := &core.Pruning{Rule: core.PruneTransactionsTTL, TTLDays: 365, Before: before.AddDate(-1, 0, 0)}
+
+	s := &Service{pruneRepo: &mockPruneRepo{prunings: []*core.Pruning{states, bodies}}}
+
+	tx := rndm.AddressTransaction(rndm.Address())
+	tx.CreatedAt = before.Add(-time.Hour)
+	tx.Account = rndm.AddressState(&tx.Address, nil, nil)
+	tx.InMsg = rndm.MessageTo(&tx.Address)
+	tx.InMsg.CreatedAt = tx.CreatedAt
+
+	t.Run("not pruned", func(t *testing.T) {
+		res := &filter.TransactionsRes{Rows: []*core.Transaction{tx}}
+		require.Nil(t, s.addTransactionPrunings(ctx, &filter.TransactionsReq{WithAccountState: true, WithMessages: true}, res))
+		require.Empty(t, res.Pruned)
+	})
+
+	t.Run("pruned state and body", func(t *testing.T) {
+		tx.Account, tx.InMsg.Body, tx.InMsg.OperationName = nil, nil, ""
+
+		res := &filter.TransactionsRes{Rows: []*core.Transaction{tx}}
+		require.Nil(t, s.addTransactionPrunings(ctx, &filter.TransactionsReq{}, res))
+		require.Empty(t, res.Pruned)
+
+		res = &filter.TransactionsRes{Rows: []*core.Transaction{tx}}
+		require.Nil(t, s.addTransactionPrunings(ctx, &filter.TransactionsReq{WithAccountState: true, WithMessages: true}, res))
+		require.Equal(t, []*core.Pruning{states, bodies}, res.Pruned)
+	})
+
+	t.Run("clickhouse ttl", func(t *testing.T) {
+		s.pruneRepo = &mockPruneRepo{prunings: []*core.Pruning{ttl}}
+
+		res := &filter.TransactionsRes{}
+		require.Nil(t, s.addTransactionPrunings(ctx, &filter.TransactionsReq{}, res))
+		require.Equal(t, []*core.Pruning{ttl}, res.Pruned)
+	})
+}
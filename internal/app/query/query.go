@@ -19,6 +19,8 @@ import (
 	"github.com/tonindexer/anton/internal/core/repository/block"
 	"github.com/tonindexer/anton/internal/core/repository/contract"
 	"github.com/tonindexer/anton/internal/core/repository/msg"
+	"github.com/tonindexer/anton/internal/core/repository/prune"
+	"github.com/tonindexer/anton/internal/core/repository/rescan"
 	"github.com/tonindexer/anton/internal/core/repository/tx"
 )
 
@@ -32,6 +34,10 @@ type Service struct {
 	txRepo       repository.Transaction
 	msgRepo      repository.Message
 	accountRepo  repository.Account
+	rescanRepo   core.RescanRepository
+	pruneRepo    core.PruneRepository
+
+	getMethodCache *getMethodCache
 }
 
 func NewService(_ context.Context, cfg *app.QueryConfig) (*Service, error) {
@@ -44,6 +50,9 @@ func NewService(_ context.Context, cfg *app.QueryConfig) (*Service, error) {
 	s.blockRepo = block.NewRepository(ch, pg)
 	s.accountRepo = account.NewRepository(ch, pg)
 	s.contractRepo = contract.NewRepository(pg)
+	s.rescanRepo = rescan.NewRepository(pg)
+	s.pruneRepo = prune.NewRepository(ch, pg)
+	s.getMethodCache = newGetMethodCache()
 
 	return s, nil
 }
@@ -56,6 +65,14 @@ func (s *Service) GetStatistics(ctx context.Context) (*aggregate.Statistics, err
 	return aggregate.GetStatistics(ctx, s.DB.CH, s.DB.PG)
 }
 
+func (s *Service) GetLiteservers(_ context.Context) ([]*app.LiteserverStats, error) {
+	m, ok := s.API.(app.LiteserverManager)
+	if !ok {
+		return nil, errors.Wrap(core.ErrNotImplemented, "liteserver manager is not used")
+	}
+	return m.Stats(), nil
+}
+
 func (s *Service) GetInterfaces(ctx context.Context) ([]*core.ContractInterface, error) {
 	return s.contractRepo.GetInterfaces(ctx)
 }
@@ -64,6 +81,14 @@ func (s *Service) GetOperations(ctx context.Context) ([]*core.ContractOperation,
 	return s.contractRepo.GetOperations(ctx)
 }
 
+func (s *Service) GetRescanTasks(ctx context.Context, all bool) ([]*core.RescanTask, error) {
+	return s.rescanRepo.GetRescanTasks(ctx, all)
+}
+
+func (s *Service) GetRescanTask(ctx context.Context, id int) (*core.RescanTask, error) {
+	return s.rescanRepo.GetRescanTask(ctx, id)
+}
+
 func (s *Service) FilterBlocks(ctx context.Context, req *filter.BlocksReq) (*filter.BlocksRes, error) {
 	return s.blockRepo.FilterBlocks(ctx, req)
 }
@@ -96,7 +121,7 @@ func (s *Service) fetchSkippedAccounts(ctx context.Context, req *filter.Accounts
 		if found[a] {
 			continue
 		}
-		if core.SkipAddress(a) {
+		if core.SkipAddress(a, core.SkipFetching) {
 			// fetch heavy skipped account states
 			skipped = append(skipped, a)
 			continue
@@ -175,6 +200,9 @@ func (s *Service) FilterAccounts(ctx context.Context, req *filter.AccountsReq) (
 	if err := s.addGetMethodDescription(ctx, res.Rows); err != nil {
 		return nil, err
 	}
+	if err := s.addAccountPrunings(ctx, req, res); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
@@ -187,7 +215,14 @@ func (s *Service) AggregateAccountsHistory(ctx context.Context, req *history.Acc
 }
 
 func (s *Service) FilterTransactions(ctx context.Context, req *filter.TransactionsReq) (*filter.TransactionsRes, error) {
-	return s.txRepo.FilterTransactions(ctx, req)
+	res, err := s.txRepo.FilterTransactions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addTransactionPrunings(ctx, req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
 func (s *Service) AggregateTransactionsHistory(ctx context.Context, req *history.TransactionsReq) (*history.TransactionsRes, error) {
@@ -198,7 +233,14 @@ func (s *Service) FilterMessages(ctx context.Context, req *filter.MessagesReq) (
 	if req.OperationID != nil && len(req.OperationNames) > 0 {
 		return nil, errors.Wrap(core.ErrInvalidArg, "filter is available either on operation name or operation id")
 	}
-	return s.msgRepo.FilterMessages(ctx, req)
+	res, err := s.msgRepo.FilterMessages(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addMessagePrunings(ctx, res); err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
 func (s *Service) AggregateMessages(ctx context.Context, req *aggregate.MessagesReq) (*aggregate.MessagesRes, error) {
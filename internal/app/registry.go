@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/aggregate"
+	"github.com/tonindexer/anton/internal/core/repository"
+)
+
+type RegistryConfig struct {
+	DB *repository.DB
+
+	// Parser executes get-methods of sample account states on schema validation
+	Parser ParserService
+}
+
+// RegistryChange is the result of contract interfaces update.
+type RegistryChange struct {
+	Revisions []*core.ContractRevision `json:"revisions"`
+	Tasks     []*core.RescanTask       `json:"rescan_tasks"`
+}
+
+type ContractOperationReq struct {
+	ContractName abi.ContractName  `json:"-"`
+	Outgoing     bool              `json:"outgoing"`
+	Schema       abi.OperationDesc `json:"schema"`
+}
+
+type ValidateSchemaReq struct {
+	Interface abi.InterfaceDesc `json:"interface"`
+
+	// sample messages are parsed with the operations of the submitted interface
+	MessageHashes [][]byte `json:"message_hashes,omitempty"`
+	// get-methods of the submitted interface are executed on the latest states of sample accounts
+	Addresses []*addr.Address `json:"addresses,omitempty"`
+}
+
+type ValidatedMessage struct {
+	Hash []byte `json:"hash"`
+
+	StoredOperationName string          `json:"stored_operation_name,omitempty"`
+	StoredData          json.RawMessage `json:"stored_data,omitempty"`
+
+	OperationName string          `json:"operation_name,omitempty"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+type ValidatedAccount struct {
+	Address  addr.Address `json:"address"`
+	LastTxLT uint64       `json:"last_tx_lt"`
+
+	StoredGetMethods []abi.GetMethodExecution `json:"stored_get_methods,omitempty"`
+
+	Matched    bool                     `json:"matched"`
+	GetMethods []abi.GetMethodExecution `json:"get_methods,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+type ValidateSchemaRes struct {
+	Messages []*ValidatedMessage `json:"messages"`
+	Accounts []*ValidatedAccount `json:"accounts"`
+}
+
+// RegistryService manages contract interfaces, operations and definitions
+// with the same validation, revisions and rescan tasks as the contract command.
+type RegistryService interface {
+	AddInterface(ctx context.Context, desc *abi.InterfaceDesc, rev *core.ContractRevision) (*RegistryChange, error)
+	UpdateInterface(ctx context.Context, desc *abi.InterfaceDesc, rev *core.ContractRevision) (*RegistryChange, error)
+	DeleteInterface(ctx context.Context, name abi.ContractName, rev *core.ContractRevision) (*RegistryChange, error)
+	RollbackInterface(ctx context.Context, name abi.ContractName, version uint32, rev *core.ContractRevision) (*RegistryChange, error)
+
+	AddOperation(ctx context.Context, req *ContractOperationReq, rev *core.ContractRevision) (*RegistryChange, error)
+	UpdateOperation(ctx context.Context, req *ContractOperationReq, rev *core.ContractRevision) (*RegistryChange, error)
+	DeleteOperation(ctx context.Context, name abi.ContractName, opName string, rev *core.ContractRevision) (*RegistryChange, error)
+
+	AddDefinition(ctx context.Context, dn abi.TLBType, d abi.TLBFieldsDesc) error
+	UpdateDefinition(ctx context.Context, dn abi.TLBType, d abi.TLBFieldsDesc, rev *core.ContractRevision) (*RegistryChange, error)
+	DeleteDefinition(ctx context.Context, dn abi.TLBType) error
+
+	ValidateSchema(ctx context.Context, req *ValidateSchemaReq) (*ValidateSchemaRes, error)
+
+	// DiscoverContracts reports accounts without matched interfaces,
+	// it scans all account states, so it is not available without the admin token
+	DiscoverContracts(ctx context.Context, req *aggregate.DiscoverContractsReq) (*aggregate.DiscoverContractsRes, error)
+}
@@ -0,0 +1,287 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+func ParseOperationDesc(t abi.ContractName, d *abi.OperationDesc) (*core.ContractOperation, error) {
+	var opId uint32
+
+	if c := d.Code; strings.HasPrefix(c, "0x") {
+		n := new(big.Int)
+		_, ok := n.SetString(c[2:], 16)
+		if !ok {
+			return nil, fmt.Errorf("wrong hex %s operation id format: %s", d.Name, d.Code)
+		}
+		opId = uint32(n.Uint64())
+	} else {
+		n, err := strconv.ParseUint(c, 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s operation id", d.Name)
+		}
+		opId = uint32(n)
+	}
+
+	// this is needed to map interface definitions into schema
+	x, err := d.New()
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating new operation structure")
+	}
+	_, err = abi.NewOperationDesc(x)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating new operation descriptor")
+	}
+
+	if d.Type == "" {
+		d.Type = string(core.Internal)
+	}
+
+	return &core.ContractOperation{
+		OperationName: d.Name,
+		ContractName:  t,
+		MessageType:   core.MessageType(strings.ToUpper(d.Type)),
+		Outgoing:      false,
+		OperationID:   opId,
+		Schema:        *d,
+	}, nil
+}
+
+func ParseInterfaceDesc(d *abi.InterfaceDesc) (*core.ContractInterface, []*core.ContractOperation, error) {
+	var operations []*core.ContractOperation
+
+	code, err := base64.StdEncoding.DecodeString(d.CodeBoc)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "decode code boc from base64")
+	}
+
+	i := core.ContractInterface{
+		Name:           d.Name,
+		Addresses:      d.Addresses,
+		Code:           code,
+		GetMethodsDesc: d.GetMethods,
+	}
+	for it := range i.GetMethodsDesc {
+		i.GetMethodHashes = append(i.GetMethodHashes, abi.MethodNameHash(i.GetMethodsDesc[it].Name))
+	}
+	if len(i.Code) == 0 {
+		i.Code = nil
+	}
+
+	for it := range d.InMessages {
+		op, err := ParseOperationDesc(i.Name, &d.InMessages[it])
+		if err != nil {
+			return nil, nil, err
+		}
+		op.Outgoing = false
+		operations = append(operations, op)
+	}
+
+	for it := range d.OutMessages {
+		op, err := ParseOperationDesc(i.Name, &d.OutMessages[it])
+		if err != nil {
+			return nil, nil, err
+		}
+		op.Outgoing = true
+		operations = append(operations, op)
+	}
+
+	i.Operations = operations
+
+	return &i, operations, nil
+}
+
+func ParseInterfacesDesc(descriptors []*abi.InterfaceDesc) (retD map[abi.TLBType]abi.TLBFieldsDesc, retI []*core.ContractInterface, retOp []*core.ContractOperation, _ error) {
+	retD = map[abi.TLBType]abi.TLBFieldsDesc{}
+	for _, desc := range descriptors {
+		err := abi.RegisterDefinitions(desc.Definitions)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for dn, d := range desc.Definitions {
+			retD[dn] = d
+		}
+	}
+	for _, desc := range descriptors {
+		i, operations, err := ParseInterfaceDesc(desc)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		retI = append(retI, i)
+		retOp = append(retOp, operations...)
+	}
+	return
+}
+
+// diffDefinitions compares stored definitions with the given ones,
+// all stored definitions updated with the given ones are also returned
+func diffDefinitions(ctx context.Context, contractRepo core.ContractRepository, current map[abi.TLBType]abi.TLBFieldsDesc) (added, changed, all map[abi.TLBType]abi.TLBFieldsDesc, err error) {
+	all, err = contractRepo.GetDefinitions(ctx)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "get definitions")
+	}
+
+	added, changed = map[abi.TLBType]abi.TLBFieldsDesc{}, map[abi.TLBType]abi.TLBFieldsDesc{}
+	for dt, d := range current {
+		od, ok := all[dt]
+		switch {
+		case !ok:
+			added[dt] = d
+		case !reflect.DeepEqual(od, d):
+			changed[dt] = d
+		}
+		all[dt] = d
+	}
+
+	return added, changed, all, nil
+}
+
+func diffSlices[V any](oldS, newS []V, getName func(v V) string) (added, changed, deleted []V) {
+	oldM, newM := map[string]V{}, map[string]V{}
+	for _, v := range oldS {
+		oldM[getName(v)] = v
+	}
+	for _, v := range newS {
+		newM[getName(v)] = v
+	}
+
+	for vn, v := range newM {
+		ov, ok := oldM[vn]
+		if !ok {
+			added = append(added, v)
+			continue
+		}
+		if !reflect.DeepEqual(ov, v) {
+			changed = append(changed, v)
+		}
+	}
+	for vn := range oldM {
+		_, ok := newM[vn]
+		if !ok {
+			deleted = append(deleted, oldM[vn])
+		}
+	}
+
+	return added, changed, deleted
+}
+
+func diffInterface(oldInterface, newInterface *core.ContractInterface) (interfaceChanged bool, added, changed, deleted []abi.GetMethodDesc) {
+	interfaceChanged = !reflect.DeepEqual(newInterface.Addresses, oldInterface.Addresses) ||
+		!reflect.DeepEqual(newInterface.Code, oldInterface.Code) ||
+		!reflect.DeepEqual(newInterface.GetMethodHashes, oldInterface.GetMethodHashes)
+
+	added, changed, deleted = diffSlices(oldInterface.GetMethodsDesc, newInterface.GetMethodsDesc, func(v abi.GetMethodDesc) string { return v.Name })
+
+	return interfaceChanged, added, changed, deleted
+}
+
+// withoutVersions copies operations with an unset revision, so that stored and new descriptions are comparable
+func withoutVersions(operations []*core.ContractOperation) (ret []*core.ContractOperation) {
+	for _, op := range operations {
+		cp := *op
+		cp.Version = 0
+		ret = append(ret, &cp)
+	}
+	return ret
+}
+
+func diffOperations(oldOperations, newOperations []*core.ContractOperation) (added, changed, deleted []*core.ContractOperation) {
+	return diffSlices(withoutVersions(oldOperations), withoutVersions(newOperations), func(v *core.ContractOperation) string { return v.OperationName })
+}
+
+func getGetMethodNames(desc []abi.GetMethodDesc) (names []string) {
+	for i := range desc {
+		if len(desc[i].Arguments) > 0 {
+			continue
+		}
+		names = append(names, desc[i].Name)
+	}
+	return
+}
+
+func newInterfaceTask(in abi.ContractName, t core.RescanTaskType) *core.RescanTask {
+	return &core.RescanTask{
+		Type:         t,
+		ContractName: in,
+	}
+}
+
+func newGetMethodTask(in abi.ContractName, t core.RescanTaskType, getMethods []string) *core.RescanTask {
+	if len(getMethods) == 0 {
+		return nil
+	}
+	return &core.RescanTask{
+		Type:              t,
+		ContractName:      in,
+		ChangedGetMethods: getMethods,
+	}
+}
+
+func newOperationTask(t core.RescanTaskType, op *core.ContractOperation) *core.RescanTask {
+	return &core.RescanTask{
+		Type:         t,
+		ContractName: op.ContractName,
+		MessageType:  op.MessageType,
+		Outgoing:     op.Outgoing,
+		OperationID:  op.OperationID,
+	}
+}
+
+func addRescanTask(ctx context.Context, db bun.IDB, repo core.RescanRepository, task *core.RescanTask) error {
+	if task == nil {
+		return nil
+	}
+
+	if err := repo.AddRescanTask(ctx, db, task); err != nil {
+		return errors.Wrapf(err, "add %s rescan task for '%s' contract interface", task.Type, task.ContractName)
+	}
+
+	l := log.Info().
+		Int("id", task.ID).
+		Str("rescan_type", string(task.Type)).
+		Str("interface_name", string(task.ContractName))
+	if len(task.ChangedGetMethods) > 0 {
+		l = l.Strs("get_methods", task.ChangedGetMethods)
+	}
+	if task.OperationID != 0 {
+		l = l.Str("operation_id", fmt.Sprintf("0x%08x", task.OperationID)).Bool("outgoing", task.Outgoing)
+	}
+	l.Msg("added rescan task")
+
+	return nil
+}
+
+// addRevision records the revision of the interface with the definitions it is parsed with,
+// the interface is nil on deletion
+func addRevision(ctx context.Context, db bun.IDB, repo core.ContractRepository, rev *core.ContractRevision,
+	i *core.ContractInterface, definitions map[abi.TLBType]abi.TLBFieldsDesc,
+) error {
+	rev.Definitions = nil
+	if i != nil {
+		rev.Definitions = interfaceDefinitions(i, definitions)
+	}
+
+	if err := repo.AddRevision(ctx, db, rev); err != nil {
+		return errors.Wrapf(err, "add revision of '%s' contract interface", rev.ContractName)
+	}
+
+	log.Info().
+		Str("interface_name", string(rev.ContractName)).
+		Uint32("version", rev.Version).
+		Str("action", string(rev.Action)).
+		Msg("added contract interface revision")
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+// rollbackDefinitions returns definitions changed by revisions after the given version
+// with the values recorded at that version or before it
+func rollbackDefinitions(revisions []*core.ContractRevision, version uint32) map[abi.TLBType]abi.TLBFieldsDesc {
+	changed, ret := map[abi.TLBType]bool{}, map[abi.TLBType]abi.TLBFieldsDesc{}
+
+	// revisions are sorted from the latest one
+	for _, rev := range revisions {
+		for dn, d := range rev.Definitions {
+			if rev.Version > version {
+				changed[dn] = true
+				continue
+			}
+			if _, ok := ret[dn]; ok || !changed[dn] {
+				continue
+			}
+			ret[dn] = d
+		}
+	}
+
+	return ret
+}
+
+// NewRollbackPlan makes the plan restoring contract interface description
+// and definitions it was parsed with from the given revision
+func NewRollbackPlan(ctx context.Context, contractRepo core.ContractRepository, name abi.ContractName, version uint32) (*Plan, error) {
+	target, err := contractRepo.GetRevision(ctx, name, version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get version %d of '%s' interface", version, name)
+	}
+	if target.Interface == nil {
+		return nil, errors.Wrapf(core.ErrInvalidArg, "'%s' interface is deleted in version %d", name, version)
+	}
+
+	revisions, err := contractRepo.GetRevisions(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get '%s' interface revisions", name)
+	}
+	definitions := rollbackDefinitions(revisions, version)
+	// revisions recorded before definitions snapshots have only changed definitions
+	for dn, d := range target.Definitions {
+		definitions[dn] = d
+	}
+
+	// operation schemas are parsed with restored definitions
+	registered, err := contractRepo.GetDefinitions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get definitions")
+	}
+	for dn, d := range definitions {
+		registered[dn] = d
+	}
+	if err := abi.RegisterDefinitions(registered); err != nil {
+		return nil, errors.Wrap(err, "register definitions")
+	}
+
+	current, err := contractRepo.GetInterface(ctx, name)
+	if err != nil && !errors.Is(err, core.ErrNotFound) {
+		return nil, errors.Wrapf(err, "get '%s' interface", name)
+	}
+
+	return NewUpdatePlan(ctx, contractRepo, definitions, current, target.Interface)
+}
@@ -0,0 +1,475 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/app/rescan"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository"
+)
+
+// Plan is the difference between the stored and the new contract interface descriptions
+// with rescan tasks fixing already parsed data.
+type Plan struct {
+	created      bool // there is no stored interface description
+	oldInterface *core.ContractInterface
+	newInterface *core.ContractInterface
+
+	addedDef, changedDef map[abi.TLBType]abi.TLBFieldsDesc
+	allDef               map[abi.TLBType]abi.TLBFieldsDesc // stored definitions after the change
+
+	interfaceChanged              bool
+	addedGm, changedGm, deletedGm []abi.GetMethodDesc
+	addedOp, changedOp, deletedOp []*core.ContractOperation
+
+	tasks []*core.RescanTask
+}
+
+func NewUpdatePlan(ctx context.Context,
+	contractRepo core.ContractRepository,
+	definitions map[abi.TLBType]abi.TLBFieldsDesc,
+	oldInterface, newInterface *core.ContractInterface,
+) (*Plan, error) {
+	var (
+		p   = &Plan{oldInterface: oldInterface, newInterface: newInterface}
+		err error
+	)
+
+	if oldInterface == nil {
+		p.created = true
+		p.oldInterface = &core.ContractInterface{Name: newInterface.Name}
+	}
+
+	p.addedDef, p.changedDef, p.allDef, err = diffDefinitions(ctx, contractRepo, definitions)
+	if err != nil {
+		return nil, err
+	}
+	p.interfaceChanged, p.addedGm, p.changedGm, p.deletedGm = diffInterface(p.oldInterface, newInterface)
+	p.addedOp, p.changedOp, p.deletedOp = diffOperations(p.oldInterface.Operations, newInterface.Operations)
+
+	name := newInterface.Name
+	switch {
+	case p.created:
+		// all get-methods are executed on interface addition
+		p.addTask(newInterfaceTask(name, core.AddInterface))
+	default:
+		if p.interfaceChanged {
+			p.addTask(newInterfaceTask(name, core.UpdInterface))
+		}
+		p.addTask(newGetMethodTask(name, core.AddGetMethod, getGetMethodNames(p.addedGm)))
+		p.addTask(newGetMethodTask(name, core.UpdGetMethod, getGetMethodNames(p.changedGm)))
+		p.addTask(newGetMethodTask(name, core.DelGetMethod, getGetMethodNames(p.deletedGm)))
+	}
+	for _, op := range p.deletedOp {
+		p.addTask(newOperationTask(core.DelOperation, op))
+	}
+	for _, op := range p.addedOp {
+		p.addTask(newOperationTask(core.UpdOperation, op))
+	}
+	for _, op := range p.changedOp {
+		p.addTask(newOperationTask(core.UpdOperation, op))
+	}
+
+	return p, nil
+}
+
+func (p *Plan) addTask(task *core.RescanTask) {
+	if task == nil {
+		return
+	}
+	if task.OperationID == 0 && task.MessageType == "" {
+		// the rescan service takes the interface from the database,
+		// here it is used to count and sample affected account states
+		task.Contract = p.newInterface
+	}
+	p.tasks = append(p.tasks, task)
+}
+
+func (p *Plan) interfaceUpdated() bool {
+	return p.interfaceChanged || len(p.addedGm) > 0 || len(p.changedGm) > 0 || len(p.deletedGm) > 0
+}
+
+func (p *Plan) empty() bool {
+	return len(p.addedDef) == 0 && len(p.changedDef) == 0 && !p.interfaceUpdated() &&
+		len(p.addedOp) == 0 && len(p.changedOp) == 0 && len(p.deletedOp) == 0
+}
+
+// Apply saves the new contract interface description, records the revision and adds rescan tasks.
+// Everything is written in one transaction, so the registry is never left changed without the revision.
+func (p *Plan) Apply(ctx context.Context, db bun.IDB, contractRepo core.ContractRepository, rescanRepo core.RescanRepository, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	if p.empty() {
+		log.Info().Str("interface_name", string(p.newInterface.Name)).Msg("contract interface is not changed")
+		return &app.RegistryChange{}, nil
+	}
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return p.apply(ctx, tx, contractRepo, rescanRepo, rev)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.RegistryChange{Revisions: []*core.ContractRevision{rev}, Tasks: p.tasks}, nil
+}
+
+func (p *Plan) apply(ctx context.Context, tx bun.Tx, contractRepo core.ContractRepository, rescanRepo core.RescanRepository, rev *core.ContractRevision) error {
+	for dn, d := range p.changedDef {
+		if err := contractRepo.UpdateDefinition(ctx, tx, dn, d); err != nil {
+			return errors.Wrapf(err, "cannot update contract definition '%s'", dn)
+		}
+	}
+	for dn, d := range p.addedDef {
+		if err := contractRepo.AddDefinition(ctx, tx, dn, d); err != nil {
+			return errors.Wrapf(err, "cannot insert contract definition '%s'", dn)
+		}
+	}
+
+	switch {
+	case p.created:
+		if err := contractRepo.AddInterface(ctx, tx, p.newInterface); err != nil {
+			return errors.Wrapf(err, "cannot insert contract interface '%s'", p.newInterface.Name)
+		}
+	case p.interfaceUpdated():
+		if err := contractRepo.UpdateInterface(ctx, tx, p.newInterface); err != nil {
+			return errors.Wrapf(err, "cannot update contract interface '%s'", p.newInterface.Name)
+		}
+	}
+
+	for _, op := range p.deletedOp {
+		if err := contractRepo.DeleteOperation(ctx, tx, op.OperationName); err != nil {
+			return errors.Wrapf(err, "cannot delete contract operation '%s'", op.OperationName)
+		}
+	}
+	for _, op := range p.changedOp {
+		if err := contractRepo.UpdateOperation(ctx, tx, op); err != nil {
+			return errors.Wrapf(err, "cannot update contract operation '%s'", op.OperationName)
+		}
+	}
+	for _, op := range p.addedOp {
+		if err := contractRepo.AddOperation(ctx, tx, op); err != nil {
+			return errors.Wrapf(err, "cannot insert contract operation '%s'", op.OperationName)
+		}
+	}
+
+	var diff bytes.Buffer
+	p.writeDiff(&diff)
+
+	rev.ContractName = p.newInterface.Name
+	rev.Diff = strings.Split(strings.TrimSuffix(diff.String(), "\n"), "\n")
+	if err := addRevision(ctx, tx, contractRepo, rev, p.newInterface, p.allDef); err != nil {
+		return err
+	}
+
+	for _, task := range p.tasks {
+		if err := addRescanTask(ctx, tx, rescanRepo, task); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedDefinitions(m map[abi.TLBType]abi.TLBFieldsDesc) (ret []string) {
+	for dn := range m {
+		ret = append(ret, string(dn))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func describeOperation(op *core.ContractOperation) string {
+	direction := "in"
+	if op.Outgoing {
+		direction = "out"
+	}
+	return fmt.Sprintf("%s (%s 0x%08x %s)", op.OperationName, op.MessageType, op.OperationID, direction)
+}
+
+func (p *Plan) writeDiff(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "definitions:")
+	for _, dn := range sortedDefinitions(p.addedDef) {
+		_, _ = fmt.Fprintf(w, "  + %s\n", dn)
+	}
+	for _, dn := range sortedDefinitions(p.changedDef) {
+		_, _ = fmt.Fprintf(w, "  ~ %s\n", dn)
+	}
+
+	_, _ = fmt.Fprintf(w, "interface %s:\n", p.newInterface.Name)
+	if p.created {
+		_, _ = fmt.Fprintln(w, "  + interface")
+	}
+	if p.interfaceChanged {
+		_, _ = fmt.Fprintln(w, "  ~ addresses, code or get-method hashes")
+	}
+	for _, gm := range p.addedGm {
+		_, _ = fmt.Fprintf(w, "  + get-method %s\n", gm.Name)
+	}
+	for _, gm := range p.changedGm {
+		_, _ = fmt.Fprintf(w, "  ~ get-method %s\n", gm.Name)
+	}
+	for _, gm := range p.deletedGm {
+		_, _ = fmt.Fprintf(w, "  - get-method %s\n", gm.Name)
+	}
+
+	_, _ = fmt.Fprintln(w, "operations:")
+	for _, op := range p.addedOp {
+		_, _ = fmt.Fprintf(w, "  + %s\n", describeOperation(op))
+	}
+	for _, op := range p.changedOp {
+		_, _ = fmt.Fprintf(w, "  ~ %s\n", describeOperation(op))
+	}
+	for _, op := range p.deletedOp {
+		_, _ = fmt.Fprintf(w, "  - %s\n", describeOperation(op))
+	}
+}
+
+func interfaceCodeHash(i *core.ContractInterface) ([]byte, error) {
+	if len(i.Code) == 0 {
+		return nil, nil
+	}
+	c, err := cell.FromBOC(i.Code)
+	if err != nil {
+		return nil, errors.Wrapf(err, "making %s code cell from boc", i.Name)
+	}
+	return c.Hash(), nil
+}
+
+func describeTask(task *core.RescanTask) string {
+	switch {
+	case len(task.ChangedGetMethods) > 0:
+		return strings.Join(task.ChangedGetMethods, ",")
+	case task.OperationID != 0:
+		direction := "in"
+		if task.Outgoing {
+			direction = "out"
+		}
+		return fmt.Sprintf("%s 0x%08x %s", task.MessageType, task.OperationID, direction)
+	default:
+		return ""
+	}
+}
+
+// writeTasks prints rescan tasks with the number of account states or messages to be rescanned
+func (p *Plan) writeTasks(ctx context.Context, w io.Writer, accountRepo repository.Account, msgRepo repository.Message) error {
+	codeHash, err := interfaceCodeHash(p.newInterface)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "rescan tasks:")
+	_, _ = fmt.Fprintln(tw, "  TYPE\tCONTRACT\tTARGET\tROWS")
+	for _, task := range p.tasks {
+		rows, err := rescan.CountTaskRows(ctx, accountRepo, msgRepo, task, codeHash, nil)
+		if err != nil {
+			return errors.Wrapf(err, "count %s task rows", task.Type)
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\t%d\n", task.Type, task.ContractName, describeTask(task), rows)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if p.interfaceChanged {
+		was, err := accountRepo.CountStatesByInterfaceDesc(ctx, p.oldInterface.Name, nil, nil, nil)
+		if err != nil {
+			return errors.Wrap(err, "count account states with the interface")
+		}
+		will, err := accountRepo.CountStatesByInterfaceDesc(ctx, "", p.newInterface.Addresses, codeHash, p.newInterface.GetMethodHashes)
+		if err != nil {
+			return errors.Wrap(err, "count account states matching the new interface")
+		}
+		_, _ = fmt.Fprintf(w, "account states with %s interface: %d, matching the new description: %d\n", p.newInterface.Name, was, will)
+	}
+
+	return nil
+}
+
+func marshalSample(v any) string {
+	if v == nil {
+		return "none"
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return string(raw)
+}
+
+func writeSample(w io.Writer, title, oldV, newV string) {
+	if oldV == newV {
+		_, _ = fmt.Fprintf(w, "  %s: not changed\n", title)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "  %s:\n    old: %s\n    new: %s\n", title, oldV, newV)
+}
+
+func findGetMethod(desc []abi.GetMethodDesc, name string) *abi.GetMethodDesc {
+	for it := range desc {
+		if desc[it].Name == name {
+			return &desc[it]
+		}
+	}
+	return nil
+}
+
+func storedExecution(acc *core.AccountState, contract abi.ContractName, name string) any {
+	for _, e := range acc.ExecutedGetMethods[contract] {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// sampleGetMethods compares stored get-method executions with the new ones on a sample of account states,
+// get-methods are executed only if the parser is set
+func (p *Plan) sampleGetMethods(ctx context.Context, w io.Writer, task *core.RescanTask, accountRepo repository.Account, parser app.ParserService, sample int) error {
+	codeHash, err := interfaceCodeHash(p.newInterface)
+	if err != nil {
+		return err
+	}
+
+	ids, err := accountRepo.MatchStatesByInterfaceDesc(ctx, task.ContractName, p.newInterface.Addresses, codeHash, p.newInterface.GetMethodHashes, nil, 0, sample)
+	if err != nil {
+		return errors.Wrap(err, "match states by interface description")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	res, err := accountRepo.FilterAccounts(ctx, &filter.AccountsReq{WithCodeData: true, StateIDs: ids})
+	if err != nil {
+		return errors.Wrap(err, "filter accounts")
+	}
+
+	for _, acc := range res.Rows {
+		for _, gm := range task.ChangedGetMethods {
+			title := fmt.Sprintf("%s %s/%d", gm, acc.Address.Base64(), acc.LastTxLT)
+			oldV := marshalSample(storedExecution(acc, task.ContractName, gm))
+
+			desc := findGetMethod(p.newInterface.GetMethodsDesc, gm)
+			switch {
+			case task.Type == core.DelGetMethod || desc == nil:
+				writeSample(w, title, oldV, marshalSample(nil))
+			case parser == nil:
+				_, _ = fmt.Fprintf(w, "  %s: blockchain config is not set, get-method is not executed\n", title)
+			default:
+				exec, err := parser.EmulateGetMethod(ctx, task.ContractName, desc, acc, nil)
+				if err != nil {
+					exec.Error = err.Error()
+				}
+				writeSample(w, title, oldV, marshalSample(exec))
+			}
+		}
+	}
+
+	return nil
+}
+
+func findOperation(operations []*core.ContractOperation, task *core.RescanTask) *core.ContractOperation {
+	for _, op := range operations {
+		if op.MessageType == task.MessageType && op.Outgoing == task.Outgoing && op.OperationID == task.OperationID {
+			return op
+		}
+	}
+	return nil
+}
+
+// sampleMessages compares stored parsed data of a sample of messages with the data parsed by the new schema
+func (p *Plan) sampleMessages(ctx context.Context, w io.Writer, task *core.RescanTask, msgRepo repository.Message, sample int) error {
+	hashes, err := msgRepo.MatchMessagesByOperationDesc(ctx, task.ContractName, task.MessageType, task.Outgoing, task.OperationID, nil, 0, sample)
+	if err != nil {
+		return errors.Wrap(err, "match messages by operation description")
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	messages, err := msgRepo.GetMessages(ctx, hashes)
+	if err != nil {
+		return errors.Wrap(err, "get messages")
+	}
+
+	op := findOperation(p.newInterface.Operations, task)
+
+	for _, msg := range messages {
+		title := fmt.Sprintf("message %x", msg.Hash)
+
+		oldV := marshalSample(nil)
+		if msg.OperationName != "" {
+			oldV = fmt.Sprintf("%s %s", msg.OperationName, bytes.TrimSpace(msg.DataJSON))
+		}
+
+		newV := marshalSample(nil)
+		if task.Type == core.UpdOperation && op != nil {
+			newV = parseSampleMessage(msg, op)
+		}
+
+		writeSample(w, title, oldV, newV)
+	}
+
+	return nil
+}
+
+func parseSampleMessage(msg *core.Message, op *core.ContractOperation) string {
+	payload, err := cell.FromBOC(msg.Body)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	parsed, err := op.Schema.FromCell(payload)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return fmt.Sprintf("%s %s", op.OperationName, marshalSample(parsed))
+}
+
+// writeSamples prints old and new parsed data for a sample of affected rows of each rescan task
+func (p *Plan) writeSamples(ctx context.Context, w io.Writer, accountRepo repository.Account, msgRepo repository.Message, parser app.ParserService, sample int) error {
+	for _, task := range p.tasks {
+		switch task.Type {
+		case core.AddGetMethod, core.UpdGetMethod, core.DelGetMethod:
+			_, _ = fmt.Fprintf(w, "sample of %s task (%s):\n", task.Type, describeTask(task))
+			if err := p.sampleGetMethods(ctx, w, task, accountRepo, parser, sample); err != nil {
+				return err
+			}
+		case core.UpdOperation, core.DelOperation:
+			_, _ = fmt.Fprintf(w, "sample of %s task (%s):\n", task.Type, describeTask(task))
+			if err := p.sampleMessages(ctx, w, task, msgRepo, sample); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Report prints the difference between interface descriptions, rescan tasks to be created
+// with the number of affected rows and a sample of changes in parsed data
+func (p *Plan) Report(ctx context.Context, w io.Writer, accountRepo repository.Account, msgRepo repository.Message, parser app.ParserService, sample int) error {
+	p.writeDiff(w)
+	if len(p.tasks) == 0 {
+		_, _ = fmt.Fprintln(w, "no rescan tasks")
+		return nil
+	}
+
+	if err := p.writeTasks(ctx, w, accountRepo, msgRepo); err != nil {
+		return err
+	}
+	if sample <= 0 {
+		return nil
+	}
+	return p.writeSamples(ctx, w, accountRepo, msgRepo, parser, sample)
+}
@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+func newRevisionFrom(template *core.ContractRevision, name abi.ContractName) *core.ContractRevision {
+	return &core.ContractRevision{
+		ContractName: name,
+		Action:       template.Action,
+		Author:       template.Author,
+		Comment:      template.Comment,
+	}
+}
+
+// AddInterfaces inserts definitions and contract interfaces with their operations,
+// records revisions and adds rescan tasks for the inserted ones in one transaction.
+// Interfaces or operations which cannot be inserted are skipped.
+func AddInterfaces(ctx context.Context,
+	db bun.IDB,
+	contractRepo core.ContractRepository,
+	rescanRepo core.RescanRepository,
+	descriptors []*abi.InterfaceDesc,
+	rev *core.ContractRevision,
+) (*app.RegistryChange, error) {
+	var ret app.RegistryChange
+
+	definitions, interfaces, operations, err := ParseInterfacesDesc(descriptors)
+	if err != nil {
+		return nil, err
+	}
+
+	addedDef, changedDef, allDef, err := diffDefinitions(ctx, contractRepo, definitions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for dn, d := range changedDef {
+			if err := contractRepo.UpdateDefinition(ctx, tx, dn, d); err != nil {
+				return errors.Wrapf(err, "cannot update contract definition '%s'", dn)
+			}
+		}
+		for dn, d := range addedDef {
+			if err := contractRepo.AddDefinition(ctx, tx, dn, d); err != nil {
+				return errors.Wrapf(err, "cannot insert contract definition '%s'", dn)
+			}
+		}
+
+		added := map[abi.ContractName][]string{}
+
+		// skipped rows are rolled back to savepoints, as a failed statement aborts the transaction
+		for _, i := range interfaces {
+			task := newInterfaceTask(i.Name, core.AddInterface)
+			err := tx.RunInTx(ctx, nil, func(ctx context.Context, sp bun.Tx) error {
+				if err := contractRepo.AddInterface(ctx, sp, i); err != nil {
+					return errors.Wrap(err, "cannot insert contract interface")
+				}
+				return addRescanTask(ctx, sp, rescanRepo, task)
+			})
+			if err != nil {
+				log.Error().Err(err).Str("interface_name", string(i.Name)).Msg("cannot add contract interface")
+				continue
+			}
+			added[i.Name] = append(added[i.Name], "  + interface")
+			ret.Tasks = append(ret.Tasks, task)
+		}
+
+		inserted := map[abi.ContractName][]*core.ContractOperation{}
+		for _, op := range operations {
+			task := newOperationTask(core.UpdOperation, op)
+			err := tx.RunInTx(ctx, nil, func(ctx context.Context, sp bun.Tx) error {
+				if err := contractRepo.AddOperation(ctx, sp, op); err != nil {
+					return errors.Wrap(err, "cannot insert contract operation")
+				}
+				return addRescanTask(ctx, sp, rescanRepo, task)
+			})
+			if err != nil {
+				log.Error().Err(err).
+					Str("interface_name", string(op.ContractName)).
+					Str("operation_name", op.OperationName).
+					Msg("cannot add contract operation")
+				continue
+			}
+			added[op.ContractName] = append(added[op.ContractName], "  + "+describeOperation(op))
+			inserted[op.ContractName] = append(inserted[op.ContractName], op)
+			ret.Tasks = append(ret.Tasks, task)
+		}
+
+		for _, i := range interfaces {
+			diff, ok := added[i.Name]
+			if !ok {
+				continue
+			}
+			r := newRevisionFrom(rev, i.Name)
+			r.Diff = diff
+			stored := *i
+			stored.Operations = inserted[i.Name]
+			if err := addRevision(ctx, tx, contractRepo, r, &stored, allDef); err != nil {
+				return err
+			}
+			ret.Revisions = append(ret.Revisions, r)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// DeleteInterface deletes contract interface with its operations,
+// records the revision and adds rescan tasks removing parsed data in one transaction.
+func DeleteInterface(ctx context.Context,
+	db bun.IDB,
+	contractRepo core.ContractRepository,
+	rescanRepo core.RescanRepository,
+	name abi.ContractName,
+	rev *core.ContractRevision,
+) (*app.RegistryChange, error) {
+	var ret app.RegistryChange
+
+	oldInterface, err := contractRepo.GetInterface(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get '%s' interface", name)
+	}
+
+	r := newRevisionFrom(rev, name)
+	r.Diff = []string{"  - interface"}
+	for _, op := range oldInterface.Operations {
+		r.Diff = append(r.Diff, "  - "+describeOperation(op))
+	}
+	ret.Revisions = append(ret.Revisions, r)
+
+	for _, op := range oldInterface.Operations {
+		ret.Tasks = append(ret.Tasks, newOperationTask(core.DelOperation, op))
+	}
+	ret.Tasks = append(ret.Tasks, newInterfaceTask(name, core.DelInterface))
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := contractRepo.DeleteInterface(ctx, tx, name); err != nil {
+			return errors.Wrapf(err, "cannot delete '%s' interface", name)
+		}
+		if err := addRevision(ctx, tx, contractRepo, r, nil, nil); err != nil {
+			return err
+		}
+		for _, task := range ret.Tasks {
+			if err := addRescanTask(ctx, tx, rescanRepo, task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
@@ -0,0 +1,533 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/aggregate"
+	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/repository/account"
+	"github.com/tonindexer/anton/internal/core/repository/contract"
+	"github.com/tonindexer/anton/internal/core/repository/msg"
+	"github.com/tonindexer/anton/internal/core/repository/rescan"
+)
+
+// maxValidationSamples limits the number of messages and accounts in the validation request
+const maxValidationSamples = 100
+
+var _ app.RegistryService = (*Service)(nil)
+
+type Service struct {
+	*app.RegistryConfig
+
+	contractRepo core.ContractRepository
+	rescanRepo   core.RescanRepository
+	accountRepo  repository.Account
+	msgRepo      repository.Message
+
+	// definitions are registered in the abi package globally,
+	// so the changes of descriptions are made one by one
+	mx sync.Mutex
+}
+
+func NewService(cfg *app.RegistryConfig) *Service {
+	var s = new(Service)
+
+	s.RegistryConfig = cfg
+	ch, pg := s.DB.CH, s.DB.PG
+	s.contractRepo = contract.NewRepository(pg)
+	s.rescanRepo = rescan.NewRepository(pg)
+	s.accountRepo = account.NewRepository(ch, pg)
+	s.msgRepo = msg.NewRepository(ch, pg)
+
+	return s
+}
+
+// lock serializes changes and registers stored definitions after each of them,
+// as submitted definitions are registered on descriptions parsing
+func (s *Service) lock(ctx context.Context) (unlock func()) {
+	s.mx.Lock()
+
+	return func() {
+		defer s.mx.Unlock()
+
+		def, err := s.contractRepo.GetDefinitions(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("get definitions")
+			return
+		}
+		if err := abi.RegisterDefinitions(def); err != nil {
+			log.Error().Err(err).Msg("register definitions")
+		}
+	}
+}
+
+func invalidDesc(err error) error {
+	return errors.Wrapf(core.ErrInvalidArg, "invalid description (%s)", err.Error())
+}
+
+func (s *Service) DiscoverContracts(ctx context.Context, req *aggregate.DiscoverContractsReq) (*aggregate.DiscoverContractsRes, error) {
+	return aggregate.DiscoverContracts(ctx, s.DB.CH, s.DB.PG, req)
+}
+
+func (s *Service) AddInterface(ctx context.Context, desc *abi.InterfaceDesc, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	defer s.lock(ctx)()
+
+	_, err := s.contractRepo.GetInterface(ctx, desc.Name)
+	if err == nil {
+		return nil, errors.Wrapf(core.ErrAlreadyExists, "'%s' interface", desc.Name)
+	}
+	if !errors.Is(err, core.ErrNotFound) {
+		return nil, errors.Wrapf(err, "get '%s' interface", desc.Name)
+	}
+
+	if _, _, _, err := ParseInterfacesDesc([]*abi.InterfaceDesc{desc}); err != nil {
+		return nil, invalidDesc(err)
+	}
+
+	rev.Action = core.RevisionAdd
+	return AddInterfaces(ctx, s.DB.PG, s.contractRepo, s.rescanRepo, []*abi.InterfaceDesc{desc}, rev)
+}
+
+func (s *Service) UpdateInterface(ctx context.Context, desc *abi.InterfaceDesc, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	defer s.lock(ctx)()
+
+	definitions, interfaces, _, err := ParseInterfacesDesc([]*abi.InterfaceDesc{desc})
+	if err != nil {
+		return nil, invalidDesc(err)
+	}
+
+	oldInterface, err := s.contractRepo.GetInterface(ctx, desc.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get '%s' interface", desc.Name)
+	}
+
+	plan, err := NewUpdatePlan(ctx, s.contractRepo, definitions, oldInterface, interfaces[0])
+	if err != nil {
+		return nil, err
+	}
+
+	rev.Action = core.RevisionUpdate
+	return plan.Apply(ctx, s.DB.PG, s.contractRepo, s.rescanRepo, rev)
+}
+
+func (s *Service) DeleteInterface(ctx context.Context, name abi.ContractName, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	defer s.lock(ctx)()
+
+	rev.Action = core.RevisionDelete
+	return DeleteInterface(ctx, s.DB.PG, s.contractRepo, s.rescanRepo, name, rev)
+}
+
+func (s *Service) RollbackInterface(ctx context.Context, name abi.ContractName, version uint32, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	defer s.lock(ctx)()
+
+	plan, err := NewRollbackPlan(ctx, s.contractRepo, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	rev.Action = core.RevisionRollback
+	if rev.Comment == "" {
+		rev.Comment = fmt.Sprintf("rollback to version %d", version)
+	}
+	return plan.Apply(ctx, s.DB.PG, s.contractRepo, s.rescanRepo, rev)
+}
+
+// updateOperations changes operations of the stored interface and applies the update plan
+func (s *Service) updateOperations(
+	ctx context.Context,
+	name abi.ContractName,
+	rev *core.ContractRevision,
+	update func([]*core.ContractOperation) ([]*core.ContractOperation, error),
+) (*app.RegistryChange, error) {
+	oldInterface, err := s.contractRepo.GetInterface(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get '%s' interface", name)
+	}
+
+	newInterface := *oldInterface
+	newInterface.Operations, err = update(oldInterface.Operations)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := NewUpdatePlan(ctx, s.contractRepo, nil, oldInterface, &newInterface)
+	if err != nil {
+		return nil, err
+	}
+
+	rev.Action = core.RevisionUpdate
+	return plan.Apply(ctx, s.DB.PG, s.contractRepo, s.rescanRepo, rev)
+}
+
+func parseOperationReq(req *app.ContractOperationReq) (*core.ContractOperation, error) {
+	op, err := ParseOperationDesc(req.ContractName, &req.Schema)
+	if err != nil {
+		return nil, invalidDesc(err)
+	}
+	op.Outgoing = req.Outgoing
+	return op, nil
+}
+
+func (s *Service) AddOperation(ctx context.Context, req *app.ContractOperationReq, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	defer s.lock(ctx)()
+
+	op, err := parseOperationReq(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateOperations(ctx, req.ContractName, rev, func(operations []*core.ContractOperation) ([]*core.ContractOperation, error) {
+		for _, x := range operations {
+			if x.OperationName == op.OperationName {
+				return nil, errors.Wrapf(core.ErrAlreadyExists, "'%s' operation", op.OperationName)
+			}
+			if x.MessageType == op.MessageType && x.Outgoing == op.Outgoing && x.OperationID == op.OperationID {
+				return nil, errors.Wrapf(core.ErrAlreadyExists, "'%s' operation with the same id", x.OperationName)
+			}
+		}
+		return append(append([]*core.ContractOperation{}, operations...), op), nil
+	})
+}
+
+func (s *Service) UpdateOperation(ctx context.Context, req *app.ContractOperationReq, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	defer s.lock(ctx)()
+
+	op, err := parseOperationReq(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateOperations(ctx, req.ContractName, rev, func(operations []*core.ContractOperation) (ret []*core.ContractOperation, _ error) {
+		var found bool
+		for _, x := range operations {
+			if x.OperationName == op.OperationName {
+				found = true
+				x = op
+			}
+			ret = append(ret, x)
+		}
+		if !found {
+			return nil, errors.Wrapf(core.ErrNotFound, "no operation '%s'", op.OperationName)
+		}
+		return ret, nil
+	})
+}
+
+func (s *Service) DeleteOperation(ctx context.Context, name abi.ContractName, opName string, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	defer s.lock(ctx)()
+
+	return s.updateOperations(ctx, name, rev, func(operations []*core.ContractOperation) (ret []*core.ContractOperation, _ error) {
+		for _, x := range operations {
+			if x.OperationName != opName {
+				ret = append(ret, x)
+			}
+		}
+		if len(ret) == len(operations) {
+			return nil, errors.Wrapf(core.ErrNotFound, "no operation '%s'", opName)
+		}
+		return ret, nil
+	})
+}
+
+func (s *Service) AddDefinition(ctx context.Context, dn abi.TLBType, d abi.TLBFieldsDesc) error {
+	defer s.lock(ctx)()
+
+	definitions, err := s.contractRepo.GetDefinitions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get definitions")
+	}
+	if _, ok := definitions[dn]; ok {
+		return errors.Wrapf(core.ErrAlreadyExists, "'%s' definition", dn)
+	}
+
+	if err := abi.RegisterDefinitions(map[abi.TLBType]abi.TLBFieldsDesc{dn: d}); err != nil {
+		return invalidDesc(err)
+	}
+
+	if err := s.contractRepo.AddDefinition(ctx, s.DB.PG, dn, d); err != nil {
+		return errors.Wrapf(err, "cannot insert contract definition '%s'", dn)
+	}
+
+	return nil
+}
+
+func (s *Service) getDefinitionUsage(ctx context.Context, dn abi.TLBType) (*definitionUsage, []*core.ContractInterface, []*core.ContractOperation, error) {
+	definitions, err := s.contractRepo.GetDefinitions(ctx)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "get definitions")
+	}
+	if _, ok := definitions[dn]; !ok {
+		return nil, nil, nil, errors.Wrapf(core.ErrNotFound, "no definition '%s'", dn)
+	}
+
+	interfaces, err := s.contractRepo.GetInterfaces(ctx)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "get interfaces")
+	}
+	operations, err := s.contractRepo.GetOperations(ctx)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "get operations")
+	}
+
+	return newDefinitionUsage(dn, definitions), interfaces, operations, nil
+}
+
+// UpdateDefinition updates the definition and records revisions of interfaces,
+// which operations or get-methods are parsed with it, adding rescan tasks for them
+func (s *Service) UpdateDefinition(ctx context.Context, dn abi.TLBType, d abi.TLBFieldsDesc, rev *core.ContractRevision) (*app.RegistryChange, error) {
+	var ret app.RegistryChange
+
+	defer s.lock(ctx)()
+
+	usage, interfaces, operations, err := s.getDefinitionUsage(ctx, dn)
+	if err != nil {
+		return nil, err
+	}
+
+	usage.definitions[dn] = d
+	if err := abi.RegisterDefinitions(usage.definitions); err != nil {
+		return nil, invalidDesc(err)
+	}
+
+	ops, getMethods := usage.affected(interfaces, operations)
+	for _, op := range ops {
+		if _, err := op.Schema.New(); err != nil {
+			return nil, invalidDesc(errors.Wrapf(err, "'%s' operation", op.OperationName))
+		}
+	}
+
+	diff, tasks := map[abi.ContractName][]string{}, map[abi.ContractName][]*core.RescanTask{}
+	for name, desc := range getMethods {
+		diff[name] = append(diff[name], fmt.Sprintf("interface %s:", name))
+		for it := range desc {
+			diff[name] = append(diff[name], "  ~ get-method "+desc[it].Name)
+		}
+		if task := newGetMethodTask(name, core.UpdGetMethod, getGetMethodNames(desc)); task != nil {
+			tasks[name] = append(tasks[name], task)
+		}
+	}
+	for _, op := range ops {
+		if _, ok := diff[op.ContractName]; !ok {
+			diff[op.ContractName] = []string{fmt.Sprintf("interface %s:", op.ContractName)}
+		}
+		diff[op.ContractName] = append(diff[op.ContractName], "  ~ "+describeOperation(op))
+		tasks[op.ContractName] = append(tasks[op.ContractName], newOperationTask(core.UpdOperation, op))
+	}
+
+	err = s.DB.PG.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := s.contractRepo.UpdateDefinition(ctx, tx, dn, d); err != nil {
+			return errors.Wrapf(err, "cannot update contract definition '%s'", dn)
+		}
+
+		for name := range diff {
+			i, err := s.contractRepo.GetInterface(ctx, name)
+			if err != nil {
+				return errors.Wrapf(err, "get '%s' interface", name)
+			}
+
+			r := newRevisionFrom(rev, name)
+			r.Action = core.RevisionUpdate
+			r.Diff = append([]string{"definitions:", "  ~ " + string(dn)}, diff[name]...)
+			if err := addRevision(ctx, tx, s.contractRepo, r, i, usage.definitions); err != nil {
+				return err
+			}
+			ret.Revisions = append(ret.Revisions, r)
+
+			for _, task := range tasks[name] {
+				if err := addRescanTask(ctx, tx, s.rescanRepo, task); err != nil {
+					return err
+				}
+				ret.Tasks = append(ret.Tasks, task)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// DeleteDefinition deletes the definition, which is not used by any operation, get-method or other definition
+func (s *Service) DeleteDefinition(ctx context.Context, dn abi.TLBType) error {
+	defer s.lock(ctx)()
+
+	usage, interfaces, operations, err := s.getDefinitionUsage(ctx, dn)
+	if err != nil {
+		return err
+	}
+
+	ops, getMethods := usage.affected(interfaces, operations)
+	if len(ops) > 0 {
+		return errors.Wrapf(core.ErrInvalidArg, "'%s' definition is used by '%s' operation", dn, ops[0].OperationName)
+	}
+	for name := range getMethods {
+		return errors.Wrapf(core.ErrInvalidArg, "'%s' definition is used by '%s' get-methods", dn, name)
+	}
+	if used := usage.usedByDefinitions(); len(used) > 0 {
+		return errors.Wrapf(core.ErrInvalidArg, "'%s' definition is used by '%s' definition", dn, used[0])
+	}
+
+	if err := s.contractRepo.DeleteDefinition(ctx, s.DB.PG, dn); err != nil {
+		return errors.Wrapf(err, "cannot delete contract definition '%s'", dn)
+	}
+
+	return nil
+}
+
+// matchOperation looks for the operation parsing the message payload,
+// the direction is taken from the stored message contracts if it is known
+func matchOperation(msg *core.Message, name abi.ContractName, operations []*core.ContractOperation) *core.ContractOperation {
+	for _, op := range operations {
+		if op.MessageType != msg.Type || op.OperationID != msg.OperationID {
+			continue
+		}
+		if (msg.SrcContract == name && !op.Outgoing) || (msg.DstContract == name && op.Outgoing) {
+			continue
+		}
+		return op
+	}
+	return nil
+}
+
+func validateMessage(msg *core.Message, name abi.ContractName, operations []*core.ContractOperation) *app.ValidatedMessage {
+	ret := &app.ValidatedMessage{
+		Hash:                msg.Hash,
+		StoredOperationName: msg.OperationName,
+		StoredData:          msg.DataJSON,
+	}
+
+	op := matchOperation(msg, name, operations)
+	if op == nil {
+		ret.Error = fmt.Sprintf("no operation with 0x%08x id", msg.OperationID)
+		return ret
+	}
+	ret.OperationName = op.OperationName
+
+	payload, err := cell.FromBOC(msg.Body)
+	if err != nil {
+		ret.Error = errors.Wrap(err, "msg body from boc").Error()
+		return ret
+	}
+	parsed, err := op.Schema.FromCell(payload)
+	if err != nil {
+		ret.Error = errors.Wrap(err, "parse msg body").Error()
+		return ret
+	}
+	ret.Data, err = json.Marshal(parsed)
+	if err != nil {
+		ret.Error = errors.Wrap(err, "json marshal parsed payload").Error()
+	}
+
+	return ret
+}
+
+func (s *Service) getLatestState(ctx context.Context, a addr.Address) (*core.AccountState, error) {
+	res, err := s.accountRepo.FilterAccounts(ctx, &filter.AccountsReq{
+		Addresses:    []*addr.Address{&a},
+		LatestState:  true,
+		WithCodeData: true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "filter accounts")
+	}
+	if len(res.Rows) == 0 {
+		return nil, errors.Wrapf(core.ErrNotFound, "no %s account state", a.Base64())
+	}
+	return res.Rows[0], nil
+}
+
+func (s *Service) validateAccount(ctx context.Context, i *core.ContractInterface, acc *core.AccountState) *app.ValidatedAccount {
+	ret := &app.ValidatedAccount{
+		Address:          acc.Address,
+		LastTxLT:         acc.LastTxLT,
+		StoredGetMethods: acc.ExecutedGetMethods[i.Name],
+	}
+
+	if s.Parser == nil {
+		ret.Error = "parser is not set, get-methods are not executed"
+		return ret
+	}
+
+	err := s.Parser.ParseAccountContractData(ctx, i, acc, s.getLatestState)
+	if errors.Is(err, app.ErrUnmatchedContractInterface) {
+		return ret
+	}
+	if err != nil {
+		ret.Error = err.Error()
+		return ret
+	}
+
+	ret.Matched = true
+	ret.GetMethods = acc.ExecutedGetMethods[i.Name]
+
+	return ret
+}
+
+// ValidateSchema parses sample messages and executes get-methods on sample accounts
+// with the submitted interface description without saving it
+func (s *Service) ValidateSchema(ctx context.Context, req *app.ValidateSchemaReq) (*app.ValidateSchemaRes, error) {
+	var ret = app.ValidateSchemaRes{
+		Messages: []*app.ValidatedMessage{},
+		Accounts: []*app.ValidatedAccount{},
+	}
+
+	if len(req.MessageHashes) > maxValidationSamples || len(req.Addresses) > maxValidationSamples {
+		return nil, errors.Wrapf(core.ErrInvalidArg, "too many samples, maximum is %d", maxValidationSamples)
+	}
+
+	defer s.lock(ctx)()
+
+	// definitions are inlined instead of being registered,
+	// so that the submitted description does not affect the running parsers
+	desc, err := req.Interface.InlineDefinitions()
+	if err != nil {
+		return nil, invalidDesc(err)
+	}
+	i, operations, err := ParseInterfaceDesc(desc)
+	if err != nil {
+		return nil, invalidDesc(err)
+	}
+
+	if len(req.MessageHashes) > 0 {
+		messages, err := s.msgRepo.GetMessages(ctx, req.MessageHashes)
+		if err != nil {
+			return nil, errors.Wrap(err, "get messages")
+		}
+		for _, msg := range messages {
+			ret.Messages = append(ret.Messages, validateMessage(msg, i.Name, operations))
+		}
+	}
+
+	if len(req.Addresses) > 0 {
+		res, err := s.accountRepo.FilterAccounts(ctx, &filter.AccountsReq{
+			Addresses:    req.Addresses,
+			LatestState:  true,
+			WithCodeData: true,
+			Limit:        len(req.Addresses),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "filter accounts")
+		}
+		for _, acc := range res.Rows {
+			ret.Accounts = append(ret.Accounts, s.validateAccount(ctx, i, acc))
+		}
+	}
+
+	return &ret, nil
+}
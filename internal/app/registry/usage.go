@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"strings"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+// referencedDefinitions returns definitions referenced by the field format or by the union in the field type
+func referencedDefinitions(tlbType string, format abi.TLBType) (ret []abi.TLBType) {
+	if format != "" {
+		ret = append(ret, format)
+	}
+	if strings.HasPrefix(tlbType, "[") && strings.HasSuffix(tlbType, "]") {
+		for _, dn := range strings.Split(tlbType[1:len(tlbType)-1], ",") {
+			ret = append(ret, abi.TLBType(dn))
+		}
+	}
+	return ret
+}
+
+// interfaceDefinitions returns definitions, which operations and get-methods of the interface are parsed with,
+// together with definitions referenced by them
+func interfaceDefinitions(i *core.ContractInterface, definitions map[abi.TLBType]abi.TLBFieldsDesc) map[abi.TLBType]abi.TLBFieldsDesc {
+	ret := map[abi.TLBType]abi.TLBFieldsDesc{}
+
+	var addFields func(fields abi.TLBFieldsDesc)
+	addType := func(t abi.TLBType) {
+		d, ok := definitions[t]
+		if _, added := ret[t]; !ok || added {
+			return
+		}
+		ret[t] = d
+		addFields(d)
+	}
+	addFields = func(fields abi.TLBFieldsDesc) {
+		for it := range fields {
+			for _, t := range referencedDefinitions(fields[it].Type, fields[it].Format) {
+				addType(t)
+			}
+			addFields(fields[it].Fields)
+		}
+	}
+
+	for _, op := range i.Operations {
+		addFields(op.Schema.Body)
+	}
+	for it := range i.GetMethodsDesc {
+		desc := &i.GetMethodsDesc[it]
+		for _, values := range [][]abi.VmValueDesc{desc.Arguments, desc.ReturnValues} {
+			for v := range values {
+				if values[v].Format != "" {
+					addType(values[v].Format)
+				}
+				addFields(values[v].Fields)
+			}
+		}
+	}
+
+	return ret
+}
+
+type definitionUsage struct {
+	dn          abi.TLBType
+	definitions map[abi.TLBType]abi.TLBFieldsDesc
+	visited     map[abi.TLBType]bool
+}
+
+func newDefinitionUsage(dn abi.TLBType, definitions map[abi.TLBType]abi.TLBFieldsDesc) *definitionUsage {
+	return &definitionUsage{dn: dn, definitions: definitions, visited: map[abi.TLBType]bool{}}
+}
+
+// usedBy checks whether the type is the definition or refers to it through other definitions
+func (u *definitionUsage) usedBy(t abi.TLBType) bool {
+	if t == u.dn {
+		return true
+	}
+	if u.visited[t] {
+		return false
+	}
+	u.visited[t] = true
+
+	d, ok := u.definitions[t]
+	return ok && u.usedByFields(d)
+}
+
+func (u *definitionUsage) usedByFields(fields abi.TLBFieldsDesc) bool {
+	for it := range fields {
+		for _, t := range referencedDefinitions(fields[it].Type, fields[it].Format) {
+			if u.usedBy(t) {
+				return true
+			}
+		}
+		if u.usedByFields(fields[it].Fields) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *definitionUsage) usedByGetMethod(desc *abi.GetMethodDesc) bool {
+	for _, values := range [][]abi.VmValueDesc{desc.Arguments, desc.ReturnValues} {
+		for it := range values {
+			if values[it].Format != "" && u.usedBy(values[it].Format) {
+				return true
+			}
+			if u.usedByFields(values[it].Fields) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// usedByDefinitions returns other definitions referring to the definition
+func (u *definitionUsage) usedByDefinitions() (ret []abi.TLBType) {
+	for dn := range u.definitions {
+		if dn == u.dn {
+			continue
+		}
+		u.visited = map[abi.TLBType]bool{}
+		if u.usedBy(dn) {
+			ret = append(ret, dn)
+		}
+	}
+	return ret
+}
+
+// affected returns operations and get-methods parsed with the definition
+func (u *definitionUsage) affected(interfaces []*core.ContractInterface, operations []*core.ContractOperation) (ops []*core.ContractOperation, getMethods map[abi.ContractName][]abi.GetMethodDesc) {
+	getMethods = map[abi.ContractName][]abi.GetMethodDesc{}
+
+	for _, op := range operations {
+		u.visited = map[abi.TLBType]bool{}
+		if u.usedByFields(op.Schema.Body) {
+			ops = append(ops, op)
+		}
+	}
+
+	for _, i := range interfaces {
+		for it := range i.GetMethodsDesc {
+			u.visited = map[abi.TLBType]bool{}
+			if u.usedByGetMethod(&i.GetMethodsDesc[it]) {
+				getMethods[i.Name] = append(getMethods[i.Name], i.GetMethodsDesc[it])
+			}
+		}
+	}
+
+	return ops, getMethods
+}
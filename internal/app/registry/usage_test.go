@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+func TestDefinitionUsage(t *testing.T) {
+	definitions := map[abi.TLBType]abi.TLBFieldsDesc{
+		"config": {
+			{Name: "owner", Type: "addr"},
+		},
+		"state": {
+			{Name: "config", Type: "^", Format: "config"},
+		},
+		"payload": {
+			{Name: "inner", Type: ".", Format: "struct", Fields: abi.TLBFieldsDesc{
+				{Name: "value", Type: "[state,other]"},
+			}},
+		},
+		"other": {
+			{Name: "amount", Type: ".", Format: "coins"},
+		},
+	}
+
+	interfaces := []*core.ContractInterface{{
+		Name: "test",
+		GetMethodsDesc: []abi.GetMethodDesc{
+			{Name: "get_config", ReturnValues: []abi.VmValueDesc{{Name: "config", StackType: "cell", Format: "config"}}},
+			{Name: "get_amount", ReturnValues: []abi.VmValueDesc{{Name: "amount", StackType: "cell", Format: "other"}}},
+		},
+	}}
+	operations := []*core.ContractOperation{
+		{ContractName: "test", OperationName: "transfer", Schema: abi.OperationDesc{Body: definitions["payload"]}},
+		{ContractName: "test", OperationName: "burn", Schema: abi.OperationDesc{Body: definitions["other"]}},
+	}
+
+	u := newDefinitionUsage("config", definitions)
+
+	used := u.usedByDefinitions()
+	require.ElementsMatch(t, []abi.TLBType{"state", "payload"}, used)
+
+	ops, getMethods := u.affected(interfaces, operations)
+	require.Len(t, ops, 1)
+	require.Equal(t, "transfer", ops[0].OperationName)
+	require.Len(t, getMethods["test"], 1)
+	require.Equal(t, "get_config", getMethods["test"][0].Name)
+
+	u = newDefinitionUsage("unused", definitions)
+	require.Len(t, u.usedByDefinitions(), 0)
+	ops, getMethods = u.affected(interfaces, operations)
+	require.Len(t, ops, 0)
+	require.Len(t, getMethods, 0)
+}
+
+func TestInterfaceDefinitions(t *testing.T) {
+	definitions := map[abi.TLBType]abi.TLBFieldsDesc{
+		"config": {
+			{Name: "owner", Type: "addr"},
+		},
+		"state": {
+			{Name: "config", Type: "^", Format: "config"},
+		},
+		"payload": {
+			{Name: "inner", Type: ".", Format: "struct", Fields: abi.TLBFieldsDesc{
+				{Name: "value", Type: "[state,other]"},
+			}},
+		},
+		"other": {
+			{Name: "amount", Type: ".", Format: "coins"},
+		},
+		"unused": {
+			{Name: "config", Type: "^", Format: "config"},
+		},
+	}
+
+	i := &core.ContractInterface{
+		Name: "test",
+		GetMethodsDesc: []abi.GetMethodDesc{
+			{Name: "get_config", ReturnValues: []abi.VmValueDesc{{Name: "config", StackType: "cell", Format: "config"}}},
+		},
+	}
+	require.Equal(t, map[abi.TLBType]abi.TLBFieldsDesc{"config": definitions["config"]}, interfaceDefinitions(i, definitions))
+
+	i.Operations = []*core.ContractOperation{
+		{ContractName: "test", OperationName: "transfer", Schema: abi.OperationDesc{Body: abi.TLBFieldsDesc{
+			{Name: "payload", Type: "^", Format: "payload"},
+		}}},
+	}
+	used := interfaceDefinitions(i, definitions)
+	require.Len(t, used, 4)
+	for _, dn := range []abi.TLBType{"config", "state", "payload", "other"} {
+		require.Equal(t, definitions[dn], used[dn])
+	}
+}
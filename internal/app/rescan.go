@@ -14,8 +14,14 @@ type RescanConfig struct {
 
 	Parser ParserService
 
+	// Workers is the number of workers parsing account states and messages,
+	// they are shared between concurrently running tasks
 	Workers int
 
+	// Tasks is the number of rescan tasks run concurrently,
+	// tasks of the same contract interface are run in order of creation
+	Tasks int
+
 	SelectLimit int
 }
 
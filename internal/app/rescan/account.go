@@ -55,6 +55,13 @@ func copyAccountState(state *core.AccountState) *core.AccountState {
 		copy(update.ExecutedGetMethods[n], e)
 	}
 
+	if state.InterfaceVersions != nil {
+		update.InterfaceVersions = map[abi.ContractName]uint32{}
+		for n, v := range state.InterfaceVersions {
+			update.InterfaceVersions[n] = v
+		}
+	}
+
 	return &update
 }
 
@@ -75,6 +82,7 @@ func (s *Service) clearParsedAccountsData(task *core.RescanTask, acc *core.Accou
 	}
 
 	delete(acc.ExecutedGetMethods, task.ContractName)
+	delete(acc.InterfaceVersions, task.ContractName)
 
 	switch task.ContractName {
 	case known.NFTCollection, known.NFTItem, known.JettonMinter, known.JettonWallet:
@@ -225,6 +233,28 @@ func (s *Service) rescanGetMethod(ctx context.Context, task *core.RescanTask, ac
 	}
 }
 
+// reparseAccount clears all parsed data of the account state,
+// determines its interfaces once again and executes suitable get-methods
+func (s *Service) reparseAccount(ctx context.Context, acc *core.AccountState) {
+	acc.Types = nil
+	acc.ExecutedGetMethods = nil
+	acc.InterfaceVersions = nil
+	acc.MinterAddress = nil
+	acc.OwnerAddress = nil
+	acc.Fake = false
+	acc.NFTContentData = core.NFTContentData{}
+	acc.FTWalletData = core.FTWalletData{}
+
+	getOtherAccountFunc := func(ctx context.Context, a addr.Address) (*core.AccountState, error) {
+		return s.getRecentAccountState(ctx, a, acc.LastTxLT)
+	}
+
+	err := s.Parser.ParseAccountData(ctx, acc, getOtherAccountFunc)
+	if err != nil && !errors.Is(err, app.ErrImpossibleParsing) {
+		log.Error().Err(err).Str("addr", acc.Address.Base64()).Msg("parse account data")
+	}
+}
+
 func (s *Service) rescanAccountsWorker(ctx context.Context, task *core.RescanTask, batch []*core.AccountState) (updates []*core.AccountState) {
 	for _, acc := range batch {
 		update := copyAccountState(acc)
@@ -236,6 +266,8 @@ func (s *Service) rescanAccountsWorker(ctx context.Context, task *core.RescanTas
 			for _, gm := range task.ChangedGetMethods {
 				s.rescanGetMethod(ctx, task, update, gm)
 			}
+		case core.RescanAccounts:
+			s.reparseAccount(ctx, update)
 		}
 
 		if reflect.DeepEqual(acc, update) {
@@ -0,0 +1,24 @@
+package rescan
+
+import (
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/metrics"
+)
+
+var (
+	processedTotal = metrics.NewCounter(
+		"anton_rescan_processed_total", "Number of account states or messages scanned by rescan tasks.", "type")
+	updatedTotal = metrics.NewCounter(
+		"anton_rescan_updated_total", "Number of account states or messages updated by rescan tasks.", "type")
+
+	tasksFinished = metrics.NewCounter(
+		"anton_rescan_tasks_finished_total", "Number of finished rescan tasks.", "type")
+	taskErrors = metrics.NewCounter(
+		"anton_rescan_task_errors_total", "Number of failed rescan task runs.", "type")
+)
+
+func observeBatch(task *core.RescanTask, processed, updated int) {
+	typ := string(task.Type)
+	processedTotal.Add(float64(processed), typ)
+	updatedTotal.Add(float64(updated), typ)
+}
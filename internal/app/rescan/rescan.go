@@ -3,12 +3,15 @@ package rescan
 import (
 	"bytes"
 	"context"
+	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
 	"github.com/xssnick/tonutils-go/tvm/cell"
 
 	"github.com/tonindexer/anton/abi"
@@ -26,6 +29,9 @@ type Service struct {
 
 	interfacesCache  *lru.Cache[addr.Address, map[uint64][]abi.ContractName]
 	minterStateCache *mintersCache
+	scopesCache      *lru.Cache[int, *core.RescanScope] // targeted task id -> scope
+
+	workerSlots chan struct{}
 
 	run bool
 	mx  sync.RWMutex
@@ -41,9 +47,15 @@ func NewService(cfg *app.RescanConfig) *Service {
 	if s.Workers < 1 {
 		s.Workers = 1
 	}
+	if s.Tasks < 1 {
+		s.Tasks = 1
+	}
+
+	s.workerSlots = make(chan struct{}, s.Workers)
 
 	s.interfacesCache = lru.New[addr.Address, map[uint64][]abi.ContractName](16384) // number of addresses
 	s.minterStateCache = newMinterStateCache(2048)                                  // number of addresses
+	s.scopesCache = lru.New[int, *core.RescanScope](256)                            // number of tasks
 
 	return s
 }
@@ -60,11 +72,14 @@ func (s *Service) Start() error {
 	s.run = true
 	s.mx.Unlock()
 
-	s.wg.Add(1)
-	go s.rescanLoop()
+	for i := 0; i < s.Tasks; i++ {
+		s.wg.Add(1)
+		go s.rescanLoop()
+	}
 
 	log.Info().
 		Int("workers", s.Workers).
+		Int("tasks", s.Tasks).
 		Msg("rescan started")
 
 	return nil
@@ -91,11 +106,15 @@ func (s *Service) rescanLoop() {
 			continue
 		}
 
-		if err := s.rescanRunTask(context.Background(), task); err != nil {
+		if err := s.rescanRunTask(context.Background(), tx, task); err != nil {
 			_ = tx.Rollback()
+			taskErrors.Inc(string(task.Type))
 			log.Error().Err(err).
 				Int("id", task.ID).
 				Msg("run rescan task")
+			if err := s.RescanRepo.AddRescanTaskError(context.Background(), task.ID, err); err != nil {
+				log.Error().Err(err).Int("id", task.ID).Msg("save rescan task error")
+			}
 			time.Sleep(time.Second)
 			continue
 		}
@@ -105,10 +124,14 @@ func (s *Service) rescanLoop() {
 			time.Sleep(time.Second)
 			continue
 		}
+		if task.Finished {
+			tasksFinished.Inc(string(task.Type))
+			s.scopesCache.Remove(task.ID)
+		}
 	}
 }
 
-func (s *Service) rescanRunTask(ctx context.Context, task *core.RescanTask) error { //nolint:gocyclo,gocognit // yeah, it's a bit long
+func (s *Service) rescanRunTask(ctx context.Context, tx bun.Tx, task *core.RescanTask) error { //nolint:gocyclo,gocognit // yeah, it's a bit long
 	var codeHash []byte
 	if task.Contract != nil && task.Contract.Code != nil {
 		codeCell, err := cell.FromBOC(task.Contract.Code)
@@ -118,6 +141,27 @@ func (s *Service) rescanRunTask(ctx context.Context, task *core.RescanTask) erro
 		codeHash = codeCell.Hash()
 	}
 
+	var scope *core.RescanScope
+	if task.Type.Targeted() {
+		var err error
+		scope, err = s.getTaskScope(ctx, task)
+		if errors.Is(err, core.ErrNotFound) {
+			task.Finished = true
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "get task scope")
+		}
+	}
+
+	if task.Total == 0 && task.LastAddress == nil && task.LastHash == nil {
+		total, err := CountTaskRows(ctx, s.AccountRepo, s.MessageRepo, task, codeHash, scope)
+		if err != nil {
+			log.Warn().Err(err).Int("id", task.ID).Msg("cannot estimate the number of rows to rescan")
+		}
+		task.Total = int64(total)
+	}
+
 	switch task.Type {
 	case core.AddInterface:
 		ids, err := s.AccountRepo.MatchStatesByInterfaceDesc(ctx, "", task.Contract.Addresses, codeHash, task.Contract.GetMethodHashes, task.LastAddress, task.LastTxLt, s.SelectLimit)
@@ -129,7 +173,7 @@ func (s *Service) rescanRunTask(ctx context.Context, task *core.RescanTask) erro
 			return nil
 		}
 
-		if err := s.rescanAccounts(ctx, task, ids); err != nil {
+		if err := s.rescanAccounts(ctx, tx, task, ids); err != nil {
 			return errors.Wrapf(err, "rescan accounts")
 		}
 
@@ -145,7 +189,7 @@ func (s *Service) rescanRunTask(ctx context.Context, task *core.RescanTask) erro
 			return nil
 		}
 
-		if err := s.rescanAccounts(ctx, task, ids); err != nil {
+		if err := s.rescanAccounts(ctx, tx, task, ids); err != nil {
 			return errors.Wrapf(err, "rescan accounts")
 		}
 
@@ -161,7 +205,7 @@ func (s *Service) rescanRunTask(ctx context.Context, task *core.RescanTask) erro
 			return nil
 		}
 
-		if err := s.rescanAccounts(ctx, task, ids); err != nil {
+		if err := s.rescanAccounts(ctx, tx, task, ids); err != nil {
 			return errors.Wrapf(err, "rescan accounts")
 		}
 
@@ -181,13 +225,121 @@ func (s *Service) rescanRunTask(ctx context.Context, task *core.RescanTask) erro
 			return errors.Wrapf(err, "rescan messages")
 		}
 
+		return nil
+
+	case core.RescanAccounts:
+		ids, err := s.AccountRepo.MatchStatesByScope(ctx, scope, task.LastAddress, task.LastTxLt, s.SelectLimit)
+		if err != nil {
+			return errors.Wrapf(err, "match states by scope")
+		}
+		if len(ids) == 0 {
+			task.Finished = true
+			return nil
+		}
+
+		if err := s.rescanAccounts(ctx, tx, task, ids); err != nil {
+			return errors.Wrapf(err, "rescan accounts")
+		}
+
+		return nil
+
+	case core.RescanMessages:
+		if task.LastHash == nil {
+			// interfaces of the accounts could be changed by another rescan service
+			for _, a := range task.Addresses {
+				s.interfacesCache.Remove(*a)
+			}
+			for _, r := range task.AccountRanges {
+				s.interfacesCache.Remove(r.Address)
+			}
+		}
+
+		hashes, err := s.MessageRepo.MatchMessagesByScope(ctx, scope, task.LastHash, s.SelectLimit)
+		if err != nil {
+			return errors.Wrapf(err, "match messages by scope")
+		}
+		if len(hashes) == 0 {
+			task.Finished = true
+			return nil
+		}
+
+		if err := s.rescanMessages(ctx, task, hashes); err != nil {
+			return errors.Wrapf(err, "rescan messages")
+		}
+
 		return nil
 	}
 
 	return errors.Wrapf(core.ErrInvalidArg, "unknown rescan task type %s", task.Type)
 }
 
-func (s *Service) rescanAccounts(ctx context.Context, task *core.RescanTask, ids []*core.AccountStateID) error {
+// getTaskScope makes the selection of account states or messages for a targeted task,
+// masterchain blocks range is resolved to the ranges of shard blocks once per task run.
+// It returns core.ErrNotFound if there are no blocks in the given masterchain range.
+func (s *Service) getTaskScope(ctx context.Context, task *core.RescanTask) (*core.RescanScope, error) {
+	if scope, ok := s.scopesCache.Get(task.ID); ok {
+		return scope, nil
+	}
+
+	scope := &core.RescanScope{
+		Addresses:     task.Addresses,
+		FromTime:      task.FromTime,
+		ToTime:        task.ToTime,
+		MessageHashes: task.MessageHashes,
+		AccountRanges: task.AccountRanges,
+	}
+
+	if task.FromMasterSeqNo == 0 && task.ToMasterSeqNo == 0 {
+		return scope, nil
+	}
+
+	to := task.ToMasterSeqNo
+	if to == 0 {
+		to = math.MaxUint32
+	}
+	blocks, err := s.BlockRepo.GetShardBlockRanges(ctx, task.FromMasterSeqNo, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "get shard block ranges")
+	}
+	if len(blocks) == 0 {
+		return nil, errors.Wrapf(core.ErrNotFound, "no blocks in %d-%d masterchain range", task.FromMasterSeqNo, task.ToMasterSeqNo)
+	}
+	scope.Blocks = blocks
+
+	s.scopesCache.Put(task.ID, scope)
+
+	return scope, nil
+}
+
+// CountTaskRows estimates the number of account states or messages to rescan,
+// matched rows may change during the task run.
+// codeHash is the hash of the contract interface code, scope is used for targeted tasks.
+func CountTaskRows(ctx context.Context,
+	accountRepo core.AccountRepository,
+	msgRepo core.MessageRepository,
+	task *core.RescanTask,
+	codeHash []byte,
+	scope *core.RescanScope,
+) (int, error) {
+	switch task.Type {
+	case core.AddInterface:
+		return accountRepo.CountStatesByInterfaceDesc(ctx, "", task.Contract.Addresses, codeHash, task.Contract.GetMethodHashes)
+	case core.DelInterface:
+		return accountRepo.CountStatesByInterfaceDesc(ctx, task.ContractName, nil, nil, nil)
+	case core.UpdInterface, core.AddGetMethod, core.DelGetMethod, core.UpdGetMethod:
+		return accountRepo.CountStatesByInterfaceDesc(ctx, task.ContractName, task.Contract.Addresses, codeHash, task.Contract.GetMethodHashes)
+	case core.DelOperation, core.UpdOperation:
+		return msgRepo.CountMessagesByOperationDesc(ctx, task.ContractName, task.MessageType, task.Outgoing, task.OperationID)
+	case core.RescanAccounts:
+		return accountRepo.CountStatesByScope(ctx, scope)
+	case core.RescanMessages:
+		return msgRepo.CountMessagesByScope(ctx, scope)
+	default:
+		return 0, nil
+	}
+}
+
+func (s *Service) rescanAccounts(ctx context.Context, tx bun.Tx, task *core.RescanTask, ids []*core.AccountStateID) error {
 	accRet, err := s.AccountRepo.FilterAccounts(ctx, &filter.AccountsReq{WithCodeData: true, StateIDs: ids})
 	if err != nil {
 		return errors.Wrapf(err, "filter accounts")
@@ -198,7 +350,7 @@ func (s *Service) rescanAccounts(ctx context.Context, task *core.RescanTask, ids
 		func(v *core.AccountState) core.AccountStateID {
 			return core.AccountStateID{Address: v.Address, LastTxLT: v.LastTxLT}
 		},
-		s.rescanAccountsWorker, s.Workers)
+		s.rescanAccountsWorker, s.Workers, s.workerSlots)
 
 	if len(updates) > 0 {
 		if err := s.AccountRepo.UpdateAccountStates(ctx, updates); err != nil {
@@ -206,12 +358,133 @@ func (s *Service) rescanAccounts(ctx context.Context, task *core.RescanTask, ids
 		}
 	}
 
+	if err := s.rescanChangedInterfacesMessages(ctx, tx, task, accRet.Rows, updates); err != nil {
+		return errors.Wrap(err, "add messages rescan task")
+	}
+
+	task.Processed += int64(len(accRet.Rows))
+	observeBatch(task, len(accRet.Rows), len(updates))
+
 	task.LastAddress = &lastScanned.Address
 	task.LastTxLt = lastScanned.LastTxLT
 
 	return nil
 }
 
+// rescanChangedInterfacesMessages adds a task to parse messages of accounts with changed interfaces once again,
+// as messages are parsed using interfaces of source and destination accounts at the time of the message.
+// Only messages in the transactions LT ranges, where account interfaces were changed, are rescanned.
+// The task is added in the transaction of the batch, one pending task is extended by the following batches.
+func (s *Service) rescanChangedInterfacesMessages(ctx context.Context, tx bun.Tx, task *core.RescanTask, rows, updates []*core.AccountState) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	types := make(map[core.AccountStateID][]abi.ContractName, len(rows))
+	for _, acc := range rows {
+		types[core.AccountStateID{Address: acc.Address, LastTxLT: acc.LastTxLT}] = acc.Types
+	}
+
+	var (
+		addresses []addr.Address
+		changed   = map[addr.Address][]uint64{}
+	)
+	for _, upd := range updates {
+		if sameInterfaces(types[core.AccountStateID{Address: upd.Address, LastTxLT: upd.LastTxLT}], upd.Types) {
+			continue
+		}
+		if _, ok := changed[upd.Address]; !ok {
+			addresses = append(addresses, upd.Address)
+		}
+		changed[upd.Address] = append(changed[upd.Address], upd.LastTxLT)
+
+		// interface updates of the account are cached for message parsing
+		s.interfacesCache.Remove(upd.Address)
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	var ranges []*core.AccountLTRange
+	for _, a := range addresses {
+		interfaces, err := s.AccountRepo.GetAllAccountInterfaces(ctx, a)
+		if err != nil {
+			return errors.Wrapf(err, "get %s interface updates", a.Base64())
+		}
+		ranges = append(ranges, changedInterfacesRanges(a, interfaces, changed[a])...)
+	}
+
+	child := &core.RescanTask{
+		Type:          core.RescanMessages,
+		AccountRanges: ranges,
+		ParentID:      task.ID,
+		Priority:      task.Priority,
+	}
+	if err := s.RescanRepo.AddChildRescanTask(ctx, tx, child); err != nil {
+		return err
+	}
+
+	log.Info().
+		Int("parent_id", task.ID).
+		Int("id", child.ID).
+		Int("addresses", len(addresses)).
+		Int("ranges", len(ranges)).
+		Msg("added messages rescan task for accounts with changed interfaces")
+
+	return nil
+}
+
+// changedInterfacesRanges returns transactions LT ranges of the account, in which messages were parsed
+// using the changed account states. The state applies to the messages of transactions
+// from its last transaction LT until the next change of interfaces, the first state applies to all earlier messages.
+// interfaces are account interfaces by LT of change after the update, as returned by GetAllAccountInterfaces.
+func changedInterfacesRanges(a addr.Address, interfaces map[uint64][]abi.ContractName, changedLT []uint64) (ret []*core.AccountLTRange) {
+	changes := make([]uint64, 0, len(interfaces))
+	for lt := range interfaces {
+		changes = append(changes, lt)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i] < changes[j] })
+
+	sort.Slice(changedLT, func(i, j int) bool { return changedLT[i] < changedLT[j] })
+
+	for _, lt := range changedLT {
+		r := &core.AccountLTRange{Address: a, FromTxLT: lt}
+		if len(changes) == 0 || lt <= changes[0] {
+			r.FromTxLT = 0
+		}
+		if i := sort.Search(len(changes), func(i int) bool { return changes[i] > lt }); i < len(changes) {
+			r.ToTxLT = changes[i]
+		}
+
+		// ranges are sorted by the start, so the new one can overlap only the last one
+		if n := len(ret); n > 0 && (ret[n-1].ToTxLT == 0 || r.FromTxLT <= ret[n-1].ToTxLT) {
+			if ret[n-1].ToTxLT != 0 && (r.ToTxLT == 0 || r.ToTxLT > ret[n-1].ToTxLT) {
+				ret[n-1].ToTxLT = r.ToTxLT
+			}
+			continue
+		}
+		ret = append(ret, r)
+	}
+
+	return ret
+}
+
+func sameInterfaces(a, b []abi.ContractName) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[abi.ContractName]struct{}, len(a))
+	for _, t := range a {
+		set[t] = struct{}{}
+	}
+	for _, t := range b {
+		if _, ok := set[t]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Service) rescanMessages(ctx context.Context, task *core.RescanTask, hashes [][]byte) error {
 	messages, err := s.MessageRepo.GetMessages(ctx, hashes)
 	if err != nil {
@@ -227,7 +500,7 @@ func (s *Service) rescanMessages(ctx context.Context, task *core.RescanTask, has
 			}
 			return msgID
 		},
-		s.rescanMessagesWorker, s.Workers)
+		s.rescanMessagesWorker, s.Workers, s.workerSlots)
 
 	if len(updates) > 0 {
 		if err := s.MessageRepo.UpdateMessages(context.Background(), updates); err != nil {
@@ -235,6 +508,15 @@ func (s *Service) rescanMessages(ctx context.Context, task *core.RescanTask, has
 		}
 	}
 
+	task.Processed += int64(len(messages))
+	observeBatch(task, len(messages), len(updates))
+
+	if task.Type == core.RescanMessages {
+		// messages of targeted tasks are selected in order of hashes
+		task.LastHash = hashes[len(hashes)-1]
+		return nil
+	}
+
 	task.LastAddress = &lastScanned.Address
 	task.LastTxLt = lastScanned.LastTxLT
 
@@ -247,6 +529,7 @@ func rescanStartWorkers[V any](ctx context.Context,
 	getID func(V) core.AccountStateID,
 	workerFunc func(context.Context, *core.RescanTask, []V) []V,
 	workers int,
+	slots chan struct{},
 ) (updatesAll []V, lastParsed core.AccountStateID) {
 	var (
 		updatesChan = make(chan []V)
@@ -266,7 +549,14 @@ func rescanStartWorkers[V any](ctx context.Context,
 		scanWG.Add(1)
 		go func(batch []V) {
 			defer scanWG.Done()
-			updatesChan <- workerFunc(ctx, task, batch)
+
+			// worker slots are shared between running tasks,
+			// waiting batches take free slots in turn
+			slots <- struct{}{}
+			updates := workerFunc(ctx, task, batch)
+			<-slots
+
+			updatesChan <- updates
 		}(slice[i : i+batchLen])
 
 		i += batchLen
@@ -0,0 +1,200 @@
+package rescan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/app"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/rndm"
+)
+
+type mockBlockRepo struct {
+	repository.Block
+	ranges []*core.BlockRange
+	calls  int
+}
+
+func (m *mockBlockRepo) GetShardBlockRanges(_ context.Context, _, _ uint32) ([]*core.BlockRange, error) {
+	m.calls++
+	return m.ranges, nil
+}
+
+func TestService_getTaskScope(t *testing.T) {
+	ctx := context.Background()
+
+	blocks := &mockBlockRepo{ranges: []*core.BlockRange{{Workchain: -1, Shard: -1 << 63, FromSeqNo: 100, ToSeqNo: 200}}}
+	s := NewService(&app.RescanConfig{BlockRepo: blocks})
+
+	task := &core.RescanTask{ID: 1, Type: core.RescanAccounts, FromMasterSeqNo: 100, ToMasterSeqNo: 200}
+
+	for i := 0; i < 3; i++ {
+		scope, err := s.getTaskScope(ctx, task)
+		require.Nil(t, err)
+		require.Equal(t, blocks.ranges, scope.Blocks)
+	}
+	require.Equal(t, 1, blocks.calls)
+
+	// finished task scope is dropped
+	s.scopesCache.Remove(task.ID)
+	_, err := s.getTaskScope(ctx, task)
+	require.Nil(t, err)
+	require.Equal(t, 2, blocks.calls)
+
+	// task without blocks range does not query blocks
+	scope, err := s.getTaskScope(ctx, &core.RescanTask{ID: 2, Type: core.RescanMessages})
+	require.Nil(t, err)
+	require.Nil(t, scope.Blocks)
+	require.Equal(t, 2, blocks.calls)
+
+	blocks.ranges = nil
+	_, err = s.getTaskScope(ctx, &core.RescanTask{ID: 3, Type: core.RescanMessages, FromMasterSeqNo: 300})
+	require.True(t, errors.Is(err, core.ErrNotFound), err)
+}
+
+func TestSameInterfaces(t *testing.T) {
+	var testCases = []struct {
+		a, b []abi.ContractName
+		same bool
+	}{
+		{nil, nil, true},
+		{nil, []abi.ContractName{}, true},
+		{[]abi.ContractName{"wallet_v3r2"}, nil, false},
+		{[]abi.ContractName{"nft_item", "nft_editable"}, []abi.ContractName{"nft_editable", "nft_item"}, true},
+		{[]abi.ContractName{"nft_item", "nft_editable"}, []abi.ContractName{"nft_item", "jetton_wallet"}, false},
+		{[]abi.ContractName{"nft_item"}, []abi.ContractName{"nft_item", "nft_editable"}, false},
+	}
+
+	for _, test := range testCases {
+		require.Equal(t, test.same, sameInterfaces(test.a, test.b), "%v %v", test.a, test.b)
+		require.Equal(t, test.same, sameInterfaces(test.b, test.a), "%v %v", test.b, test.a)
+	}
+}
+
+func TestChangedInterfacesRanges(t *testing.T) {
+	a := *rndm.Address()
+
+	// interfaces after the update
+	interfaces := map[uint64][]abi.ContractName{
+		10: nil,
+		20: {"nft_item"},
+		40: {"nft_item", "nft_editable"},
+	}
+
+	var testCases = []*struct {
+		name      string
+		changedLT []uint64
+		ranges    []*core.AccountLTRange
+	}{
+		{
+			name:      "first state",
+			changedLT: []uint64{10},
+			ranges:    []*core.AccountLTRange{{Address: a, FromTxLT: 0, ToTxLT: 20}},
+		},
+		{
+			name:      "state in the middle of unchanged interfaces",
+			changedLT: []uint64{25},
+			ranges:    []*core.AccountLTRange{{Address: a, FromTxLT: 25, ToTxLT: 40}},
+		},
+		{
+			name:      "last interfaces",
+			changedLT: []uint64{40},
+			ranges:    []*core.AccountLTRange{{Address: a, FromTxLT: 40}},
+		},
+		{
+			name:      "merged ranges",
+			changedLT: []uint64{30, 20, 35},
+			ranges:    []*core.AccountLTRange{{Address: a, FromTxLT: 20, ToTxLT: 40}},
+		},
+		{
+			name:      "merged with unlimited range",
+			changedLT: []uint64{45, 40, 50},
+			ranges:    []*core.AccountLTRange{{Address: a, FromTxLT: 40}},
+		},
+		{
+			name:      "separate ranges",
+			changedLT: []uint64{15, 45},
+			ranges: []*core.AccountLTRange{
+				{Address: a, FromTxLT: 15, ToTxLT: 20},
+				{Address: a, FromTxLT: 45},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.ranges, changedInterfacesRanges(a, interfaces, test.changedLT))
+		})
+	}
+}
+
+type mockAccountRepo struct {
+	repository.Account
+	interfaces map[addr.Address]map[uint64][]abi.ContractName
+}
+
+func (m *mockAccountRepo) GetAllAccountInterfaces(_ context.Context, a addr.Address) (map[uint64][]abi.ContractName, error) {
+	return m.interfaces[a], nil
+}
+
+type mockRescanRepo struct {
+	core.RescanRepository
+	children []*core.RescanTask
+}
+
+func (m *mockRescanRepo) AddChildRescanTask(_ context.Context, _ bun.Tx, task *core.RescanTask) error {
+	task.ID = 100 + len(m.children)
+	m.children = append(m.children, task)
+	return nil
+}
+
+func TestService_rescanChangedInterfacesMessages(t *testing.T) {
+	ctx := context.Background()
+
+	a, b := *rndm.Address(), *rndm.Address()
+
+	accounts := &mockAccountRepo{interfaces: map[addr.Address]map[uint64][]abi.ContractName{
+		a: {10: {"nft_item"}, 30: {"nft_item", "nft_editable"}},
+		b: {5: {"jetton_wallet"}},
+	}}
+	tasks := &mockRescanRepo{}
+	s := NewService(&app.RescanConfig{AccountRepo: accounts, RescanRepo: tasks})
+
+	task := &core.RescanTask{ID: 7, Type: core.AddInterface, ContractName: "nft_editable", Priority: 3}
+
+	rows := []*core.AccountState{
+		{Address: a, LastTxLT: 10, Types: []abi.ContractName{"nft_item"}},
+		{Address: a, LastTxLT: 30, Types: []abi.ContractName{"nft_item"}},
+		{Address: b, LastTxLT: 5, Types: []abi.ContractName{"jetton_wallet"}},
+	}
+	updates := []*core.AccountState{
+		{Address: a, LastTxLT: 30, Types: []abi.ContractName{"nft_editable", "nft_item"}},
+		{Address: b, LastTxLT: 5, Types: []abi.ContractName{"jetton_wallet"}},
+	}
+
+	t.Run("unchanged interfaces", func(t *testing.T) {
+		err := s.rescanChangedInterfacesMessages(ctx, bun.Tx{}, task, rows, updates[1:])
+		require.Nil(t, err)
+		require.Empty(t, tasks.children)
+	})
+
+	t.Run("changed interfaces", func(t *testing.T) {
+		err := s.rescanChangedInterfacesMessages(ctx, bun.Tx{}, task, rows, updates)
+		require.Nil(t, err)
+		require.Len(t, tasks.children, 1)
+
+		child := tasks.children[0]
+		require.Equal(t, core.RescanMessages, child.Type)
+		require.Equal(t, task.ID, child.ParentID)
+		require.Equal(t, task.Priority, child.Priority)
+		require.Empty(t, child.ContractName)
+		require.Equal(t, []*core.AccountLTRange{{Address: a, FromTxLT: 30}}, child.AccountRanges)
+	})
+}
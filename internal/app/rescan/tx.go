@@ -92,18 +92,44 @@ func (s *Service) rescanMessage(ctx context.Context, task *core.RescanTask, upda
 	return nil
 }
 
+// reparseMessage clears parsed data of the message and parses it once again
+// using interfaces of both source and destination accounts, as the indexer does
+func (s *Service) reparseMessage(ctx context.Context, update *core.Message) {
+	update.SrcContract, update.DstContract, update.OperationName, update.DataJSON, update.Error, update.SchemaVersion = "", "", "", nil, "", 0
+
+	if update.Type != core.ExternalIn {
+		update.SrcState = s.getAccountStateForMessage(ctx, update.SrcAddress, update.SrcTxLT)
+	}
+	if update.Type != core.ExternalOut {
+		update.DstState = s.getAccountStateForMessage(ctx, update.DstAddress, update.DstTxLT)
+	}
+
+	err := s.Parser.ParseMessagePayload(ctx, update)
+	if err != nil && !errors.Is(err, app.ErrImpossibleParsing) {
+		log.Error().Err(err).
+			Hex("msg_hash", update.Hash).
+			Str("src_addr", update.SrcAddress.String()).
+			Str("dst_addr", update.DstAddress.String()).
+			Uint32("op_id", update.OperationID).
+			Msg("parse message payload")
+	}
+}
+
 func (s *Service) rescanMessagesWorker(ctx context.Context, task *core.RescanTask, messages []*core.Message) (updates []*core.Message) {
 	for _, msg := range messages {
 		upd := *msg
 
 		switch task.Type {
 		case core.DelOperation:
-			upd.SrcContract, upd.DstContract, upd.OperationName, upd.DataJSON, upd.Error = "", "", "", nil, ""
+			upd.SrcContract, upd.DstContract, upd.OperationName, upd.DataJSON, upd.Error, upd.SchemaVersion = "", "", "", nil, "", 0
 
 		case core.UpdOperation:
 			if err := s.rescanMessage(ctx, task, &upd); err != nil {
 				continue
 			}
+
+		case core.RescanMessages:
+			s.reparseMessage(ctx, &upd)
 		}
 
 		if !reflect.DeepEqual(msg, &upd) {
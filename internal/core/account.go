@@ -75,9 +75,9 @@ type AccountState struct {
 	LastTxHash []byte `bun:"type:bytea,unique,notnull" json:"last_tx_hash"`
 
 	StateHash []byte `bun:"type:bytea" json:"state_hash,omitempty"` // only if account is frozen
-	Code      []byte `ch:"-" bun:"type:bytea" json:"code,omitempty"`
+	Code      []byte `ch:"-" bun:"-" json:"code,omitempty"`
 	CodeHash  []byte `bun:"type:bytea" json:"code_hash,omitempty"`
-	Data      []byte `ch:"-" bun:"type:bytea" json:"data,omitempty"`
+	Data      []byte `ch:"-" bun:"-" json:"data,omitempty"`
 	DataHash  []byte `bun:"type:bytea" json:"data_hash,omitempty"`
 	Libraries []byte `bun:"type:bytea" json:"libraries,omitempty"`
 
@@ -92,6 +92,7 @@ type AccountState struct {
 	Fake bool `ch:"type:Bool" bun:"type:boolean" json:"fake"`
 
 	ExecutedGetMethods map[abi.ContractName][]abi.GetMethodExecution `ch:"type:String" bun:"type:jsonb" json:"executed_get_methods,omitempty"`
+	InterfaceVersions  map[abi.ContractName]uint32                   `ch:"type:String" bun:"type:jsonb" json:"interface_versions,omitempty"` // revisions of interfaces used to execute get-methods
 
 	// TODO: remove this
 	NFTContentData
@@ -100,18 +101,22 @@ type AccountState struct {
 	UpdatedAt time.Time `bun:"type:timestamp without time zone,notnull" json:"updated_at"`
 }
 
+// AccountStateCode stores account code once per code hash.
 type AccountStateCode struct {
-	ch.CHModel `ch:"account_states_code" json:"-"`
+	ch.CHModel    `ch:"account_states_code" json:"-"`
+	bun.BaseModel `bun:"table:account_states_code" json:"-"`
 
-	CodeHash []byte `ch:"type:String"`
-	Code     []byte `ch:"type:String"`
+	CodeHash []byte `ch:"type:String" bun:"type:bytea,pk,notnull"`
+	Code     []byte `ch:"type:String" bun:"type:bytea,notnull"`
 }
 
+// AccountStateData stores account data once per data hash.
 type AccountStateData struct {
-	ch.CHModel `ch:"account_states_data" json:"-"`
+	ch.CHModel    `ch:"account_states_data" json:"-"`
+	bun.BaseModel `bun:"table:account_states_data" json:"-"`
 
-	DataHash []byte `ch:"type:String"`
-	Data     []byte `ch:"type:String"`
+	DataHash []byte `ch:"type:String" bun:"type:bytea,pk,notnull"`
+	Data     []byte `ch:"type:String" bun:"type:bytea,notnull"`
 }
 
 func (a *AccountState) BlockID() BlockID {
@@ -130,38 +135,14 @@ type LatestAccountState struct {
 	AccountState *AccountState `bun:"rel:has-one,join:address=address,join:last_tx_lt=last_tx_lt" json:"account"`
 }
 
-func SkipAddress(a addr.Address) bool {
-	switch a.Base64() {
-	case "EQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAM9c": // burn address
-		return true
-	case "Ef8AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAADAU": // system contract
-		return true
-	case "Ef8zMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzM0vF": // elector contract
-		return true
-	case "Ef80UXx731GHxVr0-LYf3DIViMerdo3uJLAG3ykQZFjXz2kW": // log tests contract
-		return true
-	case "Ef9VVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVbxn": // config contract
-		return true
-	case "EQAHI1vGuw7d4WG-CtfDrWqEPNtmUuKjKFEFeJmZaqqfWTvW": // BSC Bridge Collector
-		return true
-	case "EQCuzvIOXLjH2tv35gY4tzhIvXCqZWDuK9kUhFGXKLImgxT5": // ETH Bridge Collector
-		return true
-	case "EQA2u5Z5Fn59EUvTI-TIrX8PIGKQzNj3qLixdCPPujfJleXC",
-		"EQA2Pnxp0rMB9L6SU2z1VqfMIFIfutiTjQWFEXnwa_zPh0P3",
-		"EQDhIloDu1FWY9WFAgQDgw0RjuT5bLkf15Rmd5LCG3-0hyoe": // strange heavy testnet address
-		return true
-	case "EQAWBIxrfQDExJSfFmE5UL1r9drse0dQx_eaV8w9S77VK32F": // tongo emulator segmentation fault
-		return true
-	default:
-		return false
-	}
-}
-
 type AccountRepository interface {
 	AddAddressLabel(context.Context, *AddressLabel) error
 	GetAddressLabel(context.Context, addr.Address) (*AddressLabel, error)
 
+	// AddAccountStates inserts rows in the PostgreSQL transaction only, they are written to ClickHouse with OutboxBatch.
 	AddAccountStates(ctx context.Context, tx bun.Tx, states []*AccountState) error
+	// CopyAccountStates writes rows with COPY FROM STDIN on the connection with an open PostgreSQL transaction.
+	CopyAccountStates(ctx context.Context, conn bun.Conn, states []*AccountState) error
 	UpdateAccountStates(ctx context.Context, states []*AccountState) error
 
 	// MatchStatesByInterfaceDesc returns (address, last_tx_lt) pairs for suitable account states.
@@ -174,6 +155,23 @@ type AccountRepository interface {
 		afterTxLt uint64,
 		limit int) ([]*AccountStateID, error)
 
+	// CountStatesByInterfaceDesc returns the number of account states matched by MatchStatesByInterfaceDesc.
+	CountStatesByInterfaceDesc(ctx context.Context,
+		contractName abi.ContractName,
+		addresses []*addr.Address,
+		codeHash []byte,
+		getMethodHashes []int32) (int, error)
+
+	// MatchStatesByScope returns (address, last_tx_lt) pairs of account states selected by the targeted rescan scope.
+	MatchStatesByScope(ctx context.Context,
+		scope *RescanScope,
+		afterAddress *addr.Address,
+		afterTxLt uint64,
+		limit int) ([]*AccountStateID, error)
+
+	// CountStatesByScope returns the number of account states matched by MatchStatesByScope.
+	CountStatesByScope(ctx context.Context, scope *RescanScope) (int, error)
+
 	// GetAllAccountInterfaces returns transaction LT, on which contract interface was updated.
 	// It also considers, that contract can be both upgraded and downgraded.
 	GetAllAccountInterfaces(context.Context, addr.Address) (map[uint64][]abi.ContractName, error)
@@ -39,7 +39,7 @@ type AccountsRes struct {
 	Wallets      int         `json:"wallets,omitempty"`
 	TotalSupply  *bunbig.Int `json:"total_supply,omitempty"`
 	OwnedBalance []*struct {
-		WalletAddress *addr.Address `ch:"item_address,type:String" json:"wallet_address"`
+		WalletAddress *addr.Address `ch:"item_address,type:String" bun:"item_address" json:"wallet_address"`
 		OwnerAddress  *addr.Address `ch:"type:String" json:"owner_address"`
 		Balance       *bunbig.Int   `ch:"type:UInt256" json:"balance"`
 	} `json:"owned_balance,omitempty"`
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/tonindexer/anton/abi"
 	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
 type AddressStatusCount struct {
@@ -39,6 +41,14 @@ type Statistics struct {
 	MessageCount       int `json:"message_count"`
 	ParsedMessageCount int `json:"parsed_message_count"`
 
+	// data skipped by the indexer filter
+	FilteredTransactionCount int `json:"filtered_transaction_count"`
+	FilteredMessageCount     int `json:"filtered_message_count"`
+	FilteredAccountCount     int `json:"filtered_account_count"`
+
+	// addresses, which data is not fetched, emulated or parsed
+	SkippedAddresses []*core.SkippedAddress `json:"skipped_addresses"`
+
 	ContractInterfaceCount int `json:"contract_interface_count"`
 	ContractOperationCount int `json:"contract_operation_count"`
 
@@ -59,7 +69,7 @@ type accountCount struct {
 
 type addressCount struct {
 	Status     core.AccountStatus
-	Interfaces []abi.ContractName
+	Interfaces []abi.ContractName `bun:",array"`
 	Count      int
 }
 
@@ -83,55 +93,72 @@ func unmarshalInterfacesKey(raw string) (ret []abi.ContractName) {
 	return ret
 }
 
-func getBlockStatistics(ctx context.Context, ck *ch.DB, ret *Statistics) error {
-	err := ck.NewSelect().Model((*core.Block)(nil)).
+func getBlockStatistics[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, ret *Statistics) error {
+	err := newSelect().Model((*core.Block)(nil)).
 		ColumnExpr("count(seq_no) as masterchain_block_count").
-		ColumnExpr("min(seq_no) as first_masterchain_block").
-		ColumnExpr("max(seq_no) as last_masterchain_block").
+		ColumnExpr(d.Coalesce("min(seq_no)", "0")+" as first_masterchain_block").
+		ColumnExpr(d.Coalesce("max(seq_no)", "0")+" as last_masterchain_block").
 		Where("workchain = -1").
 		Scan(ctx, &ret.MasterBlockCount, &ret.FirstBlock, &ret.LastBlock)
 	if err != nil {
 		return errors.Wrap(err, "first and last masterchain blocks")
 	}
+
+	err = newSelect().Model((*core.Block)(nil)).
+		ColumnExpr(d.Sum("filtered_transactions_count")).
+		ColumnExpr(d.Sum("filtered_messages_count")).
+		ColumnExpr(d.Sum("filtered_accounts_count")).
+		Scan(ctx, &ret.FilteredTransactionCount, &ret.FilteredMessageCount, &ret.FilteredAccountCount)
+	if err != nil {
+		return errors.Wrap(err, "filtered data count")
+	}
+
 	return nil
 }
 
-func getAccountStatistics(ctx context.Context, ck *ch.DB, ret *Statistics) error {
+func getAccountStatistics[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, ret *Statistics) error {
 	var accounts []accountCount
 
-	err := ck.NewSelect().Model((*core.AccountState)(nil)).
-		ColumnExpr("length(types) > 0 as parsed").
+	err := newSelect().Model((*core.AccountState)(nil)).
+		ColumnExpr("CASE WHEN "+d.Length("types")+" > 0 THEN 1 ELSE 0 END as parsed").
 		ColumnExpr("count(address) as account_count").
 		Group("parsed").
 		Scan(ctx, &accounts)
 	if err != nil {
 		return errors.Wrap(err, "account state count")
 	}
-	for _, r := range accounts {
-		ret.AccountCount += r.AccountCount
-		if r.Parsed > 0 {
-			ret.ParsedAccountCount += r.AccountCount
-		}
-	}
+
+	latest := newSelect().
+		Model((*core.AccountState)(nil)).
+		ColumnExpr(d.LastValue("address", "last_tx_lt") + " as addr").
+		ColumnExpr(d.LastValue("status", "last_tx_lt") + " as last_status").
+		ColumnExpr(d.LastValue("types", "last_tx_lt") + " as last_types")
 
 	var addresses []addressCount
-	err = ck.NewSelect().
+	err = newSelect().
 		ColumnExpr("last_status as status").
 		ColumnExpr("last_types as interfaces").
 		ColumnExpr("count(addr) as count").
-		TableExpr("(?) as q",
-			ck.NewSelect().
-				Model((*core.AccountState)(nil)).
-				ColumnExpr("argMax(address, last_tx_lt) as addr").
-				ColumnExpr("argMax(status, last_tx_lt) as last_status").
-				ColumnExpr("argMax(types, last_tx_lt) as last_types").
-				Group("address")).
+		TableExpr("(?) as q", dialect.LatestBy(latest, d, "address", "last_tx_lt")).
 		Group("last_status", "last_types").
 		Scan(ctx, &addresses)
 	if err != nil {
 		return errors.Wrap(err, "address count")
 	}
 
+	addAccountStatistics(ret, accounts, addresses)
+
+	return nil
+}
+
+func addAccountStatistics(ret *Statistics, accounts []accountCount, addresses []addressCount) {
+	for _, r := range accounts {
+		ret.AccountCount += r.AccountCount
+		if r.Parsed > 0 {
+			ret.ParsedAccountCount += r.AccountCount
+		}
+	}
+
 	accountStatuses := map[core.AccountStatus]int{}
 	accountTypes := map[string]int{}
 	for _, r := range addresses {
@@ -162,14 +189,14 @@ func getAccountStatistics(ctx context.Context, ck *ch.DB, ret *Statistics) error
 		}
 		ret.ParsedAddressCount += row.Count
 	}
-
-	return nil
 }
 
-func getTransactionStatistics(ctx context.Context, ck *ch.DB, ret *Statistics) error {
-	err := ck.NewSelect().Model((*core.Message)(nil)).
-		ColumnExpr("operation_name as operation").
-		ColumnExpr("count() as count").
+func getTransactionStatistics[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, ret *Statistics) error {
+	var err error
+
+	err = newSelect().Model((*core.Message)(nil)).
+		ColumnExpr(d.Coalesce("operation_name", "''")+" as operation").
+		ColumnExpr(d.Count()+" as count").
 		Group("operation_name").
 		Order("count DESC").
 		Scan(ctx, &ret.MessageTypesCount)
@@ -192,7 +219,7 @@ func getTransactionStatistics(ctx context.Context, ck *ch.DB, ret *Statistics) e
 		ret.MessageTypesCount = append(ret.MessageTypesCount[:unknownOp], ret.MessageTypesCount[unknownOp+1:]...)
 	}
 
-	ret.TransactionCount, err = ck.NewSelect().Model((*core.Transaction)(nil)).Count(ctx)
+	ret.TransactionCount, err = newSelect().Model((*core.Transaction)(nil)).Count(ctx)
 	if err != nil {
 		return errors.Wrap(err, "transaction count")
 	}
@@ -200,22 +227,44 @@ func getTransactionStatistics(ctx context.Context, ck *ch.DB, ret *Statistics) e
 	return nil
 }
 
+func getStatistics[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, ret *Statistics) error {
+	if err := getBlockStatistics(ctx, newSelect, d, ret); err != nil {
+		return err
+	}
+
+	if err := getAccountStatistics(ctx, newSelect, d, ret); err != nil {
+		return err
+	}
+
+	if err := getTransactionStatistics(ctx, newSelect, d, ret); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetStatistics aggregates the indexed data on ClickHouse or, if it is nil, on PostgreSQL.
 func GetStatistics(ctx context.Context, ck *ch.DB, pg *bun.DB) (*Statistics, error) {
 	var (
 		ret Statistics
 		err error
 	)
 
-	if err := getBlockStatistics(ctx, ck, &ret); err != nil {
-		return nil, err
+	if ck == nil {
+		err = getStatistics(ctx, pg.NewSelect, dialect.PG, &ret)
+	} else {
+		err = getStatistics(ctx, ck.NewSelect, dialect.CH, &ret)
 	}
-
-	if err := getAccountStatistics(ctx, ck, &ret); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	if err := getTransactionStatistics(ctx, ck, &ret); err != nil {
-		return nil, err
+	err = pg.NewSelect().Model(&ret.SkippedAddresses).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at").
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "skipped addresses")
 	}
 
 	ret.ContractInterfaceCount, err = pg.NewSelect().Model((*core.ContractInterface)(nil)).Count(ctx)
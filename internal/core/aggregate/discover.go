@@ -0,0 +1,226 @@
+package aggregate
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/go-clickhouse/ch"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
+)
+
+// commonGetMethods are resolved in addition to get-methods of stored contract interfaces
+var commonGetMethods = []string{
+	"seqno", "get_public_key", "get_subwallet_id", "get_plugin_list", "is_plugin_installed",
+	"get_wallet_data", "get_jetton_data", "get_wallet_address",
+	"get_nft_data", "get_collection_data", "get_nft_address_by_index", "get_nft_content", "royalty_params",
+	"get_sale_data", "get_offer_data", "get_static_data", "get_authority_address", "get_revoked_time",
+	"get_editor", "dnsresolve", "get_domain", "get_full_domain",
+	"get_owner", "get_balance", "get_version", "get_config", "get_pool_data", "get_router_data",
+	"get_lp_account_address", "get_expected_outputs", "get_extensions", "get_is_signature_auth_allowed",
+	"get_multisig_data", "get_order_data", "get_vesting_data", "get_locker_data",
+}
+
+type DiscoverContractsReq struct {
+	// transactions and messages are counted since this time, the last week by default
+	Since time.Time `form:"since"`
+
+	MinAccounts     int `form:"min_accounts"`
+	OperationsLimit int `form:"operations_limit"`
+	Limit           int `form:"limit"`
+}
+
+type DiscoveredGetMethod struct {
+	Hash int32  `json:"hash"`
+	Name string `json:"name,omitempty"` // empty if the name is not known
+}
+
+type DiscoveredOperation struct {
+	OperationID uint32 `json:"operation_id"`
+	Count       int    `json:"count"`
+	SampleBody  []byte `json:"sample_body,omitempty"`
+}
+
+// UnknownContract is a cluster of accounts with the same code, which do not match any contract interface.
+type UnknownContract struct {
+	CodeHash []byte `json:"code_hash"`
+
+	AccountCount     int       `json:"account_count"`
+	TransactionCount int       `ch:"-" json:"transaction_count"`
+	FirstSeen        time.Time `json:"first_seen"`
+	LastSeen         time.Time `json:"last_seen"`
+
+	GetMethodHashes []int32               `ch:"type:Array(UInt32)" bun:",array" json:"-"`
+	GetMethods      []DiscoveredGetMethod `ch:"-" json:"get_methods"`
+
+	InOperations  []*DiscoveredOperation `ch:"-" json:"in_operations"`
+	OutOperations []*DiscoveredOperation `ch:"-" json:"out_operations"`
+}
+
+type DiscoverContractsRes struct {
+	Since   time.Time          `json:"since"`
+	Results []*UnknownContract `json:"results"`
+}
+
+func getMethodNames(ctx context.Context, pg *bun.DB) (map[int32]string, error) {
+	var interfaces []*core.ContractInterface
+
+	ret := map[int32]string{}
+	for _, name := range commonGetMethods {
+		ret[abi.MethodNameHash(name)] = name
+	}
+
+	err := pg.NewSelect().Model(&interfaces).Column("get_methods_desc").Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get contract interfaces")
+	}
+	for _, i := range interfaces {
+		for it := range i.GetMethodsDesc {
+			ret[abi.MethodNameHash(i.GetMethodsDesc[it].Name)] = i.GetMethodsDesc[it].Name
+		}
+	}
+
+	return ret, nil
+}
+
+func discoverClusters[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, req *DiscoverContractsReq) (ret []*UnknownContract, err error) {
+	states := newSelect().
+		Model((*core.AccountState)(nil)).
+		ColumnExpr(d.LastValue("code_hash", "last_tx_lt") + " AS last_code_hash").
+		ColumnExpr(d.LastValue("types", "last_tx_lt") + " AS last_types").
+		ColumnExpr(d.LastValue("get_method_hashes", "last_tx_lt") + " AS last_get_method_hashes").
+		ColumnExpr(d.OverKey("min(updated_at)", "address") + " AS first_seen").
+		ColumnExpr(d.OverKey("max(updated_at)", "address") + " AS last_seen")
+
+	err = newSelect().
+		ColumnExpr("last_code_hash AS code_hash").
+		ColumnExpr(d.Count()+" AS account_count").
+		ColumnExpr("min(first_seen) AS first_seen").
+		ColumnExpr("max(last_seen) AS last_seen").
+		ColumnExpr(d.Any("last_get_method_hashes")+" AS get_method_hashes").
+		TableExpr("(?) AS q", dialect.LatestBy(states, d, "address", "last_tx_lt")).
+		Where(d.Length("last_types")+" = 0").
+		Where("length(last_code_hash) > 0").
+		Group("last_code_hash").
+		Having(d.Count()+" >= ?", req.MinAccounts).
+		Order("account_count DESC").
+		Limit(req.Limit).
+		Scan(ctx, &ret)
+	if err != nil {
+		return nil, errors.Wrap(err, "account states by code hash")
+	}
+	return ret, nil
+}
+
+func discoverOperations[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, addresses Q, addressColumn string, req *DiscoverContractsReq) (ret []*DiscoveredOperation, err error) {
+	err = newSelect().
+		Model((*core.Message)(nil)).
+		ColumnExpr("operation_id").
+		ColumnExpr(d.Count()+" AS count").
+		ColumnExpr(d.Any("body")+" AS sample_body").
+		Where(addressColumn+" IN (?)", addresses).
+		Where("created_at >= ?", req.Since).
+		Group("operation_id").
+		Order("count DESC").
+		Limit(req.OperationsLimit).
+		Scan(ctx, &ret)
+	if err != nil {
+		return nil, errors.Wrapf(err, "count messages by %s", addressColumn)
+	}
+	return ret, nil
+}
+
+func discoverActivity[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, c *UnknownContract, req *DiscoverContractsReq) (err error) {
+	// all addresses, which have ever had the code
+	addresses := newSelect().
+		Model((*core.AccountState)(nil)).
+		ColumnExpr("DISTINCT address").
+		Where("code_hash = ?", c.CodeHash)
+
+	c.TransactionCount, err = newSelect().
+		Model((*core.Transaction)(nil)).
+		Where("address IN (?)", addresses).
+		Where("created_at >= ?", req.Since).
+		Count(ctx)
+	if err != nil {
+		return errors.Wrap(err, "count transactions")
+	}
+
+	c.InOperations, err = discoverOperations(ctx, newSelect, d, addresses, "dst_address", req)
+	if err != nil {
+		return err
+	}
+	c.OutOperations, err = discoverOperations(ctx, newSelect, d, addresses, "src_address", req)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func discover[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, req *DiscoverContractsReq) ([]*UnknownContract, error) {
+	clusters, err := discoverClusters(ctx, newSelect, d, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range clusters {
+		if err := discoverActivity(ctx, newSelect, d, c, req); err != nil {
+			return nil, errors.Wrapf(err, "code hash %x", c.CodeHash)
+		}
+	}
+	return clusters, nil
+}
+
+// DiscoverContracts clusters accounts without matched contract interfaces by code hash
+// and reports their activity, get-methods and the most frequent operations
+// to prioritize writing of new contract interfaces.
+// Without ClickHouse, the data is aggregated on PostgreSQL.
+func DiscoverContracts(ctx context.Context, ck *ch.DB, pg *bun.DB, req *DiscoverContractsReq) (*DiscoverContractsRes, error) {
+	if req.Since.IsZero() {
+		req.Since = time.Now().Add(-7 * 24 * time.Hour)
+	}
+	if req.MinAccounts < 1 {
+		req.MinAccounts = 1
+	}
+	if req.OperationsLimit < 1 || req.OperationsLimit > 100 {
+		req.OperationsLimit = 5
+	}
+	if req.Limit < 1 || req.Limit > 100 {
+		req.Limit = 10
+	}
+
+	names, err := getMethodNames(ctx, pg)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusters []*UnknownContract
+	if ck == nil {
+		clusters, err = discover(ctx, pg.NewSelect, dialect.PG, req)
+	} else {
+		clusters, err = discover(ctx, ck.NewSelect, dialect.CH, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range clusters {
+		c.GetMethods = []DiscoveredGetMethod{}
+		for _, h := range c.GetMethodHashes {
+			c.GetMethods = append(c.GetMethods, DiscoveredGetMethod{Hash: h, Name: names[h]})
+		}
+		sort.Slice(c.GetMethods, func(i, j int) bool {
+			if (c.GetMethods[i].Name == "") != (c.GetMethods[j].Name == "") {
+				return c.GetMethods[i].Name != ""
+			}
+			return c.GetMethods[i].Name < c.GetMethods[j].Name
+		})
+	}
+
+	return &DiscoverContractsRes{Since: req.Since, Results: clusters}, nil
+}
@@ -26,28 +26,43 @@ type BigIntRes []struct {
 	Timestamp time.Time
 }
 
-func GetRoundingFunction(interval time.Duration) (string, error) {
-	funcFormat := "toStartOfInterval(%s, INTERVAL %d %s)"
-
+func roundingInterval(interval time.Duration) (int, string, error) {
 	sec := int(interval.Seconds())
 
 	min := sec / 60
 	if min < 5 {
-		return "", errors.Wrapf(core.ErrInvalidArg, "unsupported interval %d seconds", sec)
+		return 0, "", errors.Wrapf(core.ErrInvalidArg, "unsupported interval %d seconds", sec)
 	}
 	if min < 60 {
-		return fmt.Sprintf(funcFormat, "%s", min, "minute"), nil
+		return min, "minute", nil
 	}
 
 	hour := min / 60
 	if hour < 24 {
-		return fmt.Sprintf(funcFormat, "%s", hour, "hour"), nil
+		return hour, "hour", nil
 	}
 
 	days := hour / 24
 	if days < 7 {
-		return fmt.Sprintf(funcFormat, "%s", days, "day"), nil
+		return days, "day", nil
 	}
 
-	return "", errors.Wrapf(core.ErrInvalidArg, "unsupported interval %d hours", days)
+	return 0, "", errors.Wrapf(core.ErrInvalidArg, "unsupported interval %d hours", days)
+}
+
+func GetRoundingFunction(interval time.Duration) (string, error) {
+	n, unit, err := roundingInterval(interval)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("toStartOfInterval(%%s, INTERVAL %d %s)", n, unit), nil
+}
+
+// GetPGRoundingFunction is the PostgreSQL version of GetRoundingFunction.
+func GetPGRoundingFunction(interval time.Duration) (string, error) {
+	n, unit, err := roundingInterval(interval)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("date_bin(INTERVAL '%d %s', %%s, TIMESTAMP '1970-01-01')", n, unit), nil
 }
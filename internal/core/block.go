@@ -41,6 +41,11 @@ type Block struct {
 	Transactions      []*Transaction  `ch:"-" bun:"rel:has-many,join:workchain=workchain,join:shard=shard,join:seq_no=block_seq_no" json:"transactions,omitempty"`
 	Accounts          []*AccountState `ch:"-" bun:"rel:has-many,join:workchain=workchain,join:shard=shard,join:seq_no=block_seq_no" json:"accounts,omitempty"`
 
+	// data skipped by the indexer filter
+	FilteredTransactionsCount int `bun:"type:integer,notnull,default:0" json:"filtered_transactions_count,omitempty"`
+	FilteredMessagesCount     int `bun:"type:integer,notnull,default:0" json:"filtered_messages_count,omitempty"`
+	FilteredAccountsCount     int `bun:"type:integer,notnull,default:0" json:"filtered_accounts_count,omitempty"`
+
 	// TODO: block info data
 
 	ScannedAt time.Time `bun:"type:timestamp without time zone,notnull" json:"scanned_at"`
@@ -55,7 +60,14 @@ func (b *Block) ID() BlockID {
 }
 
 type BlockRepository interface {
+	// AddBlocks inserts rows in the PostgreSQL transaction only, they are written to ClickHouse with OutboxBatch.
 	AddBlocks(ctx context.Context, tx bun.Tx, info []*Block) error
+	// CopyBlocks writes rows with COPY FROM STDIN on the connection with an open PostgreSQL transaction.
+	CopyBlocks(ctx context.Context, conn bun.Conn, info []*Block) error
 	GetLastMasterBlock(ctx context.Context) (*Block, error)
 	CountMasterBlocks(ctx context.Context) (int, error)
+
+	// GetShardBlockRanges returns ranges of masterchain and shard blocks
+	// committed in the given masterchain blocks range.
+	GetShardBlockRanges(ctx context.Context, fromMasterSeqNo, toMasterSeqNo uint32) ([]*BlockRange, error)
 }
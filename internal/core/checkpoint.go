@@ -0,0 +1,24 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// VerifyCheckpoint is the last masterchain block, which rows are verified to be consistent
+// between PostgreSQL and ClickHouse.
+type VerifyCheckpoint struct {
+	bun.BaseModel `bun:"table:clickhouse_verify_checkpoints" json:"-"`
+
+	Name        string    `bun:"type:text,pk,notnull" json:"name"`
+	MasterSeqNo uint32    `bun:"type:integer,notnull" json:"master_seq_no"`
+	UpdatedAt   time.Time `bun:"type:timestamp without time zone,notnull" json:"updated_at"`
+}
+
+type VerifyCheckpointRepository interface {
+	// GetVerifyCheckpoint returns the last verified masterchain block seq_no.
+	GetVerifyCheckpoint(ctx context.Context, name string) (uint32, error)
+	SetVerifyCheckpoint(ctx context.Context, name string, masterSeqNo uint32) error
+}
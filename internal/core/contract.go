@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"time"
 
 	"github.com/uptrace/bun"
 
@@ -24,6 +25,7 @@ type ContractInterface struct {
 	Code            []byte               `bun:"type:bytea,unique" json:"code,omitempty"`
 	GetMethodsDesc  []abi.GetMethodDesc  `bun:"type:text" json:"get_methods_descriptors,omitempty"`
 	GetMethodHashes []int32              `bun:"type:integer[]" json:"get_method_hashes,omitempty"`
+	Version         uint32               `bun:",notnull,default:0" json:"version,omitempty"` // last revision of the description
 	Operations      []*ContractOperation `ch:"-" bun:"rel:has-many,join:name=contract_name" json:"operations,omitempty"`
 }
 
@@ -36,24 +38,62 @@ type ContractOperation struct {
 	Outgoing      bool              `bun:",pk" json:"outgoing"`                           // if operation is going from contract
 	OperationID   uint32            `bun:",pk" json:"operation_id"`
 	Schema        abi.OperationDesc `bun:"type:jsonb" json:"schema"`
+	Version       uint32            `bun:",notnull,default:0" json:"version,omitempty"` // contract interface revision
+}
+
+type ContractRevisionAction string
+
+const (
+	RevisionAdd      ContractRevisionAction = "add"
+	RevisionUpdate   ContractRevisionAction = "update"
+	RevisionDelete   ContractRevisionAction = "delete"
+	RevisionRollback ContractRevisionAction = "rollback"
+)
+
+// ContractRevision is a snapshot of the contract interface with its operations
+// taken after every change of the description.
+type ContractRevision struct {
+	bun.BaseModel `bun:"table:contract_revisions" json:"-"`
+
+	ContractName abi.ContractName       `bun:",pk" json:"contract_name"`
+	Version      uint32                 `bun:",pk" json:"version"`
+	Action       ContractRevisionAction `bun:"type:contract_revision_action,notnull" json:"action"`
+	Author       string                 `bun:",nullzero" json:"author,omitempty"`
+	Comment      string                 `bun:",nullzero" json:"comment,omitempty"`
+	Diff         []string               `bun:"type:text[],array" json:"diff,omitempty"`
+
+	// Interface is nil for the deleted contract interface.
+	Interface *ContractInterface `bun:"type:jsonb" json:"interface,omitempty"`
+	// Definitions are the definitions, which operations and get-methods of the interface are parsed with.
+	Definitions map[abi.TLBType]abi.TLBFieldsDesc `bun:"type:jsonb" json:"definitions,omitempty"`
+
+	CreatedAt time.Time `bun:"type:timestamp without time zone,notnull" json:"created_at"`
 }
 
 type ContractRepository interface {
-	AddDefinition(context.Context, abi.TLBType, abi.TLBFieldsDesc) error
-	UpdateDefinition(ctx context.Context, dn abi.TLBType, d abi.TLBFieldsDesc) error
-	DeleteDefinition(ctx context.Context, dn abi.TLBType) error
+	// Registry changes are written with the given database or transaction,
+	// so that a change is committed together with its revision.
+	AddDefinition(ctx context.Context, db bun.IDB, dn abi.TLBType, d abi.TLBFieldsDesc) error
+	UpdateDefinition(ctx context.Context, db bun.IDB, dn abi.TLBType, d abi.TLBFieldsDesc) error
+	DeleteDefinition(ctx context.Context, db bun.IDB, dn abi.TLBType) error
 	GetDefinitions(context.Context) (map[abi.TLBType]abi.TLBFieldsDesc, error)
 
-	AddInterface(context.Context, *ContractInterface) error
-	UpdateInterface(ctx context.Context, i *ContractInterface) error
-	DeleteInterface(ctx context.Context, name abi.ContractName) error
+	AddInterface(ctx context.Context, db bun.IDB, i *ContractInterface) error
+	UpdateInterface(ctx context.Context, db bun.IDB, i *ContractInterface) error
+	DeleteInterface(ctx context.Context, db bun.IDB, name abi.ContractName) error
 	GetInterface(ctx context.Context, name abi.ContractName) (*ContractInterface, error)
 	GetInterfaces(context.Context) ([]*ContractInterface, error)
 	GetMethodDescription(ctx context.Context, name abi.ContractName, method string) (abi.GetMethodDesc, error)
 
-	AddOperation(context.Context, *ContractOperation) error
-	UpdateOperation(ctx context.Context, op *ContractOperation) error
-	DeleteOperation(ctx context.Context, opName string) error
+	AddOperation(ctx context.Context, db bun.IDB, op *ContractOperation) error
+	UpdateOperation(ctx context.Context, db bun.IDB, op *ContractOperation) error
+	DeleteOperation(ctx context.Context, db bun.IDB, opName string) error
 	GetOperations(context.Context) ([]*ContractOperation, error)
 	GetOperationsByID(ctx context.Context, t MessageType, interfaces []abi.ContractName, outgoing bool, id uint32) ([]*ContractOperation, error)
+
+	// AddRevision takes a snapshot of the stored contract interface,
+	// assigns the next version to the revision and marks the interface with it.
+	AddRevision(ctx context.Context, db bun.IDB, rev *ContractRevision) error
+	GetRevisions(ctx context.Context, name abi.ContractName) ([]*ContractRevision, error)
+	GetRevision(ctx context.Context, name abi.ContractName, version uint32) (*ContractRevision, error)
 }
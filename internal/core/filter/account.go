@@ -50,6 +50,9 @@ type AccountsReq struct {
 type AccountsRes struct {
 	Total int                  `json:"total"`
 	Rows  []*core.AccountState `json:"results"`
+
+	// Pruned lists retention rules, which removed a part of the returned history.
+	Pruned []*core.Pruning `json:"pruned,omitempty"`
 }
 
 type AccountRepository interface {
@@ -33,6 +33,9 @@ type MessagesReq struct {
 type MessagesRes struct {
 	Total int             `json:"total"`
 	Rows  []*core.Message `json:"results"`
+
+	// Pruned lists retention rules, which removed a part of the returned history.
+	Pruned []*core.Pruning `json:"pruned,omitempty"`
 }
 
 type MessageRepository interface {
@@ -33,6 +33,9 @@ type TransactionsReq struct {
 type TransactionsRes struct {
 	Total int                 `json:"total"`
 	Rows  []*core.Transaction `json:"results"`
+
+	// Pruned lists retention rules, which removed a part of the returned history.
+	Pruned []*core.Pruning `json:"pruned,omitempty"`
 }
 
 type TransactionRepository interface {
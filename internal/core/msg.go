@@ -72,13 +72,18 @@ type Message struct {
 	OperationName string          `ch:",lc" bun:",nullzero" json:"operation_name,omitempty"`
 	DataJSON      json.RawMessage `ch:"type:String" bun:"type:jsonb" json:"data,omitempty"`
 	Error         string          `json:"error,omitempty"`
+	SchemaVersion uint32          `bun:",nullzero" json:"schema_version,omitempty"` // revision of the contract interface used for parsing
 
 	CreatedAt time.Time `bun:"type:timestamp without time zone,notnull" json:"created_at"`
 	CreatedLT uint64    `bun:",notnull" json:"created_lt"`
 }
 
 type MessageRepository interface {
+	// AddMessages inserts rows in the PostgreSQL transaction only, they are written to ClickHouse with OutboxBatch.
 	AddMessages(ctx context.Context, tx bun.Tx, messages []*Message) error
+	// CopyMessages copies rows to a temporary table on the connection with an open PostgreSQL transaction,
+	// and upserts them to messages with a single statement.
+	CopyMessages(ctx context.Context, conn bun.Conn, messages []*Message) error
 	UpdateMessages(ctx context.Context, messages []*Message) error
 
 	GetMessage(ctx context.Context, hash []byte) (*Message, error)
@@ -93,4 +98,21 @@ type MessageRepository interface {
 		afterAddress *addr.Address,
 		afterTxLT uint64,
 		limit int) ([][]byte, error)
+
+	// CountMessagesByOperationDesc returns the number of messages matched by MatchMessagesByOperationDesc.
+	CountMessagesByOperationDesc(ctx context.Context,
+		contractName abi.ContractName,
+		msgType MessageType,
+		outgoing bool,
+		operationId uint32) (int, error)
+
+	// MatchMessagesByScope returns hashes of messages selected by the targeted rescan scope,
+	// messages are ordered by hash.
+	MatchMessagesByScope(ctx context.Context,
+		scope *RescanScope,
+		afterHash []byte,
+		limit int) ([][]byte, error)
+
+	// CountMessagesByScope returns the number of messages matched by MatchMessagesByScope.
+	CountMessagesByScope(ctx context.Context, scope *RescanScope) (int, error)
 }
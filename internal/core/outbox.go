@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// OutboxBatch is a batch of rows saved to PostgreSQL with a masterchain block.
+// Its key is committed in the same PostgreSQL transaction as the rows,
+// and it is removed after the rows are written to ClickHouse.
+type OutboxBatch struct {
+	bun.BaseModel `bun:"table:clickhouse_outbox" json:"-"`
+
+	Workchain int32  `bun:"type:integer,pk,notnull" json:"workchain"`
+	Shard     int64  `bun:"type:bigint,pk,notnull" json:"shard"`
+	SeqNo     uint32 `bun:"type:integer,pk,notnull" json:"seq_no"`
+
+	Blocks       []*Block        `bun:"-" json:"-"`
+	Transactions []*Transaction  `bun:"-" json:"-"`
+	Messages     []*Message      `bun:"-" json:"-"`
+	Accounts     []*AccountState `bun:"-" json:"-"`
+
+	CreatedAt time.Time `bun:"type:timestamp without time zone,notnull" json:"created_at"`
+}
+
+func (b *OutboxBatch) MasterID() BlockID {
+	return BlockID{
+		Workchain: b.Workchain,
+		Shard:     b.Shard,
+		SeqNo:     b.SeqNo,
+	}
+}
+
+type OutboxRepository interface {
+	// AddBatch saves the batch key in the PostgreSQL transaction, which inserts the batch rows.
+	AddBatch(ctx context.Context, tx bun.Tx, b *OutboxBatch) error
+
+	// FlushBatch writes the batch rows to ClickHouse and removes the batch from the outbox.
+	FlushBatch(ctx context.Context, b *OutboxBatch) error
+
+	// GetPendingBatches loads rows of not flushed batches from PostgreSQL,
+	// skipping rows which are already written to ClickHouse.
+	GetPendingBatches(ctx context.Context) ([]*OutboxBatch, error)
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/addr"
+)
+
+type RetentionRule string
+
+const (
+	// PruneAccountStates keeps only the latest states of accounts without parsed interfaces.
+	PruneAccountStates RetentionRule = "account_states"
+
+	// PruneCodeData removes code and data of superseded account states.
+	PruneCodeData RetentionRule = "code_data"
+
+	// PruneMessageBodies removes raw bodies of messages with unknown operations.
+	PruneMessageBodies RetentionRule = "message_bodies"
+
+	// PruneTransactionsTTL and PruneMessagesTTL remove old rows from ClickHouse tables.
+	PruneTransactionsTTL RetentionRule = "transactions_ttl"
+	PruneMessagesTTL     RetentionRule = "messages_ttl"
+)
+
+// Pruning is the last application of a retention rule.
+// History older than Before can be pruned by the rule.
+type Pruning struct {
+	bun.BaseModel `bun:"table:prunings" json:"-"`
+
+	Rule       RetentionRule `bun:"type:text,pk,notnull" json:"rule"`
+	KeepLatest int           `bun:",nullzero" json:"keep_latest,omitempty"` // for account states
+	TTLDays    int           `bun:",nullzero" json:"ttl_days,omitempty"`    // for ClickHouse TTL, Before moves with time
+	Before     time.Time     `bun:"type:timestamp without time zone,notnull" json:"before"`
+	PrunedAt   time.Time     `bun:"type:timestamp without time zone,notnull" json:"pruned_at"`
+}
+
+type PruneRepository interface {
+	// PruneAccountStates removes states of accounts without interfaces, except the latest keepLatest states.
+	PruneAccountStates(ctx context.Context, keepLatest int) error
+
+	// PruneCodeData removes code and data, which are referenced only by superseded states updated before the given time.
+	PruneCodeData(ctx context.Context, before time.Time) error
+
+	// PruneMessageBodies removes bodies of messages without parsed operation, which were created before the given time.
+	PruneMessageBodies(ctx context.Context, before time.Time) error
+
+	// SetTTL sets the ClickHouse table TTL in days, zero removes it.
+	SetTTL(ctx context.Context, table string, days int) error
+
+	GetPrunings(ctx context.Context) ([]*Pruning, error)
+
+	// GetPrunedAddresses returns the given addresses, which states could be removed by account states pruning.
+	GetPrunedAddresses(ctx context.Context, p *Pruning, addresses []*addr.Address) ([]*addr.Address, error)
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/tonindexer/anton/addr"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
 var _ repository.Account = (*Repository)(nil)
@@ -93,6 +94,28 @@ func createIndexes(ctx context.Context, pgDB *bun.DB) error {
 		return errors.Wrap(err, "account state last_tx_lt pg create index")
 	}
 
+	// code and data pruning looks up states by blob hashes
+
+	_, err = pgDB.NewCreateIndex().
+		Model(&core.AccountState{}).
+		Using("HASH").
+		Column("code_hash").
+		Where("code_hash IS NOT NULL").
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "account state code_hash pg create index")
+	}
+
+	_, err = pgDB.NewCreateIndex().
+		Model(&core.AccountState{}).
+		Using("HASH").
+		Column("data_hash").
+		Where("data_hash IS NOT NULL").
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "account state data_hash pg create index")
+	}
+
 	// latest account state
 
 	_, err = pgDB.NewCreateIndex().
@@ -107,20 +130,8 @@ func createIndexes(ctx context.Context, pgDB *bun.DB) error {
 	return nil
 }
 
-func CreateTables(ctx context.Context, chDB *ch.DB, pgDB *bun.DB) error {
-	_, err := pgDB.ExecContext(ctx, "CREATE TYPE account_status AS ENUM (?, ?, ?, ?)",
-		core.Uninit, core.Active, core.Frozen, core.NonExist)
-	if err != nil && !strings.Contains(err.Error(), "already exists") {
-		return errors.Wrap(err, "account status pg create enum")
-	}
-
-	_, err = pgDB.ExecContext(ctx, "CREATE TYPE label_category AS ENUM (?, ?)",
-		core.CentralizedExchange, core.Scam)
-	if err != nil && !strings.Contains(err.Error(), "already exists") {
-		return errors.Wrap(err, "address label category pg create enum")
-	}
-
-	_, err = chDB.NewCreateTable().
+func createTablesCH(ctx context.Context, chDB *ch.DB) error {
+	_, err := chDB.NewCreateTable().
 		IfNotExists().
 		Engine("ReplacingMergeTree").
 		Model(&core.AddressLabel{}).
@@ -129,15 +140,6 @@ func CreateTables(ctx context.Context, chDB *ch.DB, pgDB *bun.DB) error {
 		return errors.Wrap(err, "address label ch create table")
 	}
 
-	_, err = pgDB.NewCreateTable().
-		Model(&core.AddressLabel{}).
-		IfNotExists().
-		WithForeignKeys().
-		Exec(ctx)
-	if err != nil {
-		return errors.Wrap(err, "address label pg create table")
-	}
-
 	_, err = chDB.NewCreateTable().
 		IfNotExists().
 		Engine("EmbeddedRocksDB PRIMARY KEY code_hash").
@@ -164,6 +166,52 @@ func CreateTables(ctx context.Context, chDB *ch.DB, pgDB *bun.DB) error {
 		return errors.Wrap(err, "account state ch create table")
 	}
 
+	return nil
+}
+
+func CreateTables(ctx context.Context, chDB *ch.DB, pgDB *bun.DB) error {
+	if chDB != nil {
+		if err := createTablesCH(ctx, chDB); err != nil {
+			return err
+		}
+	}
+
+	_, err := pgDB.ExecContext(ctx, "CREATE TYPE account_status AS ENUM (?, ?, ?, ?)",
+		core.Uninit, core.Active, core.Frozen, core.NonExist)
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return errors.Wrap(err, "account status pg create enum")
+	}
+
+	_, err = pgDB.ExecContext(ctx, "CREATE TYPE label_category AS ENUM (?, ?)",
+		core.CentralizedExchange, core.Scam)
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return errors.Wrap(err, "address label category pg create enum")
+	}
+
+	_, err = pgDB.NewCreateTable().
+		Model(&core.AddressLabel{}).
+		IfNotExists().
+		WithForeignKeys().
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "address label pg create table")
+	}
+
+	_, err = pgDB.NewCreateTable().
+		Model(&core.AccountStateCode{}).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "account state code pg create table")
+	}
+	_, err = pgDB.NewCreateTable().
+		Model(&core.AccountStateData{}).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "account state data pg create table")
+	}
+
 	_, err = pgDB.NewCreateTable().
 		Model(&core.AccountState{}).
 		IfNotExists().
@@ -193,6 +241,9 @@ func (r *Repository) AddAddressLabel(ctx context.Context, label *core.AddressLab
 		}
 		return errors.Wrap(err, "pg insert label")
 	}
+	if r.ch == nil {
+		return nil
+	}
 	_, err = r.ch.NewInsert().Model(label).Exec(ctx)
 	if err != nil {
 		return errors.Wrap(err, "ch insert label")
@@ -219,59 +270,106 @@ func (r *Repository) AddAccountStates(ctx context.Context, tx bun.Tx, accounts [
 		return nil
 	}
 
+	sortExecutedGetMethods(accounts)
+
+	if err := r.addCodeData(ctx, tx, accounts); err != nil {
+		return err
+	}
+
+	_, err := tx.NewInsert().Model(&accounts).Exec(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "cannot insert new account states")
+	}
+
+	return r.setLatestStates(ctx, tx, accounts)
+}
+
+func (r *Repository) CopyAccountStates(ctx context.Context, conn bun.Conn, accounts []*core.AccountState) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	sortExecutedGetMethods(accounts)
+
+	if err := r.addCodeData(ctx, &conn, accounts); err != nil {
+		return err
+	}
+
+	if err := repository.CopyFrom(ctx, conn, accounts); err != nil {
+		return errors.Wrap(err, "cannot copy new account states")
+	}
+
+	return r.setLatestStates(ctx, &conn, accounts)
+}
+
+func sortExecutedGetMethods(accounts []*core.AccountState) {
 	for _, a := range accounts {
 		for _, executions := range a.ExecutedGetMethods {
 			sort.Slice(executions, func(i, j int) bool { return executions[i].Name < executions[j].Name })
 		}
 	}
+}
 
-	var (
-		codeKV []*core.AccountStateCode
-		dataKV []*core.AccountStateData
-	)
+// setLatestStates upserts the latest states of all given addresses with a single statement.
+func (r *Repository) setLatestStates(ctx context.Context, db bun.IDB, accounts []*core.AccountState) error {
+	addrTxLT := make(map[addr.Address]uint64)
 	for _, a := range accounts {
-		codeKV = append(codeKV, &core.AccountStateCode{CodeHash: a.CodeHash, Code: a.Code})
-		dataKV = append(dataKV, &core.AccountStateData{DataHash: a.DataHash, Data: a.Data})
-		a.Code, a.Data = nil, nil
+		if addrTxLT[a.Address] < a.LastTxLT {
+			addrTxLT[a.Address] = a.LastTxLT
+		}
 	}
 
-	if _, err := r.ch.NewInsert().Model(&codeKV).Exec(ctx); err != nil {
-		return errors.Wrapf(err, "write code to key-value store")
-	}
-	if _, err := r.ch.NewInsert().Model(&dataKV).Exec(ctx); err != nil {
-		return errors.Wrapf(err, "write data to key-value store")
+	latest := make([]*core.LatestAccountState, 0, len(addrTxLT))
+	for a, lt := range addrTxLT {
+		latest = append(latest, &core.LatestAccountState{Address: a, LastTxLT: lt})
 	}
+	// rows are locked in the same order by concurrent transactions
+	sort.Slice(latest, func(i, j int) bool { return bytes.Compare(latest[i].Address[:], latest[j].Address[:]) < 0 })
 
-	_, err := tx.NewInsert().Model(&accounts).Exec(ctx)
+	_, err := db.NewInsert().
+		Model(&latest).
+		On("CONFLICT (address) DO UPDATE").
+		Where("latest_account_state.last_tx_lt < EXCLUDED.last_tx_lt").
+		Set("last_tx_lt = EXCLUDED.last_tx_lt").
+		Exec(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "cannot insert new account states")
+		return errors.Wrap(err, "cannot set latest states")
 	}
 
-	addrTxLT := make(map[addr.Address]uint64)
+	return nil
+}
+
+// addCodeData writes code and data of account states once per hash.
+// They are written to ClickHouse key-value storage with the outbox batch.
+func (r *Repository) addCodeData(ctx context.Context, db bun.IDB, accounts []*core.AccountState) error {
+	var (
+		codeKV   []*core.AccountStateCode
+		dataKV   []*core.AccountStateData
+		codeSeen = map[string]struct{}{}
+		dataSeen = map[string]struct{}{}
+	)
 	for _, a := range accounts {
-		if addrTxLT[a.Address] < a.LastTxLT {
-			addrTxLT[a.Address] = a.LastTxLT
+		if _, ok := codeSeen[string(a.CodeHash)]; !ok && len(a.CodeHash) > 0 && len(a.Code) > 0 {
+			codeSeen[string(a.CodeHash)] = struct{}{}
+			codeKV = append(codeKV, &core.AccountStateCode{CodeHash: a.CodeHash, Code: a.Code})
+		}
+		if _, ok := dataSeen[string(a.DataHash)]; !ok && len(a.DataHash) > 0 && len(a.Data) > 0 {
+			dataSeen[string(a.DataHash)] = struct{}{}
+			dataKV = append(dataKV, &core.AccountStateData{DataHash: a.DataHash, Data: a.Data})
 		}
 	}
 
-	for a, lt := range addrTxLT {
-		_, err := tx.NewInsert().
-			Model(&core.LatestAccountState{
-				Address:  a,
-				LastTxLT: lt,
-			}).
-			On("CONFLICT (address) DO UPDATE").
-			Where("latest_account_state.last_tx_lt < ?", lt).
-			Set("last_tx_lt = EXCLUDED.last_tx_lt").
-			Exec(ctx)
+	if len(codeKV) > 0 {
+		_, err := db.NewInsert().Model(&codeKV).On("CONFLICT (code_hash) DO NOTHING").Exec(ctx)
 		if err != nil {
-			return errors.Wrapf(err, "cannot set latest state for %s", &a)
+			return errors.Wrap(err, "write code to pg")
 		}
 	}
-
-	_, err = r.ch.NewInsert().Model(&accounts).Exec(ctx)
-	if err != nil {
-		return err
+	if len(dataKV) > 0 {
+		_, err := db.NewInsert().Model(&dataKV).On("CONFLICT (data_hash) DO NOTHING").Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "write data to pg")
+		}
 	}
 
 	return nil
@@ -307,6 +405,7 @@ func (r *Repository) UpdateAccountStates(ctx context.Context, accounts []*core.A
 			Set("minter_address = ?minter_address").
 			Set("fake = ?fake").
 			Set("executed_get_methods = ?executed_get_methods").
+			Set("interface_versions = ?interface_versions").
 			Set("content_uri = ?content_uri").
 			Set("content_name = ?content_name").
 			Set("content_description = ?content_description").
@@ -320,6 +419,10 @@ func (r *Repository) UpdateAccountStates(ctx context.Context, accounts []*core.A
 		}
 	}
 
+	if r.ch == nil {
+		return nil
+	}
+
 	_, err := r.ch.NewInsert().Model(&accounts).Exec(ctx)
 	if err != nil {
 		return err
@@ -328,35 +431,57 @@ func (r *Repository) UpdateAccountStates(ctx context.Context, accounts []*core.A
 	return nil
 }
 
-func (r *Repository) MatchStatesByInterfaceDesc(ctx context.Context,
+func matchStatesQuery[Q dialect.Query[Q]](q Q, d *dialect.Dialect,
 	contractName abi.ContractName,
 	addresses []*addr.Address,
 	codeHash []byte,
 	getMethodHashes []int32,
-	afterAddress *addr.Address,
-	afterTxLt uint64,
-	limit int,
-) ([]*core.AccountStateID, error) {
-	var ids []*core.AccountStateID
-
-	q := r.ch.NewSelect().Model((*core.AccountState)(nil)).
-		ColumnExpr("DISTINCT address, last_tx_lt").
-		WhereGroup(" AND ", func(q *ch.SelectQuery) *ch.SelectQuery {
+) Q {
+	return q.Model((*core.AccountState)(nil)).
+		WhereGroup(" AND ", func(q Q) Q {
 			if contractName != "" {
-				q = q.WhereOr("hasAny(types, [?])", string(contractName))
+				q = q.WhereOr(d.Has("types"), string(contractName))
 			}
 			if len(addresses) > 0 {
-				q = q.WhereOr("address IN ?", ch.In(addresses))
+				q = q.WhereOr("address IN (?)", d.In(addresses))
 			}
 			if len(codeHash) > 0 {
 				q = q.WhereOr("code_hash = ?", codeHash)
 			}
 			if len(addresses) == 0 && len(codeHash) == 0 && len(getMethodHashes) > 0 {
 				// match by get-method hashes only if addresses and code_hash are not set
-				q = q.WhereOr("hasAll(get_method_hashes, ?)", ch.Array(getMethodHashes))
+				q = q.WhereOr(d.HasAll("get_method_hashes"), d.Array(getMethodHashes))
 			}
 			return q
 		})
+}
+
+func scopeStatesQuery[Q dialect.Query[Q]](q Q, d *dialect.Dialect, scope *core.RescanScope) Q {
+	q = q.Model((*core.AccountState)(nil))
+	if len(scope.Addresses) > 0 {
+		q = q.Where("address IN (?)", d.In(scope.Addresses))
+	}
+	if len(scope.Blocks) > 0 {
+		q = q.WhereGroup(" AND ", func(q Q) Q {
+			for _, b := range scope.Blocks {
+				q = q.WhereOr("workchain = ? AND shard = ? AND block_seq_no BETWEEN ? AND ?", b.Workchain, b.Shard, b.FromSeqNo, b.ToSeqNo)
+			}
+			return q
+		})
+	}
+	if !scope.FromTime.IsZero() {
+		q = q.Where("updated_at >= ?", scope.FromTime)
+	}
+	if !scope.ToTime.IsZero() {
+		q = q.Where("updated_at < ?", scope.ToTime)
+	}
+	return q
+}
+
+func matchStateIDs[Q dialect.Query[Q]](ctx context.Context, q Q, afterAddress *addr.Address, afterTxLt uint64, limit int) ([]*core.AccountStateID, error) {
+	var ids []*core.AccountStateID
+
+	q = q.ColumnExpr("DISTINCT address, last_tx_lt")
 	if afterAddress != nil && afterTxLt != 0 {
 		q = q.Where("(address, last_tx_lt) > (?, ?)", afterAddress, afterTxLt)
 	}
@@ -371,41 +496,119 @@ func (r *Repository) MatchStatesByInterfaceDesc(ctx context.Context,
 	return ids, nil
 }
 
+func countStateIDs[Q dialect.Query[Q]](ctx context.Context, q Q, d *dialect.Dialect) (int, error) {
+	var count int
+
+	err := q.
+		ColumnExpr(d.CountDistinct("address, last_tx_lt")).
+		Scan(ctx, &count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *Repository) MatchStatesByInterfaceDesc(ctx context.Context,
+	contractName abi.ContractName,
+	addresses []*addr.Address,
+	codeHash []byte,
+	getMethodHashes []int32,
+	afterAddress *addr.Address,
+	afterTxLt uint64,
+	limit int,
+) ([]*core.AccountStateID, error) {
+	if r.ch == nil {
+		q := matchStatesQuery(r.pg.NewSelect(), dialect.PG, contractName, addresses, codeHash, getMethodHashes)
+		return matchStateIDs(ctx, q, afterAddress, afterTxLt, limit)
+	}
+	q := matchStatesQuery(r.ch.NewSelect(), dialect.CH, contractName, addresses, codeHash, getMethodHashes)
+	return matchStateIDs(ctx, q, afterAddress, afterTxLt, limit)
+}
+
+func (r *Repository) CountStatesByInterfaceDesc(ctx context.Context,
+	contractName abi.ContractName,
+	addresses []*addr.Address,
+	codeHash []byte,
+	getMethodHashes []int32,
+) (int, error) {
+	if r.ch == nil {
+		return countStateIDs(ctx, matchStatesQuery(r.pg.NewSelect(), dialect.PG, contractName, addresses, codeHash, getMethodHashes), dialect.PG)
+	}
+	return countStateIDs(ctx, matchStatesQuery(r.ch.NewSelect(), dialect.CH, contractName, addresses, codeHash, getMethodHashes), dialect.CH)
+}
+
+func (r *Repository) MatchStatesByScope(ctx context.Context,
+	scope *core.RescanScope,
+	afterAddress *addr.Address,
+	afterTxLt uint64,
+	limit int,
+) ([]*core.AccountStateID, error) {
+	if r.ch == nil {
+		return matchStateIDs(ctx, scopeStatesQuery(r.pg.NewSelect(), dialect.PG, scope), afterAddress, afterTxLt, limit)
+	}
+	return matchStateIDs(ctx, scopeStatesQuery(r.ch.NewSelect(), dialect.CH, scope), afterAddress, afterTxLt, limit)
+}
+
+func (r *Repository) CountStatesByScope(ctx context.Context, scope *core.RescanScope) (int, error) {
+	if r.ch == nil {
+		return countStateIDs(ctx, scopeStatesQuery(r.pg.NewSelect(), dialect.PG, scope), dialect.PG)
+	}
+	return countStateIDs(ctx, scopeStatesQuery(r.ch.NewSelect(), dialect.CH, scope), dialect.CH)
+}
+
 func (r *Repository) GetAllAccountInterfaces(ctx context.Context, a addr.Address) (map[uint64][]abi.ContractName, error) {
 	var ret []struct {
 		ChangeTxLT  int64
-		ChangeTypes []abi.ContractName `ch:"type:Array(String)"`
-	}
-
-	minTxLtSubQ := r.ch.NewSelect().Model((*core.AccountState)(nil)).
-		ColumnExpr("min(last_tx_lt)").
-		Where("address = ?", &a)
-
-	err := r.ch.NewSelect().
-		TableExpr("(?) AS sq", r.ch.NewSelect().Model((*core.AccountState)(nil)).
-			ColumnExpr("last_tx_lt AS change_tx_lt").
-			ColumnExpr("types AS change_types").
-			Where("address = ? AND last_tx_lt = (?)", &a, minTxLtSubQ).
-			UnionAll(
-				r.ch.NewSelect().
-					TableExpr("(?) AS diff",
-						r.ch.NewSelect().Model((*core.AccountState)(nil)).
-							ColumnExpr("last_tx_lt AS tx_lt").
-							ColumnExpr("types").
-							ColumnExpr("leadInFrame(last_tx_lt) OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_tx_lt").
-							ColumnExpr("leadInFrame(types)      OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_types").
-							Where("address = ?", &a).
-							Order("tx_lt ASC")).
-					ColumnExpr("if(next_tx_lt = 0, tx_lt, next_tx_lt) AS change_tx_lt").
-					ColumnExpr("if(next_tx_lt = 0, types, next_types) AS change_types").
-					Where(`
-						(NOT (hasAll(types, next_types) AND hasAll(types, next_types))) OR
-						(length(types) = 0 AND length(next_types) != 0) OR
-						(length(types) != 0 AND length(next_types) = 0) OR
-						next_tx_lt = 0`).
-					Order("change_tx_lt ASC"))).
-		Order("change_tx_lt ASC").
-		Scan(ctx, &ret)
+		ChangeTypes []abi.ContractName `ch:"type:Array(String)" bun:",array"`
+	}
+
+	var err error
+	if r.ch == nil {
+		err = r.pg.NewRaw(`
+			SELECT last_tx_lt AS change_tx_lt, types AS change_types
+			FROM (
+				SELECT last_tx_lt, coalesce(types, '{}') AS types,
+					lag(last_tx_lt) OVER w AS prev_tx_lt,
+					lag(coalesce(types, '{}')) OVER w AS prev_types
+				FROM account_states
+				WHERE address = ?
+				WINDOW w AS (ORDER BY last_tx_lt ASC)
+			) AS sq
+			WHERE prev_tx_lt IS NULL OR NOT (types @> prev_types AND types <@ prev_types)
+			ORDER BY change_tx_lt ASC`, &a).
+			Scan(ctx, &ret)
+	} else {
+		minTxLtSubQ := r.ch.NewSelect().Model((*core.AccountState)(nil)).
+			ColumnExpr("min(last_tx_lt)").
+			Where("address = ?", &a)
+
+		err = r.ch.NewSelect().
+			TableExpr("(?) AS sq", r.ch.NewSelect().Model((*core.AccountState)(nil)).
+				ColumnExpr("last_tx_lt AS change_tx_lt").
+				ColumnExpr("types AS change_types").
+				Where("address = ? AND last_tx_lt = (?)", &a, minTxLtSubQ).
+				UnionAll(
+					r.ch.NewSelect().
+						TableExpr("(?) AS diff",
+							r.ch.NewSelect().Model((*core.AccountState)(nil)).
+								ColumnExpr("last_tx_lt AS tx_lt").
+								ColumnExpr("types").
+								ColumnExpr("leadInFrame(last_tx_lt) OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_tx_lt").
+								ColumnExpr("leadInFrame(types)      OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_types").
+								Where("address = ?", &a).
+								Order("tx_lt ASC")).
+						ColumnExpr("if(next_tx_lt = 0, tx_lt, next_tx_lt) AS change_tx_lt").
+						ColumnExpr("if(next_tx_lt = 0, types, next_types) AS change_types").
+						Where(`
+							(NOT (hasAll(types, next_types) AND hasAll(types, next_types))) OR
+							(length(types) = 0 AND length(next_types) != 0) OR
+							(length(types) != 0 AND length(next_types) = 0) OR
+							next_tx_lt = 0`).
+						Order("change_tx_lt ASC"))).
+			Order("change_tx_lt ASC").
+			Scan(ctx, &ret)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -432,42 +635,60 @@ func (r *Repository) GetAllAccountStates(ctx context.Context, a addr.Address, be
 		ChangeDataHash []byte `ch:"type:String"`
 	}
 
-	minTxLtSubQ := r.ch.NewSelect().Model((*core.AccountState)(nil)).
-		ColumnExpr("min(last_tx_lt)").
-		Where("address = ?", &a).
-		Where("length(code_hash) > 0").
-		Where("length(data_hash) > 0")
-
-	err := r.ch.NewSelect().
-		TableExpr("(?) AS sq", r.ch.NewSelect().Model((*core.AccountState)(nil)).
-			ColumnExpr("last_tx_lt AS change_tx_lt").
-			ColumnExpr("code_hash AS change_code_hash").
-			ColumnExpr("data_hash AS change_data_hash").
-			Where("address = ? AND last_tx_lt = (?)", &a, minTxLtSubQ).
-			UnionAll(
-				r.ch.NewSelect().
-					TableExpr("(?) AS diff",
-						r.ch.NewSelect().Model((*core.AccountState)(nil)).
-							ColumnExpr("last_tx_lt AS tx_lt").
-							ColumnExpr("code_hash").
-							ColumnExpr("data_hash").
-							ColumnExpr("leadInFrame(last_tx_lt) OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_tx_lt").
-							ColumnExpr("leadInFrame(code_hash)  OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_code_hash").
-							ColumnExpr("leadInFrame(data_hash)  OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_data_hash").
-							Where("address = ?", &a).
-							Where("length(code_hash) > 0").
-							Where("length(data_hash) > 0").
-							Order("tx_lt ASC")).
-					ColumnExpr("if(next_tx_lt = 0, tx_lt, next_tx_lt) AS change_tx_lt").
-					ColumnExpr("if(next_tx_lt = 0, code_hash, next_code_hash) AS change_code_hash").
-					ColumnExpr("if(next_tx_lt = 0, data_hash, next_data_hash) AS change_data_hash").
-					Where(`
-						code_hash != next_code_hash OR
-						data_hash != next_data_hash OR
-						next_tx_lt = 0`).
-					Order("change_tx_lt ASC"))).
-		Order("change_tx_lt ASC").
-		Scan(ctx, &ret)
+	var err error
+	if r.ch == nil {
+		err = r.pg.NewRaw(`
+			SELECT last_tx_lt AS change_tx_lt, code_hash AS change_code_hash, data_hash AS change_data_hash
+			FROM (
+				SELECT last_tx_lt, code_hash, data_hash,
+					lag(last_tx_lt) OVER w AS prev_tx_lt,
+					lag(code_hash) OVER w AS prev_code_hash,
+					lag(data_hash) OVER w AS prev_data_hash
+				FROM account_states
+				WHERE address = ? AND code_hash IS NOT NULL AND data_hash IS NOT NULL
+				WINDOW w AS (ORDER BY last_tx_lt ASC)
+			) AS sq
+			WHERE prev_tx_lt IS NULL OR code_hash != prev_code_hash OR data_hash != prev_data_hash
+			ORDER BY change_tx_lt ASC`, &a).
+			Scan(ctx, &ret)
+	} else {
+		minTxLtSubQ := r.ch.NewSelect().Model((*core.AccountState)(nil)).
+			ColumnExpr("min(last_tx_lt)").
+			Where("address = ?", &a).
+			Where("length(code_hash) > 0").
+			Where("length(data_hash) > 0")
+
+		err = r.ch.NewSelect().
+			TableExpr("(?) AS sq", r.ch.NewSelect().Model((*core.AccountState)(nil)).
+				ColumnExpr("last_tx_lt AS change_tx_lt").
+				ColumnExpr("code_hash AS change_code_hash").
+				ColumnExpr("data_hash AS change_data_hash").
+				Where("address = ? AND last_tx_lt = (?)", &a, minTxLtSubQ).
+				UnionAll(
+					r.ch.NewSelect().
+						TableExpr("(?) AS diff",
+							r.ch.NewSelect().Model((*core.AccountState)(nil)).
+								ColumnExpr("last_tx_lt AS tx_lt").
+								ColumnExpr("code_hash").
+								ColumnExpr("data_hash").
+								ColumnExpr("leadInFrame(last_tx_lt) OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_tx_lt").
+								ColumnExpr("leadInFrame(code_hash)  OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_code_hash").
+								ColumnExpr("leadInFrame(data_hash)  OVER (ORDER BY last_tx_lt ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS next_data_hash").
+								Where("address = ?", &a).
+								Where("length(code_hash) > 0").
+								Where("length(data_hash) > 0").
+								Order("tx_lt ASC")).
+						ColumnExpr("if(next_tx_lt = 0, tx_lt, next_tx_lt) AS change_tx_lt").
+						ColumnExpr("if(next_tx_lt = 0, code_hash, next_code_hash) AS change_code_hash").
+						ColumnExpr("if(next_tx_lt = 0, data_hash, next_data_hash) AS change_data_hash").
+						Where(`
+							code_hash != next_code_hash OR
+							data_hash != next_data_hash OR
+							next_tx_lt = 0`).
+						Order("change_tx_lt ASC"))).
+			Order("change_tx_lt ASC").
+			Scan(ctx, &ret)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -5,19 +5,39 @@ import (
 	"database/sql"
 
 	"github.com/pkg/errors"
-	"github.com/uptrace/go-clickhouse/ch"
 
 	"github.com/tonindexer/anton/abi"
 	"github.com/tonindexer/anton/abi/known"
 	"github.com/tonindexer/anton/addr"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
-func (r *Repository) aggregateAddressStatistics(ctx context.Context, req *aggregate.AccountsReq, res *aggregate.AccountsRes) error {
+type interfacesCount struct {
+	Types []abi.ContractName `bun:",array"`
+	Count int
+}
+
+func addOwnedInterfaces(res *aggregate.AccountsRes, countByInterfaces []interfacesCount) {
+	for _, x := range countByInterfaces {
+		for _, t := range x.Types {
+			switch t {
+			case known.NFTItem:
+				res.OwnedNFTItems += x.Count
+			case known.NFTCollection:
+				res.OwnedNFTCollections += x.Count
+			case known.JettonWallet:
+				res.OwnedJettonWallets += x.Count
+			}
+		}
+	}
+}
+
+func aggregateAddressStatistics[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, req *aggregate.AccountsReq, res *aggregate.AccountsRes) error {
 	var err error
 
-	res.TransactionsCount, err = r.ch.NewSelect().
+	res.TransactionsCount, err = newSelect().
 		Model((*core.Transaction)(nil)).
 		Where("address = ?", req.Address).
 		Count(ctx)
@@ -25,14 +45,11 @@ func (r *Repository) aggregateAddressStatistics(ctx context.Context, req *aggreg
 		return errors.Wrap(err, "count transactions")
 	}
 
-	var countByInterfaces []struct {
-		Types []abi.ContractName
-		Count int
-	}
-	err = r.ch.NewSelect().
+	var countByInterfaces []interfacesCount
+	err = newSelect().
 		Model((*core.AccountState)(nil)).
 		Column("types").
-		ColumnExpr("uniqExact(address) as count").
+		ColumnExpr(d.CountDistinct("address")+" as count").
 		Where("owner_address = ?", req.Address).
 		Group("types").
 		Scan(ctx, &countByInterfaces)
@@ -40,60 +57,51 @@ func (r *Repository) aggregateAddressStatistics(ctx context.Context, req *aggreg
 		return errors.Wrap(err, "count owned nft items")
 	}
 
-	for _, x := range countByInterfaces {
-		for _, t := range x.Types {
-			switch t {
-			case known.NFTItem:
-				res.OwnedNFTItems += x.Count
-			case known.NFTCollection:
-				res.OwnedNFTCollections += x.Count
-			case known.JettonWallet:
-				res.OwnedJettonWallets += x.Count
-			}
-		}
-	}
+	addOwnedInterfaces(res, countByInterfaces)
 
 	return nil
 }
 
-func (r *Repository) makeLastItemStateQuery(minter *addr.Address) *ch.SelectQuery {
-	return r.ch.NewSelect().
+func makeLastItemStateQuery[Q dialect.Query[Q]](q Q, d *dialect.Dialect, minter *addr.Address) Q {
+	q = q.
 		Model((*core.AccountState)(nil)).
-		ColumnExpr("argMax(address, last_tx_lt) as item_address").
+		ColumnExpr(d.LastValue("address", "last_tx_lt")+" as item_address").
 		Where("minter_address = ?", minter).
-		Where("fake = false").
-		Group("address")
+		Where("fake = false")
+	return dialect.LatestBy(q, d, "address", "last_tx_lt")
 }
 
-func (r *Repository) makeLastItemOwnerQuery(minter *addr.Address) *ch.SelectQuery {
-	return r.makeLastItemStateQuery(minter).
-		ColumnExpr("argMax(owner_address, last_tx_lt) AS owner_address")
+func makeLastItemOwnerQuery[Q dialect.Query[Q]](q Q, d *dialect.Dialect, minter *addr.Address) Q {
+	return makeLastItemStateQuery(q, d, minter).
+		ColumnExpr(d.LastValue("owner_address", "last_tx_lt") + " AS owner_address")
 }
 
-func (r *Repository) aggregateNFTMinter(ctx context.Context, req *aggregate.AccountsReq, res *aggregate.AccountsRes) error {
+func aggregateNFTMinter[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, req *aggregate.AccountsReq, res *aggregate.AccountsRes) error {
 	var err error
 
-	res.Items, err = r.makeLastItemStateQuery(req.MinterAddress).Count(ctx)
+	res.Items, err = newSelect().
+		TableExpr("(?) as q", makeLastItemStateQuery(newSelect(), d, req.MinterAddress)).
+		Count(ctx)
 	if err != nil {
 		return errors.Wrap(err, "count nft items")
 	}
 
 	// TODO: owners include sale contracts
 
-	err = r.ch.NewSelect().
-		ColumnExpr("uniqExact(owner_address)").
-		TableExpr("(?) as q", r.makeLastItemOwnerQuery(req.MinterAddress)).
+	err = newSelect().
+		ColumnExpr(d.CountDistinct("owner_address")).
+		TableExpr("(?) as q", makeLastItemOwnerQuery(newSelect(), d, req.MinterAddress)).
 		Scan(ctx, &res.OwnersCount)
 	if err != nil {
 		return errors.Wrap(err, "count owners of nft minter")
 	}
 
-	err = r.ch.NewSelect().
+	err = newSelect().
 		Model((*core.AccountState)(nil)).
 		ColumnExpr("address AS item_address").
-		ColumnExpr("uniqExact(owner_address) AS owners_count").
+		ColumnExpr(d.CountDistinct("owner_address")+" AS owners_count").
 		Where("minter_address = ?", req.MinterAddress).
-		Group("item_address").
+		Group("address").
 		Order("owners_count DESC").
 		Limit(req.Limit).
 		Scan(ctx, &res.UniqueOwners)
@@ -101,10 +109,10 @@ func (r *Repository) aggregateNFTMinter(ctx context.Context, req *aggregate.Acco
 		return errors.Wrap(err, "count unique owners of nft items")
 	}
 
-	err = r.ch.NewSelect().
+	err = newSelect().
 		ColumnExpr("owner_address").
 		ColumnExpr("count(item_address) AS items_count").
-		TableExpr("(?) as q", r.makeLastItemOwnerQuery(req.MinterAddress)).
+		TableExpr("(?) as q", makeLastItemOwnerQuery(newSelect(), d, req.MinterAddress)).
 		Group("owner_address").
 		Order("items_count DESC").
 		Limit(req.Limit).
@@ -116,45 +124,51 @@ func (r *Repository) aggregateNFTMinter(ctx context.Context, req *aggregate.Acco
 	return nil
 }
 
-func (r *Repository) aggregateFTMinter(ctx context.Context, req *aggregate.AccountsReq, res *aggregate.AccountsRes) error {
+func aggregateFTMinter[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, req *aggregate.AccountsReq, res *aggregate.AccountsRes) error {
 	var err error
 
-	res.Wallets, err = r.makeLastItemStateQuery(req.MinterAddress).Count(ctx)
+	res.Wallets, err = newSelect().
+		TableExpr("(?) as q", makeLastItemStateQuery(newSelect(), d, req.MinterAddress)).
+		Count(ctx)
 	if err != nil {
 		return errors.Wrap(err, "count jetton wallets")
 	}
 
-	err = r.ch.NewSelect().
-		ColumnExpr("sum(balance) as total_supply").
-		TableExpr("(?) as q",
-			r.makeLastItemOwnerQuery(req.MinterAddress).
-				ColumnExpr("argMax(jetton_balance, last_tx_lt) AS balance")).
+	balances := func() Q {
+		return makeLastItemOwnerQuery(newSelect(), d, req.MinterAddress).
+			ColumnExpr(d.LastValue("jetton_balance", "last_tx_lt") + " AS balance")
+	}
+
+	err = newSelect().
+		ColumnExpr(d.Sum("balance")+" as total_supply").
+		TableExpr("(?) as q", balances()).
 		Scan(ctx, &res.TotalSupply)
 	if err != nil {
 		return errors.Wrap(err, "count jetton total supply")
 	}
 
-	err = r.makeLastItemOwnerQuery(req.MinterAddress).
-		ColumnExpr("argMax(jetton_balance, last_tx_lt) AS balance").
-		Order("balance DESC").
+	err = newSelect().
+		ColumnExpr("item_address, owner_address, balance").
+		TableExpr("(?) as q", balances()).
+		OrderExpr("balance DESC NULLS LAST").
 		Limit(req.Limit).
 		Scan(ctx, &res.OwnedBalance)
 	if err != nil {
 		return errors.Wrap(err, "count jetton holders")
 	}
 
-	return err
+	return nil
 }
 
-func (r *Repository) aggregateMinterStatistics(ctx context.Context, req *aggregate.AccountsReq, res *aggregate.AccountsRes) error {
+func aggregateMinterStatistics[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, req *aggregate.AccountsReq, res *aggregate.AccountsRes) error {
 	var interfaces []abi.ContractName
 
-	err := r.ch.NewSelect().
+	q := newSelect().
 		Model((*core.AccountState)(nil)).
-		ColumnExpr("argMax(types, last_tx_lt) as interfaces").
-		Where("address = ?", req.MinterAddress).
-		Group("address").
-		Scan(ctx, &interfaces)
+		ColumnExpr(d.LastValue("types", "last_tx_lt")+" as interfaces").
+		Where("address = ?", req.MinterAddress)
+	err := dialect.LatestBy(q, d, "address", "last_tx_lt").
+		Scan(ctx, d.ArrayDest(&interfaces))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil
 	}
@@ -165,12 +179,12 @@ func (r *Repository) aggregateMinterStatistics(ctx context.Context, req *aggrega
 	for _, t := range interfaces {
 		switch t {
 		case known.NFTCollection:
-			if err := r.aggregateNFTMinter(ctx, req, res); err != nil {
+			if err := aggregateNFTMinter(ctx, newSelect, d, req, res); err != nil {
 				return err
 			}
 
 		case known.JettonMinter:
-			if err := r.aggregateFTMinter(ctx, req, res); err != nil {
+			if err := aggregateFTMinter(ctx, newSelect, d, req, res); err != nil {
 				return err
 			}
 		}
@@ -179,22 +193,29 @@ func (r *Repository) aggregateMinterStatistics(ctx context.Context, req *aggrega
 	return nil
 }
 
-func (r *Repository) AggregateAccounts(ctx context.Context, req *aggregate.AccountsReq) (*aggregate.AccountsRes, error) {
+func aggregateAccounts[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, req *aggregate.AccountsReq) (*aggregate.AccountsRes, error) {
 	var res aggregate.AccountsRes
 
 	if req.Address == nil && req.MinterAddress == nil {
 		return nil, errors.Wrap(core.ErrInvalidArg, "address must be set")
 	}
 	if req.Address != nil {
-		if err := r.aggregateAddressStatistics(ctx, req, &res); err != nil {
+		if err := aggregateAddressStatistics(ctx, newSelect, d, req, &res); err != nil {
 			return nil, err
 		}
 	}
 	if req.MinterAddress != nil {
-		if err := r.aggregateMinterStatistics(ctx, req, &res); err != nil {
+		if err := aggregateMinterStatistics(ctx, newSelect, d, req, &res); err != nil {
 			return nil, err
 		}
 	}
 
 	return &res, nil
 }
+
+func (r *Repository) AggregateAccounts(ctx context.Context, req *aggregate.AccountsReq) (*aggregate.AccountsRes, error) {
+	if r.ch == nil {
+		return aggregateAccounts(ctx, r.pg.NewSelect, dialect.PG, req)
+	}
+	return aggregateAccounts(ctx, r.ch.NewSelect, dialect.CH, req)
+}
@@ -2,6 +2,7 @@ package account_test
 
 import (
 	"context"
+	"sort"
 	"testing"
 	"time"
 
@@ -13,6 +14,9 @@ import (
 	"github.com/tonindexer/anton/addr"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate"
+	"github.com/tonindexer/anton/internal/core/repository/account"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/repotest"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
@@ -47,7 +51,10 @@ func TestRepository_AggregateAccounts_NFTCollection(t *testing.T) {
 			itemsStates = append(itemsStates, itemStates...)
 		}
 
-		err = repo.AddAccountStates(ctx, tx, append(itemsStates, collectionStates...))
+		states := append(itemsStates, collectionStates...) //nolint:gocritic // append result not assigned to the same slice
+		err = repo.AddAccountStates(ctx, tx, states)
+		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Accounts: states})
 		require.Nil(t, err)
 
 		err = tx.Commit()
@@ -72,6 +79,30 @@ func TestRepository_AggregateAccounts_NFTCollection(t *testing.T) {
 		}
 	})
 
+	t.Run("clickhouse and postgres results are the same", func(t *testing.T) {
+		repotest.SkipPGOnly(t)
+
+		req := aggregate.AccountsReq{
+			MinterAddress: &collectionStates[0].Address,
+			Limit:         25,
+		}
+		resCH, err := account.NewRepository(ck, pg).AggregateAccounts(ctx, &req)
+		require.Nil(t, err)
+		resPG, err := account.NewRepository(nil, pg).AggregateAccounts(ctx, &req)
+		require.Nil(t, err)
+
+		// all items and owners have the same counts, so the order is not defined
+		for _, res := range []*aggregate.AccountsRes{resCH, resPG} {
+			sort.Slice(res.OwnedItems, func(i, j int) bool {
+				return res.OwnedItems[i].OwnerAddress.String() < res.OwnedItems[j].OwnerAddress.String()
+			})
+			sort.Slice(res.UniqueOwners, func(i, j int) bool {
+				return res.UniqueOwners[i].ItemAddress.String() < res.UniqueOwners[j].ItemAddress.String()
+			})
+		}
+		repotest.RequireSameResults(t, resCH, resPG)
+	})
+
 	t.Run("drop tables again", func(t *testing.T) {
 		dropTables(t)
 	})
@@ -116,7 +147,10 @@ func TestRepository_AggregateAccounts_JettonMinter(t *testing.T) {
 			ownedBalance[*walletLatestData.OwnerAddress] = walletLatestData.JettonBalance
 		}
 
-		err = repo.AddAccountStates(ctx, tx, append(walletsStates, minterStates...))
+		states := append(walletsStates, minterStates...) //nolint:gocritic // append result not assigned to the same slice
+		err = repo.AddAccountStates(ctx, tx, states)
+		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Accounts: states})
 		require.Nil(t, err)
 
 		err = tx.Commit()
@@ -137,6 +171,21 @@ func TestRepository_AggregateAccounts_JettonMinter(t *testing.T) {
 		}
 	})
 
+	t.Run("clickhouse and postgres results are the same", func(t *testing.T) {
+		repotest.SkipPGOnly(t)
+
+		req := aggregate.AccountsReq{
+			MinterAddress: &minterStates[0].Address,
+			Limit:         25,
+		}
+		resCH, err := account.NewRepository(ck, pg).AggregateAccounts(ctx, &req)
+		require.Nil(t, err)
+		resPG, err := account.NewRepository(nil, pg).AggregateAccounts(ctx, &req)
+		require.Nil(t, err)
+
+		repotest.RequireSameResults(t, resCH, resPG)
+	})
+
 	t.Run("drop tables again", func(t *testing.T) {
 		dropTables(t)
 	})
@@ -0,0 +1,55 @@
+package account
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+// AddAccountStatesPerRow is the write path replaced by COPY:
+// states are inserted with a multi-row INSERT, and latest states are upserted one address at a time.
+// It is kept as the baseline of the write benchmark.
+func (r *Repository) AddAccountStatesPerRow(ctx context.Context, tx bun.Tx, accounts []*core.AccountState) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	sortExecutedGetMethods(accounts)
+
+	if err := r.addCodeData(ctx, tx, accounts); err != nil {
+		return err
+	}
+
+	_, err := tx.NewInsert().Model(&accounts).Exec(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "cannot insert new account states")
+	}
+
+	addrTxLT := make(map[addr.Address]uint64)
+	for _, a := range accounts {
+		if addrTxLT[a.Address] < a.LastTxLT {
+			addrTxLT[a.Address] = a.LastTxLT
+		}
+	}
+
+	for a, lt := range addrTxLT {
+		_, err := tx.NewInsert().
+			Model(&core.LatestAccountState{
+				Address:  a,
+				LastTxLT: lt,
+			}).
+			On("CONFLICT (address) DO UPDATE").
+			Where("latest_account_state.last_tx_lt < ?", lt).
+			Set("last_tx_lt = EXCLUDED.last_tx_lt").
+			Exec(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "cannot set latest state for %s", &a)
+		}
+	}
+
+	return nil
+}
@@ -2,8 +2,10 @@ package account
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
 func (r *Repository) filterAddressLabels(ctx context.Context, f *filter.LabelsReq) (ret []*core.AddressLabel, err error) {
@@ -39,6 +42,17 @@ func (r *Repository) filterAddressLabels(ctx context.Context, f *filter.LabelsRe
 }
 
 func (r *Repository) countAddressLabels(ctx context.Context, f *filter.LabelsReq) (int, error) {
+	if r.ch == nil {
+		q := r.pg.NewSelect().Model((*core.AddressLabel)(nil))
+		if f.Name != "" {
+			q = q.Where("name ILIKE ?", "%"+f.Name+"%")
+		}
+		if len(f.Categories) > 0 {
+			q = q.Where("categories && ?", pgdialect.Array(f.Categories))
+		}
+		return q.Count(ctx)
+	}
+
 	q := r.ch.NewSelect().Model((*core.AddressLabel)(nil))
 
 	if f.Name != "" {
@@ -83,6 +97,18 @@ func flattenStateIDs(ids []*core.AccountStateID) (ret [][]any) {
 	return
 }
 
+// stateColumns drops code and data from the given columns,
+// as they are stored once per hash in separate tables.
+func stateColumns(columns []string) (ret []string) {
+	for _, c := range columns {
+		if cl := strings.ToLower(c); cl == "code" || cl == "data" {
+			continue
+		}
+		ret = append(ret, c)
+	}
+	return ret
+}
+
 func (r *Repository) filterAccountStates(ctx context.Context, f *filter.AccountsReq, total int) (ret []*core.AccountState, err error) { //nolint:gocyclo,gocognit // that's ok
 	var (
 		q                   *bun.SelectQuery
@@ -95,14 +121,14 @@ func (r *Repository) filterAccountStates(ctx context.Context, f *filter.Accounts
 	if f.LatestState {
 		q = r.pg.NewSelect().Model(&latest).
 			Relation("AccountState", func(q *bun.SelectQuery) *bun.SelectQuery {
-				return q.ExcludeColumn(f.ExcludeColumn...)
+				return q.ExcludeColumn(stateColumns(f.ExcludeColumn)...)
 			}).
 			Relation("AccountState.Label")
 		statesTable = "latest_account_state."
 		prefix = "account_state."
 	} else {
 		q = r.pg.NewSelect().Model(&ret).
-			ExcludeColumn(f.ExcludeColumn...).
+			ExcludeColumn(stateColumns(f.ExcludeColumn)...).
 			Relation("Label")
 		statesTable = "account_state."
 	}
@@ -176,11 +202,11 @@ func (r *Repository) filterAccountStates(ctx context.Context, f *filter.Accounts
 	return ret, err
 }
 
-func (r *Repository) countAccountStates(ctx context.Context, f *filter.AccountsReq) (int, error) {
-	q := r.ch.NewSelect().Model((*core.AccountState)(nil))
+func countAccountStates[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, f *filter.AccountsReq) (int, error) {
+	q := newSelect().Model((*core.AccountState)(nil))
 
 	if len(f.Addresses) > 0 {
-		q = q.Where("address in (?)", ch.In(f.Addresses))
+		q = q.Where("address in (?)", d.In(f.Addresses))
 	}
 	if len(f.StateIDs) > 0 {
 		return 0, errors.Wrap(core.ErrNotImplemented, "do not count on filter by account state ids")
@@ -200,18 +226,18 @@ func (r *Repository) countAccountStates(ctx context.Context, f *filter.AccountsR
 	}
 
 	if len(f.ContractTypes) > 0 {
-		q = q.Where("hasAny(types, ?)", ch.Array(f.ContractTypes))
+		q = q.Where(d.HasAny("types"), d.Array(f.ContractTypes))
 	}
 	if f.MinterAddress != nil {
 		q = q.Where("minter_address = ?", f.MinterAddress)
 	}
 
 	if f.LatestState {
-		q = q.ColumnExpr("argMax(address, last_tx_lt)")
+		q = q.ColumnExpr(d.LastValue("address", "last_tx_lt"))
 		if f.OwnerAddress != nil {
-			q = q.ColumnExpr("argMax(owner_address, last_tx_lt) as owner_address")
+			q = q.ColumnExpr(d.LastValue("owner_address", "last_tx_lt") + " as owner_address")
 		}
-		q = q.Group("address")
+		q = dialect.LatestBy(q, d, "address", "last_tx_lt")
 	} else {
 		q = q.Column("address")
 		if f.OwnerAddress != nil {
@@ -219,14 +245,84 @@ func (r *Repository) countAccountStates(ctx context.Context, f *filter.AccountsR
 		}
 	}
 
-	qCount := r.ch.NewSelect().TableExpr("(?) as q", q)
+	qCount := newSelect().TableExpr("(?) as q", q)
 	if f.OwnerAddress != nil { // that's because owner address can change
 		qCount = qCount.Where("owner_address = ?", f.OwnerAddress)
 	}
 	return qCount.Count(ctx)
 }
 
-func (r *Repository) getCodeData(ctx context.Context, rows []*core.AccountState, excludeCode, excludeData bool) error { //nolint:gocognit,gocyclo // TODO: make one function working for both code and data
+func (r *Repository) countAccountStates(ctx context.Context, f *filter.AccountsReq) (int, error) {
+	if r.ch == nil {
+		return countAccountStates(ctx, r.pg.NewSelect, dialect.PG, f)
+	}
+	return countAccountStates(ctx, r.ch.NewSelect, dialect.CH, f)
+}
+
+type blobRow struct {
+	Hash []byte `ch:"type:String"`
+	Blob []byte `ch:"type:String"`
+}
+
+// getBlobsByHash looks up code or data by hash in PostgreSQL.
+// Blobs written before the deduplication in PostgreSQL are stored only in ClickHouse key-value storage,
+// so the missing hashes are looked up there, if ClickHouse is used.
+func (r *Repository) getBlobsByHash(ctx context.Context, table, hashColumn, blobColumn string, hashes map[string]struct{}) (map[string][]byte, error) {
+	const batchLen = 1000
+
+	var batches [][][]byte
+	for h := range hashes {
+		if len(batches) == 0 || len(batches[len(batches)-1]) >= batchLen {
+			batches = append(batches, nil)
+		}
+		batches[len(batches)-1] = append(batches[len(batches)-1], []byte(h))
+	}
+
+	ret := map[string][]byte{}
+	for _, b := range batches {
+		var pgRows []*blobRow
+		err := r.pg.NewSelect().
+			TableExpr(table).
+			ColumnExpr("? AS hash", bun.Ident(hashColumn)).
+			ColumnExpr("? AS blob", bun.Ident(blobColumn)).
+			Where("? IN (?)", bun.Ident(hashColumn), bun.In(b)).
+			Scan(ctx, &pgRows)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get %s from pg", blobColumn)
+		}
+		for _, x := range pgRows {
+			ret[string(x.Hash)] = x.Blob
+		}
+
+		var missing [][]byte
+		for _, h := range b {
+			if _, ok := ret[string(h)]; !ok {
+				missing = append(missing, h)
+			}
+		}
+		if len(missing) == 0 || r.ch == nil {
+			continue
+		}
+
+		var chRows []*blobRow
+		err = r.ch.NewSelect().
+			TableExpr(table).
+			ColumnExpr("? AS hash", ch.Ident(hashColumn)).
+			ColumnExpr("? AS blob", ch.Ident(blobColumn)).
+			Where("? IN ?", ch.Ident(hashColumn), ch.In(missing)).
+			Scan(ctx, &chRows)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get %s from ch", blobColumn)
+		}
+		for _, x := range chRows {
+			ret[string(x.Hash)] = x.Blob
+		}
+	}
+
+	return ret, nil
+}
+
+func (r *Repository) getCodeData(ctx context.Context, rows []*core.AccountState, excludeCode, excludeData bool) error {
 	codeHashesSet, dataHashesSet := map[string]struct{}{}, map[string]struct{}{}
 	for _, row := range rows {
 		if !excludeCode && len(row.Code) == 0 && len(row.CodeHash) == 32 {
@@ -237,56 +333,45 @@ func (r *Repository) getCodeData(ctx context.Context, rows []*core.AccountState,
 		}
 	}
 
-	batchLen := 1000
-	codeHashBatches, dataHashBatches := make([][][]byte, 1), make([][][]byte, 1)
-	appendHash := func(hash []byte, batches [][][]byte) [][][]byte {
-		b := batches[len(batches)-1]
-		if len(b) >= batchLen {
-			b = [][]byte{}
-			batches = append(batches, b)
-		}
-		batches[len(batches)-1] = append(b, hash)
-		return batches
-	}
-	for h := range codeHashesSet {
-		codeHashBatches = appendHash([]byte(h), codeHashBatches)
+	codeRes, err := r.getBlobsByHash(ctx, "account_states_code", "code_hash", "code", codeHashesSet)
+	if err != nil {
+		return err
 	}
-	for h := range dataHashesSet {
-		dataHashBatches = appendHash([]byte(h), dataHashBatches)
+	dataRes, err := r.getBlobsByHash(ctx, "account_states_data", "data_hash", "data", dataHashesSet)
+	if err != nil {
+		return err
 	}
 
-	codeRes, dataRes := map[string][]byte{}, map[string][]byte{}
-	for _, b := range codeHashBatches {
-		var codeArr []*core.AccountStateCode
-		err := r.ch.NewSelect().Model(&codeArr).Where("code_hash IN ?", ch.In(b)).Scan(ctx)
-		if err != nil {
-			return errors.Wrapf(err, "get code")
-		}
-		for _, x := range codeArr {
-			codeRes[string(x.CodeHash)] = x.Code
-		}
-	}
-	for _, b := range dataHashBatches {
-		var dataArr []*core.AccountStateData
-		err := r.ch.NewSelect().Model(&dataArr).Where("data_hash IN ?", ch.In(b)).Scan(ctx)
-		if err != nil {
-			return errors.Wrapf(err, "get data")
+	var prunedBefore *time.Time
+	missing := func(row *core.AccountState, blob string, hash []byte) error {
+		if prunedBefore == nil {
+			t, err := r.codeDataPrunedBefore(ctx)
+			if err != nil {
+				return err
+			}
+			prunedBefore = &t
 		}
-		for _, x := range dataArr {
-			dataRes[string(x.DataHash)] = x.Data
+		// code and data of superseded states can be pruned, they are left empty then
+		if row.UpdatedAt.Before(*prunedBefore) {
+			return nil
 		}
+		return fmt.Errorf("cannot find %s with %x hash", blob, hash)
 	}
 
 	for _, row := range rows {
 		var ok bool
 		if !excludeCode && len(row.Code) == 0 && len(row.CodeHash) == 32 {
 			if row.Code, ok = codeRes[string(row.CodeHash)]; !ok {
-				return fmt.Errorf("cannot find code with %x hash", row.CodeHash)
+				if err := missing(row, "code", row.CodeHash); err != nil {
+					return err
+				}
 			}
 		}
 		if !excludeData && len(row.Data) == 0 && len(row.DataHash) == 32 {
 			if row.Data, ok = dataRes[string(row.DataHash)]; !ok {
-				return fmt.Errorf("cannot find data with %x hash", row.DataHash)
+				if err := missing(row, "data", row.DataHash); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -294,6 +379,21 @@ func (r *Repository) getCodeData(ctx context.Context, rows []*core.AccountState,
 	return nil
 }
 
+// codeDataPrunedBefore returns the time, before which code and data of superseded states could be pruned.
+func (r *Repository) codeDataPrunedBefore(ctx context.Context) (time.Time, error) {
+	var p core.Pruning
+
+	err := r.pg.NewSelect().Model(&p).Where("rule = ?", core.PruneCodeData).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "get code and data pruning")
+	}
+
+	return p.Before, nil
+}
+
 func (r *Repository) FilterAccounts(ctx context.Context, f *filter.AccountsReq) (*filter.AccountsRes, error) {
 	var (
 		res = new(filter.AccountsRes)
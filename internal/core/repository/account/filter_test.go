@@ -14,6 +14,7 @@ import (
 	"github.com/tonindexer/anton/addr"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
@@ -104,8 +105,10 @@ func addAccountStatesCopy(ctx context.Context, tx bun.Tx, states []*core.Account
 		c := *s
 		copied = append(copied, &c)
 	}
-	err := repo.AddAccountStates(ctx, tx, copied)
-	return err
+	if err := repo.AddAccountStates(ctx, tx, copied); err != nil {
+		return err
+	}
+	return outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Accounts: copied})
 }
 
 func TestRepository_FilterAccounts(t *testing.T) {
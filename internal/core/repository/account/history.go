@@ -2,15 +2,14 @@ package account
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/pkg/errors"
-	"github.com/uptrace/go-clickhouse/ch"
 
 	"github.com/tonindexer/anton/abi"
 	"github.com/tonindexer/anton/abi/known"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate/history"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
 func getContractTypes(types []abi.ContractName) (ret []abi.ContractName) {
@@ -23,13 +22,11 @@ func getContractTypes(types []abi.ContractName) (ret []abi.ContractName) {
 	return
 }
 
-func (r *Repository) AggregateAccountsHistory(ctx context.Context, req *history.AccountsReq) (*history.AccountsRes, error) {
+func aggregateAccountsHistory[Q dialect.Query[Q]](ctx context.Context, q Q, d *dialect.Dialect, req *history.AccountsReq) (*history.AccountsRes, error) {
 	var res history.AccountsRes
 
-	q := r.ch.NewSelect().Model((*core.AccountState)(nil))
-
 	if len(req.ContractTypes) > 0 {
-		q = q.Where("hasAny(types, ?)", ch.Array(getContractTypes(req.ContractTypes)))
+		q = q.Where(d.HasAny("types"), d.Array(getContractTypes(req.ContractTypes)))
 	}
 	if req.MinterAddress != nil {
 		q = q.Where("minter_address = ?", req.MinterAddress)
@@ -37,16 +34,16 @@ func (r *Repository) AggregateAccountsHistory(ctx context.Context, req *history.
 
 	switch req.Metric {
 	case history.ActiveAddresses:
-		q = q.ColumnExpr("uniqExact(address) as value")
+		q = q.ColumnExpr(d.CountDistinct("address") + " as value")
 	default:
 		return nil, errors.Wrapf(core.ErrInvalidArg, "invalid account metric %s", req.Metric)
 	}
 
-	rounding, err := history.GetRoundingFunction(req.Interval)
+	rounding, err := d.Rounding(req.Interval, "updated_at")
 	if err != nil {
 		return nil, err
 	}
-	q = q.ColumnExpr(fmt.Sprintf(rounding, "updated_at") + " as timestamp")
+	q = q.ColumnExpr(rounding + " as timestamp")
 	q = q.Group("timestamp")
 
 	if !req.From.IsZero() {
@@ -64,3 +61,10 @@ func (r *Repository) AggregateAccountsHistory(ctx context.Context, req *history.
 
 	return &res, nil
 }
+
+func (r *Repository) AggregateAccountsHistory(ctx context.Context, req *history.AccountsReq) (*history.AccountsRes, error) {
+	if r.ch == nil {
+		return aggregateAccountsHistory(ctx, r.pg.NewSelect().Model((*core.AccountState)(nil)), dialect.PG, req)
+	}
+	return aggregateAccountsHistory(ctx, r.ch.NewSelect().Model((*core.AccountState)(nil)), dialect.CH, req)
+}
@@ -8,7 +8,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate/history"
+	"github.com/tonindexer/anton/internal/core/repository/account"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/repotest"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
@@ -35,6 +39,8 @@ func TestRepository_AggregateAccountsHistory(t *testing.T) {
 
 			err = repo.AddAccountStates(ctx, tx, states)
 			require.Nil(t, err)
+			err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Accounts: states})
+			require.Nil(t, err)
 		}
 
 		for i := 0; i < 10; i++ {
@@ -42,21 +48,25 @@ func TestRepository_AggregateAccountsHistory(t *testing.T) {
 
 			err = repo.AddAccountStates(ctx, tx, states)
 			require.Nil(t, err)
+			err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Accounts: states})
+			require.Nil(t, err)
 		}
 
 		err = tx.Commit()
 		require.Nil(t, err)
 	})
 
+	req := history.AccountsReq{
+		Metric:        history.ActiveAddresses,
+		ContractTypes: []abi.ContractName{"special"},
+		ReqParams: history.ReqParams{
+			From:     time.Now().UTC(),
+			Interval: 24 * time.Hour,
+		},
+	}
+
 	t.Run("count active addresses", func(t *testing.T) {
-		res, err := repo.AggregateAccountsHistory(ctx, &history.AccountsReq{
-			Metric:        history.ActiveAddresses,
-			ContractTypes: []abi.ContractName{"special"},
-			ReqParams: history.ReqParams{
-				From:     time.Now().UTC(),
-				Interval: 24 * time.Hour,
-			},
-		})
+		res, err := repo.AggregateAccountsHistory(ctx, &req)
 		require.Nil(t, err)
 		require.Equal(t, 1, len(res.CountRes))
 		for _, c := range res.CountRes {
@@ -64,6 +74,22 @@ func TestRepository_AggregateAccountsHistory(t *testing.T) {
 		}
 	})
 
+	t.Run("clickhouse and postgres results are the same", func(t *testing.T) {
+		repotest.SkipPGOnly(t)
+
+		resCH, err := account.NewRepository(ck, pg).AggregateAccountsHistory(ctx, &req)
+		require.Nil(t, err)
+		resPG, err := account.NewRepository(nil, pg).AggregateAccountsHistory(ctx, &req)
+		require.Nil(t, err)
+
+		for _, res := range []*history.AccountsRes{resCH, resPG} {
+			for i := range res.CountRes {
+				res.CountRes[i].Timestamp = res.CountRes[i].Timestamp.UTC()
+			}
+		}
+		repotest.RequireSameResults(t, resCH, resPG)
+	})
+
 	t.Run("drop tables again", func(t *testing.T) {
 		dropTables(t)
 	})
@@ -47,16 +47,18 @@ func createIndexes(ctx context.Context, pgDB *bun.DB) error {
 }
 
 func CreateTables(ctx context.Context, chDB *ch.DB, pgDB *bun.DB) error {
-	_, err := chDB.NewCreateTable().
-		IfNotExists().
-		Engine("ReplacingMergeTree").
-		Model(&core.Block{}).
-		Exec(ctx)
-	if err != nil {
-		return errors.Wrap(err, "block ch create table")
+	if chDB != nil {
+		_, err := chDB.NewCreateTable().
+			IfNotExists().
+			Engine("ReplacingMergeTree").
+			Model(&core.Block{}).
+			Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "block ch create table")
+		}
 	}
 
-	_, err = pgDB.NewCreateTable().
+	_, err := pgDB.NewCreateTable().
 		Model(&core.Block{}).
 		IfNotExists().
 		Exec(ctx)
@@ -77,13 +79,13 @@ func (r *Repository) AddBlocks(ctx context.Context, tx bun.Tx, info []*core.Bloc
 			return err
 		}
 	}
-	_, err := r.ch.NewInsert().Model(&info).Exec(ctx)
-	if err != nil {
-		return err
-	}
 	return nil
 }
 
+func (r *Repository) CopyBlocks(ctx context.Context, conn bun.Conn, info []*core.Block) error {
+	return repository.CopyFrom(ctx, conn, info)
+}
+
 func (r *Repository) GetLastMasterBlock(ctx context.Context) (*core.Block, error) {
 	ret := new(core.Block)
 
@@ -103,6 +105,12 @@ func (r *Repository) GetLastMasterBlock(ctx context.Context) (*core.Block, error
 }
 
 func (r *Repository) CountMasterBlocks(ctx context.Context) (int, error) {
+	if r.ch == nil {
+		return r.pg.NewSelect().Model((*core.Block)(nil)).
+			Where("workchain = -1").
+			Count(ctx)
+	}
+
 	ret, err := r.ch.NewSelect().Model((*core.Block)(nil)).
 		Where("workchain = -1").
 		Count(ctx)
@@ -111,3 +119,21 @@ func (r *Repository) CountMasterBlocks(ctx context.Context) (int, error) {
 	}
 	return ret, nil
 }
+
+func (r *Repository) GetShardBlockRanges(ctx context.Context, fromMasterSeqNo, toMasterSeqNo uint32) ([]*core.BlockRange, error) {
+	var ret []*core.BlockRange
+
+	err := r.pg.NewSelect().Model((*core.Block)(nil)).
+		ColumnExpr("workchain, shard").
+		ColumnExpr("min(seq_no) AS from_seq_no").
+		ColumnExpr("max(seq_no) AS to_seq_no").
+		Where("(workchain = -1 AND seq_no BETWEEN ? AND ?) OR (master_workchain = -1 AND master_seq_no BETWEEN ? AND ?)",
+			fromMasterSeqNo, toMasterSeqNo, fromMasterSeqNo, toMasterSeqNo).
+		Group("workchain", "shard").
+		Scan(ctx, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
 func loadTransactions(q *bun.SelectQuery, prefix string, f *filter.BlocksReq) *bun.SelectQuery {
@@ -151,10 +152,7 @@ func (r *Repository) filterBlocks(ctx context.Context, f *filter.BlocksReq) (ret
 	return ret, nil
 }
 
-func (r *Repository) countBlocks(ctx context.Context, f *filter.BlocksReq) (int, error) {
-	q := r.ch.NewSelect().
-		Model((*core.Block)(nil))
-
+func countBlocksQuery[Q dialect.Query[Q]](q Q, f *filter.BlocksReq) Q {
 	if f.Workchain != nil {
 		q = q.Where("workchain = ?", *f.Workchain)
 	}
@@ -169,7 +167,14 @@ func (r *Repository) countBlocks(ctx context.Context, f *filter.BlocksReq) (int,
 		q = q.Where("file_hash = ?", f.FileHash)
 	}
 
-	return q.Count(ctx)
+	return q
+}
+
+func (r *Repository) countBlocks(ctx context.Context, f *filter.BlocksReq) (int, error) {
+	if r.ch == nil {
+		return countBlocksQuery(r.pg.NewSelect().Model((*core.Block)(nil)), f).Count(ctx)
+	}
+	return countBlocksQuery(r.ch.NewSelect().Model((*core.Block)(nil)), f).Count(ctx)
 }
 
 func (r *Repository) FilterBlocks(ctx context.Context, f *filter.BlocksReq) (*filter.BlocksRes, error) {
@@ -9,6 +9,7 @@ import (
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
 	"github.com/tonindexer/anton/internal/core/repository/tx"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
@@ -49,9 +50,13 @@ func TestRepository_FilterBlocks(t *testing.T) {
 
 		err = repo.AddBlocks(ctx, dbTx, shards)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Blocks: shards})
+		require.Nil(t, err)
 
 		err = repo.AddBlocks(ctx, dbTx, []*core.Block{master})
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Blocks: []*core.Block{master}})
+		require.Nil(t, err)
 
 		err = dbTx.Commit()
 		require.Nil(t, err)
@@ -68,9 +73,13 @@ func TestRepository_FilterBlocks(t *testing.T) {
 
 		err = txRepo.AddTransactions(ctx, dbTx, masterTx)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Transactions: masterTx})
+		require.Nil(t, err)
 
 		err = txRepo.AddTransactions(ctx, dbTx, shardTx)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Transactions: shardTx})
+		require.Nil(t, err)
 
 		err = dbTx.Commit()
 		require.Nil(t, err)
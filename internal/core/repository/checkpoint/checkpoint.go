@@ -0,0 +1,61 @@
+package checkpoint
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/internal/core"
+)
+
+var _ core.VerifyCheckpointRepository = (*Repository)(nil)
+
+type Repository struct {
+	pg *bun.DB
+}
+
+func NewRepository(db *bun.DB) *Repository {
+	return &Repository{pg: db}
+}
+
+func CreateTables(ctx context.Context, pgDB *bun.DB) error {
+	_, err := pgDB.NewCreateTable().
+		Model(&core.VerifyCheckpoint{}).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "clickhouse verify checkpoints pg create table")
+	}
+	return nil
+}
+
+func (r *Repository) GetVerifyCheckpoint(ctx context.Context, name string) (uint32, error) {
+	var ret core.VerifyCheckpoint
+
+	err := r.pg.NewSelect().Model(&ret).Where("name = ?", name).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errors.Wrap(core.ErrNotFound, "no verify checkpoint")
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "get verify checkpoint %s", name)
+	}
+
+	return ret.MasterSeqNo, nil
+}
+
+func (r *Repository) SetVerifyCheckpoint(ctx context.Context, name string, masterSeqNo uint32) error {
+	c := &core.VerifyCheckpoint{Name: name, MasterSeqNo: masterSeqNo, UpdatedAt: time.Now()}
+
+	_, err := r.pg.NewInsert().Model(c).
+		On("CONFLICT (name) DO UPDATE").
+		Set("master_seq_no = EXCLUDED.master_seq_no").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "set verify checkpoint %s", name)
+	}
+	return nil
+}
@@ -67,16 +67,30 @@ func CreateTables(ctx context.Context, pgDB *bun.DB) error {
 		return errors.Wrap(err, "messages pg create source tx hash check")
 	}
 
+	_, err = pgDB.ExecContext(ctx, "CREATE TYPE contract_revision_action AS ENUM (?, ?, ?, ?)",
+		core.RevisionAdd, core.RevisionUpdate, core.RevisionDelete, core.RevisionRollback)
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return errors.Wrap(err, "contract revision action pg create enum")
+	}
+
+	_, err = pgDB.NewCreateTable().
+		Model(&core.ContractRevision{}).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "contract revisions pg create table")
+	}
+
 	return nil
 }
 
-func (r *Repository) AddDefinition(ctx context.Context, dn abi.TLBType, d abi.TLBFieldsDesc) error {
+func (r *Repository) AddDefinition(ctx context.Context, db bun.IDB, dn abi.TLBType, d abi.TLBFieldsDesc) error {
 	def := &core.ContractDefinition{
 		Name:   dn,
 		Schema: d,
 	}
 
-	_, err := r.pg.NewInsert().Model(def).Exec(ctx)
+	_, err := db.NewInsert().Model(def).Exec(ctx)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
 			return core.ErrAlreadyExists
@@ -87,13 +101,13 @@ func (r *Repository) AddDefinition(ctx context.Context, dn abi.TLBType, d abi.TL
 	return nil
 }
 
-func (r *Repository) UpdateDefinition(ctx context.Context, dn abi.TLBType, d abi.TLBFieldsDesc) error {
+func (r *Repository) UpdateDefinition(ctx context.Context, db bun.IDB, dn abi.TLBType, d abi.TLBFieldsDesc) error {
 	def := &core.ContractDefinition{
 		Name:   dn,
 		Schema: d,
 	}
 
-	ret, err := r.pg.NewUpdate().Model(def).WherePK().Exec(ctx)
+	ret, err := db.NewUpdate().Model(def).WherePK().Exec(ctx)
 	if err != nil {
 		return err
 	}
@@ -109,10 +123,10 @@ func (r *Repository) UpdateDefinition(ctx context.Context, dn abi.TLBType, d abi
 	return nil
 }
 
-func (r *Repository) DeleteDefinition(ctx context.Context, dn abi.TLBType) error {
+func (r *Repository) DeleteDefinition(ctx context.Context, db bun.IDB, dn abi.TLBType) error {
 	def := &core.ContractDefinition{Name: dn}
 
-	ret, err := r.pg.NewDelete().Model(def).WherePK().Exec(ctx)
+	ret, err := db.NewDelete().Model(def).WherePK().Exec(ctx)
 	if err != nil {
 		return err
 	}
@@ -144,8 +158,8 @@ func (r *Repository) GetDefinitions(ctx context.Context) (map[abi.TLBType]abi.TL
 	return res, nil
 }
 
-func (r *Repository) AddInterface(ctx context.Context, i *core.ContractInterface) error {
-	_, err := r.pg.NewInsert().Model(i).Exec(ctx)
+func (r *Repository) AddInterface(ctx context.Context, db bun.IDB, i *core.ContractInterface) error {
+	_, err := db.NewInsert().Model(i).Exec(ctx)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
 			return core.ErrAlreadyExists
@@ -155,16 +169,16 @@ func (r *Repository) AddInterface(ctx context.Context, i *core.ContractInterface
 	return nil
 }
 
-func (r *Repository) UpdateInterface(ctx context.Context, i *core.ContractInterface) error {
-	_, err := r.pg.NewUpdate().Model(i).WherePK().Exec(ctx)
+func (r *Repository) UpdateInterface(ctx context.Context, db bun.IDB, i *core.ContractInterface) error {
+	_, err := db.NewUpdate().Model(i).WherePK().Exec(ctx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *Repository) DeleteInterface(ctx context.Context, name abi.ContractName) error {
-	_, err := r.pg.NewDelete().
+func (r *Repository) DeleteInterface(ctx context.Context, db bun.IDB, name abi.ContractName) error {
+	_, err := db.NewDelete().
 		Model((*core.ContractOperation)(nil)).
 		Where("contract_name = ?", name).
 		Exec(ctx)
@@ -172,7 +186,7 @@ func (r *Repository) DeleteInterface(ctx context.Context, name abi.ContractName)
 		return err
 	}
 
-	ret, err := r.pg.NewDelete().
+	ret, err := db.NewDelete().
 		Model((*core.ContractInterface)(nil)).
 		Where("name = ?", name).
 		Exec(ctx)
@@ -235,6 +249,9 @@ func (r *Repository) GetMethodDescription(ctx context.Context, name abi.Contract
 	err := r.pg.NewSelect().Model(&i).
 		Where("name = ?", name).
 		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return abi.GetMethodDesc{}, core.ErrNotFound
+	}
 	if err != nil {
 		return abi.GetMethodDesc{}, err
 	}
@@ -248,8 +265,8 @@ func (r *Repository) GetMethodDescription(ctx context.Context, name abi.Contract
 	return abi.GetMethodDesc{}, core.ErrNotFound
 }
 
-func (r *Repository) AddOperation(ctx context.Context, op *core.ContractOperation) error {
-	_, err := r.pg.NewInsert().Model(op).Exec(ctx)
+func (r *Repository) AddOperation(ctx context.Context, db bun.IDB, op *core.ContractOperation) error {
+	_, err := db.NewInsert().Model(op).Exec(ctx)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
 			return core.ErrAlreadyExists
@@ -259,8 +276,8 @@ func (r *Repository) AddOperation(ctx context.Context, op *core.ContractOperatio
 	return nil
 }
 
-func (r *Repository) UpdateOperation(ctx context.Context, op *core.ContractOperation) error {
-	ret, err := r.pg.NewUpdate().Model(op).WherePK().Exec(ctx)
+func (r *Repository) UpdateOperation(ctx context.Context, db bun.IDB, op *core.ContractOperation) error {
+	ret, err := db.NewUpdate().Model(op).WherePK().Exec(ctx)
 	if err != nil {
 		return err
 	}
@@ -274,8 +291,8 @@ func (r *Repository) UpdateOperation(ctx context.Context, op *core.ContractOpera
 	return nil
 }
 
-func (r *Repository) DeleteOperation(ctx context.Context, opName string) error {
-	ret, err := r.pg.NewDelete().Model((*core.ContractOperation)(nil)).
+func (r *Repository) DeleteOperation(ctx context.Context, db bun.IDB, opName string) error {
+	ret, err := db.NewDelete().Model((*core.ContractOperation)(nil)).
 		Where("operation_name = ?", opName).
 		Exec(ctx)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -50,7 +51,9 @@ func dropTables(t testing.TB) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	_, err := pg.NewDropTable().Model((*core.ContractOperation)(nil)).IfExists().Exec(ctx)
+	_, err := pg.NewDropTable().Model((*core.ContractRevision)(nil)).IfExists().Exec(ctx)
+	require.Nil(t, err)
+	_, err = pg.NewDropTable().Model((*core.ContractOperation)(nil)).IfExists().Exec(ctx)
 	require.Nil(t, err)
 	_, err = pg.NewDropTable().Model((*core.ContractInterface)(nil)).IfExists().Exec(ctx)
 	require.Nil(t, err)
@@ -61,6 +64,10 @@ func dropTables(t testing.TB) {
 	if err != nil && !strings.Contains(err.Error(), "does not exist") {
 		t.Fatal(err)
 	}
+	_, err = pg.ExecContext(context.Background(), "DROP TYPE contract_revision_action")
+	if err != nil && !strings.Contains(err.Error(), "does not exist") {
+		t.Fatal(err)
+	}
 }
 
 func TestRepository_AddContracts(t *testing.T) {
@@ -178,7 +185,7 @@ func TestRepository_AddContracts(t *testing.T) {
 	})
 
 	t.Run("insert definition", func(t *testing.T) {
-		err := repo.AddDefinition(ctx, d.Name, d.Schema)
+		err := repo.AddDefinition(ctx, pg, d.Name, d.Schema)
 		require.Nil(t, err)
 	})
 
@@ -190,12 +197,12 @@ func TestRepository_AddContracts(t *testing.T) {
 	})
 
 	t.Run("insert interface", func(t *testing.T) {
-		err := repo.AddInterface(ctx, i)
+		err := repo.AddInterface(ctx, pg, i)
 		require.Nil(t, err)
 	})
 
 	t.Run("insert operation", func(t *testing.T) {
-		err := repo.AddOperation(ctx, op)
+		err := repo.AddOperation(ctx, pg, op)
 		require.Nil(t, err)
 	})
 
@@ -224,6 +231,86 @@ func TestRepository_AddContracts(t *testing.T) {
 		require.Equal(t, op, ret[0])
 	})
 
+	t.Run("add revisions", func(t *testing.T) {
+		rev := &core.ContractRevision{
+			ContractName: i.Name,
+			Action:       core.RevisionAdd,
+			Author:       "test",
+			Diff:         []string{"  + interface"},
+			Definitions:  map[abi.TLBType]abi.TLBFieldsDesc{d.Name: d.Schema},
+		}
+		err := repo.AddRevision(ctx, pg, rev)
+		require.Nil(t, err)
+		require.Equal(t, uint32(1), rev.Version)
+		require.NotNil(t, rev.Interface)
+		require.Equal(t, 1, len(rev.Interface.Operations))
+
+		updOp := *op
+		updOp.OperationName = "nft_item_transfer_v2"
+		err = repo.DeleteOperation(ctx, pg, op.OperationName)
+		require.Nil(t, err)
+		err = repo.AddOperation(ctx, pg, &updOp)
+		require.Nil(t, err)
+
+		rev = &core.ContractRevision{ContractName: i.Name, Action: core.RevisionUpdate}
+		err = repo.AddRevision(ctx, pg, rev)
+		require.Nil(t, err)
+		require.Equal(t, uint32(2), rev.Version)
+
+		got, err := repo.GetInterface(ctx, i.Name)
+		require.Nil(t, err)
+		require.Equal(t, uint32(2), got.Version)
+		require.Equal(t, 1, len(got.Operations))
+		require.Equal(t, uint32(2), got.Operations[0].Version)
+	})
+
+	t.Run("get revisions", func(t *testing.T) {
+		ret, err := repo.GetRevisions(ctx, i.Name)
+		require.Nil(t, err)
+		require.Equal(t, 2, len(ret))
+		require.Equal(t, uint32(2), ret[0].Version)
+		require.Equal(t, core.RevisionUpdate, ret[0].Action)
+		require.Equal(t, "nft_item_transfer_v2", ret[0].Interface.Operations[0].OperationName)
+
+		rev, err := repo.GetRevision(ctx, i.Name, 1)
+		require.Nil(t, err)
+		require.Equal(t, core.RevisionAdd, rev.Action)
+		require.Equal(t, "test", rev.Author)
+		require.Equal(t, op.OperationName, rev.Interface.Operations[0].OperationName)
+		require.Equal(t, uint32(1), rev.Interface.Version)
+		require.Equal(t, d.Schema, rev.Definitions[d.Name])
+
+		_, err = repo.GetRevision(ctx, i.Name, 3)
+		require.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("add concurrent revisions", func(t *testing.T) {
+		const n = 8
+
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for it := 0; it < n; it++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- repo.AddRevision(ctx, pg, &core.ContractRevision{ContractName: i.Name, Action: core.RevisionUpdate})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			require.Nil(t, err)
+		}
+
+		ret, err := repo.GetRevisions(ctx, i.Name)
+		require.Nil(t, err)
+		require.Equal(t, 2+n, len(ret))
+		for it, rev := range ret {
+			require.Equal(t, uint32(2+n-it), rev.Version)
+		}
+	})
+
 	t.Run("drop tables", func(t *testing.T) {
 		dropTables(t)
 	})
@@ -0,0 +1,117 @@
+package contract
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+func (r *Repository) AddRevision(ctx context.Context, db bun.IDB, rev *core.ContractRevision) error {
+	if rev.ContractName == "" {
+		return errors.Wrap(core.ErrInvalidArg, "contract name is not set")
+	}
+
+	// a savepoint is created, if the revision is added in a transaction
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var (
+			i    core.ContractInterface
+			last uint32
+		)
+
+		// the interface row is locked before reading the last version,
+		// so that concurrent revisions get sequential versions;
+		// revisions of a deleted interface are guarded by the primary key
+		err := tx.NewSelect().Model(&i).
+			Relation("Operations").
+			Where("name = ?", rev.ContractName).
+			For("UPDATE OF contract_interface").
+			Scan(ctx)
+		deleted := errors.Is(err, sql.ErrNoRows)
+		if err != nil && !deleted {
+			return errors.Wrap(err, "get contract interface")
+		}
+
+		err = tx.NewSelect().Model((*core.ContractRevision)(nil)).
+			ColumnExpr("coalesce(max(version), 0)").
+			Where("contract_name = ?", rev.ContractName).
+			Scan(ctx, &last)
+		if err != nil {
+			return errors.Wrap(err, "get last revision")
+		}
+
+		rev.Version = last + 1
+		if rev.CreatedAt.IsZero() {
+			rev.CreatedAt = time.Now().UTC()
+		}
+
+		if deleted {
+			rev.Interface = nil
+		} else {
+			_, err = tx.NewUpdate().Model((*core.ContractInterface)(nil)).
+				Set("version = ?", rev.Version).
+				Where("name = ?", rev.ContractName).
+				Exec(ctx)
+			if err != nil {
+				return errors.Wrap(err, "set contract interface version")
+			}
+
+			_, err = tx.NewUpdate().Model((*core.ContractOperation)(nil)).
+				Set("version = ?", rev.Version).
+				Where("contract_name = ?", rev.ContractName).
+				Exec(ctx)
+			if err != nil {
+				return errors.Wrap(err, "set contract operations version")
+			}
+
+			i.Version = rev.Version
+			for _, op := range i.Operations {
+				op.Version = rev.Version
+			}
+			rev.Interface = &i
+		}
+
+		_, err = tx.NewInsert().Model(rev).Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "insert contract revision")
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) GetRevisions(ctx context.Context, name abi.ContractName) ([]*core.ContractRevision, error) {
+	var ret []*core.ContractRevision
+
+	err := r.pg.NewSelect().Model(&ret).
+		Where("contract_name = ?", name).
+		Order("version DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (r *Repository) GetRevision(ctx context.Context, name abi.ContractName, version uint32) (*core.ContractRevision, error) {
+	var ret core.ContractRevision
+
+	err := r.pg.NewSelect().Model(&ret).
+		Where("contract_name = ?", name).
+		Where("version = ?", version).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/schema"
+)
+
+// CopyFrom writes a slice of models to their table with COPY FROM STDIN,
+// which is much faster than a multi-row INSERT on large batches.
+// If a transaction is begun on the connection, rows are committed with it.
+// Values are formatted in the same way as bun formats them for INSERT:
+// zero values of fields with the default tag are written as the tag value,
+// as COPY does not apply column defaults. Rows must not conflict with the existing ones.
+func CopyFrom(ctx context.Context, conn bun.Conn, models interface{}) error {
+	return CopyFromTable(ctx, conn, "", models)
+}
+
+// CopyFromTable is CopyFrom to the given table with the same columns, e.g., a temporary one.
+// Models table is used if the given name is empty.
+func CopyFromTable(ctx context.Context, conn bun.Conn, tableName string, models interface{}) error {
+	slice := reflect.Indirect(reflect.ValueOf(models))
+	if slice.Kind() != reflect.Slice {
+		return fmt.Errorf("copy from %T: slice is expected", models)
+	}
+	if slice.Len() == 0 {
+		return nil
+	}
+
+	typ := slice.Type().Elem()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	table := conn.Dialect().Tables().Get(typ)
+	fmter := schema.NewFormatter(conn.Dialect())
+	if tableName == "" {
+		tableName = string(table.SQLName)
+	}
+
+	query := make([]byte, 0, 256)
+	query = append(query, "COPY "...)
+	query = append(query, tableName...)
+	query = append(query, " ("...)
+	for i, f := range table.Fields {
+		if i > 0 {
+			query = append(query, ", "...)
+		}
+		query = append(query, f.SQLName...)
+	}
+	query = append(query, ") FROM STDIN"...)
+
+	buf, err := appendCopyRows(nil, fmter, table, slice)
+	if err != nil {
+		return errors.Wrapf(err, "copy from %T", models)
+	}
+
+	if _, err := pgdriver.CopyFrom(ctx, conn, bytes.NewReader(buf), string(query)); err != nil {
+		return errors.Wrapf(err, "copy to %s", tableName)
+	}
+	return nil
+}
+
+// appendCopyRows writes models from the slice in the text format of COPY.
+func appendCopyRows(buf []byte, fmter schema.Formatter, table *schema.Table, slice reflect.Value) ([]byte, error) {
+	var lit []byte
+	for i := 0; i < slice.Len(); i++ {
+		strct := reflect.Indirect(slice.Index(i))
+		if !strct.IsValid() {
+			return nil, fmt.Errorf("nil model at %d", i)
+		}
+
+		for j, f := range table.Fields {
+			if j > 0 {
+				buf = append(buf, '\t')
+			}
+			if (f.IsPtr && f.HasNilValue(strct)) || (f.NullZero && f.HasZeroValue(strct)) {
+				// bun sets NullZero on fields with the default tag, but COPY does not apply column defaults
+				if f.SQLDefault != "" {
+					buf = appendCopyValue(buf, []byte(f.SQLDefault))
+				} else {
+					buf = append(buf, `\N`...)
+				}
+				continue
+			}
+			lit = f.AppendValue(fmter, lit[:0], strct)
+			buf = appendCopyValue(buf, lit)
+		}
+		buf = append(buf, '\n')
+	}
+
+	return buf, nil
+}
+
+// appendCopyValue converts SQL literal to the text format of COPY.
+func appendCopyValue(b, lit []byte) []byte {
+	if string(lit) == "NULL" {
+		return append(b, `\N`...)
+	}
+
+	quoted := len(lit) >= 2 && lit[0] == '\'' && lit[len(lit)-1] == '\''
+	if quoted {
+		lit = lit[1 : len(lit)-1]
+	}
+
+	for i := 0; i < len(lit); i++ {
+		switch c := lit[i]; c {
+		case '\'':
+			if quoted && i+1 < len(lit) && lit[i+1] == '\'' {
+				i++ // quotes are doubled in string literals
+			}
+			b = append(b, c)
+		case '\\':
+			b = append(b, '\\', '\\')
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\r':
+			b = append(b, '\\', 'r')
+		case '\t':
+			b = append(b, '\\', 't')
+		default:
+			b = append(b, c)
+		}
+	}
+
+	return b
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/rndm"
+)
+
+func TestAppendCopyRows(t *testing.T) {
+	d := pgdialect.New()
+
+	copyRow := func(t *testing.T, models any) map[string]string {
+		table := d.Tables().Get(reflect.TypeOf(models).Elem().Elem())
+
+		buf, err := appendCopyRows(nil, schema.NewFormatter(d), table, reflect.ValueOf(models))
+		require.Nil(t, err)
+
+		values := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\t")
+		require.Len(t, values, len(table.Fields))
+
+		row := make(map[string]string)
+		for i, f := range table.Fields {
+			row[f.Name] = values[i]
+		}
+		return row
+	}
+
+	t.Run("zero values of fields with default", func(t *testing.T) {
+		b := rndm.Block(0)
+		b.FilteredTransactionsCount, b.FilteredMessagesCount, b.FilteredAccountsCount = 0, 0, 3
+
+		row := copyRow(t, []*core.Block{b})
+		require.Equal(t, "0", row["filtered_transactions_count"])
+		require.Equal(t, "0", row["filtered_messages_count"])
+		require.Equal(t, "3", row["filtered_accounts_count"])
+	})
+
+	t.Run("zero values of nullzero fields", func(t *testing.T) {
+		m := rndm.MessageTo(rndm.Address())
+		m.SrcAddress = addr.Address{}
+		m.SchemaVersion = 0
+
+		row := copyRow(t, []*core.Message{m})
+		require.Equal(t, `\N`, row["src_address"])
+		require.Equal(t, `\N`, row["schema_version"])
+	})
+
+	t.Run("nil model", func(t *testing.T) {
+		_, err := appendCopyRows(nil, schema.NewFormatter(d), d.Tables().Get(reflect.TypeOf(core.Block{})), reflect.ValueOf([]*core.Block{nil}))
+		require.Error(t, err)
+	})
+}
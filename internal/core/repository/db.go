@@ -13,16 +13,18 @@ import (
 )
 
 type DB struct {
-	CH *ch.DB
+	CH *ch.DB // nil in PostgreSQL-only mode
 	PG *bun.DB
 }
 
 func (db *DB) Close() {
-	_ = db.CH.Close()
+	if db.CH != nil {
+		_ = db.CH.Close()
+	}
 	_ = db.PG.Close()
 }
 
-func ConnectDB(ctx context.Context, dsnCH, dsnPG string, opts ...ch.Option) (*DB, error) {
+func connectCH(ctx context.Context, dsnCH string, opts ...ch.Option) (*ch.DB, error) {
 	var err error
 
 	opts = append(opts, ch.WithDSN(dsnCH), ch.WithAutoCreateDatabase(true), ch.WithPoolSize(16))
@@ -39,6 +41,25 @@ func ConnectDB(ctx context.Context, dsnCH, dsnPG string, opts ...ch.Option) (*DB
 		return nil, errors.Wrap(err, "cannot ping ch")
 	}
 
+	return chDB, nil
+}
+
+// ConnectDB connects to both databases.
+// If ClickHouse DSN is empty, repositories work in PostgreSQL-only mode:
+// ClickHouse writes are skipped, and aggregations are done on PostgreSQL.
+func ConnectDB(ctx context.Context, dsnCH, dsnPG string, opts ...ch.Option) (*DB, error) {
+	var (
+		chDB *ch.DB
+		err  error
+	)
+
+	if dsnCH != "" {
+		chDB, err = connectCH(ctx, dsnCH, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	sqlDB := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsnPG)))
 	pgDB := bun.NewDB(sqlDB, pgdialect.New())
 
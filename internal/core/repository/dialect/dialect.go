@@ -0,0 +1,186 @@
+// Package dialect helps to build the same select queries for ClickHouse and PostgreSQL.
+package dialect
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/go-clickhouse/ch"
+
+	"github.com/tonindexer/anton/internal/core/aggregate/history"
+)
+
+// Query is implemented by both *ch.SelectQuery and *bun.SelectQuery.
+type Query[Q any] interface {
+	Model(model any) Q
+	Column(columns ...string) Q
+	ColumnExpr(query string, args ...any) Q
+	TableExpr(query string, args ...any) Q
+	DistinctOn(query string, args ...any) Q
+	Where(query string, args ...any) Q
+	WhereOr(query string, args ...any) Q
+	WhereGroup(sep string, fn func(Q) Q) Q
+	Group(columns ...string) Q
+	GroupExpr(group string, args ...any) Q
+	Having(having string, args ...any) Q
+	Order(orders ...string) Q
+	OrderExpr(order string, args ...any) Q
+	Limit(limit int) Q
+	Scan(ctx context.Context, dest ...any) error
+	Count(ctx context.Context) (int, error)
+}
+
+var (
+	_ Query[*ch.SelectQuery]  = (*ch.SelectQuery)(nil)
+	_ Query[*bun.SelectQuery] = (*bun.SelectQuery)(nil)
+)
+
+// Dialect returns expressions, which differ between the databases.
+type Dialect struct {
+	ClickHouse bool
+}
+
+var (
+	CH = &Dialect{ClickHouse: true}
+	PG = &Dialect{}
+)
+
+// In formats a slice for the `column IN (?)` condition.
+func (d *Dialect) In(slice any) any {
+	if d.ClickHouse {
+		return ch.In(slice)
+	}
+	return bun.In(slice)
+}
+
+// Array formats a slice as an array value.
+func (d *Dialect) Array(slice any) any {
+	if d.ClickHouse {
+		return ch.Array(slice)
+	}
+	return pgdialect.Array(slice)
+}
+
+// ArrayDest wraps a pointer to a slice to scan an array column into it.
+func (d *Dialect) ArrayDest(dest any) any {
+	if d.ClickHouse {
+		return dest
+	}
+	return pgdialect.Array(dest)
+}
+
+// Count returns the expression counting all rows.
+func (d *Dialect) Count() string {
+	if d.ClickHouse {
+		return "count()"
+	}
+	return "count(*)"
+}
+
+// CountDistinct returns the expression counting distinct values of the given columns.
+func (d *Dialect) CountDistinct(columns string) string {
+	if d.ClickHouse {
+		return "uniqExact(" + columns + ")"
+	}
+	return "count(DISTINCT (" + columns + "))"
+}
+
+// Sum returns the sum of the column values, which is zero on no rows.
+func (d *Dialect) Sum(column string) string {
+	return d.Coalesce("sum("+column+")", "0")
+}
+
+// Any returns an arbitrary value of the column in the group.
+func (d *Dialect) Any(column string) string {
+	if d.ClickHouse {
+		return "any(" + column + ")"
+	}
+	return "min(" + column + ")"
+}
+
+// Coalesce replaces the NULL value of the expression with the given zero value.
+// ClickHouse columns are not nullable, and aggregate functions return zero on no rows.
+func (d *Dialect) Coalesce(expr, zero string) string {
+	if d.ClickHouse {
+		return expr
+	}
+	return "coalesce(" + expr + ", " + zero + ")"
+}
+
+// Length returns the number of elements in the array column.
+func (d *Dialect) Length(column string) string {
+	if d.ClickHouse {
+		return "length(" + column + ")"
+	}
+	return "coalesce(cardinality(" + column + "), 0)"
+}
+
+// HasAny returns the condition checking that the array column
+// has any element of the array given as a query argument.
+func (d *Dialect) HasAny(column string) string {
+	if d.ClickHouse {
+		return "hasAny(" + column + ", ?)"
+	}
+	return column + " && ?"
+}
+
+// HasAll returns the condition checking that the array column
+// has all elements of the array given as a query argument.
+func (d *Dialect) HasAll(column string) string {
+	if d.ClickHouse {
+		return "hasAll(" + column + ", ?)"
+	}
+	return column + " @> ?"
+}
+
+// Has returns the condition checking that the array column has the element given as a query argument.
+func (d *Dialect) Has(column string) string {
+	if d.ClickHouse {
+		return "has(" + column + ", ?)"
+	}
+	return "? = ANY(" + column + ")"
+}
+
+// Rounding returns the expression rounding down the time column to the given interval.
+func (d *Dialect) Rounding(interval time.Duration, column string) (string, error) {
+	rounding := history.GetPGRoundingFunction
+	if d.ClickHouse {
+		rounding = history.GetRoundingFunction
+	}
+	f, err := rounding(interval)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(f, column), nil
+}
+
+// LastValue returns the column value from the row with the greatest `by` column.
+// It must be used together with LatestBy.
+func (d *Dialect) LastValue(column, by string) string {
+	if d.ClickHouse {
+		return "argMax(" + column + ", " + by + ")"
+	}
+	return column
+}
+
+// OverKey returns the aggregate over all rows with the same key.
+// It must be used together with LatestBy.
+func (d *Dialect) OverKey(aggregate, key string) string {
+	if d.ClickHouse {
+		return aggregate
+	}
+	return aggregate + " OVER (PARTITION BY " + key + ")"
+}
+
+// LatestBy leaves one row with the greatest `by` column for every key.
+// ClickHouse groups rows by the key and takes values with argMax,
+// PostgreSQL selects distinct keys ordered by the `by` column.
+func LatestBy[Q Query[Q]](q Q, d *Dialect, key, by string) Q {
+	if d.ClickHouse {
+		return q.Group(key)
+	}
+	return q.DistinctOn(key).OrderExpr(key + ", " + by + " DESC")
+}
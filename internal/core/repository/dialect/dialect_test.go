@@ -0,0 +1,32 @@
+package dialect
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+func latestOwners[Q Query[Q]](q Q, d *Dialect) Q {
+	q = q.
+		TableExpr("account_states").
+		ColumnExpr(d.LastValue("owner_address", "last_tx_lt")+" AS owner_address").
+		Where(d.HasAny("types"), d.Array([]string{"nft_item"}))
+	return LatestBy(q, d, "address", "last_tx_lt")
+}
+
+func TestLatestBy(t *testing.T) {
+	ck := ch.Connect(ch.WithDSN("clickhouse://localhost:9000/default"))
+	pg := bun.NewDB(sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN("postgres://localhost:5432/postgres"))), pgdialect.New())
+
+	require.Equal(t,
+		"SELECT argMax(owner_address, last_tx_lt) AS owner_address FROM account_states WHERE (hasAny(types, ['nft_item'])) GROUP BY \"address\"",
+		latestOwners(ck.NewSelect(), CH).String())
+	require.Equal(t,
+		`SELECT DISTINCT ON (address) owner_address AS owner_address FROM account_states WHERE (types && '{"nft_item"}') ORDER BY address, last_tx_lt DESC`,
+		latestOwners(pg.NewSelect(), PG).String())
+}
@@ -0,0 +1,168 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/internal/core"
+	"github.com/tonindexer/anton/internal/core/aggregate"
+	"github.com/tonindexer/anton/internal/core/repository/contract"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/repotest"
+	"github.com/tonindexer/anton/internal/core/rndm"
+)
+
+// normalizeDiscovered drops the values, which are chosen arbitrarily by the databases
+func normalizeDiscovered(t *testing.T, res *aggregate.DiscoverContractsRes) {
+	for _, c := range res.Results {
+		c.FirstSeen, c.LastSeen = c.FirstSeen.UTC().Truncate(time.Second), c.LastSeen.UTC().Truncate(time.Second)
+		for _, op := range append(c.InOperations, c.OutOperations...) {
+			require.NotEmpty(t, op.SampleBody)
+			op.SampleBody = nil
+		}
+	}
+}
+
+func TestDiscoverContracts(t *testing.T) {
+	initDB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const opIn, opOut = 0x10000001, 0x10000002
+
+	// three accounts with the same unknown code
+	codeHash := rndm.Bytes(32)
+	getMethods := []int32{abi.MethodNameHash("seqno"), 12345, abi.MethodNameHash("get_discovered")}
+
+	var (
+		states       []*core.AccountState
+		messages     []*core.Message
+		transactions []*core.Transaction
+	)
+	for i := 0; i < 3; i++ {
+		a := rndm.Address()
+
+		s := rndm.AddressState(a, nil, nil)
+		s.CodeHash, s.GetMethodHashes = codeHash, getMethods
+		states = append(states, s)
+
+		in := rndm.MessageTo(a)
+		in.OperationID = opIn
+		messages = append(messages, in)
+
+		transactions = append(transactions, rndm.AddressTransactions(a, 2)...)
+
+		if i > 0 {
+			continue
+		}
+		for _, out := range rndm.MessagesFrom(a, 2) {
+			out.OperationID = opOut
+			messages = append(messages, out)
+		}
+	}
+	// single account with other unknown code
+	states = append(states, rndm.AddressState(rndm.Address(), nil, nil))
+	// accounts with known interfaces are not reported
+	known := rndm.AddressStateContract(rndm.Address(), "special", nil)
+	known.CodeHash = codeHash
+	states = append(states, known)
+
+	t.Run("drop tables", func(t *testing.T) {
+		dropTables(t)
+	})
+
+	t.Run("create tables", func(t *testing.T) {
+		createTables(t)
+	})
+
+	t.Run("insert data", func(t *testing.T) {
+		err := contract.NewRepository(db.PG).AddInterface(ctx, db.PG, &core.ContractInterface{
+			Name:           "discover_test",
+			GetMethodsDesc: []abi.GetMethodDesc{{Name: "get_discovered"}},
+		})
+		require.Nil(t, err)
+
+		dbtx, err := db.PG.Begin()
+		require.Nil(t, err)
+
+		err = accountRepo.AddAccountStates(ctx, dbtx, states)
+		require.Nil(t, err)
+		err = msgRepo.AddMessages(ctx, dbtx, messages)
+		require.Nil(t, err)
+		err = txRepo.AddTransactions(ctx, dbtx, transactions)
+		require.Nil(t, err)
+
+		err = dbtx.Commit()
+		require.Nil(t, err)
+
+		err = outbox.NewRepository(db.CH, db.PG).WriteBatch(ctx, &core.OutboxBatch{
+			Accounts:     states,
+			Messages:     messages,
+			Transactions: transactions,
+		})
+		require.Nil(t, err)
+	})
+
+	req := aggregate.DiscoverContractsReq{
+		Since:       time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		MinAccounts: 2,
+	}
+
+	t.Run("discover contracts", func(t *testing.T) {
+		res, err := aggregate.DiscoverContracts(ctx, repoCH, db.PG, &req)
+		require.Nil(t, err)
+		require.Len(t, res.Results, 1)
+
+		c := res.Results[0]
+		require.Equal(t, codeHash, c.CodeHash)
+		require.Equal(t, 3, c.AccountCount)
+		require.Equal(t, 6, c.TransactionCount)
+		require.Equal(t, states[0].UpdatedAt.UTC().Truncate(time.Second), c.FirstSeen.UTC().Truncate(time.Second))
+		require.Equal(t, states[2].UpdatedAt.UTC().Truncate(time.Second), c.LastSeen.UTC().Truncate(time.Second))
+		require.Equal(t, []aggregate.DiscoveredGetMethod{
+			{Hash: abi.MethodNameHash("get_discovered"), Name: "get_discovered"},
+			{Hash: abi.MethodNameHash("seqno"), Name: "seqno"},
+			{Hash: 12345},
+		}, c.GetMethods)
+
+		require.Len(t, c.InOperations, 1)
+		require.Equal(t, uint32(opIn), c.InOperations[0].OperationID)
+		require.Equal(t, 3, c.InOperations[0].Count)
+		require.Len(t, c.OutOperations, 1)
+		require.Equal(t, uint32(opOut), c.OutOperations[0].OperationID)
+		require.Equal(t, 2, c.OutOperations[0].Count)
+	})
+
+	t.Run("single account clusters", func(t *testing.T) {
+		res, err := aggregate.DiscoverContracts(ctx, repoCH, db.PG, &aggregate.DiscoverContractsReq{
+			Since: req.Since,
+		})
+		require.Nil(t, err)
+		require.Len(t, res.Results, 2)
+		require.Equal(t, 3, res.Results[0].AccountCount)
+		require.Equal(t, 1, res.Results[1].AccountCount)
+		require.Equal(t, states[3].CodeHash, res.Results[1].CodeHash)
+	})
+
+	t.Run("clickhouse and postgres results are the same", func(t *testing.T) {
+		repotest.SkipPGOnly(t)
+
+		resCH, err := aggregate.DiscoverContracts(ctx, db.CH, db.PG, &req)
+		require.Nil(t, err)
+		resPG, err := aggregate.DiscoverContracts(ctx, nil, db.PG, &req)
+		require.Nil(t, err)
+
+		normalizeDiscovered(t, resCH)
+		normalizeDiscovered(t, resPG)
+		require.Equal(t, resCH, resPG)
+	})
+
+	t.Run("drop tables again", func(t *testing.T) {
+		dropTables(t)
+	})
+}
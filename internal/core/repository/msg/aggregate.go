@@ -4,20 +4,17 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
-	"github.com/uptrace/go-clickhouse/ch"
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
-func (r *Repository) AggregateMessages(ctx context.Context, req *aggregate.MessagesReq) (*aggregate.MessagesRes, error) {
+func aggregateMessages[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect, req *aggregate.MessagesReq) (*aggregate.MessagesRes, error) {
 	var res aggregate.MessagesRes
 
-	if req.Address == nil {
-		return nil, errors.Wrap(core.ErrInvalidArg, "address must be set")
-	}
-
-	addTimestampFilter := func(q *ch.SelectQuery) *ch.SelectQuery {
+	selectMessages := func() Q {
+		q := newSelect().Model((*core.Message)(nil))
 		if !req.From.IsZero() {
 			q = q.Where("created_at > ?", req.From)
 		}
@@ -27,33 +24,33 @@ func (r *Repository) AggregateMessages(ctx context.Context, req *aggregate.Messa
 		return q
 	}
 
-	err := addTimestampFilter(r.ch.NewSelect().Model((*core.Message)(nil)).
-		ColumnExpr("count() as recv_count").
-		ColumnExpr("sum(amount) as recv_amount").
-		Where("dst_address = ?", req.Address)).
+	err := selectMessages().
+		ColumnExpr(d.Count()+" as recv_count").
+		ColumnExpr(d.Sum("amount")+" as recv_amount").
+		Where("dst_address = ?", req.Address).
 		Scan(ctx, &res.RecvCount, &res.RecvAmount)
 	if err != nil {
 		return nil, errors.Wrap(err, "received total")
 	}
 
-	err = addTimestampFilter(r.ch.NewSelect().Model((*core.Message)(nil)).
-		ColumnExpr("count() as sent_count").
-		ColumnExpr("sum(amount) as sent_amount").
-		Where("src_address = ?", req.Address)).
+	err = selectMessages().
+		ColumnExpr(d.Count()+" as sent_count").
+		ColumnExpr(d.Sum("amount")+" as sent_amount").
+		Where("src_address = ?", req.Address).
 		Scan(ctx, &res.SentCount, &res.SentAmount)
 	if err != nil {
 		return nil, errors.Wrap(err, "sent total")
 	}
 
-	err = r.ch.NewSelect().
+	err = newSelect().
 		ColumnExpr("src_address as sender").
-		ColumnExpr("count() as count").
-		ColumnExpr("sum(sent_amount) as amount").
+		ColumnExpr(d.Count()+" as count").
+		ColumnExpr(d.Sum("sent_amount")+" as amount").
 		TableExpr("(?) as q",
-			addTimestampFilter(r.ch.NewSelect().Model((*core.Message)(nil)).
+			selectMessages().
 				ColumnExpr("src_address").
 				ColumnExpr("amount as sent_amount").
-				Where("dst_address = ?", req.Address))).
+				Where("dst_address = ?", req.Address)).
 		Group("src_address").
 		Order(req.OrderBy+" DESC").
 		Limit(req.Limit).
@@ -62,22 +59,33 @@ func (r *Repository) AggregateMessages(ctx context.Context, req *aggregate.Messa
 		return nil, errors.Wrap(err, "received by address")
 	}
 
-	err = r.ch.NewSelect().
+	err = newSelect().
 		ColumnExpr("dst_address as receiver").
-		ColumnExpr("count() as count").
-		ColumnExpr("sum(sent_amount) as amount").
+		ColumnExpr(d.Count()+" as count").
+		ColumnExpr(d.Sum("sent_amount")+" as amount").
 		TableExpr("(?) as q",
-			addTimestampFilter(r.ch.NewSelect().Model((*core.Message)(nil)).
+			selectMessages().
 				ColumnExpr("dst_address").
 				ColumnExpr("amount as sent_amount").
-				Where("src_address = ?", req.Address))).
+				Where("src_address = ?", req.Address)).
 		Group("dst_address").
 		Order(req.OrderBy+" DESC").
 		Limit(req.Limit).
 		Scan(ctx, &res.SentByAddress)
 	if err != nil {
-		return nil, errors.Wrap(err, "received by address")
+		return nil, errors.Wrap(err, "sent by address")
 	}
 
 	return &res, nil
 }
+
+func (r *Repository) AggregateMessages(ctx context.Context, req *aggregate.MessagesReq) (*aggregate.MessagesRes, error) {
+	if req.Address == nil {
+		return nil, errors.Wrap(core.ErrInvalidArg, "address must be set")
+	}
+
+	if r.ch == nil {
+		return aggregateMessages(ctx, r.pg.NewSelect, dialect.PG, req)
+	}
+	return aggregateMessages(ctx, r.ch.NewSelect, dialect.CH, req)
+}
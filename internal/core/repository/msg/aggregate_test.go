@@ -10,7 +10,11 @@ import (
 	"github.com/uptrace/bun/extra/bunbig"
 
 	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate"
+	"github.com/tonindexer/anton/internal/core/repository/msg"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/repotest"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
@@ -52,12 +56,19 @@ func TestRepository_AggregateMessages(t *testing.T) {
 		tx, err := pg.Begin()
 		require.Nil(t, err)
 
-		err = repo.AddMessages(ctx, tx, rndm.Messages(100))
+		messages := rndm.Messages(100)
+		err = repo.AddMessages(ctx, tx, messages)
+		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Messages: messages})
 		require.Nil(t, err)
 		err = repo.AddMessages(ctx, tx, messagesTo)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Messages: messagesTo})
+		require.Nil(t, err)
 		err = repo.AddMessages(ctx, tx, messagesFrom)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Messages: messagesFrom})
+		require.Nil(t, err)
 
 		err = tx.Commit()
 		require.Nil(t, err)
@@ -87,4 +98,20 @@ func TestRepository_AggregateMessages(t *testing.T) {
 			require.Equal(t, 1, r.Count)
 		}
 	})
+
+	t.Run("clickhouse and postgres results are the same", func(t *testing.T) {
+		repotest.SkipPGOnly(t)
+
+		req := aggregate.MessagesReq{
+			Address: address,
+			OrderBy: "amount",
+			Limit:   150,
+		}
+		resCH, err := msg.NewRepository(ck, pg).AggregateMessages(ctx, &req)
+		require.Nil(t, err)
+		resPG, err := msg.NewRepository(nil, pg).AggregateMessages(ctx, &req)
+		require.Nil(t, err)
+
+		repotest.RequireSameResults(t, resCH, resPG)
+	})
 }
@@ -5,10 +5,10 @@ import (
 	"strings"
 
 	"github.com/uptrace/bun"
-	"github.com/uptrace/go-clickhouse/ch"
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
 func (r *Repository) filterMsg(ctx context.Context, req *filter.MessagesReq) (ret []*core.Message, err error) {
@@ -68,18 +68,15 @@ func (r *Repository) filterMsg(ctx context.Context, req *filter.MessagesReq) (re
 	return ret, err
 }
 
-func (r *Repository) countMsg(ctx context.Context, req *filter.MessagesReq) (int, error) {
-	q := r.ch.NewSelect().
-		Model((*core.Message)(nil))
-
+func countMsgQuery[Q dialect.Query[Q]](q Q, d *dialect.Dialect, req *filter.MessagesReq) Q {
 	if len(req.Hash) > 0 {
 		q = q.Where("hash = ?", req.Hash)
 	}
 	if len(req.SrcAddresses) > 0 {
-		q = q.Where("src_address in (?)", ch.In(req.SrcAddresses))
+		q = q.Where("src_address in (?)", d.In(req.SrcAddresses))
 	}
 	if len(req.DstAddresses) > 0 {
-		q = q.Where("dst_address in (?)", ch.In(req.DstAddresses))
+		q = q.Where("dst_address in (?)", d.In(req.DstAddresses))
 	}
 	if req.SrcWorkchain != nil {
 		q = q.Where("src_workchain = ?", *req.SrcWorkchain)
@@ -91,16 +88,22 @@ func (r *Repository) countMsg(ctx context.Context, req *filter.MessagesReq) (int
 		q = q.Where("operation_id = ?", *req.OperationID)
 	}
 	if len(req.SrcContracts) > 0 {
-		q = q.Where("src_contract IN (?)", ch.In(req.SrcContracts))
+		q = q.Where("src_contract IN (?)", d.In(req.SrcContracts))
 	}
 	if len(req.DstContracts) > 0 {
-		q = q.Where("dst_contract IN (?)", ch.In(req.DstContracts))
+		q = q.Where("dst_contract IN (?)", d.In(req.DstContracts))
 	}
 	if len(req.OperationNames) > 0 {
-		q = q.Where("operation_name IN (?)", ch.In(req.OperationNames))
+		q = q.Where("operation_name IN (?)", d.In(req.OperationNames))
 	}
+	return q
+}
 
-	return q.Count(ctx)
+func (r *Repository) countMsg(ctx context.Context, req *filter.MessagesReq) (int, error) {
+	if r.ch == nil {
+		return countMsgQuery(r.pg.NewSelect().Model((*core.Message)(nil)), dialect.PG, req).Count(ctx)
+	}
+	return countMsgQuery(r.ch.NewSelect().Model((*core.Message)(nil)), dialect.CH, req).Count(ctx)
 }
 
 func (r *Repository) FilterMessages(ctx context.Context, req *filter.MessagesReq) (*filter.MessagesRes, error) {
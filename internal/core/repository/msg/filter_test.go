@@ -10,6 +10,7 @@ import (
 	"github.com/tonindexer/anton/addr"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
@@ -41,6 +42,8 @@ func TestRepository_FilterMessages(t *testing.T) {
 
 		err = repo.AddMessages(ctx, tx, messages)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Messages: messages})
+		require.Nil(t, err)
 
 		err = tx.Commit()
 		require.Nil(t, err)
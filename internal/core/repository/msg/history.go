@@ -2,21 +2,20 @@ package msg
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/pkg/errors"
-	"github.com/uptrace/go-clickhouse/ch"
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate/history"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
-func addMessagesHistoryFilters(q *ch.SelectQuery, req *history.MessagesReq) *ch.SelectQuery {
+func addMessagesHistoryFilters[Q dialect.Query[Q]](q Q, d *dialect.Dialect, req *history.MessagesReq) Q {
 	if len(req.SrcAddresses) > 0 {
-		q = q.Where("src_address in (?)", ch.In(req.SrcAddresses))
+		q = q.Where("src_address in (?)", d.In(req.SrcAddresses))
 	}
 	if len(req.DstAddresses) > 0 {
-		q = q.Where("dst_address in (?)", ch.In(req.DstAddresses))
+		q = q.Where("dst_address in (?)", d.In(req.DstAddresses))
 	}
 	if req.SrcWorkchain != nil {
 		q = q.Where("src_workchain = ?", *req.SrcWorkchain)
@@ -25,13 +24,13 @@ func addMessagesHistoryFilters(q *ch.SelectQuery, req *history.MessagesReq) *ch.
 		q = q.Where("dst_workchain = ?", *req.DstWorkchain)
 	}
 	if len(req.SrcContracts) > 0 {
-		q = q.Where("src_contract IN (?)", ch.In(req.SrcContracts))
+		q = q.Where("src_contract IN (?)", d.In(req.SrcContracts))
 	}
 	if len(req.DstContracts) > 0 {
-		q = q.Where("dst_contract IN (?)", ch.In(req.DstContracts))
+		q = q.Where("dst_contract IN (?)", d.In(req.DstContracts))
 	}
 	if len(req.OperationNames) > 0 {
-		q = q.Where("operation_name IN (?)", ch.In(req.OperationNames))
+		q = q.Where("operation_name IN (?)", d.In(req.OperationNames))
 	}
 	if req.MinterAddress != nil {
 		q = q.Where("minter_address = ?", req.MinterAddress)
@@ -39,26 +38,26 @@ func addMessagesHistoryFilters(q *ch.SelectQuery, req *history.MessagesReq) *ch.
 	return q
 }
 
-func (r *Repository) AggregateMessagesHistory(ctx context.Context, req *history.MessagesReq) (*history.MessagesRes, error) {
+func aggregateMessagesHistory[Q dialect.Query[Q]](ctx context.Context, q Q, d *dialect.Dialect, req *history.MessagesReq) (*history.MessagesRes, error) {
 	var res history.MessagesRes
 	var bigIntRes bool // do we need to count account_data or account_states
 
-	q := addMessagesHistoryFilters(r.ch.NewSelect().Model((*core.Message)(nil)), req)
+	q = addMessagesHistoryFilters(q, d, req)
 
 	switch req.Metric {
 	case history.MessageCount:
-		q = q.ColumnExpr("count() as value")
+		q = q.ColumnExpr(d.Count() + " as value")
 	case history.MessageAmountSum:
-		q, bigIntRes = q.ColumnExpr("sum(amount) as value"), true
+		q, bigIntRes = q.ColumnExpr(d.Sum("amount")+" as value"), true
 	default:
 		return nil, errors.Wrapf(core.ErrInvalidArg, "invalid message metric %s", req.Metric)
 	}
 
-	rounding, err := history.GetRoundingFunction(req.Interval)
+	rounding, err := d.Rounding(req.Interval, "created_at")
 	if err != nil {
 		return nil, err
 	}
-	q = q.ColumnExpr(fmt.Sprintf(rounding, "created_at") + " as timestamp")
+	q = q.ColumnExpr(rounding + " as timestamp")
 	q = q.Group("timestamp")
 
 	if !req.From.IsZero() {
@@ -82,3 +81,10 @@ func (r *Repository) AggregateMessagesHistory(ctx context.Context, req *history.
 
 	return &res, nil
 }
+
+func (r *Repository) AggregateMessagesHistory(ctx context.Context, req *history.MessagesReq) (*history.MessagesRes, error) {
+	if r.ch == nil {
+		return aggregateMessagesHistory(ctx, r.pg.NewSelect().Model((*core.Message)(nil)), dialect.PG, req)
+	}
+	return aggregateMessagesHistory(ctx, r.ch.NewSelect().Model((*core.Message)(nil)), dialect.CH, req)
+}
@@ -9,7 +9,11 @@ import (
 
 	"github.com/uptrace/bun/extra/bunbig"
 
+	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate/history"
+	"github.com/tonindexer/anton/internal/core/repository/msg"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/repotest"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
@@ -46,6 +50,8 @@ func TestRepository_AggregateMessagesHistory(t *testing.T) {
 
 		err = repo.AddMessages(ctx, tx, messages)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Messages: messages})
+		require.Nil(t, err)
 
 		err = tx.Commit()
 		require.Nil(t, err)
@@ -79,6 +85,35 @@ func TestRepository_AggregateMessagesHistory(t *testing.T) {
 		require.Equal(t, amountSum, res.BigIntRes[0].Value)
 	})
 
+	t.Run("clickhouse and postgres results are the same", func(t *testing.T) {
+		repotest.SkipPGOnly(t)
+
+		for _, metric := range []history.MessageMetric{history.MessageCount, history.MessageAmountSum} {
+			req := history.MessagesReq{
+				Metric:       metric,
+				DstContracts: []string{"special"},
+				ReqParams: history.ReqParams{
+					From:     time.Now().Add(-time.Minute),
+					Interval: 24 * time.Hour,
+				},
+			}
+			resCH, err := msg.NewRepository(ck, pg).AggregateMessagesHistory(ctx, &req)
+			require.Nil(t, err)
+			resPG, err := msg.NewRepository(nil, pg).AggregateMessagesHistory(ctx, &req)
+			require.Nil(t, err)
+
+			for _, res := range []*history.MessagesRes{resCH, resPG} {
+				for i := range res.CountRes {
+					res.CountRes[i].Timestamp = res.CountRes[i].Timestamp.UTC()
+				}
+				for i := range res.BigIntRes {
+					res.BigIntRes[i].Timestamp = res.BigIntRes[i].Timestamp.UTC()
+				}
+			}
+			repotest.RequireSameResults(t, resCH, resPG)
+		}
+	})
+
 	t.Run("drop tables again", func(t *testing.T) {
 		dropTables(t)
 	})
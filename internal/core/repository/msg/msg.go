@@ -15,6 +15,7 @@ import (
 	"github.com/tonindexer/anton/addr"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/repository"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
 var _ repository.Message = (*Repository)(nil)
@@ -132,13 +133,15 @@ func CreateTables(ctx context.Context, chDB *ch.DB, pgDB *bun.DB) error {
 		return errors.Wrap(err, "messages pg create enum")
 	}
 
-	_, err = chDB.NewCreateTable().
-		IfNotExists().
-		Engine("ReplacingMergeTree").
-		Model(&core.Message{}).
-		Exec(ctx)
-	if err != nil {
-		return errors.Wrap(err, "message ch create table")
+	if chDB != nil {
+		_, err = chDB.NewCreateTable().
+			IfNotExists().
+			Engine("ReplacingMergeTree").
+			Model(&core.Message{}).
+			Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "message ch create table")
+		}
 	}
 
 	_, err = pgDB.NewCreateTable().
@@ -191,15 +194,80 @@ func (r *Repository) AddMessages(ctx context.Context, tx bun.Tx, messages []*cor
 			Set("operation_name = ?operation_name").
 			Set("data_json = ?data_json").
 			Set("error = ?error").
+			Set("schema_version = ?schema_version").
 			Exec(ctx)
 		if err != nil {
 			return err
 		}
 	}
-	_, err := r.ch.NewInsert().Model(&messages).Exec(ctx)
+	return nil
+}
+
+// uniqMessages merges messages with the same hash in the same way as the upsert in AddMessages,
+// as a single INSERT cannot update a row twice.
+func uniqMessages(messages []*core.Message) []*core.Message {
+	var (
+		ret = make([]*core.Message, 0, len(messages))
+		idx = make(map[string]int, len(messages))
+	)
+
+	for _, msg := range messages {
+		i, ok := idx[string(msg.Hash)]
+		if !ok {
+			idx[string(msg.Hash)] = len(ret)
+			ret = append(ret, msg)
+			continue
+		}
+
+		merged := *ret[i] // rows of the outbox batch are not changed
+		merged.DstTxLT, merged.DstWorkchain, merged.DstShard, merged.DstBlockSeqNo =
+			msg.DstTxLT, msg.DstWorkchain, msg.DstShard, msg.DstBlockSeqNo
+		merged.SrcContract, merged.DstContract = msg.SrcContract, msg.DstContract
+		merged.OperationName, merged.DataJSON, merged.Error, merged.SchemaVersion =
+			msg.OperationName, msg.DataJSON, msg.Error, msg.SchemaVersion
+		ret[i] = &merged
+	}
+
+	return ret
+}
+
+func (r *Repository) CopyMessages(ctx context.Context, conn bun.Conn, messages []*core.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	_, err := conn.ExecContext(ctx, "CREATE TEMPORARY TABLE messages_copy (LIKE messages INCLUDING DEFAULTS) ON COMMIT DROP")
 	if err != nil {
+		return errors.Wrap(err, "create messages copy table")
+	}
+
+	if err := repository.CopyFromTable(ctx, conn, "messages_copy", uniqMessages(messages)); err != nil {
 		return err
 	}
+
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO messages
+		SELECT * FROM messages_copy
+		ON CONFLICT (hash) DO UPDATE SET
+			dst_tx_lt = EXCLUDED.dst_tx_lt,
+			dst_workchain = EXCLUDED.dst_workchain,
+			dst_shard = EXCLUDED.dst_shard,
+			dst_block_seq_no = EXCLUDED.dst_block_seq_no,
+			src_contract = EXCLUDED.src_contract,
+			dst_contract = EXCLUDED.dst_contract,
+			operation_name = EXCLUDED.operation_name,
+			data_json = EXCLUDED.data_json,
+			error = EXCLUDED.error,
+			schema_version = EXCLUDED.schema_version`)
+	if err != nil {
+		return errors.Wrap(err, "upsert copied messages")
+	}
+
+	_, err = conn.ExecContext(ctx, "DROP TABLE messages_copy")
+	if err != nil {
+		return errors.Wrap(err, "drop messages copy table")
+	}
+
 	return nil
 }
 
@@ -227,6 +295,7 @@ func (r *Repository) UpdateMessages(ctx context.Context, messages []*core.Messag
 			Set("operation_name = ?operation_name").
 			Set("data_json = ?data_json").
 			Set("error = ?error").
+			Set("schema_version = ?schema_version").
 			WherePK().
 			Exec(ctx)
 		if err != nil {
@@ -234,6 +303,10 @@ func (r *Repository) UpdateMessages(ctx context.Context, messages []*core.Messag
 		}
 	}
 
+	if r.ch == nil {
+		return nil
+	}
+
 	_, err := r.ch.NewInsert().Model(&messages).Exec(ctx)
 	if err != nil {
 		return err
@@ -276,8 +349,7 @@ func (r *Repository) GetMessages(ctx context.Context, hashes [][]byte) ([]*core.
 	return ret, nil
 }
 
-// MatchMessagesByOperationDesc returns hashes of suitable messages for the given contract operation.
-func (r *Repository) MatchMessagesByOperationDesc(ctx context.Context,
+func matchMessagesByOperationDesc[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect,
 	contractName abi.ContractName,
 	msgType core.MessageType,
 	outgoing bool,
@@ -290,9 +362,9 @@ func (r *Repository) MatchMessagesByOperationDesc(ctx context.Context,
 		Address *addr.Address `ch:"type:String"`
 	}
 
-	q := r.ch.NewSelect().Model((*core.AccountState)(nil)).
+	q := newSelect().Model((*core.AccountState)(nil)).
 		ColumnExpr("DISTINCT address").
-		Where("hasAny(types, [?])", string(contractName))
+		Where(d.Has("types"), string(contractName))
 	if afterAddress != nil {
 		q = q.Where("address >= ?", afterAddress)
 	}
@@ -303,6 +375,9 @@ func (r *Repository) MatchMessagesByOperationDesc(ctx context.Context,
 	if err != nil {
 		return nil, errors.Wrap(err, "get contract addresses")
 	}
+	if len(addressesRet) == 0 {
+		return nil, nil
+	}
 
 	var addresses []*addr.Address
 	for _, row := range addressesRet {
@@ -314,13 +389,20 @@ func (r *Repository) MatchMessagesByOperationDesc(ctx context.Context,
 		addrCol, ltCol = "src_address", "src_tx_lt"
 	}
 
+	// message hashes are unique in postgres,
+	// but clickhouse can store duplicates until the table parts are merged
+	hashCol := "hash"
+	if d.ClickHouse {
+		hashCol = "DISTINCT hash"
+	}
+
 	var msgHashesRet []struct {
 		Hash []byte
 	}
-	q = r.ch.NewSelect().Model((*core.Message)(nil)).
-		ColumnExpr("DISTINCT hash").
+	q = newSelect().Model((*core.Message)(nil)).
+		ColumnExpr(hashCol).
 		Where("type = ?", string(msgType)).
-		Where(addrCol+" IN (?)", ch.In(addresses)).
+		Where(addrCol+" IN (?)", d.In(addresses)).
 		Where("operation_id = ?", operationId)
 	if afterAddress != nil && afterTxLt != 0 {
 		q = q.Where(fmt.Sprintf("(%s, %s) > (?, ?)", addrCol, ltCol), afterAddress, afterTxLt)
@@ -333,9 +415,170 @@ func (r *Repository) MatchMessagesByOperationDesc(ctx context.Context,
 		return nil, errors.Wrap(err, "get message hashes")
 	}
 
-	var hashes [][]byte
-	for _, row := range msgHashesRet {
-		hashes = append(hashes, row.Hash)
+	return messageHashes(msgHashesRet), nil
+}
+
+// MatchMessagesByOperationDesc returns hashes of suitable messages for the given contract operation.
+func (r *Repository) MatchMessagesByOperationDesc(ctx context.Context,
+	contractName abi.ContractName,
+	msgType core.MessageType,
+	outgoing bool,
+	operationId uint32,
+	afterAddress *addr.Address,
+	afterTxLt uint64,
+	limit int,
+) ([][]byte, error) {
+	if r.ch == nil {
+		return matchMessagesByOperationDesc(ctx, r.pg.NewSelect, dialect.PG, contractName, msgType, outgoing, operationId, afterAddress, afterTxLt, limit)
+	}
+	return matchMessagesByOperationDesc(ctx, r.ch.NewSelect, dialect.CH, contractName, msgType, outgoing, operationId, afterAddress, afterTxLt, limit)
+}
+
+func countMessagesByOperationDesc[Q dialect.Query[Q]](ctx context.Context, newSelect func() Q, d *dialect.Dialect,
+	contractName abi.ContractName,
+	msgType core.MessageType,
+	outgoing bool,
+	operationId uint32,
+) (int, error) {
+	var count int
+
+	addrCol := "dst_address"
+	if outgoing {
+		addrCol = "src_address"
+	}
+
+	addresses := newSelect().Model((*core.AccountState)(nil)).
+		ColumnExpr("DISTINCT address").
+		Where(d.Has("types"), string(contractName))
+
+	err := newSelect().Model((*core.Message)(nil)).
+		ColumnExpr(d.CountDistinct("hash")).
+		Where("type = ?", string(msgType)).
+		Where(addrCol+" IN (?)", addresses).
+		Where("operation_id = ?", operationId).
+		Scan(ctx, &count)
+	if err != nil {
+		return 0, errors.Wrap(err, "count message hashes")
+	}
+
+	return count, nil
+}
+
+// CountMessagesByOperationDesc returns the number of messages matched by MatchMessagesByOperationDesc.
+func (r *Repository) CountMessagesByOperationDesc(ctx context.Context,
+	contractName abi.ContractName,
+	msgType core.MessageType,
+	outgoing bool,
+	operationId uint32,
+) (int, error) {
+	if r.ch == nil {
+		return countMessagesByOperationDesc(ctx, r.pg.NewSelect, dialect.PG, contractName, msgType, outgoing, operationId)
+	}
+	return countMessagesByOperationDesc(ctx, r.ch.NewSelect, dialect.CH, contractName, msgType, outgoing, operationId)
+}
+
+func scopeMessagesQuery[Q dialect.Query[Q]](q Q, d *dialect.Dialect, scope *core.RescanScope) Q {
+	q = q.Model((*core.Message)(nil))
+	if len(scope.MessageHashes) > 0 {
+		q = q.Where("hash IN (?)", d.In(scope.MessageHashes))
+	}
+	if len(scope.Addresses) > 0 {
+		q = q.WhereGroup(" AND ", func(q Q) Q {
+			return q.
+				WhereOr("src_address IN (?)", d.In(scope.Addresses)).
+				WhereOr("dst_address IN (?)", d.In(scope.Addresses))
+		})
+	}
+	if len(scope.Blocks) > 0 {
+		q = q.WhereGroup(" AND ", func(q Q) Q {
+			for _, b := range scope.Blocks {
+				q = q.
+					WhereOr("src_workchain = ? AND src_shard = ? AND src_block_seq_no BETWEEN ? AND ?", b.Workchain, b.Shard, b.FromSeqNo, b.ToSeqNo).
+					WhereOr("dst_workchain = ? AND dst_shard = ? AND dst_block_seq_no BETWEEN ? AND ?", b.Workchain, b.Shard, b.FromSeqNo, b.ToSeqNo)
+			}
+			return q
+		})
+	}
+	if len(scope.AccountRanges) > 0 {
+		q = q.WhereGroup(" AND ", func(q Q) Q {
+			for _, r := range scope.AccountRanges {
+				q = q.
+					WhereOr("src_address = ? AND src_tx_lt >= ? AND (? = 0 OR src_tx_lt < ?)", &r.Address, r.FromTxLT, r.ToTxLT, r.ToTxLT).
+					WhereOr("dst_address = ? AND dst_tx_lt >= ? AND (? = 0 OR dst_tx_lt < ?)", &r.Address, r.FromTxLT, r.ToTxLT, r.ToTxLT)
+			}
+			return q
+		})
+	}
+	if !scope.FromTime.IsZero() {
+		q = q.Where("created_at >= ?", scope.FromTime)
+	}
+	if !scope.ToTime.IsZero() {
+		q = q.Where("created_at < ?", scope.ToTime)
+	}
+	return q
+}
+
+func matchMessagesByScope[Q dialect.Query[Q]](ctx context.Context, q Q, d *dialect.Dialect,
+	scope *core.RescanScope,
+	afterHash []byte,
+	limit int,
+) ([][]byte, error) {
+	var msgHashesRet []struct {
+		Hash []byte
+	}
+
+	q = scopeMessagesQuery(q, d, scope).
+		ColumnExpr("DISTINCT hash")
+	if len(afterHash) > 0 {
+		q = q.Where("hash > ?", afterHash)
+	}
+	err := q.
+		Order("hash ASC").
+		Limit(limit).
+		Scan(ctx, &msgHashesRet)
+	if err != nil {
+		return nil, errors.Wrap(err, "get message hashes")
+	}
+
+	return messageHashes(msgHashesRet), nil
+}
+
+// MatchMessagesByScope returns hashes of messages selected by the targeted rescan scope.
+func (r *Repository) MatchMessagesByScope(ctx context.Context,
+	scope *core.RescanScope,
+	afterHash []byte,
+	limit int,
+) ([][]byte, error) {
+	if r.ch == nil {
+		return matchMessagesByScope(ctx, r.pg.NewSelect(), dialect.PG, scope, afterHash, limit)
+	}
+	return matchMessagesByScope(ctx, r.ch.NewSelect(), dialect.CH, scope, afterHash, limit)
+}
+
+func countMessagesByScope[Q dialect.Query[Q]](ctx context.Context, q Q, d *dialect.Dialect, scope *core.RescanScope) (int, error) {
+	var count int
+
+	err := scopeMessagesQuery(q, d, scope).
+		ColumnExpr(d.CountDistinct("hash")).
+		Scan(ctx, &count)
+	if err != nil {
+		return 0, errors.Wrap(err, "count message hashes")
+	}
+
+	return count, nil
+}
+
+// CountMessagesByScope returns the number of messages matched by MatchMessagesByScope.
+func (r *Repository) CountMessagesByScope(ctx context.Context, scope *core.RescanScope) (int, error) {
+	if r.ch == nil {
+		return countMessagesByScope(ctx, r.pg.NewSelect(), dialect.PG, scope)
+	}
+	return countMessagesByScope(ctx, r.ch.NewSelect(), dialect.CH, scope)
+}
+
+func messageHashes(rows []struct{ Hash []byte }) (ret [][]byte) {
+	for _, row := range rows {
+		ret = append(ret, row.Hash)
 	}
-	return hashes, nil
+	return ret
 }
@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/go-clickhouse/ch"
+
+	"github.com/tonindexer/anton/internal/core"
+)
+
+type blobRow struct {
+	Hash []byte `ch:"type:String"`
+	Blob []byte `ch:"type:String"`
+}
+
+// missingBlobs returns blobs, which are not written to ClickHouse key-value storage yet.
+// Blobs of account states loaded from PostgreSQL are not set, so they are taken from PostgreSQL tables.
+func (r *Repository) missingBlobs(ctx context.Context, table, hashColumn, blobColumn string, blobs map[string][]byte) (map[string][]byte, error) {
+	if len(blobs) == 0 {
+		return nil, nil
+	}
+
+	var hashes [][]byte
+	for h := range blobs {
+		hashes = append(hashes, []byte(h))
+	}
+
+	var written []*blobRow
+	err := r.ch.NewSelect().
+		TableExpr(table).
+		ColumnExpr("? AS hash", ch.Ident(hashColumn)).
+		Where("? IN ?", ch.Ident(hashColumn), ch.In(hashes)).
+		Scan(ctx, &written)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get written %s", blobColumn)
+	}
+
+	missing := map[string][]byte{}
+	for h, b := range blobs {
+		missing[h] = b
+	}
+	for _, w := range written {
+		delete(missing, string(w.Hash))
+	}
+
+	var notSet [][]byte
+	for h, b := range missing {
+		if len(b) == 0 {
+			notSet = append(notSet, []byte(h))
+		}
+	}
+	if len(notSet) == 0 {
+		return missing, nil
+	}
+
+	var stored []*blobRow
+	err = r.pg.NewSelect().
+		TableExpr(table).
+		ColumnExpr("? AS hash", bun.Ident(hashColumn)).
+		ColumnExpr("? AS blob", bun.Ident(blobColumn)).
+		Where("? IN (?)", bun.Ident(hashColumn), bun.In(notSet)).
+		Scan(ctx, &stored)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get %s from pg", blobColumn)
+	}
+	for _, s := range stored {
+		missing[string(s.Hash)] = s.Blob
+	}
+
+	for h, b := range missing {
+		if len(b) == 0 {
+			return nil, errors.Wrapf(core.ErrNotFound, "cannot find %s with %x hash", blobColumn, []byte(h))
+		}
+	}
+
+	return missing, nil
+}
+
+// writeCodeData writes code and data of account states to ClickHouse key-value storage once per hash.
+func (r *Repository) writeCodeData(ctx context.Context, accounts []*core.AccountState) error {
+	code, data := map[string][]byte{}, map[string][]byte{}
+	for _, a := range accounts {
+		if len(a.CodeHash) > 0 && len(code[string(a.CodeHash)]) == 0 {
+			code[string(a.CodeHash)] = a.Code
+		}
+		if len(a.DataHash) > 0 && len(data[string(a.DataHash)]) == 0 {
+			data[string(a.DataHash)] = a.Data
+		}
+	}
+
+	code, err := r.missingBlobs(ctx, "account_states_code", "code_hash", "code", code)
+	if err != nil {
+		return err
+	}
+	data, err = r.missingBlobs(ctx, "account_states_data", "data_hash", "data", data)
+	if err != nil {
+		return err
+	}
+
+	if len(code) > 0 {
+		var codeKV []*core.AccountStateCode
+		for h, c := range code {
+			codeKV = append(codeKV, &core.AccountStateCode{CodeHash: []byte(h), Code: c})
+		}
+		if _, err := r.ch.NewInsert().Model(&codeKV).Exec(ctx); err != nil {
+			return errors.Wrap(err, "write code to key-value store")
+		}
+	}
+	if len(data) > 0 {
+		var dataKV []*core.AccountStateData
+		for h, d := range data {
+			dataKV = append(dataKV, &core.AccountStateData{DataHash: []byte(h), Data: d})
+		}
+		if _, err := r.ch.NewInsert().Model(&dataKV).Exec(ctx); err != nil {
+			return errors.Wrap(err, "write data to key-value store")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/go-clickhouse/ch"
+
+	"github.com/tonindexer/anton/internal/core"
+)
+
+var _ core.OutboxRepository = (*Repository)(nil)
+
+// Repository is a no-op in PostgreSQL-only mode, as there is nothing to write to ClickHouse.
+type Repository struct {
+	ch *ch.DB
+	pg *bun.DB
+}
+
+func NewRepository(ck *ch.DB, pg *bun.DB) *Repository {
+	return &Repository{ch: ck, pg: pg}
+}
+
+func CreateTables(ctx context.Context, pgDB *bun.DB) error {
+	_, err := pgDB.NewCreateTable().
+		Model(&core.OutboxBatch{}).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "clickhouse outbox pg create table")
+	}
+	return nil
+}
+
+func (r *Repository) AddBatch(ctx context.Context, tx bun.Tx, b *core.OutboxBatch) error {
+	if r.ch == nil {
+		return nil
+	}
+
+	b.CreatedAt = time.Now()
+
+	_, err := tx.NewInsert().Model(b).On("CONFLICT DO NOTHING").Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "insert outbox batch")
+	}
+	return nil
+}
+
+// WriteBatch writes the batch rows to ClickHouse without removing the batch from the outbox.
+// Blocks are written the last, so a block in ClickHouse means the whole batch is written.
+func (r *Repository) WriteBatch(ctx context.Context, b *core.OutboxBatch) error {
+	if err := r.writeCodeData(ctx, b.Accounts); err != nil {
+		return err
+	}
+	if len(b.Accounts) > 0 {
+		if _, err := r.ch.NewInsert().Model(&b.Accounts).Exec(ctx); err != nil {
+			return errors.Wrap(err, "write account states")
+		}
+	}
+	if len(b.Messages) > 0 {
+		if _, err := r.ch.NewInsert().Model(&b.Messages).Exec(ctx); err != nil {
+			return errors.Wrap(err, "write messages")
+		}
+	}
+	if len(b.Transactions) > 0 {
+		if _, err := r.ch.NewInsert().Model(&b.Transactions).Exec(ctx); err != nil {
+			return errors.Wrap(err, "write transactions")
+		}
+	}
+	if len(b.Blocks) > 0 {
+		if _, err := r.ch.NewInsert().Model(&b.Blocks).Exec(ctx); err != nil {
+			return errors.Wrap(err, "write blocks")
+		}
+	}
+	return nil
+}
+
+func (r *Repository) FlushBatch(ctx context.Context, b *core.OutboxBatch) error {
+	if r.ch == nil {
+		return nil
+	}
+
+	if err := r.WriteBatch(ctx, b); err != nil {
+		return errors.Wrapf(err, "flush batch (%d, %d, %d)", b.Workchain, b.Shard, b.SeqNo)
+	}
+
+	_, err := r.pg.NewDelete().Model(b).WherePK().Exec(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "delete outbox batch (%d, %d, %d)", b.Workchain, b.Shard, b.SeqNo)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetPendingBatches(ctx context.Context) ([]*core.OutboxBatch, error) {
+	var batches []*core.OutboxBatch
+
+	if r.ch == nil {
+		return nil, nil
+	}
+
+	err := r.pg.NewSelect().Model(&batches).
+		Order("workchain ASC", "shard ASC", "seq_no ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get outbox batches")
+	}
+
+	for _, b := range batches {
+		if err := r.loadBatch(ctx, b); err != nil {
+			return nil, errors.Wrapf(err, "load batch (%d, %d, %d)", b.Workchain, b.Shard, b.SeqNo)
+		}
+		if err := r.skipWritten(ctx, b); err != nil {
+			return nil, errors.Wrapf(err, "reconcile batch (%d, %d, %d)", b.Workchain, b.Shard, b.SeqNo)
+		}
+	}
+
+	return batches, nil
+}
@@ -0,0 +1,224 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/go-clickhouse/ch"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+type addressLT struct {
+	Address addr.Address `ch:"type:String"`
+	LT      uint64
+}
+
+// loadBatch selects rows of the given masterchain block from PostgreSQL.
+func (r *Repository) loadBatch(ctx context.Context, b *core.OutboxBatch) error {
+	err := r.pg.NewSelect().Model(&b.Blocks).
+		Where("workchain = ? AND shard = ? AND seq_no = ?", b.Workchain, b.Shard, b.SeqNo).
+		WhereOr("master_workchain = ? AND master_shard = ? AND master_seq_no = ?", b.Workchain, b.Shard, b.SeqNo).
+		Scan(ctx)
+	if err != nil {
+		return errors.Wrap(err, "select blocks")
+	}
+	return r.loadRows(ctx, b)
+}
+
+// loadRows selects account states, transactions and messages of the batch blocks from PostgreSQL.
+func (r *Repository) loadRows(ctx context.Context, b *core.OutboxBatch) error {
+	if len(b.Blocks) == 0 {
+		return nil
+	}
+
+	var blockIDs [][]int64
+	for _, x := range b.Blocks {
+		blockIDs = append(blockIDs, []int64{int64(x.Workchain), x.Shard, int64(x.SeqNo)})
+	}
+
+	err := r.pg.NewSelect().Model(&b.Accounts).
+		Where("(workchain, shard, block_seq_no) IN (?)", bun.In(blockIDs)).
+		Scan(ctx)
+	if err != nil {
+		return errors.Wrap(err, "select account states")
+	}
+
+	err = r.pg.NewSelect().Model(&b.Transactions).
+		Where("(workchain, shard, block_seq_no) IN (?)", bun.In(blockIDs)).
+		Scan(ctx)
+	if err != nil {
+		return errors.Wrap(err, "select transactions")
+	}
+	if len(b.Transactions) == 0 {
+		return nil
+	}
+
+	var (
+		inMsgHashes [][]byte
+		outMsgTxIDs [][]any
+	)
+	for _, tx := range b.Transactions {
+		if len(tx.InMsgHash) > 0 {
+			inMsgHashes = append(inMsgHashes, tx.InMsgHash)
+		}
+		outMsgTxIDs = append(outMsgTxIDs, []any{&tx.Address, tx.CreatedLT})
+	}
+
+	err = r.pg.NewSelect().Model(&b.Messages).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			if len(inMsgHashes) > 0 {
+				q = q.WhereOr("hash IN (?)", bun.In(inMsgHashes))
+			}
+			return q.WhereOr("(src_address, src_tx_lt) IN (?)", bun.In(outMsgTxIDs))
+		}).
+		Scan(ctx)
+	if err != nil {
+		return errors.Wrap(err, "select messages")
+	}
+
+	return nil
+}
+
+// skipWritten removes rows, which are already written to ClickHouse, from the batch.
+// The previous flush could have been interrupted after writing some of the tables.
+func (r *Repository) skipWritten(ctx context.Context, b *core.OutboxBatch) error {
+	count, err := r.ch.NewSelect().Model((*core.Block)(nil)).
+		Where("workchain = ? AND shard = ? AND seq_no = ?", b.Workchain, b.Shard, b.SeqNo).
+		Count(ctx)
+	if err != nil {
+		return errors.Wrap(err, "check masterchain block")
+	}
+	if count > 0 {
+		// blocks are written the last
+		b.Blocks, b.Transactions, b.Messages, b.Accounts = nil, nil, nil, nil
+		return nil
+	}
+
+	if b.Accounts, err = r.skipWrittenAccounts(ctx, b.Accounts); err != nil {
+		return err
+	}
+	if b.Transactions, err = r.skipWrittenTransactions(ctx, b.Transactions); err != nil {
+		return err
+	}
+	if b.Messages, err = r.skipWrittenMessages(ctx, b.Messages); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Repository) writtenAddressLTs(ctx context.Context, model any, ltColumn string, ids []addressLT) (map[addressLT]struct{}, error) {
+	var (
+		addresses []addr.Address
+		lts       []uint64
+		written   []*addressLT
+	)
+	for _, id := range ids {
+		addresses = append(addresses, id.Address)
+		lts = append(lts, id.LT)
+	}
+
+	err := r.ch.NewSelect().Model(model).
+		ColumnExpr("address").
+		ColumnExpr("? AS lt", ch.Ident(ltColumn)).
+		Where("address IN ?", ch.In(addresses)).
+		Where("? IN ?", ch.Ident(ltColumn), ch.In(lts)).
+		Scan(ctx, &written)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[addressLT]struct{}{}
+	for _, id := range written {
+		ret[*id] = struct{}{}
+	}
+	return ret, nil
+}
+
+func (r *Repository) skipWrittenAccounts(ctx context.Context, accounts []*core.AccountState) (ret []*core.AccountState, err error) {
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+
+	var ids []addressLT
+	for _, a := range accounts {
+		ids = append(ids, addressLT{Address: a.Address, LT: a.LastTxLT})
+	}
+
+	written, err := r.writtenAddressLTs(ctx, (*core.AccountState)(nil), "last_tx_lt", ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "get written account states")
+	}
+
+	for _, a := range accounts {
+		if _, ok := written[addressLT{Address: a.Address, LT: a.LastTxLT}]; !ok {
+			ret = append(ret, a)
+		}
+	}
+	return ret, nil
+}
+
+func (r *Repository) skipWrittenTransactions(ctx context.Context, transactions []*core.Transaction) (ret []*core.Transaction, err error) {
+	if len(transactions) == 0 {
+		return nil, nil
+	}
+
+	var ids []addressLT
+	for _, tx := range transactions {
+		ids = append(ids, addressLT{Address: tx.Address, LT: tx.CreatedLT})
+	}
+
+	written, err := r.writtenAddressLTs(ctx, (*core.Transaction)(nil), "created_lt", ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "get written transactions")
+	}
+
+	for _, tx := range transactions {
+		if _, ok := written[addressLT{Address: tx.Address, LT: tx.CreatedLT}]; !ok {
+			ret = append(ret, tx)
+		}
+	}
+	return ret, nil
+}
+
+func (r *Repository) skipWrittenMessages(ctx context.Context, messages []*core.Message) (ret []*core.Message, err error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	var hashes [][]byte
+	for _, msg := range messages {
+		hashes = append(hashes, msg.Hash)
+	}
+
+	var written []struct {
+		Hash    []byte `ch:"type:String"`
+		DstTxLT uint64
+	}
+	err = r.ch.NewSelect().Model((*core.Message)(nil)).
+		Column("hash", "dst_tx_lt").
+		Where("hash IN ?", ch.In(hashes)).
+		Scan(ctx, &written)
+	if err != nil {
+		return nil, errors.Wrap(err, "get written messages")
+	}
+
+	// a message is written again, when its destination transaction is known
+	writtenSet := map[string]map[uint64]struct{}{}
+	for _, w := range written {
+		if writtenSet[string(w.Hash)] == nil {
+			writtenSet[string(w.Hash)] = map[uint64]struct{}{}
+		}
+		writtenSet[string(w.Hash)][w.DstTxLT] = struct{}{}
+	}
+
+	for _, msg := range messages {
+		if _, ok := writtenSet[string(msg.Hash)][msg.DstTxLT]; !ok {
+			ret = append(ret, msg)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,580 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun/extra/bunbig"
+	"github.com/uptrace/go-clickhouse/ch"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+// TableDiff counts rows of a table, which differ between PostgreSQL and ClickHouse.
+type TableDiff struct {
+	Table   string
+	PG      int // rows in PostgreSQL
+	CH      int // rows in ClickHouse, including not merged versions
+	Missing int // rows absent in ClickHouse
+	Extra   int // rows absent in PostgreSQL
+	Changed int // rows with different contents
+}
+
+func (d *TableDiff) Consistent() bool {
+	return d.Missing == 0 && d.Extra == 0 && d.Changed == 0
+}
+
+// BlockDiff lists inconsistent tables of a masterchain block and its shard blocks.
+type BlockDiff struct {
+	MasterSeqNo uint32
+	Tables      []*TableDiff
+}
+
+// verifyRow identifies a row in both databases by the key and compares it by the hash of the contents.
+type verifyRow struct {
+	master uint32
+	key    string
+	hash   string
+	model  any
+}
+
+type tableRows struct {
+	name string
+	pg   map[string]*verifyRow
+	ch   map[string][]*verifyRow // ClickHouse can store several versions of a row before merge
+}
+
+func newTableRows(name string) *tableRows {
+	return &tableRows{name: name, pg: map[string]*verifyRow{}, ch: map[string][]*verifyRow{}}
+}
+
+func (t *tableRows) addPG(row *verifyRow) {
+	t.pg[row.key] = row
+}
+
+func (t *tableRows) addCH(row *verifyRow) {
+	t.ch[row.key] = append(t.ch[row.key], row)
+}
+
+// compare adds table differences to block diffs.
+// It returns PostgreSQL rows to be written to ClickHouse and ClickHouse rows to be removed.
+func (t *tableRows) compare(diffs map[uint32]*BlockDiff) (write []any, remove []any) {
+	tables := map[uint32]*TableDiff{}
+	get := func(master uint32) *TableDiff {
+		if tables[master] == nil {
+			tables[master] = &TableDiff{Table: t.name}
+		}
+		return tables[master]
+	}
+
+	for key, row := range t.pg {
+		d := get(row.master)
+		d.PG++
+
+		versions, ok := t.ch[key]
+		if !ok {
+			d.Missing++
+			write = append(write, row.model)
+			continue
+		}
+
+		var found bool
+		for _, v := range versions {
+			if v.hash == row.hash {
+				found = true
+			}
+		}
+		if !found {
+			d.Changed++
+			write = append(write, row.model)
+		}
+	}
+
+	for key, versions := range t.ch {
+		for _, v := range versions {
+			get(v.master).CH++
+		}
+		if _, ok := t.pg[key]; !ok {
+			get(versions[0].master).Extra++
+			for _, v := range versions {
+				remove = append(remove, v.model)
+			}
+		}
+	}
+
+	for master, d := range tables {
+		if d.Consistent() {
+			continue
+		}
+		if diffs[master] == nil {
+			diffs[master] = &BlockDiff{MasterSeqNo: master}
+		}
+		diffs[master].Tables = append(diffs[master].Tables, d)
+	}
+
+	return write, remove
+}
+
+// rowHash hashes the row columns, which are compared between the databases.
+func rowHash(columns ...any) string {
+	h := sha256.New()
+	for _, c := range columns {
+		_, _ = fmt.Fprintf(h, "%v\x00", c)
+	}
+	return string(h.Sum(nil))
+}
+
+// The helpers below normalize values, which are stored differently in PostgreSQL and ClickHouse.
+
+func hexString(b []byte) string {
+	return hex.EncodeToString(b)
+}
+
+func bigString(i *bunbig.Int) string {
+	if i == nil {
+		return "0"
+	}
+	return i.String()
+}
+
+func addrString(a *addr.Address) string {
+	if v, _ := a.Value(); v == nil {
+		return ""
+	}
+	return a.String()
+}
+
+// jsonString re-encodes JSON with sorted object keys, as PostgreSQL jsonb does not keep the keys order.
+func jsonString(v any) string {
+	raw, ok := v.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return err.Error()
+		}
+	}
+
+	var decoded any
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&decoded); err != nil {
+		return string(raw)
+	}
+	switch x := decoded.(type) {
+	case nil:
+		return ""
+	case map[string]any:
+		if len(x) == 0 {
+			return ""
+		}
+	}
+
+	ret, err := json.Marshal(decoded)
+	if err != nil {
+		return string(raw)
+	}
+	return string(ret)
+}
+
+func transactionHash(tx *core.Transaction) string {
+	return rowHash(
+		addrString(&tx.Address), hexString(tx.PrevTxHash), tx.PrevTxLT, hexString(tx.InMsgHash),
+		bigString(tx.InAmount), tx.OutMsgCount, bigString(tx.OutAmount), bigString(tx.TotalFees),
+		tx.ComputePhaseExitCode, tx.ActionPhaseResultCode, tx.OrigStatus, tx.EndStatus)
+}
+
+// messageHash includes the parsed columns, which are updated by rescan.
+func messageHash(msg *core.Message) string {
+	return rowHash(
+		msg.DstTxLT, addrString(&msg.SrcAddress), addrString(&msg.DstAddress),
+		bigString(msg.Amount), msg.OperationID, hexString(msg.BodyHash),
+		msg.SrcContract, msg.DstContract, msg.OperationName, jsonString(msg.DataJSON), msg.Error, msg.SchemaVersion)
+}
+
+// accountHash includes the parsed columns, which are updated by rescan.
+func accountHash(a *core.AccountState) string {
+	return rowHash(
+		hexString(a.LastTxHash), a.Status, bigString(a.Balance), hexString(a.CodeHash), hexString(a.DataHash),
+		a.Types, addrString(a.OwnerAddress), addrString(a.MinterAddress), a.Fake,
+		jsonString(a.ExecutedGetMethods), jsonString(a.InterfaceVersions),
+		a.ContentURI, a.ContentName, a.ContentDescription, a.ContentImage, hexString(a.ContentImageData),
+		bigString(a.JettonBalance))
+}
+
+func blockKey(id core.BlockID) string {
+	return fmt.Sprintf("%d:%d:%d", id.Workchain, id.Shard, id.SeqNo)
+}
+
+// timeRange returns the interval of row timestamps, which is used to skip ClickHouse partitions.
+func timeRange(times []time.Time) (from, to time.Time) {
+	for i, t := range times {
+		if i == 0 || t.Before(from) {
+			from = t
+		}
+		if i == 0 || t.After(to) {
+			to = t
+		}
+	}
+	return from, to
+}
+
+// VerifiableRange returns masterchain blocks saved to PostgreSQL and written to ClickHouse.
+// Blocks of pending outbox batches are not verified.
+func (r *Repository) VerifiableRange(ctx context.Context) (from, to uint32, err error) {
+	var ret struct {
+		FromSeqNo sql.NullInt64
+		ToSeqNo   sql.NullInt64
+	}
+
+	if r.ch == nil {
+		return 0, 0, errors.Wrap(core.ErrNotImplemented, "clickhouse is not configured")
+	}
+
+	err = r.pg.NewSelect().Model((*core.Block)(nil)).
+		ColumnExpr("min(seq_no) AS from_seq_no").
+		ColumnExpr("max(seq_no) AS to_seq_no").
+		Where("workchain = -1").
+		Scan(ctx, &ret)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "get masterchain blocks range")
+	}
+	if !ret.FromSeqNo.Valid {
+		return 0, 0, errors.Wrap(core.ErrNotFound, "no masterchain blocks")
+	}
+	from, to = uint32(ret.FromSeqNo.Int64), uint32(ret.ToSeqNo.Int64)
+
+	var pending sql.NullInt64
+	err = r.pg.NewSelect().Model((*core.OutboxBatch)(nil)).
+		ColumnExpr("min(seq_no)").
+		Where("workchain = -1").
+		Scan(ctx, &pending)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "get pending outbox batches")
+	}
+	if pending.Valid && uint32(pending.Int64) <= to {
+		to = uint32(pending.Int64) - 1
+	}
+
+	return from, to, nil
+}
+
+// Verify compares rows of masterchain blocks in the given range between PostgreSQL and ClickHouse.
+// PostgreSQL is authoritative: with repair, missing and changed rows are written to ClickHouse,
+// and extra rows are removed from ClickHouse with asynchronous mutations.
+func (r *Repository) Verify(ctx context.Context, fromSeqNo, toSeqNo uint32, repair bool) ([]*BlockDiff, error) {
+	if r.ch == nil {
+		return nil, errors.Wrap(core.ErrNotImplemented, "clickhouse is not configured")
+	}
+
+	b := new(core.OutboxBatch)
+
+	err := r.pg.NewSelect().Model(&b.Blocks).
+		Where("(workchain = -1 AND seq_no BETWEEN ? AND ?) OR (master_workchain = -1 AND master_seq_no BETWEEN ? AND ?)",
+			fromSeqNo, toSeqNo, fromSeqNo, toSeqNo).
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "select blocks")
+	}
+	if err := r.loadRows(ctx, b); err != nil {
+		return nil, err
+	}
+
+	blocks, masters, err := r.verifyBlocks(ctx, b.Blocks, fromSeqNo, toSeqNo)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := r.verifyTransactions(ctx, b.Transactions, masters)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := r.verifyMessages(ctx, b.Messages, masters)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := r.verifyAccounts(ctx, b.Accounts, masters)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := map[uint32]*BlockDiff{}
+	repairBatch := new(core.OutboxBatch)
+
+	write, removeBlocks := blocks.compare(diffs)
+	for _, m := range write {
+		repairBatch.Blocks = append(repairBatch.Blocks, m.(*core.Block))
+	}
+
+	write, removeTransactions := transactions.compare(diffs)
+	for _, m := range write {
+		repairBatch.Transactions = append(repairBatch.Transactions, m.(*core.Transaction))
+	}
+
+	write, removeMessages := messages.compare(diffs)
+	for _, m := range write {
+		repairBatch.Messages = append(repairBatch.Messages, m.(*core.Message))
+	}
+
+	write, removeAccounts := accounts.compare(diffs)
+	for _, m := range write {
+		repairBatch.Accounts = append(repairBatch.Accounts, m.(*core.AccountState))
+	}
+
+	var ret []*BlockDiff
+	for _, d := range diffs {
+		ret = append(ret, d)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].MasterSeqNo < ret[j].MasterSeqNo })
+
+	if !repair || len(ret) == 0 {
+		return ret, nil
+	}
+
+	if err := r.WriteBatch(ctx, repairBatch); err != nil {
+		return nil, errors.Wrap(err, "write missing rows")
+	}
+	if err := r.removeRows(ctx, removeBlocks, removeTransactions, removeMessages, removeAccounts); err != nil {
+		return nil, errors.Wrap(err, "remove extra rows")
+	}
+
+	return ret, nil
+}
+
+// verifyBlocks selects blocks of the range from ClickHouse.
+// It returns masterchain block seq_no of every block found in any database.
+func (r *Repository) verifyBlocks(ctx context.Context, pgBlocks []*core.Block, fromSeqNo, toSeqNo uint32) (*tableRows, map[core.BlockID]uint32, error) {
+	rows := newTableRows("block_info")
+	masters := map[core.BlockID]uint32{}
+
+	var shardSeqNo []uint32
+	for _, b := range pgBlocks {
+		master := b.SeqNo
+		if b.Workchain != -1 {
+			if b.MasterID == nil {
+				return nil, nil, fmt.Errorf("no masterchain block for (%d, %d, %d)", b.Workchain, b.Shard, b.SeqNo)
+			}
+			master = b.MasterID.SeqNo
+			shardSeqNo = append(shardSeqNo, b.SeqNo)
+		}
+		masters[b.ID()] = master
+		rows.addPG(&verifyRow{master: master, key: blockKey(b.ID()), hash: string(b.FileHash), model: b})
+	}
+
+	var chBlocks []*core.Block
+	q := r.ch.NewSelect().Model(&chBlocks).
+		Column("workchain", "shard", "seq_no", "file_hash").
+		Where("workchain = -1 AND seq_no BETWEEN ? AND ?", fromSeqNo, toSeqNo)
+	if len(shardSeqNo) > 0 {
+		q = q.WhereOr("workchain != -1 AND seq_no IN ?", ch.In(shardSeqNo))
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, nil, errors.Wrap(err, "select clickhouse blocks")
+	}
+
+	for _, b := range chBlocks {
+		master, ok := masters[b.ID()]
+		if b.Workchain == -1 && !ok {
+			master, ok = b.SeqNo, true
+			masters[b.ID()] = master // look for rows of extra masterchain blocks
+		}
+		if !ok {
+			continue
+		}
+		rows.addCH(&verifyRow{master: master, key: blockKey(b.ID()), hash: string(b.FileHash), model: b})
+	}
+
+	return rows, masters, nil
+}
+
+func blockSeqNos(masters map[core.BlockID]uint32) (ret []uint32) {
+	for id := range masters {
+		ret = append(ret, id.SeqNo)
+	}
+	return ret
+}
+
+func (r *Repository) verifyTransactions(ctx context.Context, pgTransactions []*core.Transaction, masters map[core.BlockID]uint32) (*tableRows, error) {
+	rows := newTableRows("transactions")
+	if len(masters) == 0 {
+		return rows, nil
+	}
+
+	var times []time.Time
+	for _, tx := range pgTransactions {
+		rows.addPG(&verifyRow{master: masters[tx.BlockID()], key: string(tx.Hash), hash: transactionHash(tx), model: tx})
+		times = append(times, tx.CreatedAt)
+	}
+
+	var chTransactions []*core.Transaction
+	q := r.ch.NewSelect().Model(&chTransactions).
+		Column("hash", "workchain", "shard", "block_seq_no").
+		Column("address", "prev_tx_hash", "prev_tx_lt", "in_msg_hash", "in_amount", "out_msg_count", "out_amount", "total_fees").
+		Column("compute_phase_exit_code", "action_phase_result_code", "orig_status", "end_status").
+		Where("block_seq_no IN ?", ch.In(blockSeqNos(masters)))
+	if len(times) > 0 {
+		from, to := timeRange(times)
+		q = q.Where("created_at BETWEEN ? AND ?", from, to)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, errors.Wrap(err, "select clickhouse transactions")
+	}
+
+	for _, tx := range chTransactions {
+		if master, ok := masters[tx.BlockID()]; ok {
+			rows.addCH(&verifyRow{master: master, key: string(tx.Hash), hash: transactionHash(tx), model: tx})
+		}
+	}
+
+	return rows, nil
+}
+
+func messageMaster(msg *core.Message, masters map[core.BlockID]uint32) (uint32, bool) {
+	dst := core.BlockID{Workchain: msg.DstWorkchain, Shard: msg.DstShard, SeqNo: msg.DstBlockSeqNo}
+	if master, ok := masters[dst]; ok {
+		return master, true
+	}
+	src := core.BlockID{Workchain: msg.SrcWorkchain, Shard: msg.SrcShard, SeqNo: msg.SrcBlockSeqNo}
+	master, ok := masters[src]
+	return master, ok
+}
+
+func (r *Repository) verifyMessages(ctx context.Context, pgMessages []*core.Message, masters map[core.BlockID]uint32) (*tableRows, error) {
+	rows := newTableRows("messages")
+	if len(masters) == 0 {
+		return rows, nil
+	}
+
+	var times []time.Time
+	for _, msg := range pgMessages {
+		master, _ := messageMaster(msg, masters)
+		rows.addPG(&verifyRow{master: master, key: string(msg.Hash), hash: messageHash(msg), model: msg})
+		times = append(times, msg.CreatedAt)
+	}
+
+	seqNo := ch.In(blockSeqNos(masters))
+
+	var chMessages []*core.Message
+	q := r.ch.NewSelect().Model(&chMessages).
+		Column("hash", "dst_tx_lt").
+		Column("src_workchain", "src_shard", "src_block_seq_no").
+		Column("dst_workchain", "dst_shard", "dst_block_seq_no").
+		Column("src_address", "dst_address", "amount", "operation_id", "body_hash").
+		Column("src_contract", "dst_contract", "operation_name", "data_json", "error", "schema_version").
+		Where("src_block_seq_no IN ? OR dst_block_seq_no IN ?", seqNo, seqNo)
+	if len(times) > 0 {
+		from, to := timeRange(times)
+		q = q.Where("created_at BETWEEN ? AND ?", from, to)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, errors.Wrap(err, "select clickhouse messages")
+	}
+
+	for _, msg := range chMessages {
+		if master, ok := messageMaster(msg, masters); ok {
+			rows.addCH(&verifyRow{master: master, key: string(msg.Hash), hash: messageHash(msg), model: msg})
+		}
+	}
+
+	return rows, nil
+}
+
+func accountKey(a *core.AccountState) string {
+	return a.Address.String() + ":" + strconv.FormatUint(a.LastTxLT, 10)
+}
+
+func (r *Repository) verifyAccounts(ctx context.Context, pgAccounts []*core.AccountState, masters map[core.BlockID]uint32) (*tableRows, error) {
+	rows := newTableRows("account_states")
+	if len(masters) == 0 {
+		return rows, nil
+	}
+
+	var times []time.Time
+	for _, a := range pgAccounts {
+		rows.addPG(&verifyRow{master: masters[a.BlockID()], key: accountKey(a), hash: accountHash(a), model: a})
+		times = append(times, a.UpdatedAt)
+	}
+
+	var chAccounts []*core.AccountState
+	q := r.ch.NewSelect().Model(&chAccounts).
+		Column("address", "last_tx_lt", "last_tx_hash", "workchain", "shard", "block_seq_no").
+		Column("status", "balance", "code_hash", "data_hash").
+		Column("types", "owner_address", "minter_address", "fake", "executed_get_methods", "interface_versions").
+		Column("content_uri", "content_name", "content_description", "content_image", "content_image_data", "jetton_balance").
+		Where("block_seq_no IN ?", ch.In(blockSeqNos(masters)))
+	if len(times) > 0 {
+		from, to := timeRange(times)
+		q = q.Where("updated_at BETWEEN ? AND ?", from, to)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, errors.Wrap(err, "select clickhouse account states")
+	}
+
+	for _, a := range chAccounts {
+		if master, ok := masters[a.BlockID()]; ok {
+			rows.addCH(&verifyRow{master: master, key: accountKey(a), hash: accountHash(a), model: a})
+		}
+	}
+
+	return rows, nil
+}
+
+// removeRows deletes extra rows from ClickHouse.
+func (r *Repository) removeRows(ctx context.Context, blocks, transactions, messages, accounts []any) error {
+	if len(blocks) > 0 {
+		var seqNo []uint32
+		for _, m := range blocks {
+			seqNo = append(seqNo, m.(*core.Block).SeqNo)
+		}
+		if _, err := r.ch.ExecContext(ctx, "ALTER TABLE block_info DELETE WHERE workchain = -1 AND seq_no IN ?", ch.In(seqNo)); err != nil {
+			return errors.Wrap(err, "remove blocks")
+		}
+	}
+
+	if len(transactions) > 0 {
+		var hashes [][]byte
+		for _, m := range transactions {
+			hashes = append(hashes, m.(*core.Transaction).Hash)
+		}
+		if _, err := r.ch.ExecContext(ctx, "ALTER TABLE transactions DELETE WHERE hash IN ?", ch.In(hashes)); err != nil {
+			return errors.Wrap(err, "remove transactions")
+		}
+	}
+
+	if len(messages) > 0 {
+		var hashes [][]byte
+		for _, m := range messages {
+			hashes = append(hashes, m.(*core.Message).Hash)
+		}
+		if _, err := r.ch.ExecContext(ctx, "ALTER TABLE messages DELETE WHERE hash IN ?", ch.In(hashes)); err != nil {
+			return errors.Wrap(err, "remove messages")
+		}
+	}
+
+	if len(accounts) > 0 {
+		var (
+			conditions []string
+			args       []any
+		)
+		for _, m := range accounts {
+			a := m.(*core.AccountState)
+			conditions = append(conditions, "(address = ? AND last_tx_lt = ?)")
+			args = append(args, &a.Address, a.LastTxLT)
+		}
+		query := "ALTER TABLE account_states DELETE WHERE " + strings.Join(conditions, " OR ")
+		if _, err := r.ch.ExecContext(ctx, query, args...); err != nil {
+			return errors.Wrap(err, "remove account states")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun/extra/bunbig"
+
+	"github.com/tonindexer/anton/abi"
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core/rndm"
+)
+
+func TestAccountHash_Normalized(t *testing.T) {
+	pg := rndm.AddressStateContract(rndm.Address(), "special", nil)
+	pg.OwnerAddress, pg.JettonBalance, pg.InterfaceVersions = nil, nil, nil
+
+	// values as they are scanned from clickhouse
+	ck := *pg
+	ck.OwnerAddress, ck.JettonBalance = new(addr.Address), bunbig.FromInt64(0)
+	ck.InterfaceVersions = map[abi.ContractName]uint32{}
+
+	require.Equal(t, accountHash(pg), accountHash(&ck))
+
+	ck.Types = []abi.ContractName{"changed"}
+	require.NotEqual(t, accountHash(pg), accountHash(&ck))
+}
+
+func TestMessageHash_Normalized(t *testing.T) {
+	pg := rndm.MessageTo(rndm.Address())
+	pg.DataJSON = json.RawMessage(`{"b": 1, "a": {"d": 12345678901234567890, "c": "x"}}`)
+
+	// jsonb keeps keys sorted
+	ck := *pg
+	ck.DataJSON = json.RawMessage(`{"a":{"c":"x","d":12345678901234567890},"b":1}`)
+
+	require.Equal(t, messageHash(pg), messageHash(&ck))
+
+	ck.OperationName = "changed"
+	require.NotEqual(t, messageHash(pg), messageHash(&ck))
+}
+
+func TestTableRows_Compare(t *testing.T) {
+	rows := newTableRows("test")
+	rows.addPG(&verifyRow{master: 1, key: "same", hash: "1", model: "same"})
+	rows.addPG(&verifyRow{master: 1, key: "changed", hash: "2", model: "changed"})
+	rows.addPG(&verifyRow{master: 1, key: "merged", hash: "3", model: "merged"})
+	rows.addPG(&verifyRow{master: 2, key: "missing", hash: "4", model: "missing"})
+	rows.addCH(&verifyRow{master: 1, key: "same", hash: "1", model: "same"})
+	rows.addCH(&verifyRow{master: 1, key: "changed", hash: "0", model: "changed"})
+	rows.addCH(&verifyRow{master: 1, key: "merged", hash: "0", model: "merged"})
+	rows.addCH(&verifyRow{master: 1, key: "merged", hash: "3", model: "merged"})
+	rows.addCH(&verifyRow{master: 3, key: "extra", hash: "5", model: "extra"})
+
+	diffs := map[uint32]*BlockDiff{}
+	write, remove := rows.compare(diffs)
+
+	require.ElementsMatch(t, []any{"changed", "missing"}, write)
+	require.Equal(t, []any{"extra"}, remove)
+	require.Equal(t, map[uint32]*BlockDiff{
+		1: {MasterSeqNo: 1, Tables: []*TableDiff{{Table: "test", PG: 3, CH: 4, Changed: 1}}},
+		2: {MasterSeqNo: 2, Tables: []*TableDiff{{Table: "test", PG: 1, Missing: 1}}},
+		3: {MasterSeqNo: 3, Tables: []*TableDiff{{Table: "test", CH: 1, Extra: 1}}},
+	}, diffs)
+}
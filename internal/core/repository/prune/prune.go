@@ -0,0 +1,384 @@
+package prune
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/go-clickhouse/ch"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+var _ core.PruneRepository = (*Repository)(nil)
+
+// batchSize is the approximate number of PostgreSQL rows pruned in one statement.
+const batchSize = 10000
+
+// ttlTables are ClickHouse tables, which can have TTL, with their time columns and retention rules.
+// Account states are not listed, as the latest states must be kept.
+var ttlTables = map[string]struct {
+	column string
+	rule   core.RetentionRule
+}{
+	"transactions": {column: "created_at", rule: core.PruneTransactionsTTL},
+	"messages":     {column: "created_at", rule: core.PruneMessagesTTL},
+}
+
+type Repository struct {
+	ch *ch.DB
+	pg *bun.DB
+}
+
+func NewRepository(ck *ch.DB, pg *bun.DB) *Repository {
+	return &Repository{ch: ck, pg: pg}
+}
+
+func CreateTables(ctx context.Context, pgDB *bun.DB) error {
+	_, err := pgDB.NewCreateTable().
+		Model(&core.Pruning{}).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "prunings pg create table")
+	}
+	return nil
+}
+
+// ltBatches splits rows of the table to ranges of the lt column.
+// The upper bound of the last range is zero.
+func (r *Repository) ltBatches(ctx context.Context, table, column string, f func(from, to uint64) error) error {
+	var from uint64
+	for {
+		var to uint64
+
+		err := r.pg.NewRaw(`
+				SELECT ?
+				FROM ?
+				WHERE ? >= ?
+				ORDER BY ? ASC
+				OFFSET ? ROWS FETCH FIRST 1 ROWS ONLY`,
+			bun.Ident(column), bun.Ident(table), bun.Ident(column), from, bun.Ident(column), batchSize).
+			Scan(ctx, &to)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrapf(err, "get next %s", column)
+		}
+
+		if err := f(from, to); err != nil {
+			return err
+		}
+
+		log.Debug().Str("table", table).Uint64("from", from).Uint64("to", to).Msg("pruned new batch")
+
+		if to == 0 {
+			return nil
+		}
+		from = to
+	}
+}
+
+func ltRange(column string, from, to uint64) string {
+	if to == 0 {
+		return fmt.Sprintf("%s >= %d", column, from)
+	}
+	return fmt.Sprintf("%s >= %d AND %s < %d", column, from, column, to)
+}
+
+// hashBatches splits rows of the key-value table to ranges of the hash column.
+// The upper bound of the last range is nil.
+func (r *Repository) hashBatches(ctx context.Context, table, column string, f func(from, to []byte) error) error {
+	from := []byte{}
+	for {
+		var to []byte
+
+		err := r.pg.NewRaw(`
+				SELECT ?
+				FROM ?
+				WHERE ? >= ?
+				ORDER BY ? ASC
+				OFFSET ? ROWS FETCH FIRST 1 ROWS ONLY`,
+			bun.Ident(column), bun.Ident(table), bun.Ident(column), from, bun.Ident(column), batchSize).
+			Scan(ctx, &to)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrapf(err, "get next %s", column)
+		}
+
+		if err := f(from, to); err != nil {
+			return err
+		}
+
+		log.Debug().Str("table", table).Hex("from", from).Hex("to", to).Msg("pruned new batch")
+
+		if to == nil {
+			return nil
+		}
+		from = to
+	}
+}
+
+func (r *Repository) getPruning(ctx context.Context, rule core.RetentionRule) (*core.Pruning, error) {
+	var ret core.Pruning
+
+	err := r.pg.NewSelect().Model(&ret).Where("rule = ?", rule).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrapf(core.ErrNotFound, "no %s pruning", rule)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get %s pruning", rule)
+	}
+
+	return &ret, nil
+}
+
+func (r *Repository) setPruning(ctx context.Context, p *core.Pruning) error {
+	p.PrunedAt = time.Now()
+
+	_, err := r.pg.NewInsert().Model(p).
+		On("CONFLICT (rule) DO UPDATE").
+		Set("keep_latest = EXCLUDED.keep_latest").
+		Set("ttl_days = EXCLUDED.ttl_days").
+		Set("before = EXCLUDED.before").
+		Set("pruned_at = EXCLUDED.pruned_at").
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "save %s pruning", p.Rule)
+	}
+	return nil
+}
+
+func (r *Repository) PruneAccountStates(ctx context.Context, keepLatest int) error {
+	if keepLatest < 1 {
+		return errors.Wrap(core.ErrInvalidArg, "at least the latest account state must be kept")
+	}
+
+	before := time.Now()
+
+	err := r.ltBatches(ctx, "account_states", "last_tx_lt", func(from, to uint64) error {
+		_, err := r.pg.ExecContext(ctx, `
+			DELETE FROM account_states AS s
+			WHERE `+ltRange("s.last_tx_lt", from, to)+`
+				AND (s.types IS NULL OR cardinality(s.types) = 0)
+				AND EXISTS (
+					SELECT 1
+					FROM account_states AS newer
+					WHERE newer.address = s.address AND newer.last_tx_lt > s.last_tx_lt
+					OFFSET ? LIMIT 1
+				)`, keepLatest-1)
+		if err != nil {
+			return errors.Wrapf(err, "delete pg account states from %d to %d", from, to)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.ch != nil {
+		_, err = r.ch.ExecContext(ctx, `
+			ALTER TABLE account_states
+			DELETE WHERE length(types) = 0 AND (address, last_tx_lt) IN (
+				SELECT address, last_tx_lt
+				FROM (
+					SELECT address, last_tx_lt, row_number() OVER (PARTITION BY address ORDER BY last_tx_lt DESC) AS n
+					FROM account_states
+				)
+				WHERE n > ?
+			)`, keepLatest)
+		if err != nil {
+			return errors.Wrap(err, "delete ch account states")
+		}
+	}
+
+	return r.setPruning(ctx, &core.Pruning{Rule: core.PruneAccountStates, KeepLatest: keepLatest, Before: before})
+}
+
+// pruneBlobs removes code or data in batches of hashes.
+// Indexer must not reference a blob while it is being deleted, so the key-value table is locked,
+// but only for a batch, so indexing is not paused for the whole pruning.
+func (r *Repository) pruneBlobs(ctx context.Context, table, hashColumn string, before time.Time) error {
+	err := r.hashBatches(ctx, table, hashColumn, func(from, to []byte) error {
+		return r.pg.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.ExecContext(ctx, "LOCK TABLE ? IN SHARE ROW EXCLUSIVE MODE", bun.Ident(table))
+			if err != nil {
+				return errors.Wrap(err, "lock table")
+			}
+
+			q := tx.NewDelete().
+				TableExpr("? AS b", bun.Ident(table)).
+				Where("b.? >= ?", bun.Ident(hashColumn), from).
+				Where(`NOT EXISTS (
+					SELECT 1
+					FROM account_states AS s
+					WHERE s.?0 = b.?0 AND (s.updated_at >= ?1 OR EXISTS (
+						SELECT 1
+						FROM latest_account_states AS l
+						WHERE l.address = s.address AND l.last_tx_lt = s.last_tx_lt
+					))
+				)`, bun.Ident(hashColumn), before)
+			if to != nil {
+				q = q.Where("b.? < ?", bun.Ident(hashColumn), to)
+			}
+			if _, err := q.Exec(ctx); err != nil {
+				return errors.Wrapf(err, "delete from %x to %x", from, to)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return errors.Wrapf(err, "delete pg %s", table)
+	}
+
+	if r.ch == nil {
+		return nil
+	}
+
+	_, err = r.ch.ExecContext(ctx, `
+		ALTER TABLE ?0
+		DELETE WHERE ?1 NOT IN (
+			SELECT ?1 FROM account_states WHERE updated_at >= ?2
+			UNION ALL
+			SELECT argMax(?1, last_tx_lt) FROM account_states GROUP BY address
+		)`, ch.Ident(table), ch.Ident(hashColumn), before)
+	if err != nil {
+		return errors.Wrapf(err, "delete ch %s", table)
+	}
+
+	return nil
+}
+
+func (r *Repository) PruneCodeData(ctx context.Context, before time.Time) error {
+	if err := r.pruneBlobs(ctx, "account_states_code", "code_hash", before); err != nil {
+		return err
+	}
+	if err := r.pruneBlobs(ctx, "account_states_data", "data_hash", before); err != nil {
+		return err
+	}
+	return r.setPruning(ctx, &core.Pruning{Rule: core.PruneCodeData, Before: before})
+}
+
+func (r *Repository) PruneMessageBodies(ctx context.Context, before time.Time) error {
+	err := r.ltBatches(ctx, "messages", "created_lt", func(from, to uint64) error {
+		_, err := r.pg.NewUpdate().Model((*core.Message)(nil)).
+			Set("body = NULL").
+			Where(ltRange("created_lt", from, to)).
+			Where("created_at < ?", before).
+			Where("operation_name IS NULL").
+			Where("body IS NOT NULL").
+			Exec(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "clear pg message bodies from %d to %d", from, to)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.ch != nil {
+		_, err = r.ch.ExecContext(ctx, `
+			ALTER TABLE messages
+			UPDATE body = '' WHERE created_at < ? AND operation_name = '' AND body != ''`, before)
+		if err != nil {
+			return errors.Wrap(err, "clear ch message bodies")
+		}
+	}
+
+	return r.setPruning(ctx, &core.Pruning{Rule: core.PruneMessageBodies, Before: before})
+}
+
+func (r *Repository) SetTTL(ctx context.Context, table string, days int) error {
+	ttl, ok := ttlTables[table]
+	if !ok {
+		return errors.Wrapf(core.ErrInvalidArg, "ttl is not supported on %s table", table)
+	}
+	if days < 0 {
+		return errors.Wrap(core.ErrInvalidArg, "negative ttl")
+	}
+	if r.ch == nil {
+		return errors.Wrap(core.ErrNotImplemented, "clickhouse is not configured")
+	}
+
+	p := &core.Pruning{Rule: ttl.rule, TTLDays: days, Before: time.Now().AddDate(0, 0, -days)}
+	if days == 0 {
+		prev, err := r.getPruning(ctx, ttl.rule)
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			p = nil // nothing was removed
+		case err != nil:
+			return err
+		case prev.TTLDays > 0:
+			// rows removed by the previous ttl are not restored
+			p.Before = time.Now().AddDate(0, 0, -prev.TTLDays)
+		default:
+			p.Before = prev.Before
+		}
+	}
+
+	var err error
+	if days == 0 {
+		_, err = r.ch.ExecContext(ctx, "ALTER TABLE ? REMOVE TTL", ch.Ident(table))
+	} else {
+		_, err = r.ch.ExecContext(ctx, "ALTER TABLE ? MODIFY TTL ? + INTERVAL ? DAY", ch.Ident(table), ch.Ident(ttl.column), days)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "set %s ttl", table)
+	}
+
+	if p == nil {
+		return nil
+	}
+	return r.setPruning(ctx, p)
+}
+
+func (r *Repository) GetPrunings(ctx context.Context) ([]*core.Pruning, error) {
+	var ret []*core.Pruning
+
+	err := r.pg.NewSelect().Model(&ret).Order("rule ASC").Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get prunings")
+	}
+
+	// clickhouse keeps removing rows older than ttl
+	for _, p := range ret {
+		if p.TTLDays > 0 {
+			p.Before = time.Now().AddDate(0, 0, -p.TTLDays)
+		}
+	}
+
+	return ret, nil
+}
+
+// GetPrunedAddresses returns addresses, which have at least KeepLatest states updated before the pruning.
+// Pruning has not removed states of other addresses, as it keeps KeepLatest states.
+func (r *Repository) GetPrunedAddresses(ctx context.Context, p *core.Pruning, addresses []*addr.Address) ([]*addr.Address, error) {
+	if p.Rule != core.PruneAccountStates {
+		return nil, errors.Wrapf(core.ErrInvalidArg, "%s rule does not remove account states", p.Rule)
+	}
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	var rows []*core.AccountState
+	err := r.pg.NewSelect().Model(&rows).
+		Column("address").
+		Where("address IN (?)", bun.In(addresses)).
+		Where("updated_at < ?", p.Before).
+		Group("address").
+		Having("count(*) >= ?", p.KeepLatest).
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get pruned addresses")
+	}
+
+	var ret []*addr.Address
+	for _, row := range rows {
+		ret = append(ret, &row.Address)
+	}
+	return ret, nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/uptrace/go-clickhouse/ch"
 
 	"github.com/tonindexer/anton/abi"
 	"github.com/tonindexer/anton/addr"
@@ -17,12 +18,16 @@ import (
 	"github.com/tonindexer/anton/internal/core/repository/block"
 	"github.com/tonindexer/anton/internal/core/repository/contract"
 	"github.com/tonindexer/anton/internal/core/repository/msg"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/repotest"
+	"github.com/tonindexer/anton/internal/core/repository/skip"
 	"github.com/tonindexer/anton/internal/core/repository/tx"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
 var (
-	db *repository.DB
+	db     *repository.DB
+	repoCH *ch.DB // nil in PostgreSQL-only mode
 
 	accountRepo repository.Account
 	// abiRepo     repository.Contract
@@ -31,6 +36,10 @@ var (
 	msgRepo   repository.Message
 )
 
+func TestMain(m *testing.M) {
+	repotest.Main(m)
+}
+
 func initDB() {
 	var err error
 
@@ -41,11 +50,13 @@ func initDB() {
 		panic(err)
 	}
 
-	accountRepo = account.NewRepository(db.CH, db.PG)
+	repoCH = repotest.CH(db.CH)
+
+	accountRepo = account.NewRepository(repoCH, db.PG)
 	// abiRepo = contract.NewRepository(db.PG)
-	blockRepo = block.NewRepository(db.CH, db.PG)
-	txRepo = tx.NewRepository(db.CH, db.PG)
-	msgRepo = msg.NewRepository(db.CH, db.PG)
+	blockRepo = block.NewRepository(repoCH, db.PG)
+	txRepo = tx.NewRepository(repoCH, db.PG)
+	msgRepo = msg.NewRepository(repoCH, db.PG)
 }
 
 func dropTables(t testing.TB) {
@@ -90,6 +101,9 @@ func dropTables(t testing.TB) {
 	_, err = pg.NewDropTable().Model((*core.ContractInterface)(nil)).IfExists().Exec(ctx)
 	require.Nil(t, err)
 
+	_, err = pg.NewDropTable().Model((*core.SkippedAddress)(nil)).IfExists().Exec(ctx)
+	require.Nil(t, err)
+
 	_, err = pg.ExecContext(ctx, "DROP TYPE IF EXISTS message_type")
 	require.Nil(t, err)
 }
@@ -112,6 +126,9 @@ func createTables(t testing.TB) {
 
 	err = contract.CreateTables(ctx, db.PG)
 	require.Nil(t, err)
+
+	err = skip.CreateTables(ctx, db.PG)
+	require.Nil(t, err)
 }
 
 func TestInsertKnownInterfaces(t *testing.T) {
@@ -192,12 +209,20 @@ func TestRelations(t *testing.T) {
 
 		err = accountRepo.AddAccountStates(ctx, dbtx, states)
 		require.Nil(t, err)
+		err = outbox.NewRepository(db.CH, db.PG).WriteBatch(ctx, &core.OutboxBatch{Accounts: states})
+		require.Nil(t, err)
 		err = msgRepo.AddMessages(ctx, dbtx, messages)
 		require.Nil(t, err)
+		err = outbox.NewRepository(db.CH, db.PG).WriteBatch(ctx, &core.OutboxBatch{Messages: messages})
+		require.Nil(t, err)
 		err = txRepo.AddTransactions(ctx, dbtx, transactions)
 		require.Nil(t, err)
+		err = outbox.NewRepository(db.CH, db.PG).WriteBatch(ctx, &core.OutboxBatch{Transactions: transactions})
+		require.Nil(t, err)
 		err = blockRepo.AddBlocks(ctx, dbtx, blocks)
 		require.Nil(t, err)
+		err = outbox.NewRepository(db.CH, db.PG).WriteBatch(ctx, &core.OutboxBatch{Blocks: blocks})
+		require.Nil(t, err)
 
 		err = dbtx.Commit()
 		require.Nil(t, err)
@@ -263,7 +288,7 @@ func TestRelations(t *testing.T) {
 	})
 
 	t.Run("get statistics", func(t *testing.T) {
-		stats, err := aggregate.GetStatistics(ctx, db.CH, db.PG)
+		stats, err := aggregate.GetStatistics(ctx, repoCH, db.PG)
 		require.Nil(t, err)
 		require.Equal(t, int(master.SeqNo), stats.FirstBlock)
 		require.Equal(t, int(master.SeqNo), stats.LastBlock)
@@ -282,6 +307,18 @@ func TestRelations(t *testing.T) {
 		require.Equal(t, 1, stats.AddressTypesCount[0].Count)
 		require.Equal(t, operation, stats.MessageTypesCount[0].Operation)
 		require.Equal(t, 1, stats.MessageTypesCount[0].Count)
+		require.Equal(t, len(core.DefaultSkippedAddresses), len(stats.SkippedAddresses))
+	})
+
+	t.Run("clickhouse and postgres statistics are the same", func(t *testing.T) {
+		repotest.SkipPGOnly(t)
+
+		statsCH, err := aggregate.GetStatistics(ctx, db.CH, db.PG)
+		require.Nil(t, err)
+		statsPG, err := aggregate.GetStatistics(ctx, nil, db.PG)
+		require.Nil(t, err)
+
+		repotest.RequireSameResults(t, statsCH, statsPG)
 	})
 
 	t.Run("drop tables again", func(t *testing.T) {
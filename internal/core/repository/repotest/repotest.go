@@ -0,0 +1,109 @@
+// Package repotest contains helpers shared by repository tests.
+package repotest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+// PGOnly is set on the second run of the tests,
+// which checks repositories in PostgreSQL-only mode.
+var PGOnly bool
+
+// Main runs the package tests with ClickHouse and then in PostgreSQL-only mode.
+// It is called from TestMain.
+func Main(m *testing.M) {
+	if code := m.Run(); code != 0 {
+		os.Exit(code)
+	}
+	PGOnly = true
+	os.Exit(m.Run())
+}
+
+// CH returns the given ClickHouse connection, or nil in PostgreSQL-only mode.
+func CH(ck *ch.DB) *ch.DB {
+	if PGOnly {
+		return nil
+	}
+	return ck
+}
+
+// SkipPGOnly skips the comparison of ClickHouse and PostgreSQL results in PostgreSQL-only mode,
+// as the comparison is already done on the first run.
+func SkipPGOnly(t testing.TB) {
+	if PGOnly {
+		t.Skip("checked with clickhouse")
+	}
+}
+
+// RequireSameResults checks that ClickHouse and PostgreSQL return the same results.
+// The results are compared in JSON, as the drivers scan big integers differently.
+func RequireSameResults(t testing.TB, resCH, resPG any) {
+	rawCH, err := json.Marshal(resCH)
+	require.Nil(t, err)
+	rawPG, err := json.Marshal(resPG)
+	require.Nil(t, err)
+	require.JSONEq(t, string(rawCH), string(rawPG))
+}
+
+// BenchmarkWrite compares the write path before COPY, which inserts rows in a PostgreSQL transaction,
+// with copying them on the connection, which the transaction is begun on.
+// Rows are generated for every iteration before the timer starts.
+func BenchmarkWrite[T any](b *testing.B, pg *bun.DB,
+	generate func() []T,
+	insert func(context.Context, bun.Tx, []T) error,
+	copyFrom func(context.Context, bun.Conn, []T) error,
+) {
+	ctx := context.Background()
+
+	generateAll := func(b *testing.B) [][]T {
+		rows := make([][]T, b.N)
+		for i := range rows {
+			rows[i] = generate()
+		}
+		b.ResetTimer()
+		return rows
+	}
+
+	b.Run("insert", func(b *testing.B) {
+		rows := generateAll(b)
+
+		for i := 0; i < b.N; i++ {
+			tx, err := pg.Begin()
+			require.Nil(b, err)
+
+			err = insert(ctx, tx, rows[i])
+			require.Nil(b, err)
+
+			err = tx.Commit()
+			require.Nil(b, err)
+		}
+	})
+
+	b.Run("copy", func(b *testing.B) {
+		rows := generateAll(b)
+
+		for i := 0; i < b.N; i++ {
+			conn, err := pg.Conn(ctx)
+			require.Nil(b, err)
+
+			tx, err := conn.BeginTx(ctx, nil)
+			require.Nil(b, err)
+
+			err = copyFrom(ctx, conn, rows[i])
+			require.Nil(b, err)
+
+			err = tx.Commit()
+			require.Nil(b, err)
+
+			err = conn.Close()
+			require.Nil(b, err)
+		}
+	})
+}
@@ -23,8 +23,9 @@ func NewRepository(db *bun.DB) *Repository {
 }
 
 func CreateTables(ctx context.Context, pgDB *bun.DB) error {
-	_, err := pgDB.ExecContext(ctx, "CREATE TYPE rescan_task_type AS ENUM (?, ?, ?, ?, ?, ?, ?, ?)",
-		core.AddInterface, core.UpdInterface, core.DelInterface, core.AddGetMethod, core.DelGetMethod, core.UpdGetMethod, core.UpdOperation, core.DelOperation)
+	_, err := pgDB.ExecContext(ctx, "CREATE TYPE rescan_task_type AS ENUM (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		core.AddInterface, core.UpdInterface, core.DelInterface, core.AddGetMethod, core.DelGetMethod, core.UpdGetMethod, core.UpdOperation, core.DelOperation,
+		core.RescanAccounts, core.RescanMessages)
 	if err != nil && !strings.Contains(err.Error(), "already exists") {
 		return errors.Wrap(err, "rescan task type pg create enum")
 	}
@@ -41,10 +42,10 @@ func CreateTables(ctx context.Context, pgDB *bun.DB) error {
 	return nil
 }
 
-func (r *Repository) AddRescanTask(ctx context.Context, task *core.RescanTask) error {
+func (r *Repository) AddRescanTask(ctx context.Context, db bun.IDB, task *core.RescanTask) error {
 	task.ID = 0
 	task.CreatedAt = time.Now()
-	_, err := r.pg.NewInsert().Model(task).Exec(ctx)
+	_, err := db.NewInsert().Model(task).Exec(ctx)
 	if err != nil {
 		return err
 	}
@@ -59,10 +60,25 @@ func (r *Repository) GetUnfinishedRescanTask(ctx context.Context) (bun.Tx, *core
 		return bun.Tx{}, nil, err
 	}
 
+	// tasks of one contract interface are run in order of creation,
+	// targeted tasks without contract name are independent,
+	// tasks locked by other workers are skipped
+	prev := tx.NewSelect().
+		TableExpr("rescan_tasks AS prev").
+		ColumnExpr("1").
+		Where("prev.contract_name != ''").
+		Where("prev.contract_name = rescan_task.contract_name").
+		Where("prev.id < rescan_task.id").
+		Where("prev.finished = ?", false).
+		Where("prev.canceled = ?", false)
+
 	err = tx.NewSelect().Model(&task).
-		For("UPDATE").
-		Where("finished = ?", false).
-		Order("id").
+		For("UPDATE SKIP LOCKED").
+		Where("rescan_task.finished = ?", false).
+		Where("rescan_task.paused = ?", false).
+		Where("rescan_task.canceled = ?", false).
+		Where("NOT EXISTS (?)", prev).
+		Order("rescan_task.priority DESC", "rescan_task.id").
 		Limit(1).
 		Scan(ctx)
 	if err != nil {
@@ -73,7 +89,7 @@ func (r *Repository) GetUnfinishedRescanTask(ctx context.Context) (bun.Tx, *core
 		return bun.Tx{}, nil, err
 	}
 
-	if task.Type != core.DelInterface && task.Type != core.DelOperation {
+	if task.Type != core.DelInterface && task.Type != core.DelOperation && !task.Type.Targeted() {
 		task.Contract = new(core.ContractInterface)
 		err := r.pg.NewSelect().Model(task.Contract).
 			Where("name = ?", task.ContractName).
@@ -114,6 +130,9 @@ func (r *Repository) SetRescanTask(ctx context.Context, tx bun.Tx, task *core.Re
 		Set("finished = ?finished").
 		Set("last_address = ?last_address").
 		Set("last_tx_lt = ?last_tx_lt").
+		Set("last_hash = ?last_hash").
+		Set("processed = ?processed").
+		Set("total = ?total").
 		Set("updated_at = ?", time.Now()).
 		WherePK().
 		Exec(ctx)
@@ -127,3 +146,123 @@ func (r *Repository) SetRescanTask(ctx context.Context, tx bun.Tx, task *core.Re
 
 	return nil
 }
+
+func (r *Repository) AddChildRescanTask(ctx context.Context, tx bun.Tx, task *core.RescanTask) error {
+	var child core.RescanTask
+
+	// the child task is not started until it has a checkpoint,
+	// a child task locked by a running batch is skipped
+	err := tx.NewSelect().Model(&child).
+		For("UPDATE SKIP LOCKED").
+		Where("parent_id = ?", task.ParentID).
+		Where("type = ?", task.Type).
+		Where("finished = ?", false).
+		Where("canceled = ?", false).
+		Where("last_address IS NULL").
+		Where("last_hash IS NULL").
+		Order("id DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		task.ID = 0
+		task.CreatedAt = time.Now()
+		_, err := tx.NewInsert().Model(task).Exec(ctx)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	child.AccountRanges = append(child.AccountRanges, task.AccountRanges...)
+	child.Total = 0 // estimated on the first batch
+	_, err = tx.NewUpdate().Model(&child).
+		Set("account_ranges = ?account_ranges").
+		Set("total = ?total").
+		Set("updated_at = ?", time.Now()).
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	task.ID = child.ID
+	return nil
+}
+
+func fillStatus(task *core.RescanTask) {
+	task.Status = task.GetStatus()
+	task.Progress = task.GetProgress()
+}
+
+func (r *Repository) GetRescanTasks(ctx context.Context, all bool) ([]*core.RescanTask, error) {
+	var ret []*core.RescanTask
+
+	q := r.pg.NewSelect().Model(&ret)
+	if !all {
+		q = q.Where("finished = ?", false).Where("canceled = ?", false)
+	}
+	err := q.Order("finished", "canceled", "paused", "priority DESC", "id").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, task := range ret {
+		fillStatus(task)
+	}
+	return ret, nil
+}
+
+func (r *Repository) GetRescanTask(ctx context.Context, id int) (*core.RescanTask, error) {
+	var task core.RescanTask
+
+	err := r.pg.NewSelect().Model(&task).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrapf(core.ErrNotFound, "no rescan task with id %d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fillStatus(&task)
+	return &task, nil
+}
+
+// updateUnfinishedTask updates the task if it is not finished or canceled
+func (r *Repository) updateUnfinishedTask(ctx context.Context, id int, column string, value any) error {
+	task, err := r.GetRescanTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	if task.Finished || task.Canceled {
+		return errors.Wrapf(core.ErrInvalidArg, "rescan task %d is %s", id, task.Status)
+	}
+
+	_, err = r.pg.NewUpdate().Model((*core.RescanTask)(nil)).
+		Set("? = ?", bun.Ident(column), value).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+func (r *Repository) SetRescanTaskPaused(ctx context.Context, id int, paused bool) error {
+	return r.updateUnfinishedTask(ctx, id, "paused", paused)
+}
+
+func (r *Repository) SetRescanTaskPriority(ctx context.Context, id int, priority int) error {
+	return r.updateUnfinishedTask(ctx, id, "priority", priority)
+}
+
+func (r *Repository) CancelRescanTask(ctx context.Context, id int) error {
+	return r.updateUnfinishedTask(ctx, id, "canceled", true)
+}
+
+func (r *Repository) AddRescanTaskError(ctx context.Context, id int, taskErr error) error {
+	_, err := r.pg.NewUpdate().Model((*core.RescanTask)(nil)).
+		Set("errors_count = errors_count + 1").
+		Set("last_error = ?", taskErr.Error()).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
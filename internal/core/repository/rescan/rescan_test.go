@@ -120,12 +120,12 @@ func TestRepository_CreateNewRescanTask(t *testing.T) {
 	})
 
 	t.Run("insert interface", func(t *testing.T) {
-		err := contract.NewRepository(pg).AddInterface(ctx, i)
+		err := contract.NewRepository(pg).AddInterface(ctx, pg, i)
 		require.Nil(t, err)
 	})
 
 	t.Run("create new task", func(t *testing.T) {
-		err := repo.AddRescanTask(ctx, &task)
+		err := repo.AddRescanTask(ctx, pg, &task)
 		require.NoError(t, err)
 	})
 
@@ -161,7 +161,7 @@ func TestRepository_CreateNewRescanTask(t *testing.T) {
 	})
 
 	t.Run("create second task", func(t *testing.T) {
-		err := repo.AddRescanTask(ctx, &task)
+		err := repo.AddRescanTask(ctx, pg, &task)
 		require.NoError(t, err)
 
 		tx, task, err := repo.GetUnfinishedRescanTask(ctx)
@@ -178,3 +178,218 @@ func TestRepository_CreateNewRescanTask(t *testing.T) {
 		dropTables(t)
 	})
 }
+
+func TestRepository_ManageRescanTasks(t *testing.T) {
+	initdb(t)
+
+	i := &core.ContractInterface{
+		Name:            known.NFTItem,
+		Addresses:       []*addr.Address{rndm.Address()},
+		Code:            rndm.Bytes(128),
+		GetMethodHashes: rndm.GetMethodHashes(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	t.Run("drop tables", func(t *testing.T) {
+		dropTables(t)
+	})
+
+	t.Run("create tables", func(t *testing.T) {
+		createTables(t)
+	})
+
+	t.Run("insert interface and tasks", func(t *testing.T) {
+		err := contract.NewRepository(pg).AddInterface(ctx, pg, i)
+		require.Nil(t, err)
+
+		for it := 0; it < 3; it++ {
+			err := repo.AddRescanTask(ctx, pg, &core.RescanTask{Type: core.AddInterface, ContractName: known.NFTItem})
+			require.NoError(t, err)
+		}
+
+		err = repo.AddRescanTask(ctx, pg, &core.RescanTask{Type: core.DelInterface, ContractName: known.NFTCollection})
+		require.NoError(t, err)
+	})
+
+	t.Run("pause task", func(t *testing.T) {
+		err := repo.SetRescanTaskPaused(ctx, 1, true)
+		require.NoError(t, err)
+
+		// tasks of the same interface wait for the paused one
+		tx, task, err := repo.GetUnfinishedRescanTask(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 4, task.ID)
+		require.NoError(t, tx.Rollback())
+	})
+
+	t.Run("prioritize tasks", func(t *testing.T) {
+		err := repo.SetRescanTaskPaused(ctx, 1, false)
+		require.NoError(t, err)
+
+		err = repo.SetRescanTaskPriority(ctx, 3, 10)
+		require.NoError(t, err)
+
+		tx, task, err := repo.GetUnfinishedRescanTask(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, task.ID)
+		require.NoError(t, tx.Rollback())
+
+		err = repo.SetRescanTaskPriority(ctx, 4, 5)
+		require.NoError(t, err)
+
+		tx, task, err = repo.GetUnfinishedRescanTask(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 4, task.ID)
+		require.NoError(t, tx.Rollback())
+	})
+
+	t.Run("skip locked tasks", func(t *testing.T) {
+		tx1, task1, err := repo.GetUnfinishedRescanTask(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 4, task1.ID)
+
+		tx2, task2, err := repo.GetUnfinishedRescanTask(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, task2.ID)
+
+		_, _, err = repo.GetUnfinishedRescanTask(ctx)
+		require.True(t, errors.Is(err, core.ErrNotFound))
+
+		require.NoError(t, tx1.Rollback())
+		require.NoError(t, tx2.Rollback())
+	})
+
+	t.Run("cancel tasks", func(t *testing.T) {
+		err := repo.CancelRescanTask(ctx, 3)
+		require.NoError(t, err)
+
+		err = repo.SetRescanTaskPaused(ctx, 3, true)
+		require.True(t, errors.Is(err, core.ErrInvalidArg))
+
+		err = repo.CancelRescanTask(ctx, 1)
+		require.NoError(t, err)
+
+		err = repo.SetRescanTaskPaused(ctx, 4, true)
+		require.NoError(t, err)
+
+		tx, task, err := repo.GetUnfinishedRescanTask(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, task.ID)
+		require.NoError(t, tx.Rollback())
+	})
+
+	t.Run("add task error", func(t *testing.T) {
+		err := repo.AddRescanTaskError(ctx, 2, errors.New("cannot parse"))
+		require.NoError(t, err)
+
+		task, err := repo.GetRescanTask(ctx, 2)
+		require.NoError(t, err)
+		require.Equal(t, 1, task.ErrorsCount)
+		require.Equal(t, "cannot parse", task.LastError)
+		require.Equal(t, core.RescanTaskPending, task.Status)
+	})
+
+	t.Run("list tasks", func(t *testing.T) {
+		tasks, err := repo.GetRescanTasks(ctx, false)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(tasks))
+		require.Equal(t, 2, tasks[0].ID)
+		require.Equal(t, core.RescanTaskPaused, tasks[1].Status)
+
+		tasks, err = repo.GetRescanTasks(ctx, true)
+		require.NoError(t, err)
+		require.Equal(t, 4, len(tasks))
+		require.Equal(t, core.RescanTaskCanceled, tasks[2].Status)
+
+		_, err = repo.GetRescanTask(ctx, 5)
+		require.True(t, errors.Is(err, core.ErrNotFound))
+	})
+
+	t.Run("targeted task", func(t *testing.T) {
+		addresses := []*addr.Address{rndm.Address(), rndm.Address()}
+
+		err := repo.AddRescanTask(ctx, pg, &core.RescanTask{
+			Type:            core.RescanAccounts,
+			Addresses:       addresses,
+			FromMasterSeqNo: 100,
+			ToMasterSeqNo:   200,
+			Priority:        20,
+		})
+		require.NoError(t, err)
+
+		tx, task, err := repo.GetUnfinishedRescanTask(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 5, task.ID)
+		require.Equal(t, addresses, task.Addresses)
+		require.Equal(t, uint32(100), task.FromMasterSeqNo)
+		require.Nil(t, task.Contract)
+
+		task.LastHash = []byte{1, 2, 3}
+		err = repo.SetRescanTask(ctx, tx, task)
+		require.NoError(t, err)
+
+		task, err = repo.GetRescanTask(ctx, 5)
+		require.NoError(t, err)
+		require.Equal(t, []byte{1, 2, 3}, task.LastHash)
+		require.Equal(t, core.RescanTaskRunning, task.Status)
+
+		err = repo.SetRescanTaskPaused(ctx, 5, true)
+		require.NoError(t, err)
+
+		err = repo.AddRescanTask(ctx, pg, &core.RescanTask{
+			Type:      core.RescanMessages,
+			Addresses: addresses,
+			Priority:  20,
+		})
+		require.NoError(t, err)
+
+		// targeted tasks without contract name do not wait for each other
+		tx, task, err = repo.GetUnfinishedRescanTask(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 6, task.ID)
+		require.NoError(t, tx.Rollback())
+	})
+
+	t.Run("child task", func(t *testing.T) {
+		a := *rndm.Address()
+
+		addChild := func(r *core.AccountLTRange) int {
+			tx, err := pg.BeginTx(ctx, nil)
+			require.NoError(t, err)
+
+			child := &core.RescanTask{Type: core.RescanMessages, ParentID: 2, AccountRanges: []*core.AccountLTRange{r}}
+			err = repo.AddChildRescanTask(ctx, tx, child)
+			require.NoError(t, err)
+			require.NoError(t, tx.Commit())
+
+			return child.ID
+		}
+
+		// pending child task is extended
+		id := addChild(&core.AccountLTRange{Address: a, FromTxLT: 10, ToTxLT: 20})
+		require.Equal(t, 7, id)
+		require.Equal(t, 7, addChild(&core.AccountLTRange{Address: a, FromTxLT: 30}))
+
+		task, err := repo.GetRescanTask(ctx, 7)
+		require.NoError(t, err)
+		require.Equal(t, 2, task.ParentID)
+		require.Equal(t, []*core.AccountLTRange{
+			{Address: a, FromTxLT: 10, ToTxLT: 20},
+			{Address: a, FromTxLT: 30},
+		}, task.AccountRanges)
+
+		// started child task is not changed
+		tx, err := pg.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		task.LastHash = []byte{1}
+		require.NoError(t, repo.SetRescanTask(ctx, tx, task))
+
+		require.Equal(t, 8, addChild(&core.AccountLTRange{Address: a, FromTxLT: 40}))
+	})
+
+	t.Run("drop tables", func(t *testing.T) {
+		dropTables(t)
+	})
+}
@@ -0,0 +1,131 @@
+package skip
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
+)
+
+var _ core.SkipRepository = (*Repository)(nil)
+
+type Repository struct {
+	pg *bun.DB
+}
+
+func NewRepository(db *bun.DB) *Repository {
+	return &Repository{pg: db}
+}
+
+func CreateTables(ctx context.Context, pgDB *bun.DB) error {
+	_, err := pgDB.NewCreateTable().
+		Model(&core.SkippedAddress{}).
+		IfNotExists().
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "skipped addresses pg create table")
+	}
+
+	for _, s := range core.DefaultSkippedAddresses {
+		err := insertSkippedAddress(ctx, pgDB, s, "CONFLICT (address) DO NOTHING")
+		if err != nil {
+			return errors.Wrapf(err, "insert default skipped address %s", s.Address.Base64())
+		}
+	}
+
+	return nil
+}
+
+func insertSkippedAddress(ctx context.Context, pgDB *bun.DB, s *core.SkippedAddress, on string) error {
+	s.CreatedAt = time.Now()
+
+	_, err := pgDB.NewInsert().Model(s).
+		// address is set explicitly, as the zero burn address is stored as null otherwise
+		Value("address", "?", s.Address[:]).
+		On(on).
+		Exec(ctx)
+	return err
+}
+
+func (r *Repository) AddSkippedAddress(ctx context.Context, s *core.SkippedAddress) error {
+	if len(s.Scopes) == 0 {
+		return errors.Wrap(core.ErrInvalidArg, "no skip scopes")
+	}
+
+	err := insertSkippedAddress(ctx, r.pg, s, "CONFLICT (address) DO UPDATE")
+	if err != nil {
+		return errors.Wrap(err, "pg insert skipped address")
+	}
+
+	return nil
+}
+
+func (r *Repository) DelSkippedAddress(ctx context.Context, a addr.Address) error {
+	res, err := r.pg.NewDelete().Model((*core.SkippedAddress)(nil)).
+		Where("address = ?", a[:]).
+		Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "pg delete skipped address")
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.Wrap(core.ErrNotFound, "address is not skipped")
+	}
+
+	return nil
+}
+
+func (r *Repository) GetSkippedAddresses(ctx context.Context) ([]*core.SkippedAddress, error) {
+	var ret []*core.SkippedAddress
+
+	err := r.pg.NewSelect().Model(&ret).Order("created_at").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Watch loads the skip list from the database to core.SkipAddress
+// and reloads it with the given interval until the context is done.
+func Watch(ctx context.Context, repo core.SkipRepository, interval time.Duration) error {
+	load := func() error {
+		list, err := repo.GetSkippedAddresses(ctx)
+		if err != nil {
+			return errors.Wrap(err, "get skipped addresses")
+		}
+		core.SetSkippedAddresses(list)
+		return nil
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := load(); err != nil {
+					log.Error().Err(err).Msg("cannot reload skipped addresses")
+				}
+			}
+		}
+	}()
+
+	return nil
+}
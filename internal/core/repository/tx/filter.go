@@ -5,10 +5,10 @@ import (
 	"strings"
 
 	"github.com/uptrace/bun"
-	"github.com/uptrace/go-clickhouse/ch"
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
 func (r *Repository) filterTx(ctx context.Context, req *filter.TransactionsReq) (ret []*core.Transaction, err error) {
@@ -70,10 +70,7 @@ func (r *Repository) filterTx(ctx context.Context, req *filter.TransactionsReq)
 	return ret, err
 }
 
-func (r *Repository) countTx(ctx context.Context, req *filter.TransactionsReq) (int, error) {
-	q := r.ch.NewSelect().
-		Model((*core.Transaction)(nil))
-
+func countTxQuery[Q dialect.Query[Q]](q Q, d *dialect.Dialect, req *filter.TransactionsReq) Q {
 	if len(req.Hash) > 0 {
 		q = q.Where("hash = ?", req.Hash)
 	}
@@ -81,7 +78,7 @@ func (r *Repository) countTx(ctx context.Context, req *filter.TransactionsReq) (
 		q = q.Where("in_msg_hash = ?", req.InMsgHash)
 	}
 	if len(req.Addresses) > 0 {
-		q = q.Where("address in (?)", ch.In(req.Addresses))
+		q = q.Where("address in (?)", d.In(req.Addresses))
 	}
 	if req.Workchain != nil {
 		q = q.Where("workchain = ?", *req.Workchain)
@@ -94,8 +91,14 @@ func (r *Repository) countTx(ctx context.Context, req *filter.TransactionsReq) (
 	if req.CreatedLT != nil {
 		q = q.Where("created_lt = ?", *req.CreatedLT)
 	}
+	return q
+}
 
-	return q.Count(ctx)
+func (r *Repository) countTx(ctx context.Context, req *filter.TransactionsReq) (int, error) {
+	if r.ch == nil {
+		return countTxQuery(r.pg.NewSelect().Model((*core.Transaction)(nil)), dialect.PG, req).Count(ctx)
+	}
+	return countTxQuery(r.ch.NewSelect().Model((*core.Transaction)(nil)), dialect.CH, req).Count(ctx)
 }
 
 func (r *Repository) FilterTransactions(ctx context.Context, req *filter.TransactionsReq) (*filter.TransactionsRes, error) {
@@ -10,6 +10,7 @@ import (
 	"github.com/tonindexer/anton/addr"
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/filter"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
@@ -35,6 +36,8 @@ func TestRepository_FilterTransactions(t *testing.T) {
 
 		err = repo.AddTransactions(ctx, dbtx, transactions)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Transactions: transactions})
+		require.Nil(t, err)
 
 		err = dbtx.Commit()
 		require.Nil(t, err)
@@ -2,23 +2,19 @@ package tx
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/pkg/errors"
-	"github.com/uptrace/go-clickhouse/ch"
 
 	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate/history"
+	"github.com/tonindexer/anton/internal/core/repository/dialect"
 )
 
-func (r *Repository) AggregateTransactionsHistory(ctx context.Context, req *history.TransactionsReq) (*history.TransactionsRes, error) {
+func aggregateTransactionsHistory[Q dialect.Query[Q]](ctx context.Context, q Q, d *dialect.Dialect, req *history.TransactionsReq) (*history.TransactionsRes, error) {
 	var res history.TransactionsRes
 
-	q := r.ch.NewSelect().
-		Model((*core.Transaction)(nil))
-
 	if len(req.Addresses) > 0 {
-		q = q.Where("address in (?)", ch.In(req.Addresses))
+		q = q.Where("address in (?)", d.In(req.Addresses))
 	}
 	if req.Workchain != nil {
 		q = q.Where("workchain = ?", *req.Workchain)
@@ -26,16 +22,16 @@ func (r *Repository) AggregateTransactionsHistory(ctx context.Context, req *hist
 
 	switch req.Metric {
 	case history.TransactionCount:
-		q = q.ColumnExpr("count() as value")
+		q = q.ColumnExpr(d.Count() + " as value")
 	default:
 		return nil, errors.Wrapf(core.ErrInvalidArg, "invalid transaction metric %s", req.Metric)
 	}
 
-	rounding, err := history.GetRoundingFunction(req.Interval)
+	rounding, err := d.Rounding(req.Interval, "created_at")
 	if err != nil {
 		return nil, err
 	}
-	q = q.ColumnExpr(fmt.Sprintf(rounding, "created_at") + " as timestamp")
+	q = q.ColumnExpr(rounding + " as timestamp")
 	q = q.Group("timestamp")
 
 	if !req.From.IsZero() {
@@ -53,3 +49,10 @@ func (r *Repository) AggregateTransactionsHistory(ctx context.Context, req *hist
 
 	return &res, nil
 }
+
+func (r *Repository) AggregateTransactionsHistory(ctx context.Context, req *history.TransactionsReq) (*history.TransactionsRes, error) {
+	if r.ch == nil {
+		return aggregateTransactionsHistory(ctx, r.pg.NewSelect().Model((*core.Transaction)(nil)), dialect.PG, req)
+	}
+	return aggregateTransactionsHistory(ctx, r.ch.NewSelect().Model((*core.Transaction)(nil)), dialect.CH, req)
+}
@@ -8,7 +8,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/tonindexer/anton/addr"
+	"github.com/tonindexer/anton/internal/core"
 	"github.com/tonindexer/anton/internal/core/aggregate/history"
+	"github.com/tonindexer/anton/internal/core/repository/outbox"
+	"github.com/tonindexer/anton/internal/core/repository/repotest"
+	"github.com/tonindexer/anton/internal/core/repository/tx"
 	"github.com/tonindexer/anton/internal/core/rndm"
 )
 
@@ -37,9 +41,13 @@ func TestRepository_AggregateTransactionsHistory(t *testing.T) {
 
 		err = repo.AddTransactions(ctx, dbtx, transactions)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Transactions: transactions})
+		require.Nil(t, err)
 
 		err = repo.AddTransactions(ctx, dbtx, addrTransactions)
 		require.Nil(t, err)
+		err = outbox.NewRepository(ck, pg).WriteBatch(ctx, &core.OutboxBatch{Transactions: addrTransactions})
+		require.Nil(t, err)
 
 		err = dbtx.Commit()
 		require.Nil(t, err)
@@ -77,6 +85,28 @@ func TestRepository_AggregateTransactionsHistory(t *testing.T) {
 		require.Equal(t, 20, res.CountRes[0].Value)
 	})
 
+	t.Run("clickhouse and postgres results are the same", func(t *testing.T) {
+		repotest.SkipPGOnly(t)
+
+		for _, req := range []*history.TransactionsReq{
+			{Metric: history.TransactionCount, ReqParams: history.ReqParams{Interval: time.Hour}},
+			{Metric: history.TransactionCount, Workchain: new(int32), ReqParams: history.ReqParams{Interval: time.Hour}},
+			{Metric: history.TransactionCount, Addresses: []*addr.Address{a}, ReqParams: history.ReqParams{Interval: time.Hour}},
+		} {
+			resCH, err := tx.NewRepository(ck, pg).AggregateTransactionsHistory(ctx, req)
+			require.Nil(t, err)
+			resPG, err := tx.NewRepository(nil, pg).AggregateTransactionsHistory(ctx, req)
+			require.Nil(t, err)
+
+			for _, res := range []*history.TransactionsRes{resCH, resPG} {
+				for i := range res.CountRes {
+					res.CountRes[i].Timestamp = res.CountRes[i].Timestamp.UTC()
+				}
+			}
+			repotest.RequireSameResults(t, resCH, resPG)
+		}
+	})
+
 	t.Run("drop tables again", func(t *testing.T) {
 		dropTables(t)
 	})
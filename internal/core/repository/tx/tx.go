@@ -75,16 +75,18 @@ func createIndexes(ctx context.Context, pgDB *bun.DB) error {
 }
 
 func CreateTables(ctx context.Context, chDB *ch.DB, pgDB *bun.DB) error {
-	_, err := chDB.NewCreateTable().
-		IfNotExists().
-		Engine("ReplacingMergeTree").
-		Model(&core.Transaction{}).
-		Exec(ctx)
-	if err != nil {
-		return errors.Wrap(err, "transaction ch create table")
+	if chDB != nil {
+		_, err := chDB.NewCreateTable().
+			IfNotExists().
+			Engine("ReplacingMergeTree").
+			Model(&core.Transaction{}).
+			Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "transaction ch create table")
+		}
 	}
 
-	_, err = pgDB.NewCreateTable().
+	_, err := pgDB.NewCreateTable().
 		Model(&core.Transaction{}).
 		IfNotExists().
 		// ForeignKey(`(in_msg_hash) REFERENCES messages(hash)`). // TODO: fix tests with fk
@@ -110,10 +112,9 @@ func (r *Repository) AddTransactions(ctx context.Context, tx bun.Tx, transaction
 		return err
 	}
 
-	_, err = r.ch.NewInsert().Model(&transactions).Exec(ctx)
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
+
+func (r *Repository) CopyTransactions(ctx context.Context, conn bun.Conn, transactions []*core.Transaction) error {
+	return repository.CopyFrom(ctx, conn, transactions)
+}
@@ -47,14 +47,48 @@ const (
 
 	// DelOperation task is the same algorithm, as UpdOperation, but it removes the parsed data.
 	DelOperation RescanTaskType = "del_operation"
+
+	// RescanAccounts is a targeted task, it clears parsed data of account states selected by the task scope,
+	// determines their interfaces once again and executes all suitable get-methods.
+	RescanAccounts RescanTaskType = "rescan_accounts"
+
+	// RescanMessages is a targeted task, it parses messages selected by the task scope once again,
+	// using interfaces of source and destination accounts at the time of the message.
+	RescanMessages RescanTaskType = "rescan_messages"
+)
+
+// Targeted returns true if the task is scoped by addresses, blocks, time or message hashes
+// instead of a contract interface or operation.
+func (t RescanTaskType) Targeted() bool {
+	return t == RescanAccounts || t == RescanMessages
+}
+
+type RescanTaskStatus string
+
+const (
+	RescanTaskPending  RescanTaskStatus = "pending"
+	RescanTaskRunning  RescanTaskStatus = "running"
+	RescanTaskPaused   RescanTaskStatus = "paused"
+	RescanTaskCanceled RescanTaskStatus = "canceled"
+	RescanTaskFinished RescanTaskStatus = "finished"
 )
 
 type RescanTask struct {
 	bun.BaseModel `bun:"table:rescan_tasks" json:"-"`
 
-	ID       int            `bun:",pk,autoincrement"`
-	Finished bool           `bun:"finished,notnull"`
-	Type     RescanTaskType `bun:"type:rescan_task_type,notnull"`
+	ID       int            `bun:",pk,autoincrement" json:"id"`
+	Finished bool           `bun:"finished,notnull" json:"finished"`
+	Type     RescanTaskType `bun:"type:rescan_task_type,notnull" json:"type"`
+
+	// paused and canceled tasks are not taken by the rescan service,
+	// tasks with higher priority are taken first
+	Paused   bool `bun:"paused,notnull,default:false" json:"paused"`
+	Canceled bool `bun:"canceled,notnull,default:false" json:"canceled"`
+	Priority int  `bun:"priority,notnull,default:0" json:"priority"`
+
+	// Status and Progress are filled on reading the task
+	Status   RescanTaskStatus `bun:"-" json:"status"`
+	Progress float64          `bun:"-" json:"progress"`
 
 	// contract being rescanned
 	ContractName abi.ContractName   `bun:",notnull" json:"contract_name"`
@@ -69,16 +103,107 @@ type RescanTask struct {
 	OperationID uint32             `bun:",nullzero" json:"operation_id,omitempty"`
 	Operation   *ContractOperation `bun:"rel:has-one,join:contract_name=contract_name,join:outgoing=outgoing,join:operation_id=operation_id" json:"contract_operation"`
 
+	// scope of targeted tasks, unset fields do not narrow the selection
+	Addresses       []*addr.Address   `bun:"type:bytea[]" json:"addresses,omitempty"`
+	FromMasterSeqNo uint32            `bun:"type:integer,nullzero" json:"from_master_seq_no,omitempty"`
+	ToMasterSeqNo   uint32            `bun:"type:integer,nullzero" json:"to_master_seq_no,omitempty"`
+	FromTime        time.Time         `bun:"type:timestamp without time zone,nullzero" json:"from_time,omitempty"`
+	ToTime          time.Time         `bun:"type:timestamp without time zone,nullzero" json:"to_time,omitempty"`
+	MessageHashes   [][]byte          `bun:"type:bytea[],array" json:"message_hashes,omitempty"`
+	AccountRanges   []*AccountLTRange `bun:"type:jsonb" json:"account_ranges,omitempty"`
+
+	// ParentID is the task, which changed account interfaces, for messages rescan tasks added by it
+	ParentID int `bun:"type:integer,nullzero" json:"parent_id,omitempty"`
+
 	// checkpoint
 	LastAddress *addr.Address `bun:"type:bytea" json:"last_address"`
 	LastTxLt    uint64        `bun:"type:bigint" json:"last_tx_lt"`
+	LastHash    []byte        `bun:"type:bytea" json:"last_hash,omitempty"` // for rescan_messages tasks
+
+	// progress, total is an estimated number of account states or messages to rescan
+	Processed int64 `bun:"type:bigint,notnull,default:0" json:"processed"`
+	Total     int64 `bun:"type:bigint,notnull,default:0" json:"total"`
+
+	ErrorsCount int    `bun:"type:integer,notnull,default:0" json:"errors_count"`
+	LastError   string `bun:",nullzero" json:"last_error,omitempty"`
 
 	UpdatedAt time.Time `bun:"type:timestamp without time zone,notnull" json:"updated_at"`
 	CreatedAt time.Time `bun:"type:timestamp without time zone,notnull" json:"created_at"`
 }
 
+// GetStatus returns the status of the task, a task is running after the first processed batch
+func (t *RescanTask) GetStatus() RescanTaskStatus {
+	switch {
+	case t.Finished:
+		return RescanTaskFinished
+	case t.Canceled:
+		return RescanTaskCanceled
+	case t.Paused:
+		return RescanTaskPaused
+	case t.LastAddress != nil || t.LastHash != nil || t.Processed > 0:
+		return RescanTaskRunning
+	default:
+		return RescanTaskPending
+	}
+}
+
+// GetProgress returns the estimated share of the processed account states or messages
+func (t *RescanTask) GetProgress() float64 {
+	switch {
+	case t.Finished:
+		return 1
+	case t.Total == 0:
+		return 0
+	case t.Processed >= t.Total:
+		return 0.99 // the estimation is outdated
+	default:
+		return float64(t.Processed) / float64(t.Total)
+	}
+}
+
+// BlockRange is a range of block sequence numbers in one shard
+type BlockRange struct {
+	Workchain int32  `bun:"workchain"`
+	Shard     int64  `bun:"shard"`
+	FromSeqNo uint32 `bun:"from_seq_no"`
+	ToSeqNo   uint32 `bun:"to_seq_no"`
+}
+
+// AccountLTRange selects messages of the account sent or received
+// in transactions with logical time from FromTxLT to ToTxLT (exclusive), zero ToTxLT does not limit the range.
+type AccountLTRange struct {
+	Address  addr.Address `json:"address"`
+	FromTxLT uint64       `json:"from_tx_lt"`
+	ToTxLT   uint64       `json:"to_tx_lt,omitempty"`
+}
+
+// RescanScope selects account states or messages for targeted rescan tasks,
+// empty fields do not narrow the selection.
+type RescanScope struct {
+	Addresses     []*addr.Address
+	Blocks        []*BlockRange // blocks committed in the masterchain range of the task
+	FromTime      time.Time
+	ToTime        time.Time
+	MessageHashes [][]byte
+	AccountRanges []*AccountLTRange // for messages only
+}
+
 type RescanRepository interface {
-	AddRescanTask(ctx context.Context, task *RescanTask) error
+	AddRescanTask(ctx context.Context, db bun.IDB, task *RescanTask) error
 	GetUnfinishedRescanTask(context.Context) (bun.Tx, *RescanTask, error)
 	SetRescanTask(context.Context, bun.Tx, *RescanTask) error
+
+	// AddChildRescanTask adds a task with the set ParentID in the transaction of the parent task batch.
+	// Account ranges are appended to a not yet started task of the same parent and type, if there is one.
+	AddChildRescanTask(ctx context.Context, tx bun.Tx, task *RescanTask) error
+
+	// GetRescanTasks returns tasks ordered as they are taken by the rescan service,
+	// finished and canceled tasks are returned only with the all flag.
+	GetRescanTasks(ctx context.Context, all bool) ([]*RescanTask, error)
+	GetRescanTask(ctx context.Context, id int) (*RescanTask, error)
+
+	SetRescanTaskPaused(ctx context.Context, id int, paused bool) error
+	SetRescanTaskPriority(ctx context.Context, id int, priority int) error
+	CancelRescanTask(ctx context.Context, id int) error
+	AddRescanTaskError(ctx context.Context, id int, taskErr error) error
 }
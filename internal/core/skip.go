@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/tonindexer/anton/addr"
+)
+
+type SkipScope string
+
+const (
+	// SkipFetching disables fetching of account states,
+	// heavy accounts are fetched from the liteserver only on API requests.
+	SkipFetching SkipScope = "fetch"
+
+	// SkipEmulation disables get-method execution and message emulation on account states.
+	SkipEmulation SkipScope = "emulation"
+
+	// SkipParsing disables parsing of messages going to or from the account.
+	SkipParsing SkipScope = "parsing"
+)
+
+type SkippedAddress struct {
+	bun.BaseModel `bun:"table:skipped_addresses" json:"-"`
+
+	Address addr.Address `bun:"type:bytea,pk,notnull" json:"address"`
+	Scopes  []SkipScope  `bun:"type:text[],array,notnull" json:"scopes"`
+	Reason  string       `bun:",notnull" json:"reason"`
+
+	ExpiresAt *time.Time `bun:"type:timestamp without time zone" json:"expires_at,omitempty"`
+	CreatedAt time.Time  `bun:"type:timestamp without time zone,notnull" json:"created_at"`
+}
+
+func (s *SkippedAddress) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+func (s *SkippedAddress) HasScope(scope SkipScope) bool {
+	for _, x := range s.Scopes {
+		if x == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type SkipRepository interface {
+	// AddSkippedAddress adds the skip rule or replaces the existing rule for the same address.
+	AddSkippedAddress(context.Context, *SkippedAddress) error
+	DelSkippedAddress(context.Context, addr.Address) error
+	GetSkippedAddresses(context.Context) ([]*SkippedAddress, error)
+}
+
+func defaultSkippedAddress(b64, reason string, scopes ...SkipScope) *SkippedAddress {
+	return &SkippedAddress{Address: *addr.MustFromBase64(b64), Scopes: scopes, Reason: reason}
+}
+
+// DefaultSkippedAddresses are used until the skip list is loaded from the database.
+// Also, they are inserted to the database on its creation.
+var DefaultSkippedAddresses = []*SkippedAddress{
+	defaultSkippedAddress("EQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAM9c", "burn address", SkipFetching),
+	defaultSkippedAddress("Ef8AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAADAU", "system contract", SkipFetching),
+	defaultSkippedAddress("Ef8zMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzMzM0vF", "elector contract", SkipFetching),
+	defaultSkippedAddress("Ef80UXx731GHxVr0-LYf3DIViMerdo3uJLAG3ykQZFjXz2kW", "log tests contract", SkipFetching),
+	defaultSkippedAddress("Ef9VVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVVbxn", "config contract", SkipFetching),
+	defaultSkippedAddress("EQAHI1vGuw7d4WG-CtfDrWqEPNtmUuKjKFEFeJmZaqqfWTvW", "BSC Bridge Collector", SkipFetching),
+	defaultSkippedAddress("EQCuzvIOXLjH2tv35gY4tzhIvXCqZWDuK9kUhFGXKLImgxT5", "ETH Bridge Collector", SkipFetching),
+	defaultSkippedAddress("EQA2u5Z5Fn59EUvTI-TIrX8PIGKQzNj3qLixdCPPujfJleXC", "strange heavy testnet address", SkipFetching),
+	defaultSkippedAddress("EQA2Pnxp0rMB9L6SU2z1VqfMIFIfutiTjQWFEXnwa_zPh0P3", "strange heavy testnet address", SkipFetching),
+	defaultSkippedAddress("EQDhIloDu1FWY9WFAgQDgw0RjuT5bLkf15Rmd5LCG3-0hyoe", "strange heavy testnet address", SkipFetching),
+	defaultSkippedAddress("EQAWBIxrfQDExJSfFmE5UL1r9drse0dQx_eaV8w9S77VK32F", "tongo emulator segmentation fault", SkipFetching, SkipEmulation),
+}
+
+var skipList = struct {
+	sync.RWMutex
+	rules map[addr.Address]*SkippedAddress
+}{rules: makeSkipRules(DefaultSkippedAddresses)}
+
+func makeSkipRules(list []*SkippedAddress) map[addr.Address]*SkippedAddress {
+	rules := make(map[addr.Address]*SkippedAddress, len(list))
+	for _, s := range list {
+		rules[s.Address] = s
+	}
+	return rules
+}
+
+// SetSkippedAddresses replaces the skip list used by SkipAddress.
+func SetSkippedAddresses(list []*SkippedAddress) {
+	rules := makeSkipRules(list)
+
+	skipList.Lock()
+	skipList.rules = rules
+	skipList.Unlock()
+}
+
+// SkipAddress checks if the given address is skipped in the given scope.
+func SkipAddress(a addr.Address, scope SkipScope) bool {
+	skipList.RLock()
+	rule, ok := skipList.rules[a]
+	skipList.RUnlock()
+
+	return ok && rule.HasScope(scope) && !rule.Expired(time.Now())
+}
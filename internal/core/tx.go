@@ -51,6 +51,14 @@ type Transaction struct {
 	CreatedAt time.Time `bun:"type:timestamp without time zone,notnull" json:"created_at"`
 }
 
+func (tx *Transaction) BlockID() BlockID {
+	return BlockID{
+		Workchain: tx.Workchain,
+		Shard:     tx.Shard,
+		SeqNo:     tx.BlockSeqNo,
+	}
+}
+
 func (tx *Transaction) LoadDescription() error { // TODO: optionally load description in API
 	var d tlb.TransactionDescription
 
@@ -69,5 +77,8 @@ func (tx *Transaction) LoadDescription() error { // TODO: optionally load descri
 }
 
 type TransactionRepository interface {
+	// AddTransactions inserts rows in the PostgreSQL transaction only, they are written to ClickHouse with OutboxBatch.
 	AddTransactions(ctx context.Context, tx bun.Tx, transactions []*Transaction) error
+	// CopyTransactions writes rows with COPY FROM STDIN on the connection with an open PostgreSQL transaction.
+	CopyTransactions(ctx context.Context, conn bun.Conn, transactions []*Transaction) error
 }
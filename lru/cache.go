@@ -75,3 +75,13 @@ func (c *Cache[K, V]) Keys() (keys []K) {
 	}
 	return keys
 }
+
+func (c *Cache[K, V]) Remove(k K) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if item, ok := c.items[k]; ok {
+		c.queue.Remove(item.keyPtr)
+		delete(c.items, k)
+	}
+}
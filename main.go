@@ -12,9 +12,12 @@ import (
 	"github.com/tonindexer/anton/cmd/archive"
 	"github.com/tonindexer/anton/cmd/contract"
 	"github.com/tonindexer/anton/cmd/db"
+	"github.com/tonindexer/anton/cmd/emulate"
 	"github.com/tonindexer/anton/cmd/indexer"
+	"github.com/tonindexer/anton/cmd/inspect"
 	"github.com/tonindexer/anton/cmd/label"
 	"github.com/tonindexer/anton/cmd/rescan"
+	"github.com/tonindexer/anton/cmd/skip"
 	"github.com/tonindexer/anton/cmd/web"
 )
 
@@ -42,6 +45,9 @@ func main() {
 			contract.Command,
 			label.Command,
 			rescan.Command,
+			inspect.Command,
+			emulate.Command,
+			skip.Command,
 		},
 	}
 	if err := app.Run(os.Args); err != nil {
@@ -0,0 +1,289 @@
+// Package metrics implements counters, gauges and histograms
+// exposed in the Prometheus text format without external dependencies.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are histogram buckets for durations in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type kind string
+
+const (
+	counterKind   kind = "counter"
+	gaugeKind     kind = "gauge"
+	histogramKind kind = "histogram"
+)
+
+type series struct {
+	labelValues []string
+
+	value float64 // counter or gauge value
+
+	buckets []uint64 // histogram observations for each bucket
+	count   uint64
+	sum     float64
+}
+
+type metric struct {
+	name    string
+	help    string
+	kind    kind
+	labels  []string
+	buckets []float64
+
+	mx     sync.Mutex
+	series map[string]*series
+}
+
+func (m *metric) get(labelValues []string) *series {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Errorf("%s metric has %d labels, got %d values", m.name, len(m.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if m.kind == histogramKind {
+			s.buckets = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+func (m *metric) add(v float64, labelValues []string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.get(labelValues).value += v
+}
+
+func (m *metric) set(v float64, labelValues []string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.get(labelValues).value = v
+}
+
+func (m *metric) observe(v float64, labelValues []string) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	s := m.get(labelValues)
+	for i, b := range m.buckets {
+		if v <= b {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+type Registry struct {
+	mx      sync.Mutex
+	metrics map[string]*metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]*metric{}}
+}
+
+// DefaultRegistry is used by the package level constructors and Handler
+var DefaultRegistry = NewRegistry()
+
+func (r *Registry) register(m *metric) *metric {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if _, ok := r.metrics[m.name]; ok {
+		panic(fmt.Errorf("%s metric is already registered", m.name))
+	}
+	m.series = map[string]*series{}
+	r.metrics[m.name] = m
+
+	return m
+}
+
+type Counter struct{ m *metric }
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{m: r.register(&metric{name: name, help: help, kind: counterKind, labels: labels})}
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.m.add(1, labelValues)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Errorf("%s counter cannot decrease", c.m.name))
+	}
+	c.m.add(v, labelValues)
+}
+
+type Gauge struct{ m *metric }
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{m: r.register(&metric{name: name, help: help, kind: gaugeKind, labels: labels})}
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.set(v, labelValues)
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.m.add(v, labelValues)
+}
+
+type Histogram struct{ m *metric }
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{m: r.register(&metric{name: name, help: help, kind: histogramKind, labels: labels, buckets: buckets})}
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.m.observe(v, labelValues)
+}
+
+// ObserveSince observes seconds elapsed from the start, it is handy in defer statements.
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.m.observe(time.Since(start).Seconds(), labelValues)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	var pairs []string
+	for i := range names {
+		pairs = append(pairs, names[i]+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+labelEscaper.Replace(extraValue)+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (m *metric) write(w *bufio.Writer) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n", m.name, helpEscaper.Replace(m.help))
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := make([]string, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := m.series[k]
+
+		if m.kind != histogramKind {
+			_, _ = fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labels, s.labelValues, "", ""), formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, b := range m.buckets {
+			cumulative += s.buckets[i]
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(m.labels, s.labelValues, "le", formatFloat(b)), cumulative)
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(m.labels, s.labelValues, "le", "+Inf"), s.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", m.name, formatLabels(m.labels, s.labelValues, "", ""), formatFloat(s.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", m.name, formatLabels(m.labels, s.labelValues, "", ""), s.count)
+	}
+}
+
+// Write writes all registered metrics in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mx.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for n := range r.metrics {
+		names = append(names, n)
+	}
+	r.mx.Unlock()
+
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, n := range names {
+		r.mx.Lock()
+		m := r.metrics[n]
+		r.mx.Unlock()
+
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
+
+// Handler serves metrics of the default registry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// ListenAndServe serves metrics of the default registry at /metrics
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
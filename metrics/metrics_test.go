@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounter("test_requests_total", "Number of requests.", "route")
+	g := r.NewGauge("test_lag_seconds", "Lag behind the tip.")
+	h := r.NewHistogram("test_duration_seconds", "Request duration.", []float64{1, 0.1}, "route")
+
+	c.Inc("/a")
+	c.Add(2, "/a")
+	c.Inc(`/b"\`)
+	g.Set(5)
+	g.Add(-1.5)
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(3, "/a")
+
+	var buf bytes.Buffer
+	require.Nil(t, r.Write(&buf))
+
+	require.Equal(t, `# HELP test_duration_seconds Request duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 1
+test_duration_seconds_bucket{route="/a",le="1"} 2
+test_duration_seconds_bucket{route="/a",le="+Inf"} 3
+test_duration_seconds_sum{route="/a"} 3.55
+test_duration_seconds_count{route="/a"} 3
+# HELP test_lag_seconds Lag behind the tip.
+# TYPE test_lag_seconds gauge
+test_lag_seconds 3.5
+# HELP test_requests_total Number of requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/a"} 3
+test_requests_total{route="/b\"\\"} 1
+`, buf.String())
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounter("test_total", "Test.", "label")
+
+	require.Panics(t, func() { r.NewGauge("test_total", "Duplicate.") })
+	require.Panics(t, func() { c.Inc() })
+	require.Panics(t, func() { c.Add(-1, "x") })
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), "test_total 1\n")
+}
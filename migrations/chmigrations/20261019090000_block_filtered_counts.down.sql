@@ -0,0 +1,9 @@
+ALTER TABLE block_info DROP COLUMN filtered_transactions_count;
+
+--migration:split
+
+ALTER TABLE block_info DROP COLUMN filtered_messages_count;
+
+--migration:split
+
+ALTER TABLE block_info DROP COLUMN filtered_accounts_count;
@@ -0,0 +1,9 @@
+ALTER TABLE block_info ADD COLUMN filtered_transactions_count Int64;
+
+--migration:split
+
+ALTER TABLE block_info ADD COLUMN filtered_messages_count Int64;
+
+--migration:split
+
+ALTER TABLE block_info ADD COLUMN filtered_accounts_count Int64;
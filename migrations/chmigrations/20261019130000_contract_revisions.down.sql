@@ -0,0 +1,5 @@
+ALTER TABLE account_states DROP COLUMN interface_versions;
+
+--migration:split
+
+ALTER TABLE messages DROP COLUMN schema_version;
@@ -0,0 +1,5 @@
+ALTER TABLE messages ADD COLUMN schema_version UInt32;
+
+--migration:split
+
+ALTER TABLE account_states ADD COLUMN interface_versions String;
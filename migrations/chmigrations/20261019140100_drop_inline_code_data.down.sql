@@ -0,0 +1 @@
+ALTER TABLE account_states ADD COLUMN code String AFTER state_hash, ADD COLUMN data String AFTER code_hash;
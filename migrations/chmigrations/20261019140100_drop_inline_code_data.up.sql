@@ -0,0 +1,18 @@
+-- inline code and data must be moved to the key-value storage with `migrate transferCodeData` before dropping the columns
+SELECT throwIf(countIf(kv.code_hash = '') > 0, 'account_states have code missing in account_states_code, run "anton migrate transferCodeData" first')
+FROM account_states AS s
+LEFT JOIN account_states_code AS kv ON s.code_hash = kv.code_hash
+WHERE length(s.code) > 0
+SETTINGS join_algorithm = 'direct';
+
+--migration:split
+
+SELECT throwIf(countIf(kv.data_hash = '') > 0, 'account_states have data missing in account_states_data, run "anton migrate transferCodeData" first')
+FROM account_states AS s
+LEFT JOIN account_states_data AS kv ON s.data_hash = kv.data_hash
+WHERE length(s.data) > 0
+SETTINGS join_algorithm = 'direct';
+
+--migration:split
+
+ALTER TABLE account_states DROP COLUMN code, DROP COLUMN data;
@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE block_info DROP COLUMN filtered_transactions_count;
+
+--bun:split
+
+ALTER TABLE block_info DROP COLUMN filtered_messages_count;
+
+--bun:split
+
+ALTER TABLE block_info DROP COLUMN filtered_accounts_count;
@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE block_info ADD COLUMN filtered_transactions_count integer NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE block_info ADD COLUMN filtered_messages_count integer NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE block_info ADD COLUMN filtered_accounts_count integer NOT NULL DEFAULT 0;
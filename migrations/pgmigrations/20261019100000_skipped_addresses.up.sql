@@ -0,0 +1,27 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE skipped_addresses (
+    address bytea NOT NULL,
+    scopes text[] NOT NULL,
+    reason text NOT NULL,
+    expires_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    CONSTRAINT skipped_addresses_pkey PRIMARY KEY (address)
+);
+
+--bun:split
+
+INSERT INTO skipped_addresses (address, scopes, reason, created_at) VALUES
+    ('\x000000000000000000000000000000000000000000000000000000000000000000', '{fetch}', 'burn address', now()),
+    ('\xff0000000000000000000000000000000000000000000000000000000000000000', '{fetch}', 'system contract', now()),
+    ('\xff3333333333333333333333333333333333333333333333333333333333333333', '{fetch}', 'elector contract', now()),
+    ('\xff34517c7bdf5187c55af4f8b61fdc321588c7ab768dee24b006df29106458d7cf', '{fetch}', 'log tests contract', now()),
+    ('\xff5555555555555555555555555555555555555555555555555555555555555555', '{fetch}', 'config contract', now()),
+    ('\x0007235bc6bb0edde161be0ad7c3ad6a843cdb6652e2a32851057899996aaa9f59', '{fetch}', 'BSC Bridge Collector', now()),
+    ('\x00aecef20e5cb8c7dadbf7e60638b73848bd70aa6560ee2bd91484519728b22683', '{fetch}', 'ETH Bridge Collector', now()),
+    ('\x0036bb9679167e7d114bd323e4c8ad7f0f206290ccd8f7a8b8b17423cfba37c995', '{fetch}', 'strange heavy testnet address', now()),
+    ('\x00363e7c69d2b301f4be92536cf556a7cc20521fbad8938d05851179f06bfccf87', '{fetch}', 'strange heavy testnet address', now()),
+    ('\x00e1225a03bb515663d585020403830d118ee4f96cb91fd794667792c21b7fb487', '{fetch}', 'strange heavy testnet address', now()),
+    ('\x0016048c6b7d00c4c4949f16613950bd6bf5daec7b4750c7f79a57cc3d4bbed52b', '{fetch,emulation}', 'tongo emulator segmentation fault', now());
@@ -0,0 +1,29 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN paused;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN canceled;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN priority;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN processed;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN total;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN errors_count;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN last_error;
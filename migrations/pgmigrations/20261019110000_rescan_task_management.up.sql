@@ -0,0 +1,29 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN paused boolean NOT NULL DEFAULT false;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN canceled boolean NOT NULL DEFAULT false;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN priority integer NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN processed bigint NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN total bigint NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN errors_count integer NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN last_error text;
@@ -0,0 +1,35 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DELETE FROM rescan_tasks WHERE type IN ('rescan_accounts', 'rescan_messages');
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN last_hash;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN message_hashes;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN to_time;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN from_time;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN to_master_seq_no;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN from_master_seq_no;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN addresses;
+
+-- enum values cannot be dropped, rescan_accounts and rescan_messages are left in rescan_task_type
@@ -0,0 +1,37 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TYPE rescan_task_type ADD VALUE IF NOT EXISTS 'rescan_accounts';
+
+--bun:split
+
+ALTER TYPE rescan_task_type ADD VALUE IF NOT EXISTS 'rescan_messages';
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN addresses bytea[];
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN from_master_seq_no integer;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN to_master_seq_no integer;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN from_time timestamp without time zone;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN to_time timestamp without time zone;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN message_hashes bytea[];
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN last_hash bytea;
@@ -0,0 +1,25 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE account_states DROP COLUMN interface_versions;
+
+--bun:split
+
+ALTER TABLE messages DROP COLUMN schema_version;
+
+--bun:split
+
+ALTER TABLE contract_operations DROP COLUMN version;
+
+--bun:split
+
+ALTER TABLE contract_interfaces DROP COLUMN version;
+
+--bun:split
+
+DROP TABLE contract_revisions;
+
+--bun:split
+
+DROP TYPE contract_revision_action;
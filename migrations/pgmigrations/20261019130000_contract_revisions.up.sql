@@ -0,0 +1,41 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TYPE contract_revision_action AS ENUM (
+    'add',
+    'update',
+    'delete',
+    'rollback'
+);
+
+--bun:split
+
+CREATE TABLE contract_revisions (
+    contract_name character varying NOT NULL,
+    version bigint NOT NULL,
+    action contract_revision_action NOT NULL,
+    author character varying,
+    comment character varying,
+    diff text[],
+    interface jsonb,
+    definitions jsonb,
+    created_at timestamp without time zone NOT NULL,
+    CONSTRAINT contract_revisions_pkey PRIMARY KEY (contract_name, version)
+);
+
+--bun:split
+
+ALTER TABLE contract_interfaces ADD COLUMN version bigint NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE contract_operations ADD COLUMN version bigint NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE messages ADD COLUMN schema_version bigint;
+
+--bun:split
+
+ALTER TABLE account_states ADD COLUMN interface_versions jsonb;
@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP TABLE account_states_data;
+
+--bun:split
+
+DROP TABLE account_states_code;
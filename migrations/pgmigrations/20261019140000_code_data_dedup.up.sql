@@ -0,0 +1,17 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE account_states_code (
+    code_hash bytea NOT NULL,
+    code bytea NOT NULL,
+    CONSTRAINT account_states_code_pkey PRIMARY KEY (code_hash)
+);
+
+--bun:split
+
+CREATE TABLE account_states_data (
+    data_hash bytea NOT NULL,
+    data bytea NOT NULL,
+    CONSTRAINT account_states_data_pkey PRIMARY KEY (data_hash)
+);
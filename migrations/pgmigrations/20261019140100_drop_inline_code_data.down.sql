@@ -0,0 +1,5 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE account_states ADD COLUMN code bytea, ADD COLUMN data bytea;
@@ -0,0 +1,16 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+-- inline code and data must be moved to the key-value storage with `migrate transferCodeData`
+-- and cleared with `migrate clearCodeData` before dropping the columns
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM account_states WHERE code IS NOT NULL OR data IS NOT NULL LIMIT 1) THEN
+        RAISE EXCEPTION 'account_states still have inline code or data, run "anton migrate transferCodeData" and "anton migrate clearCodeData" first';
+    END IF;
+END $$;
+
+--bun:split
+
+ALTER TABLE account_states DROP COLUMN code, DROP COLUMN data;
@@ -0,0 +1,11 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE clickhouse_outbox (
+    workchain integer NOT NULL,
+    shard bigint NOT NULL,
+    seq_no integer NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    CONSTRAINT clickhouse_outbox_pkey PRIMARY KEY (workchain, shard, seq_no)
+);
@@ -0,0 +1,10 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE clickhouse_verify_checkpoints (
+    name text NOT NULL,
+    master_seq_no integer NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    CONSTRAINT clickhouse_verify_checkpoints_pkey PRIMARY KEY (name)
+);
@@ -0,0 +1,11 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE prunings (
+    rule text NOT NULL,
+    keep_latest bigint,
+    before timestamp without time zone NOT NULL,
+    pruned_at timestamp without time zone NOT NULL,
+    CONSTRAINT prunings_pkey PRIMARY KEY (rule)
+);
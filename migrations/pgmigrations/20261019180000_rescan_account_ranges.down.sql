@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN parent_id;
+
+--bun:split
+
+ALTER TABLE rescan_tasks DROP COLUMN account_ranges;
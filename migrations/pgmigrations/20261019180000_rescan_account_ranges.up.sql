@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN account_ranges jsonb;
+
+--bun:split
+
+ALTER TABLE rescan_tasks ADD COLUMN parent_id integer;
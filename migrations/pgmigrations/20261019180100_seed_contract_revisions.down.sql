@@ -0,0 +1,17 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+UPDATE contract_operations SET version = 0
+WHERE version = 1 AND contract_name IN (SELECT contract_name FROM contract_revisions WHERE version = 1 AND author = 'migration');
+
+--bun:split
+
+UPDATE contract_interfaces SET version = 0
+WHERE version = 1 AND name IN (SELECT contract_name FROM contract_revisions WHERE version = 1 AND author = 'migration');
+
+--bun:split
+
+DELETE FROM contract_revisions AS r
+WHERE r.version = 1 AND r.author = 'migration'
+    AND NOT EXISTS (SELECT 1 FROM contract_revisions AS n WHERE n.contract_name = r.contract_name AND n.version > 1);
@@ -0,0 +1,43 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+-- the first revision of interfaces added before revisions were recorded,
+-- the interface is stored in the same json format as core.ContractInterface
+INSERT INTO contract_revisions (contract_name, version, action, author, comment, interface, created_at)
+SELECT i.name, 1, 'add', 'migration', 'interface existing before contract revisions', jsonb_strip_nulls(jsonb_build_object(
+    'name', i.name,
+    'addresses', (
+        SELECT jsonb_agg(
+            (CASE WHEN get_byte(a, 0) > 127 THEN get_byte(a, 0) - 256 ELSE get_byte(a, 0) END)::text
+            || ':' || encode(substring(a FROM 2 FOR 32), 'hex'))
+        FROM unnest(i.addresses) AS a
+    ),
+    'code', replace(encode(i.code, 'base64'), E'\n', ''),
+    'get_methods_descriptors', nullif(i.get_methods_desc, '')::jsonb,
+    'get_method_hashes', to_jsonb(i.get_method_hashes),
+    'version', 1,
+    'operations', (
+        SELECT jsonb_agg(jsonb_build_object(
+            'operation_name', o.operation_name,
+            'contract_name', o.contract_name,
+            'message_type', o.message_type,
+            'outgoing', o.outgoing,
+            'operation_id', o.operation_id,
+            'schema', o.schema,
+            'version', 1
+        ) ORDER BY o.outgoing, o.operation_id)
+        FROM contract_operations AS o
+        WHERE o.contract_name = i.name
+    )
+)), now() AT TIME ZONE 'UTC'
+FROM contract_interfaces AS i
+WHERE NOT EXISTS (SELECT 1 FROM contract_revisions AS r WHERE r.contract_name = i.name);
+
+--bun:split
+
+UPDATE contract_interfaces SET version = 1 WHERE version = 0;
+
+--bun:split
+
+UPDATE contract_operations SET version = 1 WHERE version = 0;
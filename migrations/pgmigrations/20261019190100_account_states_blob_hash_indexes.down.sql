@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP INDEX account_states_data_hash_idx;
+
+--bun:split
+
+DROP INDEX account_states_code_hash_idx;
@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS account_states_code_hash_idx ON account_states USING hash (code_hash) WHERE (code_hash IS NOT NULL);
+
+--bun:split
+
+CREATE INDEX CONCURRENTLY IF NOT EXISTS account_states_data_hash_idx ON account_states USING hash (data_hash) WHERE (data_hash IS NOT NULL);